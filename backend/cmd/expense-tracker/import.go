@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+)
+
+const importUsage = `usage: expense-tracker import csv <profile> <file.csv> --user EMAIL [--ledger ID] [--dry-run]
+       expense-tracker import ofx|qfx|qif <file> --user EMAIL [--ledger ID] [--account ID] [--category ID] [--currency CODE] [--date-format FORMAT] [--dry-run]`
+
+// runImport handles the "import" subcommand, which imports a bank statement
+// into a ledger of the user given by --user: the one given by --ledger, or
+// else their personal ledger. CSV files are read with a saved import
+// profile of that ledger, given by name or ID; OFX, QFX and QIF files go to the
+// account and category given by flags. With --dry-run it only reports what
+// it would create. Transactions imported before are skipped, every failing
+// line is logged, and nothing is imported if any line fails.
+func runImport(backend store.Backend, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	email := flags.String("user", "", "")
+	ledgerID := flags.Int64("ledger", 0, "")
+	dryRun := flags.Bool("dry-run", false, "")
+	accountID := flags.Int64("account", 0, "")
+	categoryID := flags.Int64("category", 1, "")
+	currency := flags.String("currency", "", "")
+	dateFormat := flags.String("date-format", "", "")
+
+	// Allow flags before, between and after the positional arguments
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return errors.New(importUsage)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if *email == "" {
+		return errors.New(importUsage)
+	}
+	user, err := backend.Users().GetUserByEmail(*email)
+	if err != nil {
+		return err
+	}
+	ledger, err := importLedger(backend.Ledgers(), user, *ledgerID)
+	if err != nil {
+		return err
+	}
+	importStore := backend.Stores(ledger.ID).Imports
+
+	var statement models.Statement
+	var path string
+	switch {
+	case len(positional) == 3 && positional[0] == "csv":
+		profile, err := importStore.GetImportProfileByName(positional[1])
+		if errors.Is(err, models.ErrNotFound) {
+			if id, parseErr := strconv.ParseInt(positional[1], 10, 64); parseErr == nil {
+				profile, err = importStore.GetImportProfileByID(id)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		path = positional[2]
+		if statement, err = parseStatementFile(path, func(r io.Reader) (models.Statement, error) {
+			return models.ParseStatementCSV(r, profile)
+		}); err != nil {
+			return err
+		}
+	case len(positional) == 2 && (positional[0] == "ofx" || positional[0] == "qfx" || positional[0] == "qif"):
+		path = positional[1]
+		var err error
+		statement, err = parseStatementFile(path, func(r io.Reader) (models.Statement, error) {
+			if positional[0] == "qif" {
+				return models.ParseStatementQIF(r, *dateFormat)
+			}
+			return models.ParseStatementOFX(r)
+		})
+		if err != nil {
+			return err
+		}
+		statement.CategoryID = *categoryID
+		if *accountID != 0 {
+			statement.AccountID = accountID
+		}
+		for i := range statement.Rows {
+			if statement.Rows[i].Currency == "" {
+				statement.Rows[i].Currency = *currency
+			}
+		}
+	default:
+		return errors.New(importUsage)
+	}
+
+	report, err := importStore.ImportStatement(statement, *dryRun)
+	for _, row := range report.Rows {
+		if row.Error != "" {
+			log.Printf("%s:%d: %s", path, row.Line, row.Error)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: would import %d expenses and %d incomes from %s, skipping %d imported before",
+			report.Expenses, report.Incomes, path, report.Duplicates)
+		return nil
+	}
+	log.Printf("Imported %d expenses and %d incomes from %s, skipped %d imported before",
+		report.Expenses, report.Incomes, path, report.Duplicates)
+	return nil
+}
+
+// parseStatementFile opens a statement file and parses it with parse.
+func parseStatementFile(path string, parse func(r io.Reader) (models.Statement, error)) (models.Statement, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return models.Statement{}, err
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+// importLedger returns the ledger of user with the given id, or their
+// personal ledger when id is 0. The user must be able to change it.
+func importLedger(ledgers store.LedgerStore, user models.User, id int64) (models.Ledger, error) {
+	var ledger models.Ledger
+	if id != 0 {
+		var err error
+		if ledger, err = ledgers.GetUserLedger(id, user.ID); err != nil {
+			return models.Ledger{}, err
+		}
+	} else {
+		userLedgers, err := ledgers.GetUserLedgers(user.ID)
+		if err != nil {
+			return models.Ledger{}, err
+		}
+		if len(userLedgers) == 0 {
+			return models.Ledger{}, fmt.Errorf("%s is not a member of any ledger", user.Email)
+		}
+		ledger = userLedgers[0]
+	}
+	if !ledger.Role.CanWrite() {
+		return models.Ledger{}, fmt.Errorf("%s can only view ledger %d", user.Email, ledger.ID)
+	}
+	return ledger, nil
+}
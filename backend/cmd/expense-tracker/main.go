@@ -1,38 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 
 	"expense-tracker/internal/api"
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/migrations"
+	"expense-tracker/internal/scheduler"
+	"expense-tracker/internal/store"
 )
 
 func main() {
-	// Load environment variables from .env file
+	// Load environment variables from .env file, if there is one
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Get database URL from environment variable
-	dbUrl := os.Getenv("DATABASE_PUBLIC_URL")
-	if dbUrl == "" {
-		log.Fatal("DATABASE_PUBLIC_URL is not set")
-	}
-
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // Default port if not specified
 	}
 
-	// Open connection to the database
-	db, err := sql.Open("postgres", dbUrl)
+	// Open connection to the database selected by DB_DRIVER
+	db, dialect, err := openDatabase()
 	if err != nil {
 		log.Fatal("Error opening database:", err)
 	}
@@ -46,14 +51,56 @@ func main() {
 		log.Fatal("Error pinging database:", err)
 	}
 
-	// Initialize database (only run once)
-	err = initializeDatabase(db)
+	// Handle the migrate subcommand without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, dialect, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Bring the schema up to date before serving requests
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		log.Fatal("Error migrating database:", err)
+	}
+	if err := migrateUp(migrator); err != nil {
+		log.Fatal("Error migrating database:", err)
+	}
+
+	// Open the blob store that keeps attachment content
+	blobs, err := openBlobStore()
+	if err != nil {
+		log.Fatal("Error opening blob store:", err)
+	}
+
+	// Initialize router with the database-backed stores
+	backend := store.NewSQL(db, blobs)
+
+	// Handle the rates subcommand without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "rates" {
+		if err := runRates(backend, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Handle the import subcommand without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(backend, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Generate recurring expenses and incomes in the background
+	interval, err := recurringInterval()
 	if err != nil {
-		log.Fatal("Error initializing database:", err)
+		log.Fatal(err)
 	}
+	go scheduler.RunRecurring(context.Background(), backend, interval)
 
-	// Initialize router with database connection
-	router := api.NewRouter(db)
+	router := api.NewRouter(backend)
 
 	// Start the HTTP server
 	log.Printf("Server is running on port %s", port)
@@ -62,63 +109,72 @@ func main() {
 	}
 }
 
-func initializeDatabase(db *sql.DB) error {
-	schema := `
-    -- Table: Category
-    CREATE TABLE IF NOT EXISTS Category (
-        id SERIAL PRIMARY KEY,
-        name VARCHAR(255) NOT NULL UNIQUE,
-        description TEXT
-    );
-
-    -- Table: Budget
-    CREATE TABLE IF NOT EXISTS Budget (
-        id SERIAL PRIMARY KEY,
-        category_id INT REFERENCES Category(id) ON DELETE CASCADE,
-        amount NUMERIC(10, 2) NOT NULL
-    );
-
-    -- Table: Expense
-    CREATE TABLE IF NOT EXISTS Expense (
-        id SERIAL PRIMARY KEY,
-        category_id INT REFERENCES Category(id) ON DELETE SET NULL,
-        description VARCHAR(255) NOT NULL,
-        amount NUMERIC(10, 2) NOT NULL,
-        date DATE NOT NULL
-    );
-
-    -- Table: Income
-    CREATE TABLE IF NOT EXISTS Income (
-        id SERIAL PRIMARY KEY,
-        amount NUMERIC(10, 2) NOT NULL,
-        date DATE NOT NULL,
-        source VARCHAR(255) NOT NULL
-    );
-
-    -- Table: Report
-    CREATE TABLE IF NOT EXISTS Report (
-        id SERIAL PRIMARY KEY,
-        expense_id INT REFERENCES Expense(id) ON DELETE CASCADE,
-        income_id INT REFERENCES Income(id) ON DELETE CASCADE
-    );`
-
-	// Execute the schema
-	_, err := db.Exec(schema)
-	if err != nil {
-		log.Fatal("failed to initialize database schema: %w", err)
+// openDatabase opens the database configured by the environment and returns
+// it together with its migration dialect. DB_DRIVER selects "postgres" (the
+// default, using DATABASE_PUBLIC_URL) or "sqlite" (using SQLITE_PATH).
+func openDatabase() (*sql.DB, string, error) {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "postgres":
+		dbUrl := os.Getenv("DATABASE_PUBLIC_URL")
+		if dbUrl == "" {
+			return nil, "", errors.New("DATABASE_PUBLIC_URL is not set")
+		}
+		db, err := sql.Open("postgres", dbUrl)
+		return db, "postgres", err
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "expense-tracker.db" // Default file if not specified
+		}
+		db, err := sql.Open("sqlite", sqliteDSN(path))
+		if err != nil {
+			return nil, "", err
+		}
+		// SQLite allows a single writer, so serialize access through one connection
+		db.SetMaxOpenConns(1)
+		return db, "sqlite", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported DB_DRIVER %q (expected postgres or sqlite)", driver)
 	}
+}
 
-	// Ensure the "Other" category exists
-	query := `
-	 INSERT INTO Category (id, name, description)
-	 VALUES (1, 'Other', 'Default category for uncategorized items')
-	 ON CONFLICT (id) DO NOTHING;
-	 `
-	_, err = db.Exec(query)
+// openBlobStore opens the blob store configured by the environment.
+// BLOB_DRIVER selects "local" (the default, keeping files under
+// ATTACHMENTS_PATH) or "memory", which loses attachments on restart.
+func openBlobStore() (blob.Store, error) {
+	switch driver := os.Getenv("BLOB_DRIVER"); driver {
+	case "", "local":
+		path := os.Getenv("ATTACHMENTS_PATH")
+		if path == "" {
+			path = "attachments" // Default directory if not specified
+		}
+		return blob.NewLocal(path)
+	case "memory":
+		return blob.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unsupported BLOB_DRIVER %q (expected local or memory)", driver)
+	}
+}
 
-	if err != nil {
-		log.Fatal("failed to ensure default 'Other' category: %w", err)
+// recurringInterval reads how often recurring rules are materialized from
+// RECURRING_INTERVAL (a duration such as "15m"), defaulting to one hour.
+func recurringInterval() (time.Duration, error) {
+	value := os.Getenv("RECURRING_INTERVAL")
+	if value == "" {
+		return time.Hour, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid RECURRING_INTERVAL %q (expected a positive duration such as 15m)", value)
 	}
+	return interval, nil
+}
 
-	return err
+// sqliteDSN enables foreign keys, so deletes cascade as they do in Postgres,
+// and waits on a locked database instead of failing immediately.
+func sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	return "file:" + path + "?" + query.Encode()
 }
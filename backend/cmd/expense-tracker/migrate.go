@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"expense-tracker/internal/migrations"
+	"expense-tracker/internal/models"
+)
+
+const migrateUsage = "usage: expense-tracker migrate up|down|status|adopt <email>"
+
+// runMigrate handles the "migrate" subcommand.
+func runMigrate(db *sql.DB, dialect string, args []string) error {
+	if len(args) == 2 && args[0] == "adopt" {
+		return migrateAdopt(db, args[1])
+	}
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrateUp(migrator)
+	case "down":
+		migration, reverted, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if !reverted {
+			log.Println("No migrations to revert")
+			return nil
+		}
+		log.Printf("Reverted migration %04d_%s", migration.Version, migration.Name)
+		return nil
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// migrateUp applies all pending migrations.
+func migrateUp(migrator *migrations.Migrator) error {
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
+
+// migrateAdopt gives what was recorded before there were users to the user
+// with an email.
+func migrateAdopt(db *sql.DB, email string) error {
+	adopted, err := models.AdoptUnownedData(db, email)
+	if err != nil {
+		return err
+	}
+	log.Printf("Gave %d rows recorded before there were users to %s", adopted, email)
+	return nil
+}
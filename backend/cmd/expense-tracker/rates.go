@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+)
+
+const ratesUsage = "usage: expense-tracker rates import <file.csv|file.json>"
+
+// runRates handles the "rates" subcommand, which loads exchange rates from a
+// file. The format is taken from the file extension.
+func runRates(rateStore store.ExchangeRateStore, args []string) error {
+	if len(args) != 2 || args[0] != "import" {
+		return errors.New(ratesUsage)
+	}
+
+	path := args[1]
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rates, err := models.ParseExchangeRates(file, format)
+	if err != nil {
+		return err
+	}
+	imported, err := rateStore.ImportExchangeRates(rates)
+	if err != nil {
+		return err
+	}
+	log.Printf("Imported %d exchange rates from %s", imported, path)
+	return nil
+}
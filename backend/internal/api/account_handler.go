@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultBalancePeriods is how many periods a balance history covers when
+// the request gives no start date.
+const defaultBalancePeriods = 12
+
+func getAccountsHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r, models.AccountSortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		accounts, total, err := accountStore.ListAccounts(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeList(w, accounts, total)
+	}
+}
+
+func getAccountByIDHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid account ID"))
+			return
+		}
+
+		account, err := accountStore.GetAccountByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(account)
+	}
+}
+
+func createAccountHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var account models.Account
+		if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdAccount, err := accountStore.CreateAccount(account)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdAccount)
+	}
+}
+
+func updateAccountHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid account ID"))
+			return
+		}
+
+		var account models.Account
+		if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		account.ID = id
+
+		updatedAccount, err := accountStore.UpdateAccount(account)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedAccount)
+	}
+}
+
+func deleteAccountHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid account ID"))
+			return
+		}
+
+		if err := accountStore.DeleteAccount(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getAccountBalancesHandler returns an account's balance at the end of each
+// day, week or month (interval, default month) from from to to. to defaults
+// to today and from to the last twelve periods.
+func getAccountBalancesHandler(accountStore store.AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid account ID"))
+			return
+		}
+
+		interval := models.BalanceInterval(r.URL.Query().Get("interval"))
+		if interval == "" {
+			interval = models.IntervalMonth
+		}
+		from, err := dateParam(r, "from")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now().UTC()
+		}
+		if from.IsZero() {
+			switch interval {
+			case models.IntervalDay:
+				from = to.AddDate(0, 0, 1-defaultBalancePeriods)
+			case models.IntervalWeek:
+				from = to.AddDate(0, 0, 7*(1-defaultBalancePeriods))
+			default:
+				from = to.AddDate(0, 1-defaultBalancePeriods, 0)
+			}
+		}
+
+		balances, err := accountStore.GetAccountBalances(id, from, to, interval)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(balances)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getAPITokensHandler(tokenStore store.TokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := tokenStore.GetAPITokens(currentUser(r).ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if tokens == nil {
+			tokens = []models.APIToken{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	}
+}
+
+// createAPITokenHandler creates an API token for the signed-in user. The
+// token itself is only returned here.
+func createAPITokenHandler(tokenStore store.TokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token models.APIToken
+		if err := json.NewDecoder(r.Body).Decode(&token); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdToken, err := tokenStore.CreateAPIToken(currentUser(r).ID, token)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdToken)
+	}
+}
+
+func revokeAPITokenHandler(tokenStore store.TokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid API token ID"))
+			return
+		}
+
+		if err := tokenStore.RevokeAPIToken(currentUser(r).ID, id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
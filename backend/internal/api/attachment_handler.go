@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// attachmentIDs parses the expense and attachment ids of an attachment route.
+func attachmentIDs(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	expenseID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(r.PathValue("attachment"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, models.NewValidationError("attachment", "Invalid attachment ID"))
+		return 0, 0, false
+	}
+	return expenseID, id, true
+}
+
+func getAttachmentsHandler(attachmentStore store.AttachmentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expenseID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
+			return
+		}
+
+		attachments, err := attachmentStore.GetAttachments(expenseID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if attachments == nil {
+			attachments = []models.Attachment{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attachments)
+	}
+}
+
+// createAttachmentHandler attaches the multipart/form-data field "file" to
+// the expense {id}. The file's content type is taken from its part, or
+// sniffed from its first bytes when the client sent none.
+func createAttachmentHandler(attachmentStore store.AttachmentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expenseID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
+			return
+		}
+
+		// Leave room for the multipart headers around the file
+		r.Body = http.MaxBytesReader(w, r.Body, models.MaxAttachmentSize+1<<20)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			writeProblem(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Send the file as multipart/form-data in a field named file", nil)
+			return
+		}
+		var part *multipart.Part
+		for part == nil {
+			next, err := reader.NextPart()
+			if err == io.EOF {
+				writeError(w, models.NewValidationError("file", "file must be provided"))
+				return
+			}
+			if err != nil {
+				writeUploadError(w, err)
+				return
+			}
+			if next.FormName() == "file" {
+				part = next
+			}
+		}
+
+		content := bufio.NewReader(part)
+		contentType := part.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			head, _ := content.Peek(512)
+			contentType = http.DetectContentType(head)
+		}
+
+		attachment, err := attachmentStore.CreateAttachment(models.Attachment{
+			ExpenseID:   expenseID,
+			FileName:    part.FileName(),
+			ContentType: contentType,
+		}, content)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(attachment)
+	}
+}
+
+// writeUploadError reports an error reading or storing an upload, treating
+// a body over the size limit as a file that is too large.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, models.NewValidationError("file", "file is too large (max %d MiB)", models.MaxAttachmentSize>>20))
+		return
+	}
+	writeError(w, err)
+}
+
+// downloadAttachmentHandler sends the content of an attachment as a file
+// download, with the content type it was uploaded with.
+func downloadAttachmentHandler(attachmentStore store.AttachmentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expenseID, id, ok := attachmentIDs(w, r)
+		if !ok {
+			return
+		}
+
+		attachment, content, err := attachmentStore.OpenAttachment(expenseID, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+		w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		io.Copy(w, content)
+	}
+}
+
+func deleteAttachmentHandler(attachmentStore store.AttachmentStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expenseID, id, ok := attachmentIDs(w, r)
+		if !ok {
+			return
+		}
+
+		if err := attachmentStore.DeleteAttachment(expenseID, id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strings"
+)
+
+type userKey struct{}
+
+type apiTokenKey struct{}
+
+// requireUser authenticates the session token of a request and passes the
+// signed-in user on in its context. Requests without a valid session are
+// rejected before reaching next; API tokens are refused.
+func requireUser(users store.UserStore, next http.Handler) http.Handler {
+	return requireUserOrAPIToken(users, nil, next)
+}
+
+// requireUserOrAPIToken is requireUser that also accepts the API tokens of
+// tokens, passing the token on in the context next to its user. Whoever
+// serves the request checks the scopes of the token.
+func requireUserOrAPIToken(users store.UserStore, tokens store.TokenStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			writeUnauthorized(w, models.NewUnauthorizedError("missing bearer token"))
+			return
+		}
+
+		ctx := r.Context()
+		var user models.User
+		var err error
+		if strings.HasPrefix(token, models.APITokenPrefix) {
+			if tokens == nil {
+				writeError(w, models.NewForbiddenError("API tokens cannot be used here, sign in instead"))
+				return
+			}
+			var apiToken models.APIToken
+			user, apiToken, err = tokens.AuthenticateAPIToken(token)
+			ctx = context.WithValue(ctx, apiTokenKey{}, apiToken)
+		} else {
+			user, err = users.Authenticate(token)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, userKey{}, user)))
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// currentUser returns the user authenticated by requireUser.
+func currentUser(r *http.Request) models.User {
+	user, _ := r.Context().Value(userKey{}).(models.User)
+	return user
+}
+
+// currentAPIToken returns the API token a request was authenticated with,
+// if it was not sent by a signed-in session.
+func currentAPIToken(r *http.Request) (models.APIToken, bool) {
+	token, ok := r.Context().Value(apiTokenKey{}).(models.APIToken)
+	return token, ok
+}
+
+// scopeResources maps the first segment of each data route to the resource
+// of the scopes that cover it.
+var scopeResources = map[string]string{
+	"expenses":        "expenses",
+	"incomes":         "incomes",
+	"categories":      "categories",
+	"category-rules":  "categories",
+	"budgets":         "budgets",
+	"accounts":        "accounts",
+	"transfers":       "transfers",
+	"import-profiles": "imports",
+	"imports":         "imports",
+	"recurring-rules": "recurring",
+	"tags":            "tags",
+	"exchange-rates":  "rates",
+	"transactions":    "reports",
+	"summary":         "reports",
+	"export":          "reports",
+}
+
+// checkScope reports whether an API token may make a request to a data
+// route: reads need a read or write scope of the route's resource, anything
+// else a write scope. Routes without a resource are refused.
+func checkScope(token models.APIToken, method, path string) error {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	resource, ok := scopeResources[segment]
+	if !ok {
+		return models.NewForbiddenError("API tokens cannot use %s", path)
+	}
+	write := method != http.MethodGet && method != http.MethodHead
+	if !token.HasScope(resource, write) {
+		access := "read"
+		if write {
+			access = "write"
+		}
+		return models.NewForbiddenError("API token lacks the %s:%s scope", resource, access)
+	}
+	return nil
+}
+
+// writeUnauthorized reports a request that is not signed in, asking for a
+// bearer token.
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeProblem(w, http.StatusUnauthorized, codeUnauthorized, err.Error(), nil)
+}
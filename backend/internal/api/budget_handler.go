@@ -3,43 +3,42 @@ package api
 import (
 	"encoding/json"
 	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
 	"net/http"
 	"strconv"
-
-	"database/sql"
-	"fmt"
 )
 
 // Get all budgets
-func getBudgetsHandler(db *sql.DB) http.HandlerFunc {
+func getBudgetsHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		budgets, err := models.GetBudgets(db)
+		opts, err := listOptions(r, models.BudgetSortFields)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(budgets)
+		budgets, total, err := budgetStore.ListBudgets(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeList(w, budgets, total)
 	}
 }
 
 // Get budget by ID
-func getBudgetByIDHandler(db *sql.DB) http.HandlerFunc {
+func getBudgetByIDHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid Budget ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid Budget ID"))
 			return
 		}
-		budget, err := models.GetBudgetByID(db, id)
+		budget, err := budgetStore.GetBudgetByID(id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Budget not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
 			return
 		}
 
@@ -49,20 +48,16 @@ func getBudgetByIDHandler(db *sql.DB) http.HandlerFunc {
 }
 
 // Get a budget by category
-func getBudgetByCategoryHandler(db *sql.DB) http.HandlerFunc {
+func getBudgetByCategoryHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		category, err := strconv.ParseInt(r.PathValue("category"), 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category ID"))
 			return
 		}
-		budget, err := models.GetBudgetsByCategoryID(db, category)
+		budget, err := budgetStore.GetBudgetsByCategoryID(category)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Budget not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
 			return
 		}
 
@@ -72,18 +67,18 @@ func getBudgetByCategoryHandler(db *sql.DB) http.HandlerFunc {
 }
 
 // Create a new budget
-func createBudgetHandler(db *sql.DB) http.HandlerFunc {
+func createBudgetHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var budget models.Budget
 		err := json.NewDecoder(r.Body).Decode(&budget)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 
-		createdBudget, err := models.CreateBudget(db, budget)
+		createdBudget, err := budgetStore.CreateBudget(budget)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -92,7 +87,7 @@ func createBudgetHandler(db *sql.DB) http.HandlerFunc {
 		json.NewEncoder(w).Encode(createdBudget)
 	}
 }
-func updateBudgetHandler(db *sql.DB) http.HandlerFunc {
+func updateBudgetHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the budget_id from the query parameters
 		// budgetIDStr := r.URL.Query().Get("id") // Adjust based on your router setup
@@ -104,18 +99,14 @@ func updateBudgetHandler(db *sql.DB) http.HandlerFunc {
 		// Convert the budgetID from string to int64
 		budgetID, err := strconv.ParseInt(budgetIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid Budget ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid Budget ID"))
 			return
 		}
 
 		// Fetch the current budget using the budget ID
-		existingBudget, err := models.GetBudgetByID(db, budgetID)
+		existingBudget, err := budgetStore.GetBudgetByID(budgetID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Budget not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
 			return
 		}
 
@@ -123,21 +114,21 @@ func updateBudgetHandler(db *sql.DB) http.HandlerFunc {
 		var newBudget models.Budget
 		err = json.NewDecoder(r.Body).Decode(&newBudget)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 
 		// Merge the new values with the existing budget
 		mergedBudget, err := mergeBudgets(existingBudget, newBudget)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest) // Handle validation errors
+			writeError(w, err)
 			return
 		}
 
 		// Update the budget in the database
-		updatedBudget, err := models.UpdateBudget(db, mergedBudget)
+		updatedBudget, err := budgetStore.UpdateBudget(mergedBudget)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -147,55 +138,18 @@ func updateBudgetHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// // Update an existing budget by category
-// func updateBudgetHandler(db *sql.DB) http.HandlerFunc {
-// 	return func(w http.ResponseWriter, r *http.Request) {
-// 		// Get the category_id from the path
-// 		categoryIDStr := r.PathValue("category")
-
-// 		// Convert the categoryID from string to int64
-// 		categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
-// 		if err != nil {
-// 			http.Error(w, "Invalid category ID", http.StatusBadRequest)
-// 			return
-// 		}
-
-// 		var budget models.Budget
-// 		err = json.NewDecoder(r.Body).Decode(&budget)
-// 		if err != nil {
-// 			http.Error(w, err.Error(), http.StatusBadRequest)
-// 			return
-// 		}
-// 		// Set the converted categoryID to the budget
-// 		budget.CategoryID = categoryID
-
-// 		updatedBudget, err := models.UpdateBudget(db, budget)
-// 		if err != nil {
-// 			http.Error(w, err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-
-// 		w.Header().Set("Content-Type", "application/json")
-// 		json.NewEncoder(w).Encode(updatedBudget)
-// 	}
-// }
-
 // Delete a budget by id
-func deleteBudgetHandler(db *sql.DB) http.HandlerFunc {
+func deleteBudgetHandler(budgetStore store.BudgetStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid Budget ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid Budget ID"))
 			return
 		}
-		err = models.DeleteBudget(db, id)
+		err = budgetStore.DeleteBudget(id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Budget not found", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -203,33 +157,6 @@ func deleteBudgetHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// func deleteBudgetHandler(db *sql.DB) http.HandlerFunc {
-// 	return func(w http.ResponseWriter, r *http.Request) {
-// 		// Ensure the request method is DELETE
-// 		if r.Method != http.MethodDelete {
-// 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-// 			return
-// 		}
-
-// 		// Extract the category from the path
-// 		category := r.URL.Query().Get("category")
-// 		if category == "" {
-// 			http.Error(w, "Category is required", http.StatusBadRequest)
-// 			return
-// 		}
-
-// 		// Attempt to delete the budget from the database
-// 		err := models.DeleteBudget(db, category)
-// 		if err != nil {
-// 			http.Error(w, err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-
-// 		// Respond with no content if successful
-// 		w.WriteHeader(http.StatusNoContent)
-// 	}
-// }
-
 func mergeBudgets(existing, new models.Budget) (models.Budget, error) {
 	if new.Amount > 0 {
 		existing.Amount = new.Amount
@@ -250,7 +177,7 @@ func mergeBudgets(existing, new models.Budget) (models.Budget, error) {
 
 	// Validate that end_date is after start_date
 	if !existing.EndDate.IsZero() && !existing.StartDate.IsZero() && existing.EndDate.Before(existing.StartDate) {
-		return existing, fmt.Errorf("end date must be after start date")
+		return existing, models.NewValidationError("end_date", "end date must be after start date")
 	}
 
 	return existing, nil
@@ -1,42 +1,64 @@
 package api
 
 import (
-	"database/sql"
 	"encoding/json"
 	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
 	"net/http"
 	"strconv"
 )
 
-func getCategoriesHandler(db *sql.DB) http.HandlerFunc {
+// getCategoriesHandler lists the categories a page at a time, or with
+// tree=true all of them at once, each nesting its sub-categories.
+func getCategoriesHandler(categoryStore store.CategoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		categories, err := models.GetCategories(db)
+		tree, err := boolParam(r, "tree")
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err)
+			return
+		}
+		if tree {
+			categories, err := categoryStore.GetCategories()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			roots := models.CategoryTree(categories)
+			if roots == nil {
+				roots = []models.Category{}
+			}
+			writeList(w, roots, len(categories))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(categories)
+		opts, err := listOptions(r, models.CategorySortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		categories, total, err := categoryStore.ListCategories(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeList(w, categories, total)
 	}
 }
 
-func getCategoryByIDHandler(db *sql.DB) http.HandlerFunc {
+func getCategoryByIDHandler(categoryStore store.CategoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category ID"))
 			return
 		}
 
-		category, err := models.GetCategoryByID(db, id)
+		category, err := categoryStore.GetCategoryByID(id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Category not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
 			return
 		}
 
@@ -45,17 +67,17 @@ func getCategoryByIDHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func createCategoryHandler(db *sql.DB) http.HandlerFunc {
+func createCategoryHandler(categoryStore store.CategoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var category models.Category
 		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 
-		createdCategory, err := models.CreateCategory(db, category)
+		createdCategory, err := categoryStore.CreateCategory(category)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err)
 			return
 		}
 
@@ -65,31 +87,31 @@ func createCategoryHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func updateCategoryHandler(db *sql.DB) http.HandlerFunc {
+func updateCategoryHandler(categoryStore store.CategoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category ID"))
 			return
 		}
 
 		// Prevent updates to the "Other" category
 		if id == 1 { // Assuming "Other" has ID 1
-			http.Error(w, "Cannot update the 'Other' category", http.StatusForbidden)
+			writeError(w, models.NewForbiddenError("cannot update the 'Other' category"))
 			return
 		}
 
 		var category models.Category
 		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 		category.ID = id
 
-		updatedCategory, err := models.UpdateCategory(db, category)
+		updatedCategory, err := categoryStore.UpdateCategory(category)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -98,31 +120,62 @@ func updateCategoryHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func deleteCategoryHandler(db *sql.DB) http.HandlerFunc {
+func deleteCategoryHandler(categoryStore store.CategoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid category ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category ID"))
 			return
 		}
 
 		// Prevent deletion of the "Other" category
 		if id == 1 { // Assuming "Other" has ID 1
-			http.Error(w, "Cannot delete the 'Other' category", http.StatusForbidden)
+			writeError(w, models.NewForbiddenError("cannot delete the 'Other' category"))
 			return
 		}
 
 		// Proceed with deletion if not "Other"
-		if err := models.DeleteCategory(db, id); err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Category not found", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := categoryStore.DeleteCategory(id); err != nil {
+			writeError(w, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// mergeCategoryHandler folds the category {id} into {target} and returns the
+// target. The strategy parameter, sum, keep_target or fail (the default),
+// says what to do with a budget that overlaps one of the target's.
+func mergeCategoryHandler(categoryStore store.CategoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category ID"))
+			return
+		}
+		target, err := strconv.ParseInt(r.PathValue("target"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("target", "Invalid target category ID"))
+			return
+		}
+		strategy := r.URL.Query().Get("strategy")
+		if strategy == "" {
+			strategy = models.MergeBudgetsFail
+		}
+		if err := models.ValidateMergeStrategy(strategy); err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		category, err := categoryStore.MergeCategory(id, target, strategy)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(category)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getCategoryRulesHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := ruleStore.GetCategoryRules()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if rules == nil {
+			rules = []models.CategoryRule{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules)
+	}
+}
+
+func getCategoryRuleByIDHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category rule ID"))
+			return
+		}
+
+		rule, err := ruleStore.GetCategoryRuleByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	}
+}
+
+func createCategoryRuleHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule models.CategoryRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdRule, err := ruleStore.CreateCategoryRule(rule)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdRule)
+	}
+}
+
+func updateCategoryRuleHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category rule ID"))
+			return
+		}
+
+		var rule models.CategoryRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		rule.ID = id
+
+		updatedRule, err := ruleStore.UpdateCategoryRule(rule)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedRule)
+	}
+}
+
+func deleteCategoryRuleHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid category rule ID"))
+			return
+		}
+
+		if err := ruleStore.DeleteCategoryRule(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// testCategoryRuleHandler returns the recorded expenses the rule in the
+// request body would match, whatever their category, without saving it.
+func testCategoryRuleHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule models.CategoryRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		expenses, err := ruleStore.TestCategoryRule(rule)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if expenses == nil {
+			expenses = []models.Expense{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(expenses)
+	}
+}
+
+// applyCategoryRulesHandler runs the rules over the expenses still in the
+// 'Other' category and returns the ones it moved.
+func applyCategoryRulesHandler(ruleStore store.CategoryRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expenses, err := ruleStore.ApplyCategoryRules()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if expenses == nil {
+			expenses = []models.Expense{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(expenses)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"expense-tracker/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// problem is the JSON body of every error response. Code is a stable,
+// machine-readable identifier; Fields maps input fields to what is wrong
+// with them.
+type problem struct {
+	Status  int               `json:"status"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// Error codes reported in problem bodies.
+const (
+	codeBadRequest           = "bad_request"
+	codeValidationFailed     = "validation_failed"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNoExchangeRate       = "no_exchange_rate"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeInternal             = "internal_error"
+)
+
+func writeProblem(w http.ResponseWriter, status int, code, message string, fields map[string]string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{Status: status, Code: code, Message: message, Fields: fields})
+}
+
+// writeError reports an error returned by a store with the status matching
+// its kind. Anything that is not a domain error is logged and reported
+// without its details, which may come straight from the database.
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrValidation):
+		writeProblem(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error(), errorFields(err))
+	case errors.Is(err, models.ErrNotFound):
+		writeProblem(w, http.StatusNotFound, codeNotFound, err.Error(), nil)
+	case errors.Is(err, models.ErrConflict):
+		writeProblem(w, http.StatusConflict, codeConflict, err.Error(), nil)
+	case errors.Is(err, models.ErrUnauthorized):
+		writeUnauthorized(w, err)
+	case errors.Is(err, models.ErrForbidden):
+		writeProblem(w, http.StatusForbidden, codeForbidden, err.Error(), nil)
+	case errors.Is(err, models.ErrNoExchangeRate):
+		writeProblem(w, http.StatusUnprocessableEntity, codeNoExchangeRate, err.Error(), nil)
+	default:
+		log.Printf("Internal error: %v", err)
+		writeProblem(w, http.StatusInternalServerError, codeInternal, "internal server error", nil)
+	}
+}
+
+// writeBadRequest reports a request that could not be read, such as an
+// invalid path or query parameter.
+func writeBadRequest(w http.ResponseWriter, err error) {
+	writeProblem(w, http.StatusBadRequest, codeBadRequest, err.Error(), errorFields(err))
+}
+
+// writeDecodeError reports a request body that is not valid JSON for the
+// expected type, naming the offending field when the decoder knows it.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		err = models.NewValidationError(typeErr.Field, "%s must be a %s", typeErr.Field, typeErr.Type)
+	} else {
+		err = fmt.Errorf("invalid JSON body: %w", err)
+	}
+	writeBadRequest(w, err)
+}
+
+// errorFields returns the field details of a validation error, if any.
+func errorFields(err error) map[string]string {
+	var domainErr *models.Error
+	if errors.As(err, &domainErr) && domainErr.Field != "" {
+		return map[string]string{domainErr.Field: domainErr.Message}
+	}
+	return nil
+}
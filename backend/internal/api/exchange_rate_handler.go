@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strings"
+)
+
+func getExchangeRatesHandler(rateStore store.ExchangeRateStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		base := strings.ToUpper(r.URL.Query().Get("base"))
+		quote := strings.ToUpper(r.URL.Query().Get("quote"))
+
+		rates, err := rateStore.GetExchangeRates(base, quote)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rates)
+	}
+}
+
+// currencyParam reads the optional currency query parameter that asks for
+// amounts to be converted. It returns an empty string when none was given.
+func currencyParam(r *http.Request) (string, error) {
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		return "", nil
+	}
+	return models.NormalizeCurrency(currency)
+}
+
+// convertExpenses fills in the converted amount of each expense.
+func convertExpenses(rateStore store.ExchangeRateStore, expenses []models.Expense, currency string) error {
+	for i, expense := range expenses {
+		conversion, err := rateStore.ConvertAmount(expense.Amount, expense.Currency, currency, expense.Date)
+		if err != nil {
+			return err
+		}
+		expenses[i].Converted = &conversion
+	}
+	return nil
+}
+
+// convertIncomes fills in the converted amount of each income.
+func convertIncomes(rateStore store.ExchangeRateStore, incomes []models.Income, currency string) error {
+	for i, income := range incomes {
+		conversion, err := rateStore.ConvertAmount(income.Amount, income.Currency, currency, income.Date)
+		if err != nil {
+			return err
+		}
+		incomes[i].Converted = &conversion
+	}
+	return nil
+}
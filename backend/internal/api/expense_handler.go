@@ -3,42 +3,68 @@ package api
 import (
 	"encoding/json"
 	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
 	"net/http"
 	"strconv"
-
-	"database/sql"
 )
 
-func getExpensesHandler(db *sql.DB) http.HandlerFunc {
+func getExpensesHandler(expenseStore store.ExpenseStore, rateStore store.ExchangeRateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		expenses, err := models.GetExpenses(db)
+		currency, err := currencyParam(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(expenses)
+		opts, err := listOptions(r, models.ExpenseSortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		expenses, total, err := expenseStore.ListExpenses(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Show each amount in the requested currency alongside the original
+		if currency != "" {
+			if err := convertExpenses(rateStore, expenses, currency); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		writeList(w, expenses, total)
 	}
 }
 
-func getExpenseByIDHandler(db *sql.DB) http.HandlerFunc {
+func getExpenseByIDHandler(expenseStore store.ExpenseStore, rateStore store.ExchangeRateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
 			return
 		}
 
-		expense, err := models.GetExpenseByID(db, id)
+		expense, err := expenseStore.GetExpenseByID(id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Expense not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
+			return
+		}
+
+		if currency, err := currencyParam(r); err != nil {
+			writeBadRequest(w, err)
 			return
+		} else if currency != "" {
+			conversion, err := rateStore.ConvertAmount(expense.Amount, expense.Currency, currency, expense.Date)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			expense.Converted = &conversion
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -46,53 +72,46 @@ func getExpenseByIDHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func createExpenseHandler(db *sql.DB) http.HandlerFunc {
+func createExpenseHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var expense models.Expense
 		if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 
-		err := db.QueryRow(
-			"INSERT INTO Expense (category_id, description, amount, date) VALUES ($1, $2, $3, $4) RETURNING id, category_id, description, amount, date",
-			expense.CategoryID,
-			expense.Description,
-			expense.Amount,
-			expense.Date,
-		).Scan(&expense.ID, &expense.CategoryID, &expense.Description, &expense.Amount, &expense.Date)
-
+		createdExpense, err := expenseStore.CreateExpense(expense)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(expense)
+		json.NewEncoder(w).Encode(createdExpense)
 	}
 }
 
-func updateExpenseHandler(db *sql.DB) http.HandlerFunc {
+func updateExpenseHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
 			return
 		}
 
 		var expense models.Expense
 		err = json.NewDecoder(r.Body).Decode(&expense)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 		expense.ID = id
 
-		updatedExpense, err := models.UpdateExpense(db, expense)
+		updatedExpense, err := expenseStore.UpdateExpense(expense)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -101,21 +120,89 @@ func updateExpenseHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func deleteExpenseHandler(db *sql.DB) http.HandlerFunc {
+func deleteExpenseHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid expense ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
 			return
 		}
 
-		err = models.DeleteExpense(db, id)
+		err = expenseStore.DeleteExpense(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
-}
\ No newline at end of file
+}
+
+// getDuplicateExpensesHandler lists the pairs of expenses that look like the
+// same purchase recorded twice, leaving out dismissed pairs.
+func getDuplicateExpensesHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pairs, err := expenseStore.GetDuplicateExpenses()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if pairs == nil {
+			pairs = []models.DuplicatePair{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pairs)
+	}
+}
+
+// mergeExpenseHandler deletes the expense {id} as a duplicate of {target}
+// and returns the expense kept.
+func mergeExpenseHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
+			return
+		}
+		target, err := strconv.ParseInt(r.PathValue("target"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("target", "Invalid target expense ID"))
+			return
+		}
+
+		expense, err := expenseStore.MergeExpense(id, target)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(expense)
+	}
+}
+
+// dismissDuplicateHandler records that the expenses {id} and {duplicate} are
+// not duplicates, so the pair is no longer listed.
+func dismissDuplicateHandler(expenseStore store.ExpenseStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid expense ID"))
+			return
+		}
+		duplicate, err := strconv.ParseInt(r.PathValue("duplicate"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("duplicate", "Invalid duplicate expense ID"))
+			return
+		}
+
+		if err := expenseStore.DismissDuplicate(id, duplicate); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
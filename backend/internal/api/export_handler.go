@@ -0,0 +1,58 @@
+package api
+
+import (
+	"expense-tracker/internal/export"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// getExportHandler downloads the ledger in format csv (a ZIP of CSV files),
+// json (the default) or xlsx. from and to optionally bound the expenses,
+// incomes and budgets exported; categories and accounts are always included.
+func getExportHandler(stores store.Stores) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		contentType, ok := export.Formats[format]
+		if !ok {
+			writeBadRequest(w, models.NewValidationError("format", "Invalid format %q (expected csv, json or xlsx)", format))
+			return
+		}
+
+		from, err := dateParam(r, "from")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		// JSON and CSV are streamed, reading entries as they are written;
+		// workbooks are built from the whole ledger in memory
+		open := export.Open
+		if format == "xlsx" {
+			open = export.Load
+		}
+		ledger, err := open(stores, from, to)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ledger%s"`, export.FileExtensions[format]))
+		// The status is sent by now, so a failure, including one reading the
+		// entries of a streamed export, can only be logged
+		if err := export.Write(w, ledger, format); err != nil {
+			log.Printf("Failed to write export: %v", err)
+		}
+	}
+}
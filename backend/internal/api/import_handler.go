@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func getImportProfilesHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		profiles, err := importStore.GetImportProfiles()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if profiles == nil {
+			profiles = []models.ImportProfile{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profiles)
+	}
+}
+
+func getImportProfileByIDHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid import profile ID"))
+			return
+		}
+
+		profile, err := importStore.GetImportProfileByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+	}
+}
+
+func createImportProfileHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var profile models.ImportProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdProfile, err := importStore.CreateImportProfile(profile)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdProfile)
+	}
+}
+
+func updateImportProfileHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid import profile ID"))
+			return
+		}
+
+		var profile models.ImportProfile
+		if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		profile.ID = id
+
+		updatedProfile, err := importStore.UpdateImportProfile(profile)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedProfile)
+	}
+}
+
+func deleteImportProfileHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid import profile ID"))
+			return
+		}
+
+		if err := importStore.DeleteImportProfile(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// importStatementHandler reads a bank statement from the request body and
+// creates its expenses and incomes. The format is csv, ofx, qfx or qif:
+//
+//	csv           profile_id names the import profile to read it with
+//	ofx, qfx, qif category_id (default 1), account_id and currency, for rows
+//	              that do not name one, say where the rows go; qif also
+//	              takes date_format
+//
+// With dry_run=true it only reports what it would create. Rows imported
+// before are skipped. If any row cannot be imported nothing is created, and
+// the problem lists the failing lines. Statements over
+// models.MaxStatementSize are rejected.
+func importStatementHandler(importStore store.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := boolParam(r, "dry_run")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, models.MaxStatementSize)
+
+		var statement models.Statement
+		switch format := r.PathValue("format"); format {
+		case "csv":
+			profileID, err := intParam(r, "profile_id")
+			if err != nil {
+				writeBadRequest(w, err)
+				return
+			}
+			if profileID == 0 {
+				writeBadRequest(w, models.NewValidationError("profile_id", "profile_id must be provided"))
+				return
+			}
+			profile, err := importStore.GetImportProfileByID(profileID)
+			if errors.Is(err, models.ErrNotFound) {
+				writeBadRequest(w, models.NewValidationError("profile_id", "import profile %d does not exist", profileID))
+				return
+			}
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			statement, err = models.ParseStatementCSV(r.Body, profile)
+			if err != nil {
+				writeStatementError(w, err)
+				return
+			}
+		case "ofx", "qfx", "qif":
+			if format == "qif" {
+				statement, err = models.ParseStatementQIF(r.Body, r.URL.Query().Get("date_format"))
+			} else {
+				statement, err = models.ParseStatementOFX(r.Body)
+			}
+			if err != nil {
+				writeStatementError(w, err)
+				return
+			}
+			if statement, err = statementDestination(r, statement); err != nil {
+				writeBadRequest(w, err)
+				return
+			}
+		default:
+			writeProblem(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("unsupported statement format %q (expected csv, ofx, qfx or qif)", format), nil)
+			return
+		}
+		writeStatementImport(w, importStore, statement, dryRun)
+	}
+}
+
+// writeStatementError reports a statement that could not be read, treating
+// a body over the size limit as a file that is too large.
+func writeStatementError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, models.NewValidationError("file", "statement is too large (max %d MiB)", models.MaxStatementSize>>20))
+		return
+	}
+	writeBadRequest(w, err)
+}
+
+// statementDestination reads the category_id, account_id and currency query
+// parameters that say where the rows of a statement without a profile go.
+// The currency only applies to rows that do not name their own.
+func statementDestination(r *http.Request, statement models.Statement) (models.Statement, error) {
+	categoryID, err := intParam(r, "category_id")
+	if err != nil {
+		return models.Statement{}, err
+	}
+	statement.CategoryID = 1
+	if categoryID != 0 {
+		statement.CategoryID = categoryID
+	}
+	accountID, err := intParam(r, "account_id")
+	if err != nil {
+		return models.Statement{}, err
+	}
+	if accountID != 0 {
+		statement.AccountID = &accountID
+	}
+	currency, err := currencyParam(r)
+	if err != nil {
+		return models.Statement{}, err
+	}
+	for i := range statement.Rows {
+		if statement.Rows[i].Currency == "" {
+			statement.Rows[i].Currency = currency
+		}
+	}
+	return statement, nil
+}
+
+// writeStatementImport imports a parsed statement and reports the result:
+// 200 for a dry run, 201 once the rows are created, or a validation problem
+// naming each line that cannot be imported.
+func writeStatementImport(w http.ResponseWriter, importStore store.ImportStore, statement models.Statement, dryRun bool) {
+	report, err := importStore.ImportStatement(statement, dryRun)
+	if err != nil && report.Errors > 0 {
+		fields := map[string]string{}
+		for _, row := range report.Rows {
+			if row.Error != "" {
+				fields[fmt.Sprintf("line %d", row.Line)] = row.Error
+			}
+		}
+		writeProblem(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error(), fields)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !dryRun {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// boolParam reads an optional boolean query parameter, which defaults to
+// false.
+func boolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, models.NewValidationError(name, "Invalid %s", name)
+	}
+	return b, nil
+}
@@ -3,61 +3,87 @@ package api
 import (
 	"encoding/json"
 	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
 	"net/http"
 	"strconv"
-
-	"database/sql"
 )
 
-func getIncomesHandler(db *sql.DB) http.HandlerFunc {
+func getIncomesHandler(incomeStore store.IncomeStore, rateStore store.ExchangeRateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		incomes, err := models.GetIncomes(db)
+		currency, err := currencyParam(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeBadRequest(w, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(incomes)
+		opts, err := listOptions(r, models.IncomeSortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		incomes, total, err := incomeStore.ListIncomes(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// Show each amount in the requested currency alongside the original
+		if currency != "" {
+			if err := convertIncomes(rateStore, incomes, currency); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		writeList(w, incomes, total)
 	}
 }
 
-func getIncomeByIDHandler(db *sql.DB) http.HandlerFunc {
+func getIncomeByIDHandler(incomeStore store.IncomeStore, rateStore store.ExchangeRateStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid income ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid income ID"))
 			return
 		}
 
-		income, err := models.GetIncomeByID(db, id)
+		income, err := incomeStore.GetIncomeByID(id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Income not found", http.StatusNotFound)
-			} else {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			writeError(w, err)
 			return
 		}
 
+		if currency, err := currencyParam(r); err != nil {
+			writeBadRequest(w, err)
+			return
+		} else if currency != "" {
+			conversion, err := rateStore.ConvertAmount(income.Amount, income.Currency, currency, income.Date)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			income.Converted = &conversion
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(income)
 	}
 }
 
-func createIncomeHandler(db *sql.DB) http.HandlerFunc {
+func createIncomeHandler(incomeStore store.IncomeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var income models.Income
 		err := json.NewDecoder(r.Body).Decode(&income)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 
-		createdIncome, err := models.CreateIncome(db, income)
+		createdIncome, err := incomeStore.CreateIncome(income)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeError(w, err)
 			return
 		}
 
@@ -67,26 +93,26 @@ func createIncomeHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func updateIncomeHandler(db *sql.DB) http.HandlerFunc {
+func updateIncomeHandler(incomeStore store.IncomeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid income ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid income ID"))
 			return
 		}
 
 		var income models.Income
 		err = json.NewDecoder(r.Body).Decode(&income)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeDecodeError(w, err)
 			return
 		}
 		income.ID = id
 
-		updatedIncome, err := models.UpdateIncome(db, income)
+		updatedIncome, err := incomeStore.UpdateIncome(income)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -95,21 +121,21 @@ func updateIncomeHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-func deleteIncomeHandler(db *sql.DB) http.HandlerFunc {
+func deleteIncomeHandler(incomeStore store.IncomeStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid income ID", http.StatusBadRequest)
+			writeBadRequest(w, models.NewValidationError("id", "Invalid income ID"))
 			return
 		}
 
-		err = models.DeleteIncome(db, id)
+		err = incomeStore.DeleteIncome(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type ledgerKey struct{}
+
+// ledgerRoutes serves the data routes with the stores of a ledger the
+// signed-in user belongs to: the one named by a /ledgers/{ledgerID}/ prefix,
+// or else the first one they joined, normally their personal ledger. Viewers
+// can only read, and API tokens only reach the routes their scopes cover.
+// Building the routes of a ledger takes a while, so those of the most
+// recently used ledgers are kept.
+type ledgerRoutes struct {
+	backend store.Backend
+	mu      sync.Mutex
+
+	// routes finds the cachedRoutes element of a ledger in recent, which is
+	// ordered from most to least recently used.
+	routes map[int64]*list.Element
+	recent *list.List
+}
+
+// maxCachedLedgers bounds the number of ledgers whose routes are kept.
+const maxCachedLedgers = 128
+
+// cachedRoutes are the routes built for a ledger.
+type cachedRoutes struct {
+	ledgerID int64
+	handler  http.Handler
+}
+
+func newLedgerRoutes(backend store.Backend) *ledgerRoutes {
+	return &ledgerRoutes{backend: backend, routes: map[int64]*list.Element{}, recent: list.New()}
+}
+
+// ledgerHandler returns the routes of a ledger, building them if they are
+// not kept and forgetting those of the least recently used ledger if there
+// are too many.
+func (l *ledgerRoutes) ledgerHandler(ledgerID int64) http.Handler {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.routes[ledgerID]; ok {
+		l.recent.MoveToFront(element)
+		return element.Value.(cachedRoutes).handler
+	}
+	handler := dataRoutes(l.backend.Stores(ledgerID))
+	l.routes[ledgerID] = l.recent.PushFront(cachedRoutes{ledgerID: ledgerID, handler: handler})
+	if l.recent.Len() > maxCachedLedgers {
+		oldest := l.recent.Remove(l.recent.Back()).(cachedRoutes)
+		delete(l.routes, oldest.ledgerID)
+	}
+	return handler
+}
+
+func (l *ledgerRoutes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ledger models.Ledger
+	if r.PathValue("ledgerID") != "" {
+		var ok bool
+		if ledger, ok = pathLedger(w, r, l.backend.Ledgers()); !ok {
+			return
+		}
+	} else {
+		ledgers, err := l.backend.Ledgers().GetUserLedgers(currentUser(r).ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if len(ledgers) == 0 {
+			writeError(w, models.NewNotFoundError("ledger"))
+			return
+		}
+		ledger = ledgers[0]
+	}
+	if !ledger.Role.CanWrite() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, models.NewForbiddenError("viewers can only read the ledger"))
+		return
+	}
+	prefix := ""
+	if r.PathValue("ledgerID") != "" {
+		prefix = "/ledgers/" + r.PathValue("ledgerID")
+	}
+	if token, ok := currentAPIToken(r); ok {
+		if err := checkScope(token, r.Method, strings.TrimPrefix(r.URL.Path, prefix)); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	handler := l.ledgerHandler(ledger.ID)
+	if prefix != "" {
+		handler = http.StripPrefix(prefix, handler)
+	}
+	handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ledgerKey{}, ledger)))
+}
+
+// pathLedger returns the ledger of the ledgerID path parameter with the role
+// of the signed-in user, who must belong to it.
+func pathLedger(w http.ResponseWriter, r *http.Request, ledgerStore store.LedgerStore) (models.Ledger, bool) {
+	id, err := strconv.ParseInt(r.PathValue("ledgerID"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, models.NewValidationError("ledgerID", "Invalid ledger ID"))
+		return models.Ledger{}, false
+	}
+	ledger, err := ledgerStore.GetUserLedger(id, currentUser(r).ID)
+	if err != nil {
+		writeError(w, err)
+		return models.Ledger{}, false
+	}
+	return ledger, true
+}
+
+// currentLedger returns the ledger chosen by ledgerRoutes.
+func currentLedger(r *http.Request) models.Ledger {
+	ledger, _ := r.Context().Value(ledgerKey{}).(models.Ledger)
+	return ledger
+}
+
+// requireOwner only lets owners of the current ledger through to next;
+// action completes "only owners can ...".
+func requireOwner(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if currentLedger(r).Role != models.RoleOwner {
+			writeError(w, models.NewForbiddenError("only owners can %s", action))
+			return
+		}
+		next(w, r)
+	}
+}
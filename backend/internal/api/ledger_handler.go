@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getLedgersHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ledgers, err := ledgerStore.GetUserLedgers(currentUser(r).ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if ledgers == nil {
+			ledgers = []models.Ledger{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ledgers)
+	}
+}
+
+func createLedgerHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ledger models.Ledger
+		if err := json.NewDecoder(r.Body).Decode(&ledger); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdLedger, err := ledgerStore.CreateLedger(currentUser(r).ID, ledger.Name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdLedger)
+	}
+}
+
+func getMembersHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ledger, ok := pathLedger(w, r, ledgerStore)
+		if !ok {
+			return
+		}
+
+		members, err := ledgerStore.GetMembers(ledger.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if members == nil {
+			members = []models.Member{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(members)
+	}
+}
+
+// memberRoute returns the ledger and the member's user id of a member
+// route, after checking that the signed-in user owns the ledger or, when
+// self is set, is the member.
+func memberRoute(w http.ResponseWriter, r *http.Request, ledgerStore store.LedgerStore, self bool) (models.Ledger, int64, bool) {
+	ledger, ok := pathLedger(w, r, ledgerStore)
+	if !ok {
+		return models.Ledger{}, 0, false
+	}
+	userID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, models.NewValidationError("userID", "Invalid user ID"))
+		return models.Ledger{}, 0, false
+	}
+	if ledger.Role != models.RoleOwner && !(self && userID == currentUser(r).ID) {
+		writeError(w, models.NewForbiddenError("only owners can manage members"))
+		return models.Ledger{}, 0, false
+	}
+	return ledger, userID, true
+}
+
+func updateMemberHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ledger, userID, ok := memberRoute(w, r, ledgerStore, false)
+		if !ok {
+			return
+		}
+
+		var member models.Member
+		if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		updatedMember, err := ledgerStore.UpdateMember(ledger.ID, userID, member.Role)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedMember)
+	}
+}
+
+// removeMemberHandler takes a member out of a ledger. Members other than
+// owners can only remove themselves, to leave it.
+func removeMemberHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ledger, userID, ok := memberRoute(w, r, ledgerStore, true)
+		if !ok {
+			return
+		}
+
+		if err := ledgerStore.RemoveMember(ledger.ID, userID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// createInvitationHandler invites someone to a ledger. The token of the
+// invitation is only returned here, for the owner to pass on.
+func createInvitationHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ledger, ok := pathLedger(w, r, ledgerStore)
+		if !ok {
+			return
+		}
+		if ledger.Role != models.RoleOwner {
+			writeError(w, models.NewForbiddenError("only owners can invite members"))
+			return
+		}
+
+		var invitation models.Invitation
+		if err := json.NewDecoder(r.Body).Decode(&invitation); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdInvitation, err := ledgerStore.CreateInvitation(ledger.ID, currentUser(r).ID, invitation.Role)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdInvitation)
+	}
+}
+
+// acceptInvitationHandler makes the signed-in user a member of the ledger
+// an invitation token is for, and returns that ledger.
+func acceptInvitationHandler(ledgerStore store.LedgerStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		ledger, err := ledgerStore.AcceptInvitation(body.Token, currentUser(r).ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ledger)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateParam reads an optional YYYY-MM-DD query parameter.
+func dateParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, models.NewValidationError(name, "Invalid %s date (expected YYYY-MM-DD)", name)
+	}
+	return date, nil
+}
+
+// moneyParam reads an optional decimal amount query parameter.
+func moneyParam(r *http.Request, name string) (*models.Money, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := models.ParseMoney(value)
+	if err != nil {
+		return nil, models.NewValidationError(name, "Invalid %s: %v", name, err)
+	}
+	return &amount, nil
+}
+
+// intParam reads an optional non-negative integer query parameter.
+func intParam(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, models.NewValidationError(name, "Invalid %s", name)
+	}
+	return n, nil
+}
+
+// listOptions reads the query parameters shared by list endpoints:
+//
+//	from, to               date range, YYYY-MM-DD, inclusive
+//	category_id            category to list
+//	account_id             account to list
+//	min_amount, max_amount amount range, inclusive
+//	q                      text to search for
+//	tag                    tags entries must all carry, repeated or comma-separated
+//	sort                   fields to order by, e.g. sort=-date,amount
+//	limit, offset          page to return
+//
+// Endpoints ignore filters that do not apply to them.
+func listOptions(r *http.Request, sortable map[string]string) (models.ListOptions, error) {
+	var opts models.ListOptions
+	var err error
+
+	if opts.From, err = dateParam(r, "from"); err != nil {
+		return opts, err
+	}
+	if opts.To, err = dateParam(r, "to"); err != nil {
+		return opts, err
+	}
+	if opts.CategoryID, err = intParam(r, "category_id"); err != nil {
+		return opts, err
+	}
+	if opts.AccountID, err = intParam(r, "account_id"); err != nil {
+		return opts, err
+	}
+	if opts.MinAmount, err = moneyParam(r, "min_amount"); err != nil {
+		return opts, err
+	}
+	if opts.MaxAmount, err = moneyParam(r, "max_amount"); err != nil {
+		return opts, err
+	}
+	opts.Search = strings.TrimSpace(r.URL.Query().Get("q"))
+	for _, value := range r.URL.Query()["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				opts.Tags = append(opts.Tags, strings.ToLower(tag))
+			}
+		}
+	}
+	if opts.Sort, err = models.ParseSort(r.URL.Query().Get("sort"), sortable); err != nil {
+		return opts, err
+	}
+
+	limit, err := intParam(r, "limit")
+	if err != nil {
+		return opts, err
+	}
+	offset, err := intParam(r, "offset")
+	if err != nil {
+		return opts, err
+	}
+	opts.Limit, opts.Offset = int(limit), int(offset)
+
+	if r.URL.Query().Has("limit") && opts.Limit == 0 {
+		return opts, models.NewValidationError("limit", "limit must be between 1 and %d", models.MaxListLimit)
+	}
+	return opts, models.ValidateListOptions(opts)
+}
+
+// writeList writes one page of a list, reporting the number of items that
+// match the filters across all pages in the X-Total-Count header.
+func writeList(w http.ResponseWriter, items any, total int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Number of occurrences previewed when no count is given, and the most that
+// can be requested at once.
+const (
+	defaultOccurrenceCount = 5
+	maxOccurrenceCount     = 100
+)
+
+func getRecurringRulesHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := ruleStore.GetRecurringRules()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rules)
+	}
+}
+
+func getRecurringRuleByIDHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid recurring rule ID"))
+			return
+		}
+
+		rule, err := ruleStore.GetRecurringRuleByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+	}
+}
+
+// getRecurringRuleOccurrencesHandler previews the next occurrences of a rule
+// that have not been generated yet.
+func getRecurringRuleOccurrencesHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid recurring rule ID"))
+			return
+		}
+
+		count := defaultOccurrenceCount
+		if value := r.URL.Query().Get("count"); value != "" {
+			count, err = strconv.Atoi(value)
+			if err != nil || count < 1 || count > maxOccurrenceCount {
+				writeBadRequest(w, models.NewValidationError("count", "Invalid count (expected 1 to 100)"))
+				return
+			}
+		}
+
+		rule, err := ruleStore.GetRecurringRuleByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		dates := rule.Upcoming(count)
+		if dates == nil {
+			dates = []time.Time{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dates)
+	}
+}
+
+// createRecurringRuleHandler stores a rule and immediately generates any
+// occurrences that are already due, so a rule starting today or in the past
+// shows up without waiting for the scheduler.
+func createRecurringRuleHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule models.RecurringRule
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdRule, err := ruleStore.CreateRecurringRule(rule)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// The scheduler retries anything that fails here, so only log it
+		if _, err := ruleStore.MaterializeRecurringRules(time.Now()); err != nil {
+			log.Printf("Failed to materialize recurring rules: %v", err)
+		} else if refreshed, err := ruleStore.GetRecurringRuleByID(createdRule.ID); err == nil {
+			createdRule = refreshed
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdRule)
+	}
+}
+
+func updateRecurringRuleHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid recurring rule ID"))
+			return
+		}
+
+		var rule models.RecurringRule
+		err = json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		rule.ID = id
+
+		updatedRule, err := ruleStore.UpdateRecurringRule(rule)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedRule)
+	}
+}
+
+func deleteRecurringRuleHandler(ruleStore store.RecurringRuleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid recurring rule ID"))
+			return
+		}
+
+		err = ruleStore.DeleteRecurringRule(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
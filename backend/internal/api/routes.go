@@ -1,43 +1,153 @@
 package api
 
 import (
-	"database/sql"
 	"expense-tracker/internal/api/middleware"
+	"expense-tracker/internal/store"
 	"net/http"
 )
 
-func NewRouter(db *sql.DB) http.Handler {
+// NewRouter serves the API of a backend. Apart from registering and signing
+// in, every route needs a session token. The data routes serve one of the
+// ledgers of the signed-in user, chosen by a /ledgers/{ledgerID} prefix, and
+// also accept API tokens with the scopes they need.
+func NewRouter(backend store.Backend) http.Handler {
+	users := backend.Users()
+	tokens := backend.Tokens()
+	ledgers := backend.Ledgers()
+	mux := http.NewServeMux()
+
+	// Auth routes
+	mux.HandleFunc("POST /auth/register", registerHandler(users))
+	mux.HandleFunc("POST /auth/login", loginHandler(users))
+	mux.Handle("POST /auth/logout", requireUser(users, logoutHandler(users)))
+	mux.Handle("GET /auth/me", requireUser(users, getCurrentUserHandler()))
+
+	// API token routes
+	mux.Handle("GET /api-tokens", requireUser(users, getAPITokensHandler(tokens)))
+	mux.Handle("POST /api-tokens", requireUser(users, createAPITokenHandler(tokens)))
+	mux.Handle("DELETE /api-tokens/{id}", requireUser(users, revokeAPITokenHandler(tokens)))
+
+	// Ledger routes
+	mux.Handle("GET /ledgers", requireUser(users, getLedgersHandler(ledgers)))
+	mux.Handle("POST /ledgers", requireUser(users, createLedgerHandler(ledgers)))
+	mux.Handle("GET /ledgers/{ledgerID}/members", requireUser(users, getMembersHandler(ledgers)))
+	mux.Handle("PUT /ledgers/{ledgerID}/members/{userID}", requireUser(users, updateMemberHandler(ledgers)))
+	mux.Handle("DELETE /ledgers/{ledgerID}/members/{userID}", requireUser(users, removeMemberHandler(ledgers)))
+	mux.Handle("POST /ledgers/{ledgerID}/invitations", requireUser(users, createInvitationHandler(ledgers)))
+	mux.Handle("POST /invitations/accept", requireUser(users, acceptInvitationHandler(ledgers)))
+
+	data := requireUserOrAPIToken(users, tokens, newLedgerRoutes(backend))
+	mux.Handle("/ledgers/{ledgerID}/", data)
+	mux.Handle("/", data)
+
+	// Wrap the mux with CORS middleware
+	return middleware.CORS(mux)
+}
+
+// dataRoutes serves the data routes with the stores of one ledger. Deleting
+// categories and budgets is left to owners.
+func dataRoutes(stores store.Stores) http.Handler {
 	mux := http.NewServeMux()
 
 	// Category routes
-	mux.HandleFunc("GET /categories", getCategoriesHandler(db))
-	mux.HandleFunc("GET /categories/{id}", getCategoryByIDHandler(db))
-	mux.HandleFunc("POST /categories", createCategoryHandler(db))
-	mux.HandleFunc("PUT /categories/{id}", updateCategoryHandler(db))
-	mux.HandleFunc("DELETE /categories/{id}", deleteCategoryHandler(db))
+	mux.HandleFunc("GET /categories", getCategoriesHandler(stores.Categories))
+	mux.HandleFunc("GET /categories/{id}", getCategoryByIDHandler(stores.Categories))
+	mux.HandleFunc("POST /categories", createCategoryHandler(stores.Categories))
+	mux.HandleFunc("PUT /categories/{id}", updateCategoryHandler(stores.Categories))
+	mux.HandleFunc("DELETE /categories/{id}", requireOwner("delete categories", deleteCategoryHandler(stores.Categories)))
+	mux.HandleFunc("POST /categories/{id}/merge-into/{target}", requireOwner("merge categories", mergeCategoryHandler(stores.Categories)))
+
+	// Category rule routes
+	mux.HandleFunc("GET /category-rules", getCategoryRulesHandler(stores.CategoryRules))
+	mux.HandleFunc("GET /category-rules/{id}", getCategoryRuleByIDHandler(stores.CategoryRules))
+	mux.HandleFunc("POST /category-rules", createCategoryRuleHandler(stores.CategoryRules))
+	mux.HandleFunc("PUT /category-rules/{id}", updateCategoryRuleHandler(stores.CategoryRules))
+	mux.HandleFunc("DELETE /category-rules/{id}", deleteCategoryRuleHandler(stores.CategoryRules))
+	mux.HandleFunc("POST /category-rules/test", testCategoryRuleHandler(stores.CategoryRules))
+	mux.HandleFunc("POST /category-rules/apply", applyCategoryRulesHandler(stores.CategoryRules))
 
 	// Income routes
-	mux.HandleFunc("GET /incomes", getIncomesHandler(db))
-	mux.HandleFunc("GET /incomes/{id}", getIncomeByIDHandler(db))
-	mux.HandleFunc("POST /incomes", createIncomeHandler(db))
-	mux.HandleFunc("PUT /incomes/{id}", updateIncomeHandler(db))
-	mux.HandleFunc("DELETE /incomes/{id}", deleteIncomeHandler(db))
+	mux.HandleFunc("GET /incomes", getIncomesHandler(stores.Incomes, stores.ExchangeRates))
+	mux.HandleFunc("GET /incomes/{id}", getIncomeByIDHandler(stores.Incomes, stores.ExchangeRates))
+	mux.HandleFunc("POST /incomes", createIncomeHandler(stores.Incomes))
+	mux.HandleFunc("PUT /incomes/{id}", updateIncomeHandler(stores.Incomes))
+	mux.HandleFunc("DELETE /incomes/{id}", deleteIncomeHandler(stores.Incomes))
 
 	// Expense routes
-	mux.HandleFunc("GET /expenses", getExpensesHandler(db))
-	mux.HandleFunc("GET /expenses/{id}", getExpenseByIDHandler(db))
-	mux.HandleFunc("POST /expenses", createExpenseHandler(db))
-	mux.HandleFunc("PUT /expenses/{id}", updateExpenseHandler(db))
-	mux.HandleFunc("DELETE /expenses/{id}", deleteExpenseHandler(db))
+	mux.HandleFunc("GET /expenses", getExpensesHandler(stores.Expenses, stores.ExchangeRates))
+	mux.HandleFunc("GET /expenses/{id}", getExpenseByIDHandler(stores.Expenses, stores.ExchangeRates))
+	mux.HandleFunc("POST /expenses", createExpenseHandler(stores.Expenses))
+	mux.HandleFunc("PUT /expenses/{id}", updateExpenseHandler(stores.Expenses))
+	mux.HandleFunc("DELETE /expenses/{id}", deleteExpenseHandler(stores.Expenses))
+	mux.HandleFunc("GET /expenses/duplicates", getDuplicateExpensesHandler(stores.Expenses))
+	mux.HandleFunc("POST /expenses/{id}/merge-into/{target}", mergeExpenseHandler(stores.Expenses))
+	mux.HandleFunc("POST /expenses/{id}/dismiss-duplicate/{duplicate}", dismissDuplicateHandler(stores.Expenses))
+
+	// Attachment routes
+	mux.HandleFunc("GET /expenses/{id}/attachments", getAttachmentsHandler(stores.Attachments))
+	mux.HandleFunc("POST /expenses/{id}/attachments", createAttachmentHandler(stores.Attachments))
+	mux.HandleFunc("GET /expenses/{id}/attachments/{attachment}", downloadAttachmentHandler(stores.Attachments))
+	mux.HandleFunc("DELETE /expenses/{id}/attachments/{attachment}", deleteAttachmentHandler(stores.Attachments))
+
+	// Account routes
+	mux.HandleFunc("GET /accounts", getAccountsHandler(stores.Accounts))
+	mux.HandleFunc("GET /accounts/{id}", getAccountByIDHandler(stores.Accounts))
+	mux.HandleFunc("GET /accounts/{id}/balances", getAccountBalancesHandler(stores.Accounts))
+	mux.HandleFunc("POST /accounts", createAccountHandler(stores.Accounts))
+	mux.HandleFunc("PUT /accounts/{id}", updateAccountHandler(stores.Accounts))
+	mux.HandleFunc("DELETE /accounts/{id}", deleteAccountHandler(stores.Accounts))
+
+	// Transfer routes
+	mux.HandleFunc("GET /transfers", getTransfersHandler(stores.Transfers))
+	mux.HandleFunc("GET /transfers/{id}", getTransferByIDHandler(stores.Transfers))
+	mux.HandleFunc("POST /transfers", createTransferHandler(stores.Transfers))
+	mux.HandleFunc("PUT /transfers/{id}", updateTransferHandler(stores.Transfers))
+	mux.HandleFunc("DELETE /transfers/{id}", deleteTransferHandler(stores.Transfers))
+
+	// Transaction routes
+	mux.HandleFunc("GET /transactions", getTransactionsHandler(stores.Transactions))
 
 	// Budget routes
-	mux.HandleFunc("GET /budgets", getBudgetsHandler(db))
-	mux.HandleFunc("GET /budgets/{id}", getBudgetByIDHandler(db))
-	mux.HandleFunc("GET /budgets/category/{category}", getBudgetByCategoryHandler(db))
-	mux.HandleFunc("POST /budgets", createBudgetHandler(db))
-	mux.HandleFunc("PUT /budgets/{id}", updateBudgetHandler(db))
-	mux.HandleFunc("DELETE /budgets/{id}", deleteBudgetHandler(db))
+	mux.HandleFunc("GET /budgets", getBudgetsHandler(stores.Budgets))
+	mux.HandleFunc("GET /budgets/{id}", getBudgetByIDHandler(stores.Budgets))
+	mux.HandleFunc("GET /budgets/category/{category}", getBudgetByCategoryHandler(stores.Budgets))
+	mux.HandleFunc("POST /budgets", createBudgetHandler(stores.Budgets))
+	mux.HandleFunc("PUT /budgets/{id}", updateBudgetHandler(stores.Budgets))
+	mux.HandleFunc("DELETE /budgets/{id}", requireOwner("delete budgets", deleteBudgetHandler(stores.Budgets)))
 
-	// Wrap the mux with CORS middleware
-	return middleware.CORS(mux)
+	// Exchange rate routes. Rates are shared by every ledger, so they are
+	// only loaded with the rates subcommand.
+	mux.HandleFunc("GET /exchange-rates", getExchangeRatesHandler(stores.ExchangeRates))
+
+	// Import routes
+	mux.HandleFunc("GET /import-profiles", getImportProfilesHandler(stores.Imports))
+	mux.HandleFunc("GET /import-profiles/{id}", getImportProfileByIDHandler(stores.Imports))
+	mux.HandleFunc("POST /import-profiles", createImportProfileHandler(stores.Imports))
+	mux.HandleFunc("PUT /import-profiles/{id}", updateImportProfileHandler(stores.Imports))
+	mux.HandleFunc("DELETE /import-profiles/{id}", deleteImportProfileHandler(stores.Imports))
+	mux.HandleFunc("POST /imports/{format}", importStatementHandler(stores.Imports))
+
+	// Recurring rule routes
+	mux.HandleFunc("GET /recurring-rules", getRecurringRulesHandler(stores.Recurring))
+	mux.HandleFunc("GET /recurring-rules/{id}", getRecurringRuleByIDHandler(stores.Recurring))
+	mux.HandleFunc("GET /recurring-rules/{id}/occurrences", getRecurringRuleOccurrencesHandler(stores.Recurring))
+	mux.HandleFunc("POST /recurring-rules", createRecurringRuleHandler(stores.Recurring))
+	mux.HandleFunc("PUT /recurring-rules/{id}", updateRecurringRuleHandler(stores.Recurring))
+	mux.HandleFunc("DELETE /recurring-rules/{id}", deleteRecurringRuleHandler(stores.Recurring))
+
+	// Tag routes
+	mux.HandleFunc("GET /tags", getTagsHandler(stores.Tags))
+	mux.HandleFunc("GET /tags/summary", getTagSummaryHandler(stores.Tags))
+	mux.HandleFunc("GET /tags/{id}", getTagByIDHandler(stores.Tags))
+	mux.HandleFunc("POST /tags", createTagHandler(stores.Tags))
+	mux.HandleFunc("PUT /tags/{id}", updateTagHandler(stores.Tags))
+	mux.HandleFunc("DELETE /tags/{id}", deleteTagHandler(stores.Tags))
+
+	// Report routes
+	mux.HandleFunc("GET /summary", getSummaryHandler(stores.Reports))
+
+	// Export routes
+	mux.HandleFunc("GET /export", getExportHandler(stores))
+
+	return mux
 }
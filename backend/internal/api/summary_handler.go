@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+)
+
+// getSummaryHandler totals expenses and incomes in one currency, converting
+// each amount at the rate for its own date. The currency defaults to
+// models.DefaultCurrency; from and to optionally bound the period.
+func getSummaryHandler(reportStore store.ReportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currency, err := currencyParam(r)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		if currency == "" {
+			currency = models.DefaultCurrency
+		}
+
+		from, err := dateParam(r, "from")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		summary, err := reportStore.GetSummary(currency, from, to)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getTagsHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tags, err := tagStore.GetTags()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if tags == nil {
+			tags = []models.Tag{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+func getTagByIDHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid tag ID"))
+			return
+		}
+
+		tag, err := tagStore.GetTagByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tag)
+	}
+}
+
+func createTagHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tag models.Tag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdTag, err := tagStore.CreateTag(tag)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdTag)
+	}
+}
+
+// updateTagHandler renames a tag on every expense and income carrying it.
+func updateTagHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid tag ID"))
+			return
+		}
+
+		var tag models.Tag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		tag.ID = id
+
+		updatedTag, err := tagStore.UpdateTag(tag)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedTag)
+	}
+}
+
+func deleteTagHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid tag ID"))
+			return
+		}
+
+		if err := tagStore.DeleteTag(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getTagSummaryHandler totals spending and income per tag over an optional
+// from/to period, converted into the currency parameter.
+func getTagSummaryHandler(tagStore store.TagStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currency, err := currencyParam(r)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		if currency == "" {
+			currency = models.DefaultCurrency
+		}
+
+		from, err := dateParam(r, "from")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		totals, err := tagStore.GetTagSummary(currency, from, to)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if totals == nil {
+			totals = []models.TagTotal{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(totals)
+	}
+}
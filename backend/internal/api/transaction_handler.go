@@ -0,0 +1,28 @@
+package api
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+)
+
+// getTransactionsHandler lists expenses and incomes as one ledger of signed
+// amounts with a running balance per currency, oldest first unless a sort
+// is given. It takes the same filters and paging as the other lists.
+func getTransactionsHandler(transactionStore store.TransactionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r, models.TransactionSortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		transactions, total, err := transactionStore.ListTransactions(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeList(w, transactions, total)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+)
+
+func getTransfersHandler(transferStore store.TransferStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r, models.TransferSortFields)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		transfers, total, err := transferStore.ListTransfers(opts)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeList(w, transfers, total)
+	}
+}
+
+func getTransferByIDHandler(transferStore store.TransferStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid transfer ID"))
+			return
+		}
+
+		transfer, err := transferStore.GetTransferByID(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transfer)
+	}
+}
+
+func createTransferHandler(transferStore store.TransferStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var transfer models.Transfer
+		if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		createdTransfer, err := transferStore.CreateTransfer(transfer)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createdTransfer)
+	}
+}
+
+func updateTransferHandler(transferStore store.TransferStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid transfer ID"))
+			return
+		}
+
+		var transfer models.Transfer
+		if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		transfer.ID = id
+
+		updatedTransfer, err := transferStore.UpdateTransfer(transfer)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updatedTransfer)
+	}
+}
+
+func deleteTransferHandler(transferStore store.TransferStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeBadRequest(w, models.NewValidationError("id", "Invalid transfer ID"))
+			return
+		}
+
+		if err := transferStore.DeleteTransfer(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,85 @@
+// Package blob stores the content of uploaded files, such as expense
+// receipts, under opaque keys. The Store interface lets the content live on
+// the local filesystem, in memory for tests, or in any other backend.
+package blob
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no content is stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps content under keys made by Save. Keys are lowercase hex, so
+// drivers can use them directly as file or object names.
+type Store interface {
+	// Put stores the content read from r under key, replacing any content
+	// already there.
+	Put(key string, r io.Reader) error
+	// Open returns the content stored under key, or ErrNotFound.
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key. Deleting a missing key is
+	// not an error.
+	Delete(key string) error
+}
+
+// Info describes content saved by Save.
+type Info struct {
+	Key    string
+	Size   int64
+	SHA256 string
+}
+
+// Save stores the content read from r under a new random key and returns
+// the key with the content's size and SHA-256 digest.
+func Save(store Store, r io.Reader) (Info, error) {
+	key, err := newKey()
+	if err != nil {
+		return Info{}, err
+	}
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, hash)}
+	if err := store.Put(key, counter); err != nil {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// newKey returns a random 128-bit key.
+func newKey() (string, error) {
+	var key [16]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key[:]), nil
+}
+
+// validKey reports whether key could have been made by Save, which keeps
+// keys from escaping a driver's directory.
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,366 @@
+// Package export writes the whole ledger out as JSON, as a ZIP of CSV files
+// or as an XLSX workbook.
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+)
+
+// Formats lists the supported export formats with their content types.
+var Formats = map[string]string{
+	"json": "application/json",
+	"csv":  "application/zip",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// FileExtensions gives the file name extension of each format.
+var FileExtensions = map[string]string{"json": ".json", "csv": ".zip", "xlsx": ".xlsx"}
+
+// Ledger is everything an export contains. Expenses and incomes are limited
+// to the dates from From to To, and budgets to the periods overlapping them;
+// categories and accounts are always exported in full.
+//
+// A ledger from Load holds its budgets, expenses and incomes, while one from
+// Open reads them from its stores a page at a time as they are written.
+type Ledger struct {
+	ExportedAt time.Time  `json:"exported_at"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+
+	Categories []models.Category `json:"categories"`
+	Accounts   []models.Account  `json:"accounts"`
+	Budgets    []models.Budget   `json:"budgets"`
+	Expenses   []models.Expense  `json:"expenses"`
+	Incomes    []models.Income   `json:"incomes"`
+
+	stores   store.Stores
+	from, to time.Time
+}
+
+// pageSize is the number of budgets, expenses or incomes an opened ledger
+// reads at a time.
+const pageSize = 500
+
+// Open reads the categories and accounts of the ledger from stores, leaving
+// the budgets, expenses and incomes to be read as they are written, so memory
+// does not grow with the ledger. Zero from or to dates leave that end of the
+// range open.
+func Open(stores store.Stores, from, to time.Time) (Ledger, error) {
+	ledger := Ledger{ExportedAt: time.Now().UTC(), stores: stores, from: from, to: to}
+	if !from.IsZero() {
+		ledger.From = &from
+	}
+	if !to.IsZero() {
+		ledger.To = &to
+	}
+
+	var err error
+	if ledger.Categories, err = stores.Categories.GetCategories(); err != nil {
+		return Ledger{}, err
+	}
+	if ledger.Accounts, err = stores.Accounts.GetAccounts(); err != nil {
+		return Ledger{}, err
+	}
+	return ledger, nil
+}
+
+// Load reads the whole ledger from stores into memory. Only the XLSX export
+// needs it: spreadsheets open workbooks whole and cap sheets at about a
+// million rows, so a workbook is only useful at sizes that fit in memory.
+func Load(stores store.Stores, from, to time.Time) (Ledger, error) {
+	ledger, err := Open(stores, from, to)
+	if err != nil {
+		return Ledger{}, err
+	}
+	if ledger.Budgets, _, err = stores.Budgets.ListBudgets(ledger.budgetOptions()); err != nil {
+		return Ledger{}, err
+	}
+	if ledger.Expenses, _, err = stores.Expenses.ListExpenses(ledger.byDate()); err != nil {
+		return Ledger{}, err
+	}
+	if ledger.Incomes, _, err = stores.Incomes.ListIncomes(ledger.byDate()); err != nil {
+		return Ledger{}, err
+	}
+	ledger.stores = store.Stores{}
+	return ledger, nil
+}
+
+func (ledger Ledger) budgetOptions() models.ListOptions {
+	return models.ListOptions{From: ledger.from, To: ledger.to, Sort: []models.SortField{{Field: "start_date"}, {Field: "id"}}}
+}
+
+func (ledger Ledger) byDate() models.ListOptions {
+	return models.ListOptions{From: ledger.from, To: ledger.to, Sort: []models.SortField{{Field: "date"}, {Field: "id"}}}
+}
+
+// budgets calls fn with each page of the ledger's budgets, in order.
+func (ledger Ledger) budgets(fn func([]models.Budget) error) error {
+	if ledger.stores.Budgets == nil {
+		return fn(ledger.Budgets)
+	}
+	return pages(ledger.stores.Budgets.ListBudgets, ledger.budgetOptions(), fn)
+}
+
+// expenses calls fn with each page of the ledger's expenses, in order.
+func (ledger Ledger) expenses(fn func([]models.Expense) error) error {
+	if ledger.stores.Expenses == nil {
+		return fn(ledger.Expenses)
+	}
+	return pages(ledger.stores.Expenses.ListExpenses, ledger.byDate(), fn)
+}
+
+// incomes calls fn with each page of the ledger's incomes, in order.
+func (ledger Ledger) incomes(fn func([]models.Income) error) error {
+	if ledger.stores.Incomes == nil {
+		return fn(ledger.Incomes)
+	}
+	return pages(ledger.stores.Incomes.ListIncomes, ledger.byDate(), fn)
+}
+
+// pages calls fn with each page of a list, in order, until a page comes
+// back short.
+func pages[T any](list func(models.ListOptions) ([]T, int, error), opts models.ListOptions, fn func([]T) error) error {
+	opts.Limit = pageSize
+	for {
+		items, _, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if err := fn(items); err != nil {
+			return err
+		}
+		if len(items) < pageSize {
+			return nil
+		}
+		opts.Offset += pageSize
+	}
+}
+
+// all passes items to fn as a single page.
+func all[T any](items []T) func(fn func([]T) error) error {
+	return func(fn func([]T) error) error { return fn(items) }
+}
+
+// Write writes the ledger to w in format, one of the keys of Formats.
+func Write(w io.Writer, ledger Ledger, format string) error {
+	switch format {
+	case "json":
+		return WriteJSON(w, ledger)
+	case "csv":
+		return WriteCSV(w, ledger)
+	case "xlsx":
+		return WriteXLSX(w, ledger)
+	default:
+		return models.NewValidationError("format", "unsupported export format %q (expected csv, json or xlsx)", format)
+	}
+}
+
+// WriteJSON writes the ledger as one JSON object, encoding entries one at a
+// time rather than building the whole document in memory.
+func WriteJSON(w io.Writer, ledger Ledger) error {
+	bw := bufio.NewWriter(w)
+	header, err := json.Marshal(struct {
+		ExportedAt time.Time  `json:"exported_at"`
+		From       *time.Time `json:"from,omitempty"`
+		To         *time.Time `json:"to,omitempty"`
+	}{ledger.ExportedAt, ledger.From, ledger.To})
+	if err != nil {
+		return err
+	}
+	// Reopen the header object to append the arrays
+	bw.Write(header[:len(header)-1])
+	if err := writeJSONArray(bw, "categories", all(ledger.Categories)); err != nil {
+		return err
+	}
+	if err := writeJSONArray(bw, "accounts", all(ledger.Accounts)); err != nil {
+		return err
+	}
+	if err := writeJSONArray(bw, "budgets", ledger.budgets); err != nil {
+		return err
+	}
+	if err := writeJSONArray(bw, "expenses", ledger.expenses); err != nil {
+		return err
+	}
+	if err := writeJSONArray(bw, "incomes", ledger.incomes); err != nil {
+		return err
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func writeJSONArray[T any](w *bufio.Writer, name string, each func(fn func([]T) error) error) error {
+	fmt.Fprintf(w, ",%q:[", name)
+	first := true
+	err := each(func(items []T) error {
+		for _, item := range items {
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			w.Write(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteString("]")
+	return err
+}
+
+// WriteCSV writes a ZIP archive holding one CSV file per table.
+func WriteCSV(w io.Writer, ledger Ledger) error {
+	archive := zip.NewWriter(w)
+	for _, t := range ledger.tables() {
+		file, err := archive.Create(t.name + ".csv")
+		if err != nil {
+			return err
+		}
+		writer := csv.NewWriter(file)
+		writer.Write(t.header)
+		err = t.rows(func(row []any) error {
+			record := make([]string, len(row))
+			for i, value := range row {
+				record[i] = formatCell(value)
+			}
+			return writer.Write(record)
+		})
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// table is one sheet or CSV file of an export. Cells hold a string, an
+// int64, a models.Money, a time.Time or nil.
+type table struct {
+	name   string
+	header []string
+
+	// rows calls emit with each row of the table, in order.
+	rows func(emit func(row []any) error) error
+}
+
+// eachRow builds the rows function of a table whose entries each calls fn
+// with page by page, laying out each entry with row.
+func eachRow[T any](each func(fn func([]T) error) error, row func(T) []any) func(emit func([]any) error) error {
+	return func(emit func([]any) error) error {
+		return each(func(items []T) error {
+			for _, item := range items {
+				if err := emit(row(item)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// tables lays the ledger out as tables, resolving category and account IDs
+// to names.
+func (ledger Ledger) tables() []table {
+	categoryNames := map[int64]string{}
+	for _, category := range ledger.Categories {
+		categoryNames[category.ID] = category.Name
+	}
+	categories := table{name: "categories", header: []string{"id", "name", "description", "parent_id"},
+		rows: eachRow(all(ledger.Categories), func(category models.Category) []any {
+			return []any{category.ID, category.Name, category.Description, optionalID(category.ParentID)}
+		})}
+
+	accountNames := map[int64]string{}
+	for _, account := range ledger.Accounts {
+		accountNames[account.ID] = account.Name
+	}
+	accounts := table{name: "accounts", header: []string{"id", "name", "type", "currency", "opening_balance", "balance"},
+		rows: eachRow(all(ledger.Accounts), func(account models.Account) []any {
+			return []any{account.ID, account.Name, string(account.Type), account.Currency, account.OpeningBalance, account.Balance}
+		})}
+	account := func(id *int64) (any, any) {
+		if id == nil {
+			return nil, nil
+		}
+		return *id, accountNames[*id]
+	}
+
+	budgets := table{name: "budgets", header: []string{"id", "category_id", "category", "amount", "spent", "currency", "start_date", "end_date"},
+		rows: eachRow(ledger.budgets, func(budget models.Budget) []any {
+			return []any{budget.ID, budget.CategoryID, categoryNames[budget.CategoryID], budget.Amount, budget.Spent,
+				budget.Currency, budget.StartDate, budget.EndDate}
+		})}
+
+	expenses := table{name: "expenses", header: []string{"id", "date", "description", "amount", "currency", "category_id", "category",
+		"account_id", "account", "recurring_rule_id"},
+		rows: eachRow(ledger.expenses, func(expense models.Expense) []any {
+			accountID, accountName := account(expense.AccountID)
+			return []any{expense.ID, expense.Date, expense.Description, expense.Amount, expense.Currency,
+				expense.CategoryID, categoryNames[expense.CategoryID], accountID, accountName, optionalID(expense.RecurringRuleID)}
+		})}
+
+	// The lines of split expenses, whose amounts add up to the expense's,
+	// read with a second pass over the expenses
+	splits := table{name: "splits", header: []string{"id", "expense_id", "category_id", "category", "amount", "memo"},
+		rows: func(emit func([]any) error) error {
+			return ledger.expenses(func(page []models.Expense) error {
+				for _, expense := range page {
+					for _, split := range expense.Splits {
+						if err := emit([]any{split.ID, expense.ID, split.CategoryID, categoryNames[split.CategoryID], split.Amount, split.Memo}); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+		}}
+
+	incomes := table{name: "incomes", header: []string{"id", "date", "source", "amount", "currency", "account_id", "account", "recurring_rule_id"},
+		rows: eachRow(ledger.incomes, func(income models.Income) []any {
+			accountID, accountName := account(income.AccountID)
+			return []any{income.ID, income.Date, income.Source, income.Amount, income.Currency,
+				accountID, accountName, optionalID(income.RecurringRuleID)}
+		})}
+
+	return []table{expenses, splits, incomes, budgets, categories, accounts}
+}
+
+func optionalID(id *int64) any {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+// formatCell writes a cell as CSV text. Dates are written as YYYY-MM-DD and
+// amounts with two decimal places.
+func formatCell(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case models.Money:
+		return v.String()
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return ""
+}
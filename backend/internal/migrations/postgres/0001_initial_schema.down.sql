@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS Report;
+DROP TABLE IF EXISTS Income;
+DROP TABLE IF EXISTS Expense;
+DROP TABLE IF EXISTS Budget;
+DROP TABLE IF EXISTS Category;
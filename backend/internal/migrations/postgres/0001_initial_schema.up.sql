@@ -0,0 +1,49 @@
+-- Baseline schema. Uses IF NOT EXISTS so deployments created by the old
+-- initializeDatabase bootstrap can adopt the migration history in place.
+
+-- Table: Category
+CREATE TABLE IF NOT EXISTS Category (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT
+);
+
+-- Table: Budget
+CREATE TABLE IF NOT EXISTS Budget (
+    id SERIAL PRIMARY KEY,
+    category_id INT REFERENCES Category(id) ON DELETE CASCADE,
+    amount NUMERIC(10, 2) NOT NULL
+);
+
+-- Table: Expense
+CREATE TABLE IF NOT EXISTS Expense (
+    id SERIAL PRIMARY KEY,
+    category_id INT REFERENCES Category(id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL,
+    date DATE NOT NULL
+);
+
+-- Table: Income
+CREATE TABLE IF NOT EXISTS Income (
+    id SERIAL PRIMARY KEY,
+    amount NUMERIC(10, 2) NOT NULL,
+    date DATE NOT NULL,
+    source VARCHAR(255) NOT NULL
+);
+
+-- Table: Report
+CREATE TABLE IF NOT EXISTS Report (
+    id SERIAL PRIMARY KEY,
+    expense_id INT REFERENCES Expense(id) ON DELETE CASCADE,
+    income_id INT REFERENCES Income(id) ON DELETE CASCADE
+);
+
+-- Ensure the "Other" category exists
+INSERT INTO Category (id, name, description)
+VALUES (1, 'Other', 'Default category for uncategorized items')
+ON CONFLICT (id) DO NOTHING;
+
+-- The explicit id above does not advance the serial sequence, so move it
+-- past the seeded row before the first category is created.
+SELECT setval(pg_get_serial_sequence('category', 'id'), (SELECT MAX(id) FROM Category));
@@ -0,0 +1,4 @@
+ALTER TABLE Budget
+    DROP COLUMN IF EXISTS end_date,
+    DROP COLUMN IF EXISTS start_date,
+    DROP COLUMN IF EXISTS spent;
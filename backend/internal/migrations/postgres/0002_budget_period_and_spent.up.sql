@@ -0,0 +1,26 @@
+-- Budgets track a period and the amount spent in it. Older deployments were
+-- created without these columns, so add them and backfill existing rows with
+-- the current calendar month.
+ALTER TABLE Budget
+    ADD COLUMN IF NOT EXISTS spent NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS start_date DATE,
+    ADD COLUMN IF NOT EXISTS end_date DATE;
+
+UPDATE Budget
+SET start_date = date_trunc('month', CURRENT_DATE)::date
+WHERE start_date IS NULL;
+
+UPDATE Budget
+SET end_date = (date_trunc('month', start_date) + INTERVAL '1 month' - INTERVAL '1 day')::date
+WHERE end_date IS NULL;
+
+UPDATE Budget b
+SET spent = (
+    SELECT COALESCE(SUM(e.amount), 0)
+    FROM Expense e
+    WHERE e.category_id = b.category_id AND e.date >= b.start_date AND e.date <= b.end_date
+);
+
+ALTER TABLE Budget
+    ALTER COLUMN start_date SET NOT NULL,
+    ALTER COLUMN end_date SET NOT NULL;
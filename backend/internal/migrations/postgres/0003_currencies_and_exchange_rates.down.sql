@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ExchangeRate;
+ALTER TABLE Budget DROP COLUMN IF EXISTS currency;
+ALTER TABLE Income DROP COLUMN IF EXISTS currency;
+ALTER TABLE Expense DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS income_account_date_idx;
+DROP INDEX IF EXISTS expense_account_date_idx;
+ALTER TABLE Income DROP COLUMN IF EXISTS account_id;
+ALTER TABLE Expense DROP COLUMN IF EXISTS account_id;
+DROP TABLE IF EXISTS Account;
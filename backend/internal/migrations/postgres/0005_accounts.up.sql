@@ -0,0 +1,17 @@
+-- Table: Account
+-- Where money is held or owed. Balances are not stored: they are the
+-- opening balance plus the account's incomes minus its expenses.
+CREATE TABLE IF NOT EXISTS Account (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('cash', 'checking', 'savings', 'credit_card')),
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    opening_balance NUMERIC(12, 2) NOT NULL DEFAULT 0
+);
+
+-- Existing rows are not assigned to an account. An account cannot be
+-- deleted while entries still reference it.
+ALTER TABLE Expense ADD COLUMN IF NOT EXISTS account_id INT REFERENCES Account(id);
+ALTER TABLE Income ADD COLUMN IF NOT EXISTS account_id INT REFERENCES Account(id);
+CREATE INDEX IF NOT EXISTS expense_account_date_idx ON Expense (account_id, date);
+CREATE INDEX IF NOT EXISTS income_account_date_idx ON Income (account_id, date);
@@ -0,0 +1,18 @@
+-- Table: Transfer
+-- Money moved between two accounts. amount leaves from_account_id in its
+-- currency and to_amount arrives in to_account_id's currency; the two are
+-- equal unless the currencies differ. Transfers are neither spending nor
+-- income, so budgets and summaries ignore them.
+CREATE TABLE IF NOT EXISTS Transfer (
+    id SERIAL PRIMARY KEY,
+    from_account_id INT NOT NULL REFERENCES Account(id),
+    to_account_id INT NOT NULL REFERENCES Account(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    to_amount NUMERIC(12, 2) NOT NULL CHECK (to_amount > 0),
+    date DATE NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    CHECK (from_account_id <> to_account_id)
+);
+
+CREATE INDEX IF NOT EXISTS transfer_from_account_date_idx ON Transfer (from_account_id, date);
+CREATE INDEX IF NOT EXISTS transfer_to_account_date_idx ON Transfer (to_account_id, date);
@@ -0,0 +1,23 @@
+-- Table: ImportProfile
+-- How to read one bank's CSV statements. Columns are named by their
+-- header. Amounts come either from one signed amount_column, read with
+-- sign_convention, or from separate debit_column and credit_column.
+CREATE TABLE IF NOT EXISTS ImportProfile (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    delimiter CHAR(1) NOT NULL DEFAULT ',',
+    skip_rows INT NOT NULL DEFAULT 0 CHECK (skip_rows >= 0),
+    date_column VARCHAR(255) NOT NULL,
+    date_format VARCHAR(50) NOT NULL DEFAULT 'YYYY-MM-DD',
+    description_column VARCHAR(255) NOT NULL,
+    amount_column VARCHAR(255) NOT NULL DEFAULT '',
+    debit_column VARCHAR(255) NOT NULL DEFAULT '',
+    credit_column VARCHAR(255) NOT NULL DEFAULT '',
+    sign_convention VARCHAR(20) NOT NULL DEFAULT 'negative_expense'
+        CHECK (sign_convention IN ('negative_expense', 'positive_expense')),
+    decimal_separator CHAR(1) NOT NULL DEFAULT '.' CHECK (decimal_separator IN ('.', ',')),
+    currency_column VARCHAR(255) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL DEFAULT '',
+    category_id INT NOT NULL DEFAULT 1 REFERENCES Category(id) ON DELETE SET DEFAULT,
+    account_id INT REFERENCES Account(id) ON DELETE SET NULL
+);
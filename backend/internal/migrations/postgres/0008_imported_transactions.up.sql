@@ -0,0 +1,11 @@
+-- Table: ImportedTransaction
+-- Remembers each statement transaction that was imported, by the bank's
+-- OFX FITID or a hash of its QIF contents, so importing the same file again
+-- skips it. The link to the created expense or income is cleared when that
+-- entry is deleted, and the transaction stays marked as imported.
+CREATE TABLE IF NOT EXISTS ImportedTransaction (
+    external_id TEXT PRIMARY KEY,
+    expense_id INT REFERENCES Expense(id) ON DELETE SET NULL,
+    income_id INT REFERENCES Income(id) ON DELETE SET NULL,
+    imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,17 @@
+-- Table: CategoryRule
+-- Assigns category_id to expenses created without a category, and to
+-- imported expenses, that match every condition given. Rules are tried in
+-- ascending priority and the first match wins.
+CREATE TABLE IF NOT EXISTS CategoryRule (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    category_id INT NOT NULL REFERENCES Category(id) ON DELETE CASCADE,
+    description_contains VARCHAR(255) NOT NULL DEFAULT '',
+    description_pattern VARCHAR(255) NOT NULL DEFAULT '',
+    min_amount NUMERIC(12, 2),
+    max_amount NUMERIC(12, 2),
+    account_id INT REFERENCES Account(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS category_rule_priority_idx ON CategoryRule (priority, id);
@@ -0,0 +1,9 @@
+-- Table: DismissedDuplicate
+-- Pairs of expenses a user reviewed and found not to be duplicates, so
+-- they are not flagged again. expense_id is the smaller of the two ids.
+CREATE TABLE IF NOT EXISTS DismissedDuplicate (
+    expense_id INT NOT NULL REFERENCES Expense(id) ON DELETE CASCADE,
+    duplicate_id INT NOT NULL REFERENCES Expense(id) ON DELETE CASCADE,
+    PRIMARY KEY (expense_id, duplicate_id),
+    CHECK (expense_id < duplicate_id)
+);
@@ -0,0 +1,5 @@
+-- Categories form a tree: parent_id names the category a sub-category
+-- belongs to, such as Food for Groceries. Budgets on a category cover the
+-- expenses of all its sub-categories.
+ALTER TABLE Category ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES Category(id);
+CREATE INDEX IF NOT EXISTS category_parent_idx ON Category (parent_id);
@@ -0,0 +1,35 @@
+-- Reverting fails if two users have used the same name, or imported the
+-- same statement transaction.
+DROP INDEX IF EXISTS imported_transaction_user_external_idx;
+DROP INDEX IF EXISTS tag_user_name_idx;
+DROP INDEX IF EXISTS import_profile_user_name_idx;
+DROP INDEX IF EXISTS account_user_name_idx;
+DROP INDEX IF EXISTS category_user_name_idx;
+
+ALTER TABLE ImportedTransaction ADD CONSTRAINT importedtransaction_pkey PRIMARY KEY (external_id);
+ALTER TABLE Tag ADD CONSTRAINT tag_name_key UNIQUE (name);
+ALTER TABLE ImportProfile ADD CONSTRAINT importprofile_name_key UNIQUE (name);
+ALTER TABLE Account ADD CONSTRAINT account_name_key UNIQUE (name);
+ALTER TABLE Category ADD CONSTRAINT category_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS category_rule_user_idx;
+DROP INDEX IF EXISTS recurring_rule_user_idx;
+DROP INDEX IF EXISTS transfer_user_date_idx;
+DROP INDEX IF EXISTS budget_user_category_idx;
+DROP INDEX IF EXISTS income_user_date_idx;
+DROP INDEX IF EXISTS expense_user_date_idx;
+
+ALTER TABLE Tag DROP COLUMN IF EXISTS user_id;
+ALTER TABLE CategoryRule DROP COLUMN IF EXISTS user_id;
+ALTER TABLE ImportedTransaction DROP COLUMN IF EXISTS user_id;
+ALTER TABLE ImportProfile DROP COLUMN IF EXISTS user_id;
+ALTER TABLE RecurringRule DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Transfer DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Account DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Budget DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Income DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Expense DROP COLUMN IF EXISTS user_id;
+ALTER TABLE Category DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS Session;
+DROP TABLE IF EXISTS UserAccount;
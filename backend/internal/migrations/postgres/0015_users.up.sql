@@ -0,0 +1,58 @@
+-- Table: UserAccount
+-- People who can sign in, by email. Passwords are stored as salted PBKDF2
+-- hashes. (USER is a reserved word in Postgres.)
+CREATE TABLE IF NOT EXISTS UserAccount (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(254) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Table: Session
+-- Signed-in sessions, kept by the SHA-256 hash of their bearer token so a
+-- leaked table does not leak usable tokens.
+CREATE TABLE IF NOT EXISTS Session (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES UserAccount(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_user_idx ON Session (user_id);
+
+-- Everything recorded belongs to a user. Rows recorded before there were
+-- users have no owner, and stay hidden until an operator gives them to a
+-- user with "migrate adopt", except the 'Other' category, which stays shared
+-- by everyone.
+ALTER TABLE Category ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Expense ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Account ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportProfile ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportedTransaction ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Tag ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS expense_user_date_idx ON Expense (user_id, date);
+CREATE INDEX IF NOT EXISTS income_user_date_idx ON Income (user_id, date);
+CREATE INDEX IF NOT EXISTS budget_user_category_idx ON Budget (user_id, category_id);
+CREATE INDEX IF NOT EXISTS transfer_user_date_idx ON Transfer (user_id, date);
+CREATE INDEX IF NOT EXISTS recurring_rule_user_idx ON RecurringRule (user_id);
+CREATE INDEX IF NOT EXISTS category_rule_user_idx ON CategoryRule (user_id, priority, id);
+
+-- Names, and the transactions a statement import skips, are unique per user
+-- rather than across everyone
+ALTER TABLE Category DROP CONSTRAINT IF EXISTS category_name_key;
+ALTER TABLE Account DROP CONSTRAINT IF EXISTS account_name_key;
+ALTER TABLE ImportProfile DROP CONSTRAINT IF EXISTS importprofile_name_key;
+ALTER TABLE Tag DROP CONSTRAINT IF EXISTS tag_name_key;
+ALTER TABLE ImportedTransaction DROP CONSTRAINT IF EXISTS importedtransaction_pkey;
+
+CREATE UNIQUE INDEX IF NOT EXISTS category_user_name_idx ON Category (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS account_user_name_idx ON Account (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS import_profile_user_name_idx ON ImportProfile (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS tag_user_name_idx ON Tag (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS imported_transaction_user_external_idx ON ImportedTransaction (user_id, external_id);
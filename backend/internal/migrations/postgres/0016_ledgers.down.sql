@@ -0,0 +1,65 @@
+-- Reverting hands each ledger's data to its first owner, and fails if an
+-- owner has two ledgers that use the same name.
+ALTER INDEX IF EXISTS imported_transaction_ledger_external_idx RENAME TO imported_transaction_user_external_idx;
+ALTER INDEX IF EXISTS tag_ledger_name_idx RENAME TO tag_user_name_idx;
+ALTER INDEX IF EXISTS import_profile_ledger_name_idx RENAME TO import_profile_user_name_idx;
+ALTER INDEX IF EXISTS account_ledger_name_idx RENAME TO account_user_name_idx;
+ALTER INDEX IF EXISTS category_ledger_name_idx RENAME TO category_user_name_idx;
+ALTER INDEX IF EXISTS category_rule_ledger_idx RENAME TO category_rule_user_idx;
+ALTER INDEX IF EXISTS recurring_rule_ledger_idx RENAME TO recurring_rule_user_idx;
+ALTER INDEX IF EXISTS transfer_ledger_date_idx RENAME TO transfer_user_date_idx;
+ALTER INDEX IF EXISTS budget_ledger_category_idx RENAME TO budget_user_category_idx;
+ALTER INDEX IF EXISTS income_ledger_date_idx RENAME TO income_user_date_idx;
+ALTER INDEX IF EXISTS expense_ledger_date_idx RENAME TO expense_user_date_idx;
+
+ALTER TABLE Tag DROP CONSTRAINT IF EXISTS tag_ledger_id_fkey;
+ALTER TABLE CategoryRule DROP CONSTRAINT IF EXISTS categoryrule_ledger_id_fkey;
+ALTER TABLE ImportedTransaction DROP CONSTRAINT IF EXISTS importedtransaction_ledger_id_fkey;
+ALTER TABLE ImportProfile DROP CONSTRAINT IF EXISTS importprofile_ledger_id_fkey;
+ALTER TABLE RecurringRule DROP CONSTRAINT IF EXISTS recurringrule_ledger_id_fkey;
+ALTER TABLE Transfer DROP CONSTRAINT IF EXISTS transfer_ledger_id_fkey;
+ALTER TABLE Account DROP CONSTRAINT IF EXISTS account_ledger_id_fkey;
+ALTER TABLE Budget DROP CONSTRAINT IF EXISTS budget_ledger_id_fkey;
+ALTER TABLE Income DROP CONSTRAINT IF EXISTS income_ledger_id_fkey;
+ALTER TABLE Expense DROP CONSTRAINT IF EXISTS expense_ledger_id_fkey;
+ALTER TABLE Category DROP CONSTRAINT IF EXISTS category_ledger_id_fkey;
+
+UPDATE Tag SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Tag.ledger_id AND m.role = 'owner');
+UPDATE CategoryRule SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = CategoryRule.ledger_id AND m.role = 'owner');
+UPDATE ImportedTransaction SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = ImportedTransaction.ledger_id AND m.role = 'owner');
+UPDATE ImportProfile SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = ImportProfile.ledger_id AND m.role = 'owner');
+UPDATE RecurringRule SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = RecurringRule.ledger_id AND m.role = 'owner');
+UPDATE Transfer SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Transfer.ledger_id AND m.role = 'owner');
+UPDATE Account SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Account.ledger_id AND m.role = 'owner');
+UPDATE Budget SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Budget.ledger_id AND m.role = 'owner');
+UPDATE Income SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Income.ledger_id AND m.role = 'owner');
+UPDATE Expense SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Expense.ledger_id AND m.role = 'owner');
+UPDATE Category SET ledger_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Category.ledger_id AND m.role = 'owner');
+
+ALTER TABLE Tag RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE CategoryRule RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE ImportedTransaction RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE ImportProfile RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE RecurringRule RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Transfer RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Account RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Budget RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Income RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Expense RENAME COLUMN ledger_id TO user_id;
+ALTER TABLE Category RENAME COLUMN ledger_id TO user_id;
+
+ALTER TABLE Tag ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportedTransaction ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportProfile ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Account ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Expense ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Category ADD FOREIGN KEY (user_id) REFERENCES UserAccount(id) ON DELETE CASCADE;
+
+DROP TABLE IF EXISTS LedgerInvitation;
+DROP TABLE IF EXISTS LedgerMember;
+DROP TABLE IF EXISTS Ledger;
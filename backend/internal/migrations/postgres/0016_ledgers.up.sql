@@ -0,0 +1,92 @@
+-- Table: Ledger
+-- A set of categories, budgets, expenses and everything else recorded,
+-- shared by its members.
+CREATE TABLE IF NOT EXISTS Ledger (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Table: LedgerMember
+-- Who can use a ledger: owners manage it and its members, editors change
+-- its data and viewers only read it.
+CREATE TABLE IF NOT EXISTS LedgerMember (
+    ledger_id INT NOT NULL REFERENCES Ledger(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES UserAccount(id) ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (ledger_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_member_user_idx ON LedgerMember (user_id);
+
+-- Table: LedgerInvitation
+-- Pending invitations to join a ledger, kept by the SHA-256 hash of their
+-- token. Accepting an invitation deletes it.
+CREATE TABLE IF NOT EXISTS LedgerInvitation (
+    id SERIAL PRIMARY KEY,
+    ledger_id INT NOT NULL REFERENCES Ledger(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    invited_by INT REFERENCES UserAccount(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ledger_invitation_ledger_idx ON LedgerInvitation (ledger_id);
+
+-- Every user gets a personal ledger, under the same id as the user, that
+-- they own and that takes over their data
+INSERT INTO Ledger (id, name, created_at) SELECT id, 'Personal', created_at FROM UserAccount;
+SELECT setval(pg_get_serial_sequence('ledger', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM Ledger;
+INSERT INTO LedgerMember (ledger_id, user_id, role, created_at) SELECT id, id, 'owner', created_at FROM UserAccount;
+
+-- Everything recorded belongs to a ledger instead of a user. Renaming the
+-- column keeps its indexes, so only its foreign key is replaced.
+ALTER TABLE Category RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Expense RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Income RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Budget RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Account RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Transfer RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE RecurringRule RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE ImportProfile RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE ImportedTransaction RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE CategoryRule RENAME COLUMN user_id TO ledger_id;
+ALTER TABLE Tag RENAME COLUMN user_id TO ledger_id;
+
+ALTER TABLE Category DROP CONSTRAINT IF EXISTS category_user_id_fkey;
+ALTER TABLE Expense DROP CONSTRAINT IF EXISTS expense_user_id_fkey;
+ALTER TABLE Income DROP CONSTRAINT IF EXISTS income_user_id_fkey;
+ALTER TABLE Budget DROP CONSTRAINT IF EXISTS budget_user_id_fkey;
+ALTER TABLE Account DROP CONSTRAINT IF EXISTS account_user_id_fkey;
+ALTER TABLE Transfer DROP CONSTRAINT IF EXISTS transfer_user_id_fkey;
+ALTER TABLE RecurringRule DROP CONSTRAINT IF EXISTS recurringrule_user_id_fkey;
+ALTER TABLE ImportProfile DROP CONSTRAINT IF EXISTS importprofile_user_id_fkey;
+ALTER TABLE ImportedTransaction DROP CONSTRAINT IF EXISTS importedtransaction_user_id_fkey;
+ALTER TABLE CategoryRule DROP CONSTRAINT IF EXISTS categoryrule_user_id_fkey;
+ALTER TABLE Tag DROP CONSTRAINT IF EXISTS tag_user_id_fkey;
+
+ALTER TABLE Category ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Expense ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Account ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE ImportProfile ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE ImportedTransaction ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Tag ADD FOREIGN KEY (ledger_id) REFERENCES Ledger(id) ON DELETE CASCADE;
+
+ALTER INDEX IF EXISTS expense_user_date_idx RENAME TO expense_ledger_date_idx;
+ALTER INDEX IF EXISTS income_user_date_idx RENAME TO income_ledger_date_idx;
+ALTER INDEX IF EXISTS budget_user_category_idx RENAME TO budget_ledger_category_idx;
+ALTER INDEX IF EXISTS transfer_user_date_idx RENAME TO transfer_ledger_date_idx;
+ALTER INDEX IF EXISTS recurring_rule_user_idx RENAME TO recurring_rule_ledger_idx;
+ALTER INDEX IF EXISTS category_rule_user_idx RENAME TO category_rule_ledger_idx;
+ALTER INDEX IF EXISTS category_user_name_idx RENAME TO category_ledger_name_idx;
+ALTER INDEX IF EXISTS account_user_name_idx RENAME TO account_ledger_name_idx;
+ALTER INDEX IF EXISTS import_profile_user_name_idx RENAME TO import_profile_ledger_name_idx;
+ALTER INDEX IF EXISTS tag_user_name_idx RENAME TO tag_ledger_name_idx;
+ALTER INDEX IF EXISTS imported_transaction_user_external_idx RENAME TO imported_transaction_ledger_external_idx;
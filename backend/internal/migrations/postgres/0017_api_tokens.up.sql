@@ -0,0 +1,17 @@
+-- Table: ApiToken
+-- Long-lived bearer tokens for scripts, acting as their user within their
+-- space-separated scopes, such as "expenses:write reports:read". Kept by
+-- the SHA-256 hash of the token; revoked tokens stay listed.
+CREATE TABLE IF NOT EXISTS ApiToken (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES UserAccount(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS api_token_user_idx ON ApiToken (user_id);
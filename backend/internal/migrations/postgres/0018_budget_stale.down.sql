@@ -0,0 +1 @@
+ALTER TABLE Budget DROP COLUMN IF EXISTS stale;
@@ -0,0 +1,4 @@
+-- A budget is stale when its spent amount leaves out expenses that no stored
+-- exchange rate could convert into its currency. Importing rates
+-- recalculates stale budgets.
+ALTER TABLE Budget ADD COLUMN IF NOT EXISTS stale BOOLEAN NOT NULL DEFAULT FALSE;
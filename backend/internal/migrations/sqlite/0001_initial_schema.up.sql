@@ -0,0 +1,45 @@
+-- Baseline schema, kept column-for-column in step with the Postgres
+-- migrations so both backends behave the same.
+
+-- Table: Category
+CREATE TABLE IF NOT EXISTS Category (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT
+);
+
+-- Table: Budget
+CREATE TABLE IF NOT EXISTS Budget (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category_id INT REFERENCES Category(id) ON DELETE CASCADE,
+    amount NUMERIC(10, 2) NOT NULL
+);
+
+-- Table: Expense
+CREATE TABLE IF NOT EXISTS Expense (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category_id INT REFERENCES Category(id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    amount NUMERIC(10, 2) NOT NULL,
+    date DATE NOT NULL
+);
+
+-- Table: Income
+CREATE TABLE IF NOT EXISTS Income (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    amount NUMERIC(10, 2) NOT NULL,
+    date DATE NOT NULL,
+    source VARCHAR(255) NOT NULL
+);
+
+-- Table: Report
+CREATE TABLE IF NOT EXISTS Report (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    expense_id INT REFERENCES Expense(id) ON DELETE CASCADE,
+    income_id INT REFERENCES Income(id) ON DELETE CASCADE
+);
+
+-- Ensure the "Other" category exists
+INSERT INTO Category (id, name, description)
+VALUES (1, 'Other', 'Default category for uncategorized items')
+ON CONFLICT (id) DO NOTHING;
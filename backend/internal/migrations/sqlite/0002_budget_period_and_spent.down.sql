@@ -0,0 +1,3 @@
+ALTER TABLE Budget DROP COLUMN end_date;
+ALTER TABLE Budget DROP COLUMN start_date;
+ALTER TABLE Budget DROP COLUMN spent;
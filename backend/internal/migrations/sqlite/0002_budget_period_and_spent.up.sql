@@ -0,0 +1,18 @@
+-- SQLite cannot add a NOT NULL column without a constant default, so the
+-- backfilled columns are added with placeholder defaults and then filled
+-- with the current calendar month, as in the Postgres migration.
+ALTER TABLE Budget ADD COLUMN spent NUMERIC(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE Budget ADD COLUMN start_date DATE NOT NULL DEFAULT '';
+ALTER TABLE Budget ADD COLUMN end_date DATE NOT NULL DEFAULT '';
+
+UPDATE Budget
+SET start_date = date('now', 'start of month') || ' 00:00:00+00:00',
+    end_date = date('now', 'start of month', '+1 month', '-1 day') || ' 00:00:00+00:00'
+WHERE start_date = '';
+
+UPDATE Budget
+SET spent = (
+    SELECT COALESCE(SUM(e.amount), 0)
+    FROM Expense e
+    WHERE e.category_id = Budget.category_id AND e.date >= Budget.start_date AND e.date <= Budget.end_date
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ExchangeRate;
+ALTER TABLE Budget DROP COLUMN currency;
+ALTER TABLE Income DROP COLUMN currency;
+ALTER TABLE Expense DROP COLUMN currency;
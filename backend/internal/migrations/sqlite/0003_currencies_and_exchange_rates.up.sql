@@ -0,0 +1,18 @@
+-- Record the currency of every amount. Existing rows predate multi-currency
+-- support and are assumed to be in US dollars.
+ALTER TABLE Expense ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE Income ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE Budget ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Table: ExchangeRate
+-- One unit of base_currency is worth rate units of quote_currency on date.
+CREATE TABLE IF NOT EXISTS ExchangeRate (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    base_currency CHAR(3) NOT NULL,
+    quote_currency CHAR(3) NOT NULL,
+    rate NUMERIC(18, 8) NOT NULL CHECK (rate > 0),
+    UNIQUE (date, base_currency, quote_currency)
+);
+
+CREATE INDEX IF NOT EXISTS exchange_rate_pair_date_idx ON ExchangeRate (base_currency, quote_currency, date);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS income_recurring_occurrence_idx;
+DROP INDEX IF EXISTS expense_recurring_occurrence_idx;
+ALTER TABLE Income DROP COLUMN recurring_rule_id;
+ALTER TABLE Expense DROP COLUMN recurring_rule_id;
+DROP TABLE IF EXISTS RecurringRule;
@@ -0,0 +1,27 @@
+-- Table: RecurringRule
+-- A schedule plus the template of the expense or income it generates.
+-- next_date is the first occurrence not generated yet, or NULL once the
+-- schedule has ended.
+CREATE TABLE IF NOT EXISTS RecurringRule (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('expense', 'income')),
+    frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly', 'yearly')),
+    interval_count INT NOT NULL DEFAULT 1 CHECK (interval_count > 0),
+    day_of_month INT CHECK (day_of_month BETWEEN 1 AND 31),
+    start_date DATE NOT NULL,
+    end_date DATE,
+    next_date DATE,
+    category_id INT DEFAULT 1 REFERENCES Category(id) ON DELETE SET DEFAULT,
+    amount NUMERIC(10, 2) NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    description VARCHAR(255) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS recurring_rule_next_date_idx ON RecurringRule (next_date);
+
+-- Generated rows point back at their rule. The unique indexes make
+-- generation idempotent: an occurrence can only be inserted once.
+ALTER TABLE Expense ADD COLUMN recurring_rule_id INT REFERENCES RecurringRule(id) ON DELETE SET NULL;
+ALTER TABLE Income ADD COLUMN recurring_rule_id INT REFERENCES RecurringRule(id) ON DELETE SET NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS expense_recurring_occurrence_idx ON Expense (recurring_rule_id, date);
+CREATE UNIQUE INDEX IF NOT EXISTS income_recurring_occurrence_idx ON Income (recurring_rule_id, date);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS transfer_to_account_date_idx;
+DROP INDEX IF EXISTS transfer_from_account_date_idx;
+DROP TABLE IF EXISTS Transfer;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS category_parent_idx;
+ALTER TABLE Category DROP COLUMN parent_id;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS IncomeTag;
+DROP TABLE IF EXISTS ExpenseTag;
+DROP TABLE IF EXISTS Tag;
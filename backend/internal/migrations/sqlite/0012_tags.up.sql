@@ -0,0 +1,22 @@
+-- Table: Tag
+-- Free-form labels such as "vacation-2026" or "reimbursable" that cut across
+-- categories. An expense or income can carry any number of them.
+CREATE TABLE IF NOT EXISTS Tag (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS ExpenseTag (
+    expense_id INT NOT NULL REFERENCES Expense(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES Tag(id) ON DELETE CASCADE,
+    PRIMARY KEY (expense_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS IncomeTag (
+    income_id INT NOT NULL REFERENCES Income(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES Tag(id) ON DELETE CASCADE,
+    PRIMARY KEY (income_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS expense_tag_tag_idx ON ExpenseTag (tag_id);
+CREATE INDEX IF NOT EXISTS income_tag_tag_idx ON IncomeTag (tag_id);
@@ -0,0 +1,14 @@
+-- Table: ExpenseSplit
+-- The lines of an expense split across categories, such as one receipt
+-- covering groceries and household items. The line amounts add up to the
+-- expense amount, and each counts toward its own category's budgets.
+CREATE TABLE IF NOT EXISTS ExpenseSplit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    expense_id INT NOT NULL REFERENCES Expense(id) ON DELETE CASCADE,
+    category_id INT NOT NULL REFERENCES Category(id),
+    amount NUMERIC(10, 2) NOT NULL,
+    memo VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS expense_split_expense_idx ON ExpenseSplit (expense_id);
+CREATE INDEX IF NOT EXISTS expense_split_category_idx ON ExpenseSplit (category_id);
@@ -0,0 +1,16 @@
+-- Table: Attachment
+-- Files attached to an expense, such as receipts kept for reimbursement or
+-- tax. The content lives in the blob store under storage_key; the row keeps
+-- its metadata.
+CREATE TABLE IF NOT EXISTS Attachment (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    expense_id INT NOT NULL REFERENCES Expense(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(64) NOT NULL UNIQUE,
+    uploaded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachment_expense_idx ON Attachment (expense_id);
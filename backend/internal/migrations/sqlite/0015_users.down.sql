@@ -0,0 +1,91 @@
+-- Reverting fails if two users have used the same name, or imported the
+-- same statement transaction. The tables given a per-user name are rebuilt
+-- as they were, as in the up migration.
+CREATE TABLE ImportedTransaction_old (
+    external_id TEXT PRIMARY KEY,
+    expense_id INT REFERENCES Expense(id) ON DELETE SET NULL,
+    income_id INT REFERENCES Income(id) ON DELETE SET NULL,
+    imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO ImportedTransaction_old (external_id, expense_id, income_id, imported_at)
+SELECT external_id, expense_id, income_id, imported_at FROM ImportedTransaction;
+DROP TABLE ImportedTransaction;
+ALTER TABLE ImportedTransaction_old RENAME TO ImportedTransaction;
+
+CREATE TABLE Tag_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE
+);
+INSERT INTO Tag_old (id, name) SELECT id, name FROM Tag;
+DROP TABLE Tag;
+ALTER TABLE Tag_old RENAME TO Tag;
+
+CREATE TABLE ImportProfile_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    delimiter CHAR(1) NOT NULL DEFAULT ',',
+    skip_rows INT NOT NULL DEFAULT 0 CHECK (skip_rows >= 0),
+    date_column VARCHAR(255) NOT NULL,
+    date_format VARCHAR(50) NOT NULL DEFAULT 'YYYY-MM-DD',
+    description_column VARCHAR(255) NOT NULL,
+    amount_column VARCHAR(255) NOT NULL DEFAULT '',
+    debit_column VARCHAR(255) NOT NULL DEFAULT '',
+    credit_column VARCHAR(255) NOT NULL DEFAULT '',
+    sign_convention VARCHAR(20) NOT NULL DEFAULT 'negative_expense'
+        CHECK (sign_convention IN ('negative_expense', 'positive_expense')),
+    decimal_separator CHAR(1) NOT NULL DEFAULT '.' CHECK (decimal_separator IN ('.', ',')),
+    currency_column VARCHAR(255) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL DEFAULT '',
+    category_id INT NOT NULL DEFAULT 1 REFERENCES Category(id) ON DELETE SET DEFAULT,
+    account_id INT REFERENCES Account(id) ON DELETE SET NULL
+);
+INSERT INTO ImportProfile_old (id, name, delimiter, skip_rows, date_column, date_format, description_column,
+    amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column, currency,
+    category_id, account_id)
+SELECT id, name, delimiter, skip_rows, date_column, date_format, description_column,
+    amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column, currency,
+    category_id, account_id
+FROM ImportProfile;
+DROP TABLE ImportProfile;
+ALTER TABLE ImportProfile_old RENAME TO ImportProfile;
+
+CREATE TABLE Account_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('cash', 'checking', 'savings', 'credit_card')),
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    opening_balance NUMERIC(12, 2) NOT NULL DEFAULT 0
+);
+INSERT INTO Account_old (id, name, type, currency, opening_balance)
+SELECT id, name, type, currency, opening_balance FROM Account;
+DROP TABLE Account;
+ALTER TABLE Account_old RENAME TO Account;
+
+CREATE TABLE Category_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    parent_id INT REFERENCES Category(id)
+);
+INSERT INTO Category_old (id, name, description, parent_id)
+SELECT id, name, description, parent_id FROM Category;
+DROP TABLE Category;
+ALTER TABLE Category_old RENAME TO Category;
+CREATE INDEX IF NOT EXISTS category_parent_idx ON Category (parent_id);
+
+DROP INDEX IF EXISTS category_rule_user_idx;
+DROP INDEX IF EXISTS recurring_rule_user_idx;
+DROP INDEX IF EXISTS transfer_user_date_idx;
+DROP INDEX IF EXISTS budget_user_category_idx;
+DROP INDEX IF EXISTS income_user_date_idx;
+DROP INDEX IF EXISTS expense_user_date_idx;
+
+ALTER TABLE CategoryRule DROP COLUMN user_id;
+ALTER TABLE RecurringRule DROP COLUMN user_id;
+ALTER TABLE Transfer DROP COLUMN user_id;
+ALTER TABLE Budget DROP COLUMN user_id;
+ALTER TABLE Income DROP COLUMN user_id;
+ALTER TABLE Expense DROP COLUMN user_id;
+
+DROP TABLE IF EXISTS Session;
+DROP TABLE IF EXISTS UserAccount;
@@ -0,0 +1,125 @@
+-- Table: UserAccount
+-- People who can sign in, by email. Passwords are stored as salted PBKDF2
+-- hashes. (USER is a reserved word in Postgres.)
+CREATE TABLE IF NOT EXISTS UserAccount (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email VARCHAR(254) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Table: Session
+-- Signed-in sessions, kept by the SHA-256 hash of their bearer token so a
+-- leaked table does not leak usable tokens.
+CREATE TABLE IF NOT EXISTS Session (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES UserAccount(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_user_idx ON Session (user_id);
+
+-- Everything recorded belongs to a user. Rows recorded before there were
+-- users have no owner, and stay hidden until an operator gives them to a
+-- user with "migrate adopt", except the 'Other' category, which stays shared
+-- by everyone.
+ALTER TABLE Expense ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS expense_user_date_idx ON Expense (user_id, date);
+CREATE INDEX IF NOT EXISTS income_user_date_idx ON Income (user_id, date);
+CREATE INDEX IF NOT EXISTS budget_user_category_idx ON Budget (user_id, category_id);
+CREATE INDEX IF NOT EXISTS transfer_user_date_idx ON Transfer (user_id, date);
+CREATE INDEX IF NOT EXISTS recurring_rule_user_idx ON RecurringRule (user_id);
+CREATE INDEX IF NOT EXISTS category_rule_user_idx ON CategoryRule (user_id, priority, id);
+
+-- Names, and the transactions a statement import skips, are unique per user
+-- rather than across everyone. SQLite cannot drop a UNIQUE constraint, so
+-- these tables are rebuilt with their owner column; the migration runner
+-- turns foreign keys off while they are.
+CREATE TABLE Category_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    parent_id INT REFERENCES Category(id),
+    user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE
+);
+INSERT INTO Category_new (id, name, description, parent_id)
+SELECT id, name, description, parent_id FROM Category;
+DROP TABLE Category;
+ALTER TABLE Category_new RENAME TO Category;
+CREATE INDEX IF NOT EXISTS category_parent_idx ON Category (parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS category_user_name_idx ON Category (user_id, name);
+
+CREATE TABLE Account_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('cash', 'checking', 'savings', 'credit_card')),
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    opening_balance NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE
+);
+INSERT INTO Account_new (id, name, type, currency, opening_balance)
+SELECT id, name, type, currency, opening_balance FROM Account;
+DROP TABLE Account;
+ALTER TABLE Account_new RENAME TO Account;
+CREATE UNIQUE INDEX IF NOT EXISTS account_user_name_idx ON Account (user_id, name);
+
+CREATE TABLE ImportProfile_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    delimiter CHAR(1) NOT NULL DEFAULT ',',
+    skip_rows INT NOT NULL DEFAULT 0 CHECK (skip_rows >= 0),
+    date_column VARCHAR(255) NOT NULL,
+    date_format VARCHAR(50) NOT NULL DEFAULT 'YYYY-MM-DD',
+    description_column VARCHAR(255) NOT NULL,
+    amount_column VARCHAR(255) NOT NULL DEFAULT '',
+    debit_column VARCHAR(255) NOT NULL DEFAULT '',
+    credit_column VARCHAR(255) NOT NULL DEFAULT '',
+    sign_convention VARCHAR(20) NOT NULL DEFAULT 'negative_expense'
+        CHECK (sign_convention IN ('negative_expense', 'positive_expense')),
+    decimal_separator CHAR(1) NOT NULL DEFAULT '.' CHECK (decimal_separator IN ('.', ',')),
+    currency_column VARCHAR(255) NOT NULL DEFAULT '',
+    currency CHAR(3) NOT NULL DEFAULT '',
+    category_id INT NOT NULL DEFAULT 1 REFERENCES Category(id) ON DELETE SET DEFAULT,
+    account_id INT REFERENCES Account(id) ON DELETE SET NULL,
+    user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE
+);
+INSERT INTO ImportProfile_new (id, name, delimiter, skip_rows, date_column, date_format, description_column,
+    amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column, currency,
+    category_id, account_id)
+SELECT id, name, delimiter, skip_rows, date_column, date_format, description_column,
+    amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column, currency,
+    category_id, account_id
+FROM ImportProfile;
+DROP TABLE ImportProfile;
+ALTER TABLE ImportProfile_new RENAME TO ImportProfile;
+CREATE UNIQUE INDEX IF NOT EXISTS import_profile_user_name_idx ON ImportProfile (user_id, name);
+
+CREATE TABLE Tag_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL,
+    user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE
+);
+INSERT INTO Tag_new (id, name) SELECT id, name FROM Tag;
+DROP TABLE Tag;
+ALTER TABLE Tag_new RENAME TO Tag;
+CREATE UNIQUE INDEX IF NOT EXISTS tag_user_name_idx ON Tag (user_id, name);
+
+CREATE TABLE ImportedTransaction_new (
+    external_id TEXT NOT NULL,
+    expense_id INT REFERENCES Expense(id) ON DELETE SET NULL,
+    income_id INT REFERENCES Income(id) ON DELETE SET NULL,
+    imported_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE
+);
+INSERT INTO ImportedTransaction_new (external_id, expense_id, income_id, imported_at)
+SELECT external_id, expense_id, income_id, imported_at FROM ImportedTransaction;
+DROP TABLE ImportedTransaction;
+ALTER TABLE ImportedTransaction_new RENAME TO ImportedTransaction;
+CREATE UNIQUE INDEX IF NOT EXISTS imported_transaction_user_external_idx ON ImportedTransaction (user_id, external_id);
@@ -0,0 +1,66 @@
+-- Reverting hands each ledger's data to its first owner, and fails if an
+-- owner has two ledgers that use the same name. The columns are swapped
+-- back as in the up migration.
+ALTER TABLE Tag ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportedTransaction ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE ImportProfile ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Account ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Expense ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+ALTER TABLE Category ADD COLUMN user_id INT REFERENCES UserAccount(id) ON DELETE CASCADE;
+
+UPDATE Tag SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Tag.ledger_id AND m.role = 'owner');
+UPDATE CategoryRule SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = CategoryRule.ledger_id AND m.role = 'owner');
+UPDATE ImportedTransaction SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = ImportedTransaction.ledger_id AND m.role = 'owner');
+UPDATE ImportProfile SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = ImportProfile.ledger_id AND m.role = 'owner');
+UPDATE RecurringRule SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = RecurringRule.ledger_id AND m.role = 'owner');
+UPDATE Transfer SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Transfer.ledger_id AND m.role = 'owner');
+UPDATE Account SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Account.ledger_id AND m.role = 'owner');
+UPDATE Budget SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Budget.ledger_id AND m.role = 'owner');
+UPDATE Income SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Income.ledger_id AND m.role = 'owner');
+UPDATE Expense SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Expense.ledger_id AND m.role = 'owner');
+UPDATE Category SET user_id = (SELECT MIN(m.user_id) FROM LedgerMember m WHERE m.ledger_id = Category.ledger_id AND m.role = 'owner');
+
+DROP INDEX IF EXISTS imported_transaction_ledger_external_idx;
+DROP INDEX IF EXISTS tag_ledger_name_idx;
+DROP INDEX IF EXISTS import_profile_ledger_name_idx;
+DROP INDEX IF EXISTS account_ledger_name_idx;
+DROP INDEX IF EXISTS category_ledger_name_idx;
+DROP INDEX IF EXISTS category_rule_ledger_idx;
+DROP INDEX IF EXISTS recurring_rule_ledger_idx;
+DROP INDEX IF EXISTS transfer_ledger_date_idx;
+DROP INDEX IF EXISTS budget_ledger_category_idx;
+DROP INDEX IF EXISTS income_ledger_date_idx;
+DROP INDEX IF EXISTS expense_ledger_date_idx;
+
+ALTER TABLE Tag DROP COLUMN ledger_id;
+ALTER TABLE CategoryRule DROP COLUMN ledger_id;
+ALTER TABLE ImportedTransaction DROP COLUMN ledger_id;
+ALTER TABLE ImportProfile DROP COLUMN ledger_id;
+ALTER TABLE RecurringRule DROP COLUMN ledger_id;
+ALTER TABLE Transfer DROP COLUMN ledger_id;
+ALTER TABLE Account DROP COLUMN ledger_id;
+ALTER TABLE Budget DROP COLUMN ledger_id;
+ALTER TABLE Income DROP COLUMN ledger_id;
+ALTER TABLE Expense DROP COLUMN ledger_id;
+ALTER TABLE Category DROP COLUMN ledger_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS imported_transaction_user_external_idx ON ImportedTransaction (user_id, external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS tag_user_name_idx ON Tag (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS import_profile_user_name_idx ON ImportProfile (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS account_user_name_idx ON Account (user_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS category_user_name_idx ON Category (user_id, name);
+CREATE INDEX IF NOT EXISTS category_rule_user_idx ON CategoryRule (user_id, priority, id);
+CREATE INDEX IF NOT EXISTS recurring_rule_user_idx ON RecurringRule (user_id);
+CREATE INDEX IF NOT EXISTS transfer_user_date_idx ON Transfer (user_id, date);
+CREATE INDEX IF NOT EXISTS budget_user_category_idx ON Budget (user_id, category_id);
+CREATE INDEX IF NOT EXISTS income_user_date_idx ON Income (user_id, date);
+CREATE INDEX IF NOT EXISTS expense_user_date_idx ON Expense (user_id, date);
+
+DROP TABLE IF EXISTS LedgerInvitation;
+DROP TABLE IF EXISTS LedgerMember;
+DROP TABLE IF EXISTS Ledger;
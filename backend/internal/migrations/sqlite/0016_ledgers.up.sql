@@ -0,0 +1,104 @@
+-- Table: Ledger
+-- A set of categories, budgets, expenses and everything else recorded,
+-- shared by its members.
+CREATE TABLE IF NOT EXISTS Ledger (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Table: LedgerMember
+-- Who can use a ledger: owners manage it and its members, editors change
+-- its data and viewers only read it.
+CREATE TABLE IF NOT EXISTS LedgerMember (
+    ledger_id INT NOT NULL REFERENCES Ledger(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES UserAccount(id) ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (ledger_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_member_user_idx ON LedgerMember (user_id);
+
+-- Table: LedgerInvitation
+-- Pending invitations to join a ledger, kept by the SHA-256 hash of their
+-- token. Accepting an invitation deletes it.
+CREATE TABLE IF NOT EXISTS LedgerInvitation (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ledger_id INT NOT NULL REFERENCES Ledger(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    invited_by INT REFERENCES UserAccount(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ledger_invitation_ledger_idx ON LedgerInvitation (ledger_id);
+
+-- Every user gets a personal ledger, under the same id as the user, that
+-- they own and that takes over their data
+INSERT INTO Ledger (id, name, created_at) SELECT id, 'Personal', created_at FROM UserAccount;
+INSERT INTO LedgerMember (ledger_id, user_id, role, created_at) SELECT id, id, 'owner', created_at FROM UserAccount;
+
+-- Everything recorded belongs to a ledger instead of a user. SQLite cannot
+-- change the foreign key of a column, so each table gets a new column and
+-- drops the old one along with its indexes.
+ALTER TABLE Category ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Expense ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Income ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Budget ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Account ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Transfer ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE RecurringRule ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE ImportProfile ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE ImportedTransaction ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE CategoryRule ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+ALTER TABLE Tag ADD COLUMN ledger_id INT REFERENCES Ledger(id) ON DELETE CASCADE;
+
+UPDATE Category SET ledger_id = user_id;
+UPDATE Expense SET ledger_id = user_id;
+UPDATE Income SET ledger_id = user_id;
+UPDATE Budget SET ledger_id = user_id;
+UPDATE Account SET ledger_id = user_id;
+UPDATE Transfer SET ledger_id = user_id;
+UPDATE RecurringRule SET ledger_id = user_id;
+UPDATE ImportProfile SET ledger_id = user_id;
+UPDATE ImportedTransaction SET ledger_id = user_id;
+UPDATE CategoryRule SET ledger_id = user_id;
+UPDATE Tag SET ledger_id = user_id;
+
+DROP INDEX IF EXISTS expense_user_date_idx;
+DROP INDEX IF EXISTS income_user_date_idx;
+DROP INDEX IF EXISTS budget_user_category_idx;
+DROP INDEX IF EXISTS transfer_user_date_idx;
+DROP INDEX IF EXISTS recurring_rule_user_idx;
+DROP INDEX IF EXISTS category_rule_user_idx;
+DROP INDEX IF EXISTS category_user_name_idx;
+DROP INDEX IF EXISTS account_user_name_idx;
+DROP INDEX IF EXISTS import_profile_user_name_idx;
+DROP INDEX IF EXISTS tag_user_name_idx;
+DROP INDEX IF EXISTS imported_transaction_user_external_idx;
+
+ALTER TABLE Category DROP COLUMN user_id;
+ALTER TABLE Expense DROP COLUMN user_id;
+ALTER TABLE Income DROP COLUMN user_id;
+ALTER TABLE Budget DROP COLUMN user_id;
+ALTER TABLE Account DROP COLUMN user_id;
+ALTER TABLE Transfer DROP COLUMN user_id;
+ALTER TABLE RecurringRule DROP COLUMN user_id;
+ALTER TABLE ImportProfile DROP COLUMN user_id;
+ALTER TABLE ImportedTransaction DROP COLUMN user_id;
+ALTER TABLE CategoryRule DROP COLUMN user_id;
+ALTER TABLE Tag DROP COLUMN user_id;
+
+CREATE INDEX IF NOT EXISTS expense_ledger_date_idx ON Expense (ledger_id, date);
+CREATE INDEX IF NOT EXISTS income_ledger_date_idx ON Income (ledger_id, date);
+CREATE INDEX IF NOT EXISTS budget_ledger_category_idx ON Budget (ledger_id, category_id);
+CREATE INDEX IF NOT EXISTS transfer_ledger_date_idx ON Transfer (ledger_id, date);
+CREATE INDEX IF NOT EXISTS recurring_rule_ledger_idx ON RecurringRule (ledger_id);
+CREATE INDEX IF NOT EXISTS category_rule_ledger_idx ON CategoryRule (ledger_id, priority, id);
+CREATE UNIQUE INDEX IF NOT EXISTS category_ledger_name_idx ON Category (ledger_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS account_ledger_name_idx ON Account (ledger_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS import_profile_ledger_name_idx ON ImportProfile (ledger_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS tag_ledger_name_idx ON Tag (ledger_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS imported_transaction_ledger_external_idx ON ImportedTransaction (ledger_id, external_id);
@@ -0,0 +1 @@
+ALTER TABLE Budget DROP COLUMN stale;
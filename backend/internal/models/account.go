@@ -0,0 +1,353 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AccountType is the kind of place an account holds money.
+type AccountType string
+
+const (
+	AccountCash       AccountType = "cash"
+	AccountChecking   AccountType = "checking"
+	AccountSavings    AccountType = "savings"
+	AccountCreditCard AccountType = "credit_card"
+)
+
+// Account is where money is held or owed. Expenses and incomes recorded
+// against an account must be in its currency.
+type Account struct {
+	ID             int64       `json:"id"`
+	Name           string      `json:"name"`
+	Type           AccountType `json:"type"`
+	Currency       string      `json:"currency"`
+	OpeningBalance Money       `json:"opening_balance"`
+
+	// Balance is the opening balance plus the account's incomes and incoming
+	// transfers, minus its expenses and outgoing transfers. It is computed on
+	// read and ignored on writes.
+	Balance Money `json:"balance"`
+}
+
+// accountColumns lists the columns read by scanAccount, in order.
+const accountColumns = "id, name, type, currency, opening_balance, " +
+	"opening_balance + COALESCE((SELECT SUM(amount) FROM Income WHERE account_id = Account.id), 0) - " +
+	"COALESCE((SELECT SUM(amount) FROM Expense WHERE account_id = Account.id), 0) + " +
+	"COALESCE((SELECT SUM(to_amount) FROM Transfer WHERE to_account_id = Account.id), 0) - " +
+	"COALESCE((SELECT SUM(amount) FROM Transfer WHERE from_account_id = Account.id), 0) AS balance"
+
+func scanAccount(row rowScanner) (Account, error) {
+	var account Account
+	err := row.Scan(&account.ID, &account.Name, &account.Type, &account.Currency, &account.OpeningBalance, &account.Balance)
+	return account, err
+}
+
+func GetAccounts(db *sql.DB, ledgerID int64) ([]Account, error) {
+	accounts, _, err := ListAccounts(db, ledgerID, ListOptions{})
+	return accounts, err
+}
+
+// ListAccounts returns one page of the accounts whose name matches the
+// search in opts, along with the total number of matches.
+func ListAccounts(db *sql.DB, ledgerID int64, opts ListOptions) ([]Account, int, error) {
+	var q listQuery
+	q.where("ledger_id = ?", ledgerID)
+	q.search(opts, "name")
+	return list(db, "Account", accountColumns, AccountSortFields, q, opts, scanAccount)
+}
+
+func GetAccountByID(db *sql.DB, ledgerID, id int64) (Account, error) {
+	return getAccountByID(db, ledgerID, id)
+}
+
+func getAccountByID(q querier, ledgerID, id int64) (Account, error) {
+	account, err := scanAccount(q.QueryRow("SELECT "+accountColumns+" FROM Account WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return Account{}, notFound(err, "account")
+	}
+	return account, nil
+}
+
+// ValidateAccount validates fields for creating or replacing an account.
+func ValidateAccount(account Account) error {
+	if account.Name == "" {
+		return NewValidationError("name", "name must be provided")
+	}
+	if len(account.Name) > 255 {
+		return NewValidationError("name", "name is too long (max 255 characters)")
+	}
+	switch account.Type {
+	case AccountCash, AccountChecking, AccountSavings, AccountCreditCard:
+	default:
+		return NewValidationError("type", "type must be cash, checking, savings or credit_card")
+	}
+	if _, err := NormalizeCurrency(account.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+// duplicateAccount reports a clash with an existing account's name as a
+// conflict, and returns any other error unchanged.
+func duplicateAccount(err error, name string) error {
+	if isUniqueViolation(err) {
+		return NewConflictError("account %q already exists", name)
+	}
+	return err
+}
+
+func CreateAccount(db *sql.DB, ledgerID int64, account Account) (Account, error) {
+	if err := ValidateAccount(account); err != nil {
+		return Account{}, err
+	}
+	account.Currency, _ = NormalizeCurrency(account.Currency)
+
+	err := db.QueryRow(
+		"INSERT INTO Account (name, type, currency, opening_balance, ledger_id) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		account.Name, account.Type, account.Currency, account.OpeningBalance, ledgerID,
+	).Scan(&account.ID)
+	if err != nil {
+		return Account{}, duplicateAccount(err, account.Name)
+	}
+	account.Balance = account.OpeningBalance
+	return account, nil
+}
+
+// UpdateAccount replaces an account's fields. The currency can only change
+// while no expenses, incomes or transfers are recorded against the account.
+func UpdateAccount(db *sql.DB, ledgerID int64, account Account) (Account, error) {
+	if err := ValidateAccount(account); err != nil {
+		return Account{}, err
+	}
+	account.Currency, _ = NormalizeCurrency(account.Currency)
+
+	var updated Account
+	err := withTx(db, func(tx *sql.Tx) error {
+		current, err := getAccountByID(tx, ledgerID, account.ID)
+		if err != nil {
+			return err
+		}
+		if account.Currency != current.Currency {
+			var used bool
+			err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM Expense WHERE account_id = $1)
+				OR EXISTS (SELECT 1 FROM Income WHERE account_id = $1)
+				OR EXISTS (SELECT 1 FROM Transfer WHERE from_account_id = $1 OR to_account_id = $1)`,
+				account.ID).Scan(&used)
+			if err != nil {
+				return err
+			}
+			if used {
+				return NewConflictError("cannot change the currency of an account with expenses, incomes or transfers")
+			}
+		}
+
+		_, err = tx.Exec("UPDATE Account SET name = $1, type = $2, currency = $3, opening_balance = $4 WHERE id = $5",
+			account.Name, account.Type, account.Currency, account.OpeningBalance, account.ID)
+		if err != nil {
+			return err
+		}
+		updated, err = getAccountByID(tx, ledgerID, account.ID)
+		return err
+	})
+	if err != nil {
+		return Account{}, duplicateAccount(err, account.Name)
+	}
+	return updated, nil
+}
+
+// DeleteAccount deletes an account that no expense, income or transfer
+// refers to.
+func DeleteAccount(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM Account WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if isForeignKeyViolation(err) {
+		return NewConflictError("cannot delete an account with expenses, incomes or transfers")
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("account")
+	}
+	return nil
+}
+
+// entryCurrency resolves the currency of an expense or income recorded
+// against accountID: an empty currency defaults to the account's, and any
+// other must match it. Without an account the currency is only normalized.
+// Another ledger's account is reported as missing.
+func entryCurrency(q querier, ledgerID int64, accountID *int64, currency string) (string, error) {
+	if accountID == nil {
+		return NormalizeCurrency(currency)
+	}
+	var accountCurrency string
+	err := q.QueryRow("SELECT currency FROM Account WHERE id = $1 AND ledger_id = $2", *accountID, ledgerID).Scan(&accountCurrency)
+	if err == sql.ErrNoRows {
+		return "", NewValidationError("account_id", "account %d does not exist", *accountID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return MatchAccountCurrency(accountCurrency, currency)
+}
+
+// MatchAccountCurrency checks the currency of an entry against its
+// account's, defaulting an empty currency to the account's.
+func MatchAccountCurrency(accountCurrency, currency string) (string, error) {
+	if currency == "" {
+		return accountCurrency, nil
+	}
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return "", err
+	}
+	if currency != accountCurrency {
+		return "", NewValidationError("currency", "currency must match the account currency %s", accountCurrency)
+	}
+	return currency, nil
+}
+
+// BalanceInterval is the length of the periods in a balance history.
+type BalanceInterval string
+
+const (
+	IntervalDay   BalanceInterval = "day"
+	IntervalWeek  BalanceInterval = "week"
+	IntervalMonth BalanceInterval = "month"
+)
+
+// maxBalancePeriods caps the length of a balance history.
+const maxBalancePeriods = 1000
+
+// AccountBalance is the money that moved in and out of an account during
+// one period, and the account's balance at the end of it.
+type AccountBalance struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Inflow  Money     `json:"inflow"`
+	Outflow Money     `json:"outflow"`
+	Balance Money     `json:"balance"`
+}
+
+// AccountMovement totals the money that moved in and out of an account on
+// one day.
+type AccountMovement struct {
+	Date    time.Time
+	Inflow  Money
+	Outflow Money
+}
+
+// periodStart returns the start of the period containing date. Weeks start
+// on Monday.
+func (interval BalanceInterval) periodStart(date time.Time) time.Time {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case IntervalMonth:
+		return date.AddDate(0, 0, 1-date.Day())
+	}
+	return date
+}
+
+// next returns the start of the period after the one starting at start.
+func (interval BalanceInterval) next(start time.Time) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// BalancePeriods returns the start of each period of interval from the
+// period containing from to the one containing to.
+func BalancePeriods(from, to time.Time, interval BalanceInterval) ([]time.Time, error) {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth:
+	default:
+		return nil, NewValidationError("interval", "interval must be day, week or month")
+	}
+	if to.Before(from) {
+		return nil, NewValidationError("to", "from must not be after to")
+	}
+	var starts []time.Time
+	for start := interval.periodStart(from); !start.After(to); start = interval.next(start) {
+		if len(starts) == maxBalancePeriods {
+			return nil, NewValidationError("from", "the range covers more than %d periods", maxBalancePeriods)
+		}
+		starts = append(starts, start)
+	}
+	return starts, nil
+}
+
+// BalanceHistory totals movements, ordered by date, into the periods
+// starting at starts, carrying the balance forward from the opening balance.
+func BalanceHistory(openingBalance Money, movements []AccountMovement, starts []time.Time, interval BalanceInterval) []AccountBalance {
+	history := make([]AccountBalance, 0, len(starts))
+	balance := openingBalance
+	i := 0
+	// Movements before the first period only carry into the balance
+	for ; len(starts) > 0 && i < len(movements) && movements[i].Date.Before(starts[0]); i++ {
+		balance += movements[i].Inflow - movements[i].Outflow
+	}
+	for _, start := range starts {
+		end := interval.next(start)
+		period := AccountBalance{Start: start, End: end.AddDate(0, 0, -1)}
+		for ; i < len(movements) && movements[i].Date.Before(end); i++ {
+			period.Inflow += movements[i].Inflow
+			period.Outflow += movements[i].Outflow
+		}
+		balance += period.Inflow - period.Outflow
+		period.Balance = balance
+		history = append(history, period)
+	}
+	return history
+}
+
+// GetAccountBalances returns the account's balance history from the period
+// containing from to the one containing to.
+func GetAccountBalances(db *sql.DB, ledgerID, id int64, from, to time.Time, interval BalanceInterval) ([]AccountBalance, error) {
+	starts, err := BalancePeriods(from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+	account, err := GetAccountByID(db, ledgerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT date, SUM(inflow), SUM(outflow) FROM (
+			SELECT date, amount AS inflow, 0 AS outflow FROM Income WHERE account_id = $1 AND date < $2
+			UNION ALL
+			SELECT date, 0, amount FROM Expense WHERE account_id = $1 AND date < $2
+			UNION ALL
+			SELECT date, to_amount, 0 FROM Transfer WHERE to_account_id = $1 AND date < $2
+			UNION ALL
+			SELECT date, 0, amount FROM Transfer WHERE from_account_id = $1 AND date < $2
+		) AS Movement
+		GROUP BY date ORDER BY date`, id, interval.next(starts[len(starts)-1]))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []AccountMovement
+	for rows.Next() {
+		var movement AccountMovement
+		if err := rows.Scan(&movement.Date, &movement.Inflow, &movement.Outflow); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return BalanceHistory(account.OpeningBalance, movements, starts, interval), nil
+}
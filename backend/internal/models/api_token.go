@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIToken is a long-lived bearer token for scripts and automations that
+// cannot sign in. It acts as the user who created it, limited to its
+// scopes. Only a hash of the token is stored, so Token is only known when
+// the token is created.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APITokenPrefix starts every API token, telling them apart from session
+// tokens.
+const APITokenPrefix = "etk_"
+
+// TokenResources are the parts of a ledger an API token can be given access
+// to. A scope is a resource followed by ":read" or ":write"; writing
+// includes reading.
+var TokenResources = []string{
+	"expenses", "incomes", "categories", "budgets", "accounts", "transfers", "imports", "recurring", "tags",
+	"rates", "reports",
+}
+
+// lastUsedResolution is how stale an API token's LastUsedAt may get, which
+// spares a write on every request.
+const lastUsedResolution = time.Minute
+
+// HasScope reports whether the token may read, or with write set change, a
+// resource.
+func (t APIToken) HasScope(resource string, write bool) bool {
+	if slices.Contains(t.Scopes, resource+":write") {
+		return true
+	}
+	return !write && slices.Contains(t.Scopes, resource+":read")
+}
+
+// Validate checks a new API token, trimming its name and sorting its scopes.
+func (t *APIToken) Validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return NewValidationError("name", "name must be provided")
+	}
+	if len(t.Name) > 255 {
+		return NewValidationError("name", "name is too long (max 255 characters)")
+	}
+	if len(t.Scopes) == 0 {
+		return NewValidationError("scopes", "at least one scope must be provided")
+	}
+	for _, scope := range t.Scopes {
+		resource, access, _ := strings.Cut(scope, ":")
+		if !slices.Contains(TokenResources, resource) || (access != "read" && access != "write") {
+			return NewValidationError("scopes", "unknown scope %q", scope)
+		}
+	}
+	slices.Sort(t.Scopes)
+	t.Scopes = slices.Compact(t.Scopes)
+	if t.ExpiresAt != nil {
+		expiresAt := t.ExpiresAt.UTC()
+		if !expiresAt.After(time.Now()) {
+			return NewValidationError("expires_at", "expiry must be in the future")
+		}
+		t.ExpiresAt = &expiresAt
+	}
+	return nil
+}
+
+// NewAPIToken returns a random API token.
+func NewAPIToken() (string, error) {
+	token, err := NewSessionToken()
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + token, nil
+}
+
+// apiTokenColumns lists the columns read by scanAPIToken, in order, of the
+// ApiToken table aliased as t.
+const apiTokenColumns = "t.id, t.name, t.scopes, t.created_at, t.expires_at, t.last_used_at, t.revoked_at"
+
+// scanAPIToken scans the apiTokenColumns of a row, followed by any extra
+// columns into extra.
+func scanAPIToken(row rowScanner, extra ...any) (APIToken, error) {
+	var token APIToken
+	var scopes string
+	dest := []any{&token.ID, &token.Name, &scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return APIToken{}, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	token.CreatedAt = token.CreatedAt.UTC()
+	for _, t := range []*time.Time{token.ExpiresAt, token.LastUsedAt, token.RevokedAt} {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+	return token, nil
+}
+
+// GetAPITokens returns the API tokens of a user, revoked ones included.
+func GetAPITokens(db *sql.DB, userID int64) ([]APIToken, error) {
+	rows, err := db.Query("SELECT "+apiTokenColumns+" FROM ApiToken t WHERE t.user_id = $1 ORDER BY t.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// CreateAPIToken stores a new API token of a user and returns it with the
+// token itself, which cannot be read back later.
+func CreateAPIToken(db *sql.DB, userID int64, token APIToken) (APIToken, error) {
+	if err := token.Validate(); err != nil {
+		return APIToken{}, err
+	}
+	secret, err := NewAPIToken()
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	token.Token = secret
+	token.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	token.LastUsedAt, token.RevokedAt = nil, nil
+	err = db.QueryRow(`
+		INSERT INTO ApiToken (user_id, name, token_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		userID, token.Name, HashToken(secret), strings.Join(token.Scopes, " "), token.CreatedAt, token.ExpiresAt).Scan(&token.ID)
+	if err != nil {
+		return APIToken{}, err
+	}
+	return token, nil
+}
+
+// RevokeAPIToken stops an API token of a user from working. Revoking it
+// again changes nothing.
+func RevokeAPIToken(db *sql.DB, userID, id int64) error {
+	result, err := db.Exec("UPDATE ApiToken SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2 AND user_id = $3",
+		time.Now().UTC().Truncate(time.Second), id, userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return NewNotFoundError("API token")
+	}
+	return nil
+}
+
+// AuthenticateAPIToken returns the API token and its user for a bearer
+// token that is neither revoked nor expired, and records that it was used.
+func AuthenticateAPIToken(db *sql.DB, secret string) (User, APIToken, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	var user User
+	token, err := scanAPIToken(db.QueryRow(`
+		SELECT `+apiTokenColumns+`, u.id, u.email, u.created_at
+		FROM ApiToken t JOIN UserAccount u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.revoked_at IS NULL AND (t.expires_at IS NULL OR t.expires_at > $2)`,
+		HashToken(secret), now), &user.ID, &user.Email, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return User{}, APIToken{}, NewUnauthorizedError("API token is invalid, revoked or has expired")
+	}
+	if err != nil {
+		return User{}, APIToken{}, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		_, err := db.Exec("UPDATE ApiToken SET last_used_at = $1 WHERE id = $2", now, token.ID)
+		if err != nil {
+			return User{}, APIToken{}, err
+		}
+		token.LastUsedAt = &now
+	}
+	return user, token, nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxAttachmentSize is the largest file, in bytes, that can be attached to
+// an expense.
+const MaxAttachmentSize = 10 << 20
+
+// Attachment is a file attached to an expense, such as a receipt kept for
+// reimbursement or tax. Its content lives in a blob store under Key; the
+// size and SHA-256 digest are those of the stored content.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	ExpenseID   int64     `json:"expense_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Key         string    `json:"-"`
+}
+
+// attachmentColumns lists the columns read by scanAttachment, in order.
+const attachmentColumns = "id, expense_id, file_name, content_type, size, sha256, uploaded_at, storage_key"
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var attachment Attachment
+	err := row.Scan(&attachment.ID, &attachment.ExpenseID, &attachment.FileName, &attachment.ContentType,
+		&attachment.Size, &attachment.SHA256, &attachment.UploadedAt, &attachment.Key)
+	if err != nil {
+		return Attachment{}, err
+	}
+	attachment.UploadedAt = attachment.UploadedAt.UTC()
+	return attachment, nil
+}
+
+// NormalizeAttachment checks the metadata of an uploaded file. The file
+// name loses any directories the client sent along with it, and the content
+// type is reduced to its canonical form, defaulting to
+// application/octet-stream.
+func NormalizeAttachment(attachment Attachment) (Attachment, error) {
+	name := strings.TrimSpace(path.Base(strings.ReplaceAll(attachment.FileName, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return Attachment{}, NewValidationError("file", "file name must be provided")
+	}
+	if len(name) > 255 {
+		return Attachment{}, NewValidationError("file", "file name is too long (max 255 characters)")
+	}
+	attachment.FileName = name
+
+	if attachment.ContentType == "" {
+		attachment.ContentType = "application/octet-stream"
+	}
+	mediaType, params, err := mime.ParseMediaType(attachment.ContentType)
+	if err != nil {
+		return Attachment{}, NewValidationError("content_type", "invalid content type %q", attachment.ContentType)
+	}
+	attachment.ContentType = mime.FormatMediaType(mediaType, params)
+	if len(attachment.ContentType) > 255 {
+		return Attachment{}, NewValidationError("content_type", "content type is too long (max 255 characters)")
+	}
+
+	if attachment.Size <= 0 {
+		return Attachment{}, NewValidationError("file", "file is empty")
+	}
+	if attachment.Size > MaxAttachmentSize {
+		return Attachment{}, NewValidationError("file", "file is too large (max %d MiB)", MaxAttachmentSize>>20)
+	}
+	return attachment, nil
+}
+
+// checkExpense reports a missing expense, or another ledger's, as not found.
+func checkExpense(q querier, ledgerID, id int64) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM Expense WHERE id = $1 AND ledger_id = $2)", id, ledgerID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return NewNotFoundError("expense")
+	}
+	return nil
+}
+
+// GetAttachments returns the files attached to an expense in upload order.
+func GetAttachments(db *sql.DB, ledgerID, expenseID int64) ([]Attachment, error) {
+	if err := checkExpense(db, ledgerID, expenseID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT "+attachmentColumns+" FROM Attachment WHERE expense_id = $1 ORDER BY id", expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func GetAttachmentByID(db *sql.DB, ledgerID, expenseID, id int64) (Attachment, error) {
+	attachment, err := scanAttachment(db.QueryRow("SELECT "+attachmentColumns+" FROM Attachment WHERE id = $1 AND expense_id = $2"+
+		" AND expense_id IN (SELECT id FROM Expense WHERE ledger_id = $3)", id, expenseID, ledgerID))
+	if err != nil {
+		return Attachment{}, notFound(err, "attachment")
+	}
+	return attachment, nil
+}
+
+// CreateAttachment records the metadata of a file whose content has already
+// been stored under attachment.Key.
+func CreateAttachment(db *sql.DB, ledgerID int64, attachment Attachment) (Attachment, error) {
+	attachment, err := NormalizeAttachment(attachment)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if attachment.UploadedAt.IsZero() {
+		attachment.UploadedAt = time.Now().UTC().Truncate(time.Second)
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if err := checkExpense(tx, ledgerID, attachment.ExpenseID); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO Attachment (expense_id, file_name, content_type, size, sha256, uploaded_at, storage_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			attachment.ExpenseID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.SHA256,
+			attachment.UploadedAt, attachment.Key,
+		).Scan(&attachment.ID)
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment removes the metadata of a file and returns it, so the
+// caller can remove its content.
+func DeleteAttachment(db *sql.DB, ledgerID, expenseID, id int64) (Attachment, error) {
+	var attachment Attachment
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		attachment, err = scanAttachment(tx.QueryRow("SELECT "+attachmentColumns+" FROM Attachment WHERE id = $1 AND expense_id = $2"+
+			" AND expense_id IN (SELECT id FROM Expense WHERE ledger_id = $3)", id, expenseID, ledgerID))
+		if err != nil {
+			return notFound(err, "attachment")
+		}
+		_, err = tx.Exec("DELETE FROM Attachment WHERE id = $1", id)
+		return err
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
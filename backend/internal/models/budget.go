@@ -11,15 +11,29 @@ import (
 type Budget struct {
 	ID         int64     `json:"id"`
 	CategoryID int64     `json:"category_id"`
-	Amount     float64   `json:"amount"`
-	Spent      float64   `json:"spent"`
+	Amount     Money     `json:"amount"`
+	Spent      Money     `json:"spent"`
+	Currency   string    `json:"currency"`
 	StartDate  time.Time `json:"start_date"`
 	EndDate    time.Time `json:"end_date"`
+	// Stale is set when Spent leaves out expenses that no stored exchange
+	// rate could convert into Currency. Importing the missing rates
+	// recalculates the budget and clears it.
+	Stale bool `json:"stale"`
+}
+
+// budgetColumns lists the columns read by scanBudget, in order.
+const budgetColumns = "id, category_id, amount, spent, currency, start_date, end_date, stale"
+
+func scanBudget(row rowScanner) (Budget, error) {
+	var budget Budget
+	err := row.Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.Currency, &budget.StartDate, &budget.EndDate, &budget.Stale)
+	return budget, err
 }
 
 // GetBudgets retrieves all budgets from the database.
-func GetBudgets(db *sql.DB) ([]Budget, error) {
-	rows, err := db.Query("SELECT id, category_id, amount, spent, start_date, end_date FROM Budget")
+func GetBudgets(db *sql.DB, ledgerID int64) ([]Budget, error) {
+	rows, err := db.Query("SELECT "+budgetColumns+" FROM Budget WHERE ledger_id = $1 ORDER BY id", ledgerID)
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +41,7 @@ func GetBudgets(db *sql.DB) ([]Budget, error) {
 
 	var budgets []Budget
 	for rows.Next() {
-		var budget Budget
-		err := rows.Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.StartDate, &budget.EndDate)
+		budget, err := scanBudget(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -38,39 +51,42 @@ func GetBudgets(db *sql.DB) ([]Budget, error) {
 	return budgets, nil
 }
 
-// // GetBudgetByCategory retrieves a budget by category name.
-// func GetBudgetByCategory(db *sql.DB, category string) (Budget, error) {
-// 	categoryID, err := strconv.ParseInt(category, 10, 64)
-// 	if err != nil {
-// 		return Budget{}, err
-// 	}
-
-// 	var budget Budget
-// 	err = db.QueryRow("SELECT id, category_id, amount, spent, start_date, end_date FROM Budget WHERE category_id = $1",
-// 		categoryID).Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.StartDate, &budget.EndDate)
-// 	if err != nil {
-// 		return Budget{}, err
-// 	}
-// 	return budget, nil
-// }
-
-func GetBudgetsByCategoryName(db *sql.DB, categoryName string) ([]Budget, error) {
+// ListBudgets returns one page of the budgets whose period overlaps the date
+// range in opts and that match its category and amount range, along with the
+// total number of matches.
+func ListBudgets(db *sql.DB, ledgerID int64, opts ListOptions) ([]Budget, int, error) {
+	var q listQuery
+	q.where("ledger_id = ?", ledgerID)
+	if !opts.From.IsZero() {
+		q.where("end_date >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		q.where("start_date <= ?", opts.To)
+	}
+	if opts.CategoryID != 0 {
+		q.where("category_id = ?", opts.CategoryID)
+	}
+	q.amountRange("amount", opts)
+	return list(db, "Budget", budgetColumns, BudgetSortFields, q, opts, scanBudget)
+}
+
+func GetBudgetsByCategoryName(db *sql.DB, ledgerID int64, categoryName string) ([]Budget, error) {
 	// Retrieve the category ID using the category name
 	var categoryID int64
-	err := db.QueryRow("SELECT id FROM Category WHERE name = $1", categoryName).Scan(&categoryID)
+	err := db.QueryRow("SELECT id FROM Category WHERE name = $1 AND (ledger_id = $2 OR id = 1)", categoryName, ledgerID).Scan(&categoryID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("category not found: %s", categoryName)
+			return nil, NewNotFoundError("category " + categoryName)
 		}
 		return nil, fmt.Errorf("failed to retrieve category ID: %w", err)
 	}
 
 	// Retrieve all budgets associated with the category ID
 	rows, err := db.Query(`
-		SELECT id, category_id, amount, spent, start_date, end_date 
+		SELECT `+budgetColumns+`
 		FROM Budget 
-		WHERE category_id = $1
-	`, categoryID)
+		WHERE category_id = $1 AND ledger_id = $2
+	`, categoryID, ledgerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve budgets: %w", err)
 	}
@@ -79,8 +95,7 @@ func GetBudgetsByCategoryName(db *sql.DB, categoryName string) ([]Budget, error)
 	// Collect all budgets into a slice
 	var budgets []Budget
 	for rows.Next() {
-		var budget Budget
-		err := rows.Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.StartDate, &budget.EndDate)
+		budget, err := scanBudget(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan budget: %w", err)
 		}
@@ -96,23 +111,22 @@ func GetBudgetsByCategoryName(db *sql.DB, categoryName string) ([]Budget, error)
 }
 
 // GetBudgetByID retrieves a budget by ID.
-func GetBudgetByID(db *sql.DB, id int64) (Budget, error) {
-	var budget Budget
-	err := db.QueryRow("SELECT id, category_id, amount, spent, start_date, end_date FROM Budget WHERE id = $1", id).Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.StartDate, &budget.EndDate)
+func GetBudgetByID(db *sql.DB, ledgerID, id int64) (Budget, error) {
+	budget, err := scanBudget(db.QueryRow("SELECT "+budgetColumns+" FROM Budget WHERE id = $1 AND ledger_id = $2", id, ledgerID))
 	if err != nil {
-		return Budget{}, err
+		return Budget{}, notFound(err, "budget")
 	}
 	return budget, nil
 }
 
 // GetBudgetByCategory retrieves a budget by category id.
-func GetBudgetsByCategoryID(db *sql.DB, categoryID int64) ([]Budget, error) {
+func GetBudgetsByCategoryID(db *sql.DB, ledgerID, categoryID int64) ([]Budget, error) {
 	// Retrieve all budgets associated with the category ID
 	rows, err := db.Query(`
-		SELECT id, category_id, amount, spent, start_date, end_date 
+		SELECT `+budgetColumns+`
 		FROM Budget 
-		WHERE category_id = $1
-	`, categoryID)
+		WHERE category_id = $1 AND ledger_id = $2
+	`, categoryID, ledgerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve budgets: %w", err)
 	}
@@ -121,8 +135,7 @@ func GetBudgetsByCategoryID(db *sql.DB, categoryID int64) ([]Budget, error) {
 	// Collect all budgets into a slice
 	var budgets []Budget
 	for rows.Next() {
-		var budget Budget
-		err := rows.Scan(&budget.ID, &budget.CategoryID, &budget.Amount, &budget.Spent, &budget.StartDate, &budget.EndDate)
+		budget, err := scanBudget(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan budget: %w", err)
 		}
@@ -137,35 +150,50 @@ func GetBudgetsByCategoryID(db *sql.DB, categoryID int64) ([]Budget, error) {
 	return budgets, nil
 }
 
-// CreateBudget adds a new budget to the database.
-func CreateBudget(db *sql.DB, budget Budget) (Budget, error) {
-	if budget.CategoryID == 0 {
-		return Budget{}, errors.New("category cannot be empty")
-	}
+// ValidateBudget validates the amount and period shared by budget creation and updates.
+func ValidateBudget(budget Budget) error {
 	if budget.Amount <= 0 {
-		return Budget{}, errors.New("amount must be greater than zero")
+		return NewValidationError("amount", "amount must be greater than zero")
 	}
 	if budget.StartDate.IsZero() {
-		return Budget{}, errors.New("start date must be provided")
+		return NewValidationError("start_date", "start date must be provided")
 	}
 	if budget.EndDate.IsZero() {
-		return Budget{}, errors.New("end date must be provided")
+		return NewValidationError("end_date", "end date must be provided")
 	}
 	if budget.EndDate.Before(budget.StartDate) {
-		return Budget{}, errors.New("end date must be after start date")
+		return NewValidationError("end_date", "end date must be after start date")
+	}
+	if _, err := NormalizeCurrency(budget.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateBudget adds a new budget to the database.
+func CreateBudget(db *sql.DB, ledgerID int64, budget Budget) (Budget, error) {
+	if budget.CategoryID == 0 {
+		return Budget{}, NewValidationError("category_id", "category cannot be empty")
+	}
+	if err := ValidateBudget(budget); err != nil {
+		return Budget{}, err
+	}
+	budget.Currency, _ = NormalizeCurrency(budget.Currency)
+	if err := checkCategory(db, ledgerID, budget.CategoryID); err != nil {
+		return Budget{}, err
 	}
 
 	// Check for overlapping budgets
-	overlap, err := DoesBudgetOverlap(db, budget.CategoryID, budget.StartDate, budget.EndDate, 0)
+	overlap, err := DoesBudgetOverlap(db, ledgerID, budget.CategoryID, budget.StartDate, budget.EndDate, 0)
 	if err != nil {
 		return Budget{}, fmt.Errorf("failed to validate budget overlap: %w", err)
 	}
 	if overlap {
-		return Budget{}, errors.New("budget dates overlap with an existing budget")
+		return Budget{}, NewConflictError("budget dates overlap with an existing budget")
 	}
 
 	// Calculate the total spent for the category and date range
-	totalSpent, err := CalculateTotalSpent(db, budget.CategoryID, budget.StartDate, budget.EndDate)
+	totalSpent, err := CalculateTotalSpent(db, ledgerID, budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
 	if err != nil {
 		return Budget{}, err
 	}
@@ -173,63 +201,74 @@ func CreateBudget(db *sql.DB, budget Budget) (Budget, error) {
 
 	// Set the calculated spent amount
 	budget.Spent = totalSpent
+	budget.Stale = false
 
 	var id int64
-	err = db.QueryRow("INSERT INTO Budget (category_id, amount, spent, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		budget.CategoryID, budget.Amount, budget.Spent, budget.StartDate, budget.EndDate).Scan(&id)
+	err = db.QueryRow("INSERT INTO Budget (category_id, amount, spent, currency, start_date, end_date, ledger_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		budget.CategoryID, budget.Amount, budget.Spent, budget.Currency, budget.StartDate, budget.EndDate, ledgerID).Scan(&id)
 	if err != nil {
-		return Budget{}, err
+		return Budget{}, missingCategory(err, budget.CategoryID)
 	}
 	budget.ID = id
 	return budget, nil
 }
 
 // UpdateBudget updates an existing budget's information.
-func UpdateBudget(db *sql.DB, budget Budget) (Budget, error) {
+func UpdateBudget(db *sql.DB, ledgerID int64, budget Budget) (Budget, error) {
 	if budget.CategoryID == 0 {
-		return Budget{}, errors.New("category id must be provided")
+		return Budget{}, NewValidationError("category_id", "category id must be provided")
 	}
 
-	if budget.Amount <= 0 {
-		return Budget{}, errors.New("amount must be greater than zero")
-	}
-	if budget.StartDate.IsZero() {
-		return Budget{}, errors.New("start date must be provided")
-	}
-	if budget.EndDate.IsZero() {
-		return Budget{}, errors.New("end date must be provided")
+	if err := ValidateBudget(budget); err != nil {
+		return Budget{}, err
 	}
-	if budget.EndDate.Before(budget.StartDate) {
-		return Budget{}, errors.New("end date must be after start date")
+
+	// Keep the budget's currency unless a new one is given
+	if budget.Currency == "" {
+		err := db.QueryRow("SELECT currency FROM Budget WHERE id = $1 AND ledger_id = $2", budget.ID, ledgerID).Scan(&budget.Currency)
+		if err != nil {
+			return Budget{}, notFound(err, "budget")
+		}
+	} else {
+		budget.Currency, _ = NormalizeCurrency(budget.Currency)
 	}
 
 	// Check for overlapping budgets
-	overlap, err := DoesBudgetOverlap(db, budget.CategoryID, budget.StartDate, budget.EndDate, budget.ID)
+	if err := checkCategory(db, ledgerID, budget.CategoryID); err != nil {
+		return Budget{}, err
+	}
+	overlap, err := DoesBudgetOverlap(db, ledgerID, budget.CategoryID, budget.StartDate, budget.EndDate, budget.ID)
 	if err != nil {
 		return Budget{}, fmt.Errorf("failed to validate budget overlap: %w", err)
 	}
 	if overlap {
-		return Budget{}, errors.New("budget dates overlap with an existing budget")
+		return Budget{}, NewConflictError("budget dates overlap with an existing budget")
 	}
 
 	// Calculate the total spent for the category and date range
-	totalSpent, err := CalculateTotalSpent(db, budget.CategoryID, budget.StartDate, budget.EndDate)
+	totalSpent, err := CalculateTotalSpent(db, ledgerID, budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
 	if err != nil {
 		return Budget{}, err
 	}
 	budget.Spent = totalSpent
+	budget.Stale = false
 
-	_, err = db.Exec("UPDATE Budget SET amount = $1, spent = $2, start_date = $3, end_date = $4 WHERE category_id = $5",
-		budget.Amount, budget.Spent, budget.StartDate, budget.EndDate, budget.CategoryID)
+	result, err := db.Exec("UPDATE Budget SET category_id = $1, amount = $2, spent = $3, currency = $4, start_date = $5, end_date = $6, stale = FALSE WHERE id = $7 AND ledger_id = $8",
+		budget.CategoryID, budget.Amount, budget.Spent, budget.Currency, budget.StartDate, budget.EndDate, budget.ID, ledgerID)
 	if err != nil {
 		return Budget{}, err
 	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return Budget{}, err
+	} else if rowsAffected == 0 {
+		return Budget{}, NewNotFoundError("budget")
+	}
 	return budget, nil
 }
 
-// DeleteBudget removes a budget by category name.
-func DeleteBudget(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM Budget WHERE id = $1", id)
+// DeleteBudget removes a budget by id.
+func DeleteBudget(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM Budget WHERE id = $1 AND ledger_id = $2", id, ledgerID)
 	if err != nil {
 		return fmt.Errorf("failed to execute delete query: %w", err)
 	}
@@ -240,42 +279,141 @@ func DeleteBudget(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return NewNotFoundError("budget")
 	}
 	return nil
 }
 
-func DoesBudgetOverlap(db *sql.DB, categoryID int64, startDate, endDate time.Time, excludeBudgetID int64) (bool, error) {
-	// Check if the budget overlaps with any existing budget other than the one being updated
+func DoesBudgetOverlap(db *sql.DB, ledgerID, categoryID int64, startDate, endDate time.Time, excludeBudgetID int64) (bool, error) {
+	// Check if the budget overlaps with any existing budget other than the
+	// one being updated, on the category or on one above or below it, since
+	// their budgets would count the same spending
 	query := `
+		WITH RECURSIVE ` + subcategoriesCTE + `, ` + parentCategoriesCTE + `
 		SELECT EXISTS (
 			SELECT 1
 			FROM Budget
-			WHERE category_id = $1
-			AND id <> $4
+			WHERE (category_id IN (SELECT id FROM subcategories) OR category_id IN (SELECT id FROM parent_categories))
+			AND id <> $4 AND ledger_id = $5
 			AND (
 				(start_date <= $3 AND end_date >= $2)
 			)
 		)
 	`
 	var exists bool
-	err := db.QueryRow(query, categoryID, startDate, endDate, excludeBudgetID).Scan(&exists)
+	err := db.QueryRow(query, categoryID, startDate, endDate, excludeBudgetID, ledgerID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check budget overlap: %w", err)
 	}
 	return exists, nil
 }
 
-func CalculateTotalSpent(db *sql.DB, categoryID int64, startDate, endDate time.Time) (float64, error) {
-	var totalSpent float64
-	err := db.QueryRow(`
-		SELECT COALESCE(SUM(amount), 0)
-		FROM Expense
-		WHERE category_id = $1 AND date >= $2 AND date <= $3
-	`, categoryID, startDate, endDate).Scan(&totalSpent)
+// CalculateTotalSpent sums the expenses, and the split lines of split
+// expenses, in the period of the category and its sub-categories, converting
+// each one into the budget currency at the rate for its date. If no rate can
+// convert some of them, it returns the total of the others along with an
+// ErrNoExchangeRate error.
+func CalculateTotalSpent(db *sql.DB, ledgerID, categoryID int64, currency string, startDate, endDate time.Time) (Money, error) {
+	return calculateTotalSpent(db, ledgerID, categoryID, currency, startDate, endDate)
+}
 
+func calculateTotalSpent(q querier, ledgerID, categoryID int64, currency string, startDate, endDate time.Time) (Money, error) {
+	rows, err := q.Query(`
+		WITH RECURSIVE `+subcategoriesCTE+`, `+expenseAllocationsCTE+`
+		SELECT currency, date, COALESCE(SUM(amount), 0)
+		FROM expense_allocations
+		WHERE category_id IN (SELECT id FROM subcategories) AND date >= $2 AND date <= $3 AND ledger_id = $4
+		GROUP BY currency, date
+	`, categoryID, startDate, endDate, ledgerID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to calculate spent amount: %w", err)
 	}
-	return totalSpent, nil
+
+	// Read every group before converting, since the rate lookups need the connection
+	var groups []Expense
+	for rows.Next() {
+		var group Expense
+		if err := rows.Scan(&group.Currency, &group.Date, &group.Amount); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to calculate spent amount: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to calculate spent amount: %w", err)
+	}
+
+	return sumConverted(groups, currency, rateCache(q))
+}
+
+// recalculateBudgets recomputes the spent amount of every budget on the
+// category and the categories above it, for when the expenses below them
+// change other than one at a time.
+func recalculateBudgets(q querier, ledgerID, categoryID int64) error {
+	budgets, err := queryBudgets(q, "WITH RECURSIVE "+parentCategoriesCTE+`
+		SELECT `+budgetColumns+` FROM Budget WHERE category_id IN (SELECT id FROM parent_categories) AND ledger_id = $2`, categoryID, ledgerID)
+	if err != nil {
+		return err
+	}
+
+	for _, budget := range budgets {
+		if err := recalculateBudget(q, ledgerID, budget); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recalculateBudget recomputes the spent amount of a budget, marking it stale
+// rather than failing when a rate is missing.
+func recalculateBudget(q querier, ledgerID int64, budget Budget) error {
+	spent, err := calculateTotalSpent(q, ledgerID, budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
+	stale := errors.Is(err, ErrNoExchangeRate)
+	if err != nil && !stale {
+		return err
+	}
+	_, err = q.Exec("UPDATE Budget SET spent = $1, stale = $2 WHERE id = $3", spent, stale, budget.ID)
+	return err
+}
+
+// sumConverted adds up expenses after converting them into one currency. It
+// leaves out those no rate can convert, returning the first such error along
+// with the total of the others.
+func sumConverted(expenses []Expense, currency string, rates RateFunc) (Money, error) {
+	var total Money
+	var missing error
+	for _, expense := range expenses {
+		amount, err := convertWith(rates, expense.Amount, expense.Currency, currency, expense.Date)
+		if errors.Is(err, ErrNoExchangeRate) {
+			if missing == nil {
+				missing = err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += amount
+	}
+	return total, missing
+}
+
+// queryBudgets reads every budget a query returns.
+func queryBudgets(q querier, query string, args ...any) ([]Budget, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, rows.Err()
 }
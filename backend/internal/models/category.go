@@ -2,18 +2,52 @@ package models
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
+	"slices"
 )
 
 type Category struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// ParentID is the category this one is a sub-category of. Budgets on a
+	// category cover the spending in all of its sub-categories.
+	ParentID *int64 `json:"parent_id,omitempty"`
+
+	// Children holds the sub-categories when categories are listed as a tree.
+	Children []Category `json:"children,omitempty"`
 }
 
-func GetCategories(db *sql.DB) ([]Category, error) {
-	rows, err := db.Query("SELECT id, name, description FROM Category")
+// categoryColumns lists the columns read by scanCategory, in order.
+const categoryColumns = "id, name, description, parent_id"
+
+func scanCategory(row rowScanner) (Category, error) {
+	var category Category
+	err := row.Scan(&category.ID, &category.Name, &category.Description, &category.ParentID)
+	return category, err
+}
+
+// Recursive query parts for the categories below and above the category $1,
+// each including the category itself. UNION rather than UNION ALL keeps them
+// finite even if the tree ever held a cycle.
+const (
+	subcategoriesCTE = `subcategories(id) AS (
+		SELECT id FROM Category WHERE id = $1
+		UNION
+		SELECT c.id FROM Category c JOIN subcategories s ON c.parent_id = s.id
+	)`
+	parentCategoriesCTE = `parent_categories(id) AS (
+		SELECT id FROM Category WHERE id = $1
+		UNION
+		SELECT c.parent_id FROM Category c JOIN parent_categories p ON c.id = p.id WHERE c.parent_id IS NOT NULL
+	)`
+)
+
+// GetCategories returns the ledger's categories and the shared 'Other'
+// category.
+func GetCategories(db *sql.DB, ledgerID int64) ([]Category, error) {
+	rows, err := db.Query("SELECT "+categoryColumns+" FROM Category WHERE ledger_id = $1 OR id = 1 ORDER BY id", ledgerID)
 	if err != nil {
 		return nil, err
 	}
@@ -21,8 +55,7 @@ func GetCategories(db *sql.DB) ([]Category, error) {
 
 	var categories []Category
 	for rows.Next() {
-		var category Category
-		err := rows.Scan(&category.ID, &category.Name, &category.Description)
+		category, err := scanCategory(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -31,71 +64,378 @@ func GetCategories(db *sql.DB) ([]Category, error) {
 	return categories, nil
 }
 
-func GetCategoryByID(db *sql.DB, id int64) (Category, error) {
-	var category Category
-	err := db.QueryRow("SELECT id, name, description FROM Category WHERE id = $1", id).
-		Scan(&category.ID, &category.Name, &category.Description)
+// ListCategories returns one page of the categories whose name or
+// description matches the search in opts, along with the total number of
+// matches.
+func ListCategories(db *sql.DB, ledgerID int64, opts ListOptions) ([]Category, int, error) {
+	var q listQuery
+	q.where("(ledger_id = ? OR id = 1)", ledgerID)
+	q.search(opts, "name", "description")
+	return list(db, "Category", categoryColumns, CategorySortFields, q, opts, scanCategory)
+}
+
+func GetCategoryByID(db *sql.DB, ledgerID, id int64) (Category, error) {
+	category, err := scanCategory(db.QueryRow("SELECT "+categoryColumns+" FROM Category WHERE id = $1 AND (ledger_id = $2 OR id = 1)", id, ledgerID))
 	if err != nil {
-		return Category{}, err
+		return Category{}, notFound(err, "category")
 	}
 	return category, nil
 }
 
-func CreateCategory(db *sql.DB, category Category) (Category, error) {
+// CategoryTree nests categories under their parents, returning the top-level
+// ones in the order given.
+func CategoryTree(categories []Category) []Category {
+	known := map[int64]bool{}
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+	children := map[int64][]Category{}
+	var roots []Category
+	for _, category := range categories {
+		if category.ParentID != nil && known[*category.ParentID] {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+
+	var attach func(nodes []Category) []Category
+	attach = func(nodes []Category) []Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
+}
+
+// missingCategory reports a write that referenced a category that does not
+// exist as a validation error, and returns any other error unchanged.
+func missingCategory(err error, categoryID int64) error {
+	if isForeignKeyViolation(err) {
+		return NewValidationError("category_id", "category %d does not exist", categoryID)
+	}
+	return err
+}
+
+// duplicateCategory reports a clash with an existing category's name as a
+// conflict, and returns any other error unchanged.
+func duplicateCategory(err error, name string) error {
+	if isUniqueViolation(err) {
+		return NewConflictError("category %q already exists", name)
+	}
+	return err
+}
+
+// ValidateCategory validates fields for creating a category.
+func ValidateCategory(category Category) error {
 	if category.Name == "" {
-		return Category{}, errors.New("name must be provided")
+		return NewValidationError("name", "name must be provided")
 	}
+	return nil
+}
 
-	err := db.QueryRow(
-		"INSERT INTO Category (name, description) VALUES ($1, $2) RETURNING id",
-		category.Name, category.Description,
-	).Scan(&category.ID)
+// checkCategoryName reports a category named like the shared 'Other'
+// category as a conflict. Names are otherwise unique per ledger.
+func checkCategoryName(q querier, category Category) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM Category WHERE id = 1 AND name = $1)", category.Name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return NewConflictError("category %q already exists", category.Name)
+	}
+	return nil
+}
+
+// checkCategoryParent checks that a category's parent is one of the ledger's
+// categories and is not the category itself or one of its sub-categories,
+// which would make a cycle.
+func checkCategoryParent(q querier, ledgerID int64, category Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	if *category.ParentID == category.ID {
+		return NewValidationError("parent_id", "a category cannot be its own parent")
+	}
+	var exists, descendant bool
+	err := q.QueryRow("WITH RECURSIVE "+subcategoriesCTE+`
+		SELECT EXISTS (SELECT 1 FROM Category WHERE id = $2 AND (ledger_id = $3 OR id = 1)),
+			EXISTS (SELECT 1 FROM subcategories WHERE id = $2)`,
+		category.ID, *category.ParentID, ledgerID,
+	).Scan(&exists, &descendant)
 	if err != nil {
+		return err
+	}
+	if !exists {
+		return NewValidationError("parent_id", "parent category %d does not exist", *category.ParentID)
+	}
+	if descendant {
+		return NewValidationError("parent_id", "a category cannot be moved under one of its own sub-categories")
+	}
+	return nil
+}
+
+func CreateCategory(db *sql.DB, ledgerID int64, category Category) (Category, error) {
+	if err := ValidateCategory(category); err != nil {
 		return Category{}, err
 	}
+
+	category.ID = 0
+	err := withTx(db, func(tx *sql.Tx) error {
+		if err := checkCategoryName(tx, category); err != nil {
+			return err
+		}
+		if err := checkCategoryParent(tx, ledgerID, category); err != nil {
+			return err
+		}
+		return tx.QueryRow(
+			"INSERT INTO Category (name, description, parent_id, ledger_id) VALUES ($1, $2, $3, $4) RETURNING id",
+			category.Name, category.Description, category.ParentID, ledgerID,
+		).Scan(&category.ID)
+	})
+	if err != nil {
+		return Category{}, duplicateCategory(err, category.Name)
+	}
 	return category, nil
 }
 
-func UpdateCategory(db *sql.DB, category Category) (Category, error) {
+func UpdateCategory(db *sql.DB, ledgerID int64, category Category) (Category, error) {
 	if category.ID == 0 {
-		return Category{}, errors.New("id must be provided")
+		return Category{}, NewValidationError("id", "id must be provided")
+	}
+	// The 'Other' category is shared by every ledger
+	if category.ID == 1 {
+		return Category{}, NewForbiddenError("cannot change the 'Other' category")
 	}
 
-	_, err := db.Exec(
-		"UPDATE Category SET name = $1, description = $2 WHERE id = $3",
-		category.Name, category.Description, category.ID,
-	)
+	err := withTx(db, func(tx *sql.Tx) error {
+		var oldParentID *int64
+		if err := tx.QueryRow("SELECT parent_id FROM Category WHERE id = $1 AND ledger_id = $2", category.ID, ledgerID).Scan(&oldParentID); err != nil {
+			return notFound(err, "category")
+		}
+		if err := checkCategoryName(tx, category); err != nil {
+			return err
+		}
+		if err := checkCategoryParent(tx, ledgerID, category); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE Category SET name = $1, description = $2, parent_id = $3 WHERE id = $4",
+			category.Name, category.Description, category.ParentID, category.ID,
+		); err != nil {
+			return err
+		}
+
+		// Moving the category moves its spending from the budgets above its
+		// old place to those above its new one
+		if sameParent(oldParentID, category.ParentID) {
+			return nil
+		}
+		for _, parentID := range []*int64{oldParentID, category.ParentID} {
+			if parentID == nil {
+				continue
+			}
+			if err := recalculateBudgets(tx, ledgerID, *parentID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return Category{}, err
+		return Category{}, duplicateCategory(err, category.Name)
 	}
 	return category, nil
 }
 
-func DeleteCategory(db *sql.DB, id int64) error {
+func sameParent(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// DeleteCategory removes a category. Its sub-categories and expenses move up
+// to its parent, or for a top-level category the sub-categories become
+// top-level and the expenses go to 'Other'.
+func DeleteCategory(db *sql.DB, ledgerID, id int64) error {
 	// Prevent deletion of the "Other" category
 	if id == 1 {
-		return fmt.Errorf("cannot delete the 'Other' category")
+		return NewForbiddenError("cannot delete the 'Other' category")
 	}
 
-	// Reassign all expenses to the "Other" category
-	_, err := db.Exec("UPDATE Expense SET category_id = 1 WHERE category_id = $1", id)
-	if err != nil {
-		return fmt.Errorf("failed to reassign expenses to 'Other': %w", err)
+	// Re-home the children and expenses and delete the category together, so
+	// a failed delete does not leave them moved
+	return withTx(db, func(tx *sql.Tx) error {
+		var parentID *int64
+		if err := tx.QueryRow("SELECT parent_id FROM Category WHERE id = $1 AND ledger_id = $2", id, ledgerID).Scan(&parentID); err != nil {
+			return notFound(err, "category")
+		}
+
+		if _, err := tx.Exec("UPDATE Category SET parent_id = $1 WHERE parent_id = $2", parentID, id); err != nil {
+			return fmt.Errorf("failed to re-home sub-categories: %w", err)
+		}
+
+		// Reassign all expenses and split lines to the parent, or the "Other" category
+		newCategoryID := int64(1)
+		if parentID != nil {
+			newCategoryID = *parentID
+		}
+		for _, table := range []string{"Expense", "ExpenseSplit"} {
+			if _, err := tx.Exec("UPDATE "+table+" SET category_id = $1 WHERE category_id = $2", newCategoryID, id); err != nil {
+				return fmt.Errorf("failed to reassign expenses: %w", err)
+			}
+		}
+
+		result, err := tx.Exec("DELETE FROM Category WHERE id = $1", id)
+		if err != nil {
+			return fmt.Errorf("failed to execute delete query: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return NewNotFoundError("category")
+		}
+
+		// The budgets of the category gaining the expenses count them
+		return recalculateBudgets(tx, ledgerID, newCategoryID)
+	})
+}
+
+// What MergeCategory does with a budget of the merged category whose period
+// overlaps a budget of the target, or of a category above or below it
+const (
+	// MergeBudgetsSum adds the merged budget's amount to the budget it
+	// overlaps, the earliest if there are several
+	MergeBudgetsSum = "sum"
+	// MergeBudgetsKeepTarget drops the merged budget
+	MergeBudgetsKeepTarget = "keep_target"
+	// MergeBudgetsFail refuses the merge
+	MergeBudgetsFail = "fail"
+)
+
+// ValidateMergeStrategy checks a budget merge strategy.
+func ValidateMergeStrategy(strategy string) error {
+	switch strategy {
+	case MergeBudgetsSum, MergeBudgetsKeepTarget, MergeBudgetsFail:
+		return nil
 	}
+	return NewValidationError("strategy", "unknown budget strategy %q (expected sum, keep_target or fail)", strategy)
+}
 
-	result, err := db.Exec("DELETE FROM Category WHERE id = $1", id)
-	if err != nil {
-		return fmt.Errorf("failed to execute delete query: %w", err)
+// MergeCategory folds one category into another: its expenses, budgets,
+// categorization rules, recurring rules, import profiles and sub-categories
+// move to the target, budgets that overlap the target's are reconciled by
+// strategy, budget spend is recomputed and the merged category is deleted.
+// It returns the target.
+func MergeCategory(db *sql.DB, ledgerID, sourceID, targetID int64, strategy string) (Category, error) {
+	if err := ValidateMergeStrategy(strategy); err != nil {
+		return Category{}, err
 	}
+	if sourceID == targetID {
+		return Category{}, NewValidationError("target", "a category cannot be merged into itself")
+	}
+	if sourceID == 1 {
+		return Category{}, NewForbiddenError("cannot merge the 'Other' category")
+	}
+
+	var target Category
+	err := withTx(db, func(tx *sql.Tx) error {
+		source, err := scanCategory(tx.QueryRow("SELECT "+categoryColumns+" FROM Category WHERE id = $1 AND ledger_id = $2", sourceID, ledgerID))
+		if err != nil {
+			return notFound(err, "category")
+		}
+		target, err = scanCategory(tx.QueryRow("SELECT "+categoryColumns+" FROM Category WHERE id = $1 AND (ledger_id = $2 OR id = 1)", targetID, ledgerID))
+		if err != nil {
+			return notFound(err, "category")
+		}
+		var below bool
+		err = tx.QueryRow("WITH RECURSIVE "+subcategoriesCTE+" SELECT EXISTS (SELECT 1 FROM subcategories WHERE id = $2)",
+			sourceID, targetID).Scan(&below)
+		if err != nil {
+			return err
+		}
+		if below {
+			return NewValidationError("target", "a category cannot be merged into one of its own sub-categories")
+		}
+
+		if _, err := tx.Exec("UPDATE Category SET parent_id = $1 WHERE parent_id = $2", targetID, sourceID); err != nil {
+			return err
+		}
+		if err := mergeBudgets(tx, ledgerID, sourceID, targetID, strategy); err != nil {
+			return err
+		}
+		for _, table := range []string{"Expense", "ExpenseSplit", "CategoryRule", "RecurringRule", "ImportProfile"} {
+			if _, err := tx.Exec("UPDATE "+table+" SET category_id = $1 WHERE category_id = $2", targetID, sourceID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM Category WHERE id = $1", sourceID); err != nil {
+			return err
+		}
 
-	rowsAffected, err := result.RowsAffected()
+		// The target's budgets gain the merged spending and those above the
+		// merged category lose it
+		if err := recalculateBudgets(tx, ledgerID, targetID); err != nil {
+			return err
+		}
+		if source.ParentID != nil {
+			return recalculateBudgets(tx, ledgerID, *source.ParentID)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return Category{}, err
 	}
+	return target, nil
+}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+// mergeBudgets moves the budgets of the source category to the target,
+// reconciling those that overlap a budget of the target or of a category
+// above or below it by strategy.
+func mergeBudgets(tx *sql.Tx, ledgerID, sourceID, targetID int64, strategy string) error {
+	sources, err := queryBudgets(tx, "SELECT "+budgetColumns+" FROM Budget WHERE category_id = $1 ORDER BY start_date, id", sourceID)
+	if err != nil || len(sources) == 0 {
+		return err
 	}
+	targets, err := queryBudgets(tx, "WITH RECURSIVE "+subcategoriesCTE+", "+parentCategoriesCTE+`
+		SELECT `+budgetColumns+` FROM Budget
+		WHERE (category_id IN (SELECT id FROM subcategories) OR category_id IN (SELECT id FROM parent_categories)) AND category_id <> $2
+			AND ledger_id = $3
+		ORDER BY start_date, id`, targetID, sourceID, ledgerID)
+	if err != nil {
+		return err
+	}
+
+	rates := rateCache(tx)
+	for _, budget := range sources {
+		i := slices.IndexFunc(targets, func(target Budget) bool {
+			return !target.StartDate.After(budget.EndDate) && !target.EndDate.Before(budget.StartDate)
+		})
+		if i < 0 {
+			if _, err := tx.Exec("UPDATE Budget SET category_id = $1 WHERE id = $2", targetID, budget.ID); err != nil {
+				return err
+			}
+			continue
+		}
 
+		switch strategy {
+		case MergeBudgetsFail:
+			return NewConflictError("budget %d overlaps budget %d of the target category", budget.ID, targets[i].ID)
+		case MergeBudgetsSum:
+			amount, err := convertWith(rates, budget.Amount, budget.Currency, targets[i].Currency, targets[i].StartDate)
+			if err != nil {
+				return err
+			}
+			targets[i].Amount += amount
+			if _, err := tx.Exec("UPDATE Budget SET amount = $1 WHERE id = $2", targets[i].Amount, targets[i].ID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM Budget WHERE id = $1", budget.ID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CategoryRule assigns a category to expenses that match all of its
+// conditions. Rules are tried in ascending Priority, then by id, and the
+// first match wins.
+type CategoryRule struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Priority   int    `json:"priority"`
+	CategoryID int64  `json:"category_id"`
+
+	// DescriptionContains matches descriptions containing it, ignoring case.
+	// DescriptionPattern is a regular expression the description must match.
+	DescriptionContains string `json:"description_contains,omitempty"`
+	DescriptionPattern  string `json:"description_pattern,omitempty"`
+
+	// MinAmount and MaxAmount bound the amount, inclusive, in whatever
+	// currency the expense is recorded in.
+	MinAmount *Money `json:"min_amount,omitempty"`
+	MaxAmount *Money `json:"max_amount,omitempty"`
+
+	// AccountID limits the rule to expenses paid from one account.
+	AccountID *int64 `json:"account_id,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// categoryRuleColumns lists the columns read by scanCategoryRule, in order.
+const categoryRuleColumns = "id, name, priority, category_id, description_contains, description_pattern, min_amount, max_amount, account_id"
+
+func scanCategoryRule(row rowScanner) (CategoryRule, error) {
+	var rule CategoryRule
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Priority, &rule.CategoryID, &rule.DescriptionContains,
+		&rule.DescriptionPattern, &rule.MinAmount, &rule.MaxAmount, &rule.AccountID)
+	if err != nil {
+		return CategoryRule{}, err
+	}
+	if rule.DescriptionPattern != "" {
+		if rule.pattern, err = regexp.Compile(rule.DescriptionPattern); err != nil {
+			return CategoryRule{}, fmt.Errorf("category rule %d: %w", rule.ID, err)
+		}
+	}
+	return rule, nil
+}
+
+// ValidateCategoryRuleConditions checks the conditions of a rule, which
+// must include at least one, and compiles its pattern.
+func ValidateCategoryRuleConditions(rule CategoryRule) (CategoryRule, error) {
+	if rule.DescriptionContains == "" && rule.DescriptionPattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil && rule.AccountID == nil {
+		return CategoryRule{}, NewValidationError("description_contains", "a rule needs at least one condition")
+	}
+	if rule.DescriptionPattern != "" {
+		pattern, err := regexp.Compile(rule.DescriptionPattern)
+		if err != nil {
+			return CategoryRule{}, NewValidationError("description_pattern", "invalid description pattern: %v", err)
+		}
+		rule.pattern = pattern
+	}
+	if rule.MinAmount != nil && *rule.MinAmount < 0 {
+		return CategoryRule{}, NewValidationError("min_amount", "minimum amount cannot be negative")
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MaxAmount < *rule.MinAmount {
+		return CategoryRule{}, NewValidationError("max_amount", "maximum amount must not be less than the minimum")
+	}
+	return rule, nil
+}
+
+// NormalizeCategoryRule checks all of a rule's fields.
+func NormalizeCategoryRule(rule CategoryRule) (CategoryRule, error) {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return CategoryRule{}, NewValidationError("name", "name must be provided")
+	}
+	if len(rule.Name) > 255 {
+		return CategoryRule{}, NewValidationError("name", "name is too long (max 255 characters)")
+	}
+	if rule.CategoryID <= 0 {
+		return CategoryRule{}, NewValidationError("category_id", "category ID must be provided")
+	}
+	return ValidateCategoryRuleConditions(rule)
+}
+
+// Matches reports whether an expense meets every condition of the rule.
+func (rule CategoryRule) Matches(expense Expense) bool {
+	if rule.DescriptionContains != "" && !strings.Contains(strings.ToLower(expense.Description), strings.ToLower(rule.DescriptionContains)) {
+		return false
+	}
+	if rule.DescriptionPattern != "" {
+		pattern := rule.pattern
+		if pattern == nil {
+			var err error
+			if pattern, err = regexp.Compile(rule.DescriptionPattern); err != nil {
+				return false
+			}
+		}
+		if !pattern.MatchString(expense.Description) {
+			return false
+		}
+	}
+	if rule.MinAmount != nil && expense.Amount < *rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount != nil && expense.Amount > *rule.MaxAmount {
+		return false
+	}
+	if rule.AccountID != nil && (expense.AccountID == nil || *expense.AccountID != *rule.AccountID) {
+		return false
+	}
+	return true
+}
+
+// Categorize returns the category of the first of rules, in priority order,
+// that matches the expense, or fallback if none does.
+func Categorize(rules []CategoryRule, expense Expense, fallback int64) int64 {
+	for _, rule := range rules {
+		if rule.Matches(expense) {
+			return rule.CategoryID
+		}
+	}
+	return fallback
+}
+
+func GetCategoryRules(db *sql.DB, ledgerID int64) ([]CategoryRule, error) {
+	return getCategoryRules(db, ledgerID)
+}
+
+// getCategoryRules returns every rule of the ledger in the order they are
+// tried.
+func getCategoryRules(q querier, ledgerID int64) ([]CategoryRule, error) {
+	rows, err := q.Query("SELECT "+categoryRuleColumns+" FROM CategoryRule WHERE ledger_id = $1 ORDER BY priority, id", ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []CategoryRule
+	for rows.Next() {
+		rule, err := scanCategoryRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func GetCategoryRuleByID(db *sql.DB, ledgerID, id int64) (CategoryRule, error) {
+	rule, err := scanCategoryRule(db.QueryRow("SELECT "+categoryRuleColumns+" FROM CategoryRule WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return CategoryRule{}, notFound(err, "category rule")
+	}
+	return rule, nil
+}
+
+// checkRuleReferences reports a rule's missing category or account as a
+// validation error.
+func checkRuleReferences(q querier, ledgerID int64, rule CategoryRule) error {
+	if err := checkCategory(q, ledgerID, rule.CategoryID); err != nil {
+		return err
+	}
+	if rule.AccountID != nil {
+		var exists bool
+		if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM Account WHERE id = $1 AND ledger_id = $2)", *rule.AccountID, ledgerID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return NewValidationError("account_id", "account %d does not exist", *rule.AccountID)
+		}
+	}
+	return nil
+}
+
+func CreateCategoryRule(db *sql.DB, ledgerID int64, rule CategoryRule) (CategoryRule, error) {
+	rule, err := NormalizeCategoryRule(rule)
+	if err != nil {
+		return CategoryRule{}, err
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if err := checkRuleReferences(tx, ledgerID, rule); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO CategoryRule (name, priority, category_id, description_contains, description_pattern,
+				min_amount, max_amount, account_id, ledger_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+			rule.Name, rule.Priority, rule.CategoryID, rule.DescriptionContains, rule.DescriptionPattern,
+			rule.MinAmount, rule.MaxAmount, rule.AccountID, ledgerID,
+		).Scan(&rule.ID)
+	})
+	if err != nil {
+		return CategoryRule{}, err
+	}
+	return rule, nil
+}
+
+// UpdateCategoryRule replaces a rule's fields.
+func UpdateCategoryRule(db *sql.DB, ledgerID int64, rule CategoryRule) (CategoryRule, error) {
+	rule, err := NormalizeCategoryRule(rule)
+	if err != nil {
+		return CategoryRule{}, err
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if err := checkRuleReferences(tx, ledgerID, rule); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			UPDATE CategoryRule SET name = $1, priority = $2, category_id = $3, description_contains = $4,
+				description_pattern = $5, min_amount = $6, max_amount = $7, account_id = $8
+			WHERE id = $9 AND ledger_id = $10`,
+			rule.Name, rule.Priority, rule.CategoryID, rule.DescriptionContains, rule.DescriptionPattern,
+			rule.MinAmount, rule.MaxAmount, rule.AccountID, rule.ID, ledgerID,
+		)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return NewNotFoundError("category rule")
+		}
+		return nil
+	})
+	if err != nil {
+		return CategoryRule{}, err
+	}
+	return rule, nil
+}
+
+func DeleteCategoryRule(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM CategoryRule WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("category rule")
+	}
+	return nil
+}
+
+// TestCategoryRule returns the recorded expenses a rule would match,
+// whatever their category, without saving the rule.
+func TestCategoryRule(db *sql.DB, ledgerID int64, rule CategoryRule) ([]Expense, error) {
+	rule, err := ValidateCategoryRuleConditions(rule)
+	if err != nil {
+		return nil, err
+	}
+	expenses, err := GetExpenses(db, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Expense
+	for _, expense := range expenses {
+		if rule.Matches(expense) {
+			matches = append(matches, expense)
+		}
+	}
+	return matches, nil
+}
+
+// ApplyCategoryRules runs the rules over the expenses still in the 'Other'
+// category and moves those a rule matches, keeping budgets in step. Split
+// expenses are left alone, since their lines carry their own categories. It
+// returns the moved expenses.
+func ApplyCategoryRules(db *sql.DB, ledgerID int64) ([]Expense, error) {
+	var moved []Expense
+	err := withTx(db, func(tx *sql.Tx) error {
+		moved = nil
+		rules, err := getCategoryRules(tx, ledgerID)
+		if err != nil || len(rules) == 0 {
+			return err
+		}
+
+		rows, err := tx.Query("SELECT "+expenseColumns+" FROM Expense e WHERE category_id = $1 AND ledger_id = $2 AND NOT EXISTS (SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = e.id) ORDER BY id", 1, ledgerID)
+		if err != nil {
+			return err
+		}
+		var uncategorized []Expense
+		for rows.Next() {
+			expense, err := scanExpense(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			uncategorized = append(uncategorized, expense)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, expense := range uncategorized {
+			categoryID := Categorize(rules, expense, 1)
+			if categoryID == 1 {
+				continue
+			}
+			if err := adjustBudgetSpent(tx, ledgerID, expense, -1); err != nil {
+				return err
+			}
+			expense.CategoryID = categoryID
+			if _, err := tx.Exec("UPDATE Expense SET category_id = $1 WHERE id = $2", categoryID, expense.ID); err != nil {
+				return err
+			}
+			if err := adjustBudgetSpent(tx, ledgerID, expense, 1); err != nil {
+				return err
+			}
+			moved = append(moved, expense)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is assumed for amounts recorded without a currency, and
+// for rows created before currencies were tracked.
+const DefaultCurrency = "USD"
+
+// currencyPattern matches ISO 4217 alphabetic codes.
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ErrNoExchangeRate is returned when no stored rate can convert between two
+// currencies on or before the requested date.
+var ErrNoExchangeRate = errors.New("no exchange rate available")
+
+// NormalizeCurrency upper-cases a currency code and checks that it looks
+// like an ISO 4217 code. An empty code becomes DefaultCurrency.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, nil
+	}
+	if !currencyPattern.MatchString(code) {
+		return "", NewValidationError("currency", "invalid currency code %q", code)
+	}
+	return code, nil
+}
+
+// Conversion is an amount expressed in another currency, together with the
+// rate that was used.
+type Conversion struct {
+	Amount   Money  `json:"amount"`
+	Currency string `json:"currency"`
+	Rate     string `json:"rate"`
+}
+
+// Convert applies an exchange rate to an amount.
+func Convert(amount Money, currency string, rate *big.Rat) Conversion {
+	return Conversion{Amount: amount.MulRat(rate), Currency: currency, Rate: formatRate(rate)}
+}
+
+// formatRate prints a rate with up to eight decimal places, the precision
+// stored in the ExchangeRate table, without trailing zeros.
+func formatRate(rate *big.Rat) string {
+	s := rate.FloatString(8)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// RateFunc looks up how many units of the to currency one unit of the from
+// currency was worth on a date.
+type RateFunc func(from, to string, on time.Time) (*big.Rat, error)
+
+// ConvertAmount converts an amount between currencies using the stored rate
+// for the given date.
+func ConvertAmount(db *sql.DB, amount Money, from, to string, on time.Time) (Conversion, error) {
+	rate, err := lookupRate(db, from, to, on)
+	if err != nil {
+		return Conversion{}, err
+	}
+	return Convert(amount, to, rate), nil
+}
+
+// convertWith converts an amount using a rate lookup. Amounts that are
+// already in the target currency are returned unchanged.
+func convertWith(rates RateFunc, amount Money, from, to string, on time.Time) (Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	rate, err := rates(from, to, on)
+	if err != nil {
+		return 0, err
+	}
+	return amount.MulRat(rate), nil
+}
+
+// lookupRate finds the most recent rate on or before the date. A rate stored
+// for the opposite direction is inverted when no direct rate exists.
+func lookupRate(q querier, from, to string, on time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	var rate, base string
+	err := q.QueryRow(`
+		SELECT rate, base_currency
+		FROM ExchangeRate
+		WHERE ((base_currency = $1 AND quote_currency = $2) OR (base_currency = $2 AND quote_currency = $1))
+		AND date <= $3
+		ORDER BY date DESC, base_currency = $1 DESC
+		LIMIT 1
+	`, from, to, on).Scan(&rate, &base)
+	if err == sql.ErrNoRows {
+		return nil, noRateError(from, to, on)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up exchange rate: %w", err)
+	}
+
+	r, err := parseRat(rate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange rate %q: %w", rate, err)
+	}
+	if base != from {
+		r.Inv(r)
+	}
+	return r, nil
+}
+
+// rateCache memoizes lookups made by a single summary or budget calculation,
+// which usually convert many amounts on the same few dates.
+func rateCache(q querier) RateFunc {
+	type key struct {
+		from, to string
+		on       time.Time
+	}
+	cache := map[key]*big.Rat{}
+	return func(from, to string, on time.Time) (*big.Rat, error) {
+		k := key{from, to, on}
+		if rate, ok := cache[k]; ok {
+			return rate, nil
+		}
+		rate, err := lookupRate(q, from, to, on)
+		if err != nil {
+			return nil, err
+		}
+		cache[k] = rate
+		return rate, nil
+	}
+}
+
+// FindExchangeRate applies the same lookup rules as the database query to an
+// in-memory list of rates.
+func FindExchangeRate(rates []ExchangeRate, from, to string, on time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	var best *ExchangeRate
+	for i := range rates {
+		rate := &rates[i]
+		direct := rate.BaseCurrency == from && rate.QuoteCurrency == to
+		inverse := rate.BaseCurrency == to && rate.QuoteCurrency == from
+		if (!direct && !inverse) || rate.Date.After(on) {
+			continue
+		}
+		if best == nil || rate.Date.After(best.Date) || (rate.Date.Equal(best.Date) && direct) {
+			best = rate
+		}
+	}
+	if best == nil {
+		return nil, noRateError(from, to, on)
+	}
+
+	r, err := parseRat(best.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange rate %q: %w", best.Rate, err)
+	}
+	if best.BaseCurrency != from {
+		r.Inv(r)
+	}
+	return r, nil
+}
+
+func noRateError(from, to string, on time.Time) error {
+	return fmt.Errorf("%w from %s to %s on %s", ErrNoExchangeRate, from, to, on.Format("2006-01-02"))
+}
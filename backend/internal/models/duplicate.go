@@ -0,0 +1,201 @@
+package models
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DuplicateWindow is how many days apart two expenses may be dated and
+// still be flagged as duplicates, since a purchase and its bank posting can
+// land on different days.
+const DuplicateWindow = 3
+
+// DuplicatePair is two expenses that look like the same purchase recorded
+// twice. Expense is the one recorded first.
+type DuplicatePair struct {
+	Expense   Expense `json:"expense"`
+	Duplicate Expense `json:"duplicate"`
+}
+
+// descriptionWords splits a description into its lowercase words of letters
+// and digits.
+func descriptionWords(description string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// SimilarDescriptions reports whether two descriptions likely name the same
+// purchase: the words of one include all the words of the other, as with
+// "Amazon" and "AMAZON MKTPLACE 1234", or they share at least half of their
+// words between them.
+func SimilarDescriptions(a, b string) bool {
+	wordsA, wordsB := descriptionWords(a), descriptionWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return len(wordsA) == len(wordsB)
+	}
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return shared == min(len(wordsA), len(wordsB)) || 2*shared >= len(wordsA)+len(wordsB)-shared
+}
+
+// IsDuplicate reports whether two expenses look like the same purchase: the
+// same amount in the same currency, dated at most DuplicateWindow days
+// apart, with similar descriptions.
+func IsDuplicate(a, b Expense) bool {
+	if a.ID == b.ID || a.Amount != b.Amount || a.Currency != b.Currency {
+		return false
+	}
+	days := a.Date.Sub(b.Date).Hours() / 24
+	return days <= DuplicateWindow && days >= -DuplicateWindow && SimilarDescriptions(a.Description, b.Description)
+}
+
+// FindDuplicates returns every pair of expenses that look like duplicates,
+// leaving out the pairs dismissed reports as reviewed. dismissed is called
+// with the smaller id first.
+func FindDuplicates(expenses []Expense, dismissed func(expenseID, duplicateID int64) bool) []DuplicatePair {
+	sorted := slices.Clone(expenses)
+	slices.SortFunc(sorted, func(a, b Expense) int {
+		return cmp.Or(cmp.Compare(a.Currency, b.Currency), cmp.Compare(a.Amount, b.Amount), a.Date.Compare(b.Date), cmp.Compare(a.ID, b.ID))
+	})
+
+	var pairs []DuplicatePair
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if b.Currency != a.Currency || b.Amount != a.Amount || b.Date.Sub(a.Date) > DuplicateWindow*24*time.Hour {
+				break
+			}
+			first, second := a, b
+			if first.ID > second.ID {
+				first, second = second, first
+			}
+			if IsDuplicate(first, second) && !dismissed(first.ID, second.ID) {
+				pairs = append(pairs, DuplicatePair{Expense: first, Duplicate: second})
+			}
+		}
+	}
+	slices.SortFunc(pairs, func(a, b DuplicatePair) int {
+		return cmp.Or(cmp.Compare(a.Expense.ID, b.Expense.ID), cmp.Compare(a.Duplicate.ID, b.Duplicate.ID))
+	})
+	return pairs
+}
+
+// possibleDuplicates returns the ids of the ledger's other expenses an expense
+// may duplicate.
+func possibleDuplicates(q querier, ledgerID int64, expense Expense) ([]int64, error) {
+	window := DuplicateWindow * 24 * time.Hour
+	rows, err := q.Query("SELECT "+expenseColumns+" FROM Expense WHERE id <> $1 AND currency = $2 AND amount = $3 AND date >= $4 AND date <= $5 AND ledger_id = $6 ORDER BY id",
+		expense.ID, expense.Currency, expense.Amount, expense.Date.Add(-window), expense.Date.Add(window), ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		other, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		if SimilarDescriptions(expense.Description, other.Description) {
+			ids = append(ids, other.ID)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// GetDuplicateExpenses returns the pairs of expenses that look like
+// duplicates and have not been dismissed.
+func GetDuplicateExpenses(db *sql.DB, ledgerID int64) ([]DuplicatePair, error) {
+	expenses, err := GetExpenses(db, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT d.expense_id, d.duplicate_id FROM DismissedDuplicate d JOIN Expense e ON e.id = d.expense_id WHERE e.ledger_id = $1", ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dismissed := map[[2]int64]bool{}
+	for rows.Next() {
+		var pair [2]int64
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		dismissed[pair] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return FindDuplicates(expenses, func(expenseID, duplicateID int64) bool {
+		return dismissed[[2]int64{expenseID, duplicateID}]
+	}), nil
+}
+
+// DismissDuplicate records that two expenses are not duplicates, so the
+// pair is not flagged again.
+func DismissDuplicate(db *sql.DB, ledgerID, expenseID, duplicateID int64) error {
+	if expenseID == duplicateID {
+		return NewValidationError("duplicate", "an expense cannot duplicate itself")
+	}
+	expenseID, duplicateID = min(expenseID, duplicateID), max(expenseID, duplicateID)
+	return withTx(db, func(tx *sql.Tx) error {
+		for _, id := range []int64{expenseID, duplicateID} {
+			if _, err := getExpenseByID(tx, ledgerID, id); err != nil {
+				return err
+			}
+		}
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM DismissedDuplicate WHERE expense_id = $1 AND duplicate_id = $2)",
+			expenseID, duplicateID).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO DismissedDuplicate (expense_id, duplicate_id) VALUES ($1, $2)", expenseID, duplicateID)
+		return err
+	})
+}
+
+// MergeExpense folds a duplicate expense into the one it duplicates: the
+// duplicate is deleted, its budget spend reversed, its attachments moved to
+// the kept expense, and a statement import that created it is credited to
+// the kept expense so a re-import still skips it. It returns the kept
+// expense.
+func MergeExpense(db *sql.DB, ledgerID, duplicateID, targetID int64) (Expense, error) {
+	if duplicateID == targetID {
+		return Expense{}, NewValidationError("target", "an expense cannot be merged into itself")
+	}
+	var target Expense
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		if target, err = getExpenseByID(tx, ledgerID, targetID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE ImportedTransaction SET expense_id = $1 WHERE expense_id = $2", targetID, duplicateID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE Attachment SET expense_id = $1 WHERE expense_id = $2", targetID, duplicateID); err != nil {
+			return err
+		}
+		// Deleting fails, undoing the moves, unless the duplicate is the ledger's
+		return deleteExpense(tx, ledgerID, duplicateID)
+	})
+	if err != nil {
+		return Expense{}, err
+	}
+	return target, nil
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Kinds of domain error. Every *Error wraps one of them, so callers can test
+// for a kind with errors.Is.
+var (
+	ErrValidation   = errors.New("validation failed")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Error is a failure the caller can act on, as opposed to a storage failure.
+// Field names the offending input field of a validation error.
+type Error struct {
+	Kind    error
+	Field   string
+	Message string
+
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// NewValidationError reports invalid input in field.
+func NewValidationError(field, format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// NewNotFoundError reports that a resource, such as "expense", does not exist.
+func NewNotFoundError(resource string) error {
+	return &Error{Kind: ErrNotFound, Message: resource + " not found", Err: sql.ErrNoRows}
+}
+
+// NewConflictError reports a request that clashes with existing data.
+func NewConflictError(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// NewForbiddenError reports an operation that is never allowed, or not
+// allowed to the caller.
+func NewForbiddenError(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// NewUnauthorizedError reports a caller that is not signed in, or whose
+// credentials were rejected.
+func NewUnauthorizedError(format string, args ...any) error {
+	return &Error{Kind: ErrUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+// notFound turns sql.ErrNoRows into a not-found error for resource and
+// returns any other error unchanged.
+func notFound(err error, resource string) error {
+	if err == sql.ErrNoRows {
+		return NewNotFoundError(resource)
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExchangeRate records that one unit of BaseCurrency was worth Rate units of
+// QuoteCurrency on Date. Rate is kept as a decimal string so it round-trips
+// exactly.
+type ExchangeRate struct {
+	ID            int64     `json:"id"`
+	Date          time.Time `json:"date"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          string    `json:"rate"`
+}
+
+// rateDateLayouts are the date formats accepted in rate files.
+var rateDateLayouts = []string{"2006-01-02", time.RFC3339}
+
+func parseRateDate(s string) (time.Time, error) {
+	for _, layout := range rateDateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, NewValidationError("date", "invalid date %q (expected YYYY-MM-DD)", s)
+}
+
+// ValidateExchangeRate checks and normalizes a rate before it is stored.
+func ValidateExchangeRate(rate ExchangeRate) (ExchangeRate, error) {
+	if rate.Date.IsZero() {
+		return ExchangeRate{}, NewValidationError("date", "date must be provided")
+	}
+	base, err := NormalizeCurrency(rate.BaseCurrency)
+	if err != nil || rate.BaseCurrency == "" {
+		return ExchangeRate{}, NewValidationError("base_currency", "invalid base currency %q", rate.BaseCurrency)
+	}
+	quote, err := NormalizeCurrency(rate.QuoteCurrency)
+	if err != nil || rate.QuoteCurrency == "" {
+		return ExchangeRate{}, NewValidationError("quote_currency", "invalid quote currency %q", rate.QuoteCurrency)
+	}
+	if base == quote {
+		return ExchangeRate{}, NewValidationError("quote_currency", "base and quote currencies must differ")
+	}
+	r, err := parseRat(strings.TrimSpace(rate.Rate))
+	if err != nil || r.Sign() <= 0 {
+		return ExchangeRate{}, NewValidationError("rate", "rate must be a positive number, got %q", rate.Rate)
+	}
+
+	rate.BaseCurrency = base
+	rate.QuoteCurrency = quote
+	rate.Rate = formatRate(r)
+	return rate, nil
+}
+
+// ParseExchangeRates reads rates from a CSV or JSON file. CSV files need a
+// header row naming the date, base_currency, quote_currency and rate
+// columns; JSON files hold an array of objects with the same keys.
+func ParseExchangeRates(r io.Reader, format string) ([]ExchangeRate, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return parseExchangeRatesCSV(r)
+	case "json":
+		return parseExchangeRatesJSON(r)
+	default:
+		return nil, NewValidationError("format", "unsupported exchange rate format %q (expected csv or json)", format)
+	}
+}
+
+func parseExchangeRatesCSV(r io.Reader) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "base_currency", "quote_currency", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, NewValidationError(name, "CSV header is missing the %s column", name)
+		}
+	}
+
+	var rates []ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		date, err := parseRateDate(record[columns["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rate, err := ValidateExchangeRate(ExchangeRate{
+			Date:          date,
+			BaseCurrency:  record[columns["base_currency"]],
+			QuoteCurrency: record[columns["quote_currency"]],
+			Rate:          record[columns["rate"]],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func parseExchangeRatesJSON(r io.Reader) ([]ExchangeRate, error) {
+	var entries []struct {
+		Date          string      `json:"date"`
+		BaseCurrency  string      `json:"base_currency"`
+		QuoteCurrency string      `json:"quote_currency"`
+		Rate          json.Number `json:"rate"`
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	rates := make([]ExchangeRate, 0, len(entries))
+	for i, entry := range entries {
+		date, err := parseRateDate(entry.Date)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		rate, err := ValidateExchangeRate(ExchangeRate{
+			Date:          date,
+			BaseCurrency:  entry.BaseCurrency,
+			QuoteCurrency: entry.QuoteCurrency,
+			Rate:          entry.Rate.String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// GetExchangeRates lists stored rates, newest first. Empty currency codes
+// match any currency.
+func GetExchangeRates(db *sql.DB, baseCurrency, quoteCurrency string) ([]ExchangeRate, error) {
+	rows, err := db.Query(`
+		SELECT id, date, base_currency, quote_currency, rate
+		FROM ExchangeRate
+		WHERE ($1 = '' OR base_currency = $1) AND ($2 = '' OR quote_currency = $2)
+		ORDER BY date DESC, base_currency, quote_currency
+	`, baseCurrency, quoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []ExchangeRate
+	for rows.Next() {
+		var rate ExchangeRate
+		if err := rows.Scan(&rate.ID, &rate.Date, &rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate); err != nil {
+			return nil, err
+		}
+		// Postgres pads NUMERIC(18, 8) with zeros and SQLite may return a float
+		if r, err := parseRat(rate.Rate); err == nil {
+			rate.Rate = formatRate(r)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// ImportExchangeRates stores a batch of rates in one transaction, replacing
+// any existing rate for the same date and currency pair, and recalculates the
+// stale budgets the new rates may complete. It returns the number of rates
+// written.
+func ImportExchangeRates(db *sql.DB, rates []ExchangeRate) (int, error) {
+	validated := make([]ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		rate, err := ValidateExchangeRate(rate)
+		if err != nil {
+			return 0, err
+		}
+		validated = append(validated, rate)
+	}
+
+	err := withTx(db, func(tx *sql.Tx) error {
+		for _, rate := range validated {
+			_, err := tx.Exec(`
+				INSERT INTO ExchangeRate (date, base_currency, quote_currency, rate)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (date, base_currency, quote_currency) DO UPDATE SET rate = excluded.rate
+			`, rate.Date, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate)
+			if err != nil {
+				return fmt.Errorf("failed to store %s/%s rate for %s: %w",
+					rate.BaseCurrency, rate.QuoteCurrency, rate.Date.Format("2006-01-02"), err)
+			}
+		}
+		return recalculateStaleBudgets(tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(validated), nil
+}
+
+// recalculateStaleBudgets recomputes the spent amount of every stale budget,
+// in any ledger, leaving those still missing a rate stale.
+func recalculateStaleBudgets(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT DISTINCT ledger_id FROM Budget WHERE stale = TRUE")
+	if err != nil {
+		return err
+	}
+	var ledgerIDs []int64
+	for rows.Next() {
+		var ledgerID int64
+		if err := rows.Scan(&ledgerID); err != nil {
+			rows.Close()
+			return err
+		}
+		ledgerIDs = append(ledgerIDs, ledgerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, ledgerID := range ledgerIDs {
+		budgets, err := queryBudgets(tx, "SELECT "+budgetColumns+" FROM Budget WHERE stale = TRUE AND ledger_id = $1", ledgerID)
+		if err != nil {
+			return err
+		}
+		for _, budget := range budgets {
+			if err := recalculateBudget(tx, ledgerID, budget); err != nil {
+				return fmt.Errorf("failed to recalculate budget %d: %w", budget.ID, err)
+			}
+		}
+	}
+	return nil
+}
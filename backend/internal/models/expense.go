@@ -11,13 +11,48 @@ import (
 type Expense struct {
 	ID          int64     `json:"id"`
 	CategoryID  int64     `json:"category_id"`
-	Amount      float64   `json:"amount"`
+	Amount      Money     `json:"amount"`
+	Currency    string    `json:"currency"`
 	Date        time.Time `json:"date"`
 	Description string    `json:"description"`
+
+	// RecurringRuleID is set on expenses generated by a recurring rule.
+	RecurringRuleID *int64 `json:"recurring_rule_id,omitempty"`
+
+	// AccountID is the account the expense was paid from, if recorded.
+	AccountID *int64 `json:"account_id,omitempty"`
+
+	// Converted is filled in when a caller asks for amounts in another currency.
+	Converted *Conversion `json:"converted,omitempty"`
+
+	// PossibleDuplicates is filled in on a newly created expense with the ids
+	// of existing expenses it may duplicate.
+	PossibleDuplicates []int64 `json:"possible_duplicates,omitempty"`
+
+	// Tags are the names of the tags the expense carries, in order.
+	Tags []string `json:"tags,omitempty"`
+
+	// Splits spread the amount over several categories, each line counting
+	// toward its own category's budgets instead of CategoryID.
+	Splits []ExpenseSplit `json:"splits,omitempty"`
 }
 
-func GetExpenses(db *sql.DB) ([]Expense, error) {
-	rows, err := db.Query("SELECT id, category_id, amount, date, description FROM Expense")
+// expenseColumns lists the columns read by scanExpense, in order.
+const expenseColumns = "id, category_id, amount, currency, date, description, recurring_rule_id, account_id"
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExpense(row rowScanner) (Expense, error) {
+	var expense Expense
+	err := row.Scan(&expense.ID, &expense.CategoryID, &expense.Amount, &expense.Currency, &expense.Date, &expense.Description, &expense.RecurringRuleID, &expense.AccountID)
+	return expense, err
+}
+
+func GetExpenses(db *sql.DB, ledgerID int64) ([]Expense, error) {
+	rows, err := db.Query("SELECT "+expenseColumns+" FROM Expense WHERE ledger_id = $1 ORDER BY id", ledgerID)
 	if err != nil {
 		return nil, err
 	}
@@ -25,97 +60,291 @@ func GetExpenses(db *sql.DB) ([]Expense, error) {
 
 	var expenses []Expense
 	for rows.Next() {
-		var expense Expense
-		if err := rows.Scan(&expense.ID, &expense.CategoryID, &expense.Amount, &expense.Date, &expense.Description); err != nil {
+		expense, err := scanExpense(rows)
+		if err != nil {
 			return nil, err
 		}
 		expenses = append(expenses, expense)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	if expenses, err = loadExpenseSplits(db, expenses); err != nil {
+		return nil, err
+	}
+	return loadExpenseTags(db, expenses)
+}
+
+// loadExpenseTags fills in the tags of the expenses.
+func loadExpenseTags(q querier, expenses []Expense) ([]Expense, error) {
+	if len(expenses) == 0 {
+		return expenses, nil
+	}
+	ids := make([]int64, len(expenses))
+	for i, expense := range expenses {
+		ids[i] = expense.ID
+	}
+	tags, err := expenseTagLink.load(q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range expenses {
+		expenses[i].Tags = tags[expenses[i].ID]
+	}
 	return expenses, nil
 }
 
-func GetExpenseByID(db *sql.DB, id int64) (Expense, error) {
-	var expense Expense
-	err := db.QueryRow("SELECT id, category_id, amount, date, description FROM Expense WHERE id = $1", id).
-		Scan(&expense.ID, &expense.CategoryID, &expense.Amount, &expense.Date, &expense.Description)
+// ListExpenses returns one page of the expenses matching the date range,
+// category, account, amount range, tags and description search in opts, along
+// with the total number of matches. A split expense matches the categories of
+// each of its lines.
+func ListExpenses(db *sql.DB, ledgerID int64, opts ListOptions) ([]Expense, int, error) {
+	var q listQuery
+	q.where("ledger_id = ?", ledgerID)
+	q.dateRange("date", opts)
+	if opts.CategoryID != 0 {
+		q.where(allocatedTo("Expense.id"), opts.CategoryID)
+	}
+	if opts.AccountID != 0 {
+		q.where("account_id = ?", opts.AccountID)
+	}
+	q.amountRange("amount", opts)
+	q.search(opts, "description")
+	expenseTagLink.filter(&q, opts.Tags)
+	expenses, total, err := list(db, "Expense", expenseColumns, ExpenseSortFields, q, opts, scanExpense)
+	if err != nil {
+		return nil, 0, err
+	}
+	if expenses, err = loadExpenseSplits(db, expenses); err != nil {
+		return nil, 0, err
+	}
+	expenses, err = loadExpenseTags(db, expenses)
+	return expenses, total, err
+}
+
+func GetExpenseByID(db *sql.DB, ledgerID, id int64) (Expense, error) {
+	expense, err := getExpenseByID(db, ledgerID, id)
 	if err != nil {
 		return Expense{}, err
 	}
-	return expense, nil
+	expenses, err := loadExpenseTags(db, []Expense{expense})
+	if err != nil {
+		return Expense{}, err
+	}
+	return expenses[0], nil
+}
+
+// getExpenseByID returns an expense with its split lines, which budgets
+// depend on, but without its tags.
+func getExpenseByID(q querier, ledgerID, id int64) (Expense, error) {
+	expense, err := scanExpense(q.QueryRow("SELECT "+expenseColumns+" FROM Expense WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return Expense{}, notFound(err, "expense")
+	}
+	expenses, err := loadExpenseSplits(q, []Expense{expense})
+	if err != nil {
+		return Expense{}, err
+	}
+	return expenses[0], nil
 }
 
-// validateCreateExpense validates fields for creating an expense.
-func validateCreateExpense(expense Expense) error {
+// ValidateCreateExpense validates fields for creating an expense.
+func ValidateCreateExpense(expense Expense) error {
 	if expense.Description == "" {
-		return errors.New("description is required")
+		return NewValidationError("description", "description is required")
 	}
 	if expense.Amount <= 0 {
-		return errors.New("amount must be greater than zero")
+		return NewValidationError("amount", "amount must be greater than zero")
 	}
 	if expense.Date.IsZero() || expense.Date.After(time.Now()) {
-		return errors.New("date must be provided and cannot be in the future")
+		return NewValidationError("date", "date must be provided and cannot be in the future")
 	}
 	if expense.CategoryID <= 0 {
-		return errors.New("category ID must be provided")
+		return NewValidationError("category_id", "category ID must be provided")
+	}
+	if _, err := NormalizeCurrency(expense.Currency); err != nil {
+		return err
 	}
 	return nil
 }
 
 // CreateExpense adds a new expense to the database and updates the associated budget.
-func CreateExpense(db *sql.DB, expense Expense) (Expense, error) {
-	if err := validateCreateExpense(expense); err != nil {
+// An expense without a category gets the category of its first split line,
+// or of the first matching category rule, or 'Other' if none matches. The
+// created expense lists the existing expenses it may duplicate.
+func CreateExpense(db *sql.DB, ledgerID int64, expense Expense) (Expense, error) {
+	if expense.CategoryID == 0 && len(expense.Splits) > 0 {
+		expense.CategoryID = expense.Splits[0].CategoryID
+	}
+	if expense.CategoryID == 0 {
+		rules, err := GetCategoryRules(db, ledgerID)
+		if err != nil {
+			return Expense{}, err
+		}
+		expense.CategoryID = Categorize(rules, expense, 1)
+	}
+	if err := ValidateCreateExpense(expense); err != nil {
 		return Expense{}, err
 	}
-
-	// Insert the new expense and get the ID using RETURNING
-	err := db.QueryRow(
-		"INSERT INTO Expense (category_id, amount, date, description) VALUES ($1, $2, $3, $4) RETURNING id, category_id, amount, date, description",
-		expense.CategoryID, expense.Amount, expense.Date, expense.Description,
-	).Scan(&expense.ID, &expense.CategoryID, &expense.Amount, &expense.Date, &expense.Description)
+	tags, err := NormalizeTags(expense.Tags)
 	if err != nil {
 		return Expense{}, err
 	}
-
-	// Check if budget exists before updating it
-	budgetExists, err := checkBudgetExists(db, expense.CategoryID)
+	splits, err := ValidateSplits(expense.Amount, expense.Splits)
 	if err != nil {
 		return Expense{}, err
 	}
 
-	if budgetExists {
-		if err := updateBudgetSpent(db, expense.CategoryID, expense.Amount); err != nil {
-			return Expense{}, err
+	// Insert the expense and update the budget in one transaction so a
+	// failure cannot leave the budget's spent amount out of step
+	err = withTx(db, func(tx *sql.Tx) error {
+		var err error
+		expense.Currency, err = entryCurrency(tx, ledgerID, expense.AccountID, expense.Currency)
+		if err != nil {
+			return err
+		}
+		if err := checkCategory(tx, ledgerID, expense.CategoryID); err != nil {
+			return err
+		}
+		if err := checkSplitCategories(tx, ledgerID, splits); err != nil {
+			return err
+		}
+
+		// Insert the new expense and get the ID using RETURNING
+		expense, err = insertExpense(tx, ledgerID, expense)
+		if err != nil {
+			return err
 		}
+		if len(splits) > 0 {
+			if expense.Splits, err = setExpenseSplits(tx, expense.ID, splits); err != nil {
+				return err
+			}
+		}
+		if len(tags) > 0 {
+			if err := expenseTagLink.set(tx, ledgerID, expense.ID, tags); err != nil {
+				return err
+			}
+			expense.Tags = tags
+		}
+
+		if err := adjustBudgetSpent(tx, ledgerID, expense, 1); err != nil {
+			return err
+		}
+
+		expense.PossibleDuplicates, err = possibleDuplicates(tx, ledgerID, expense)
+		return err
+	})
+	if err != nil {
+		return Expense{}, missingCategory(err, expense.CategoryID)
 	}
 
 	return expense, nil
 }
 
-// validateUpdateExpense validates fields for updating an expense.
-func validateUpdateExpense(expense Expense, existingExpense Expense) error {
+// insertExpense inserts an expense and returns it as stored.
+func insertExpense(q querier, ledgerID int64, expense Expense) (Expense, error) {
+	return scanExpense(q.QueryRow(
+		"INSERT INTO Expense (category_id, amount, currency, date, description, recurring_rule_id, account_id, ledger_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING "+expenseColumns,
+		expense.CategoryID, expense.Amount, expense.Currency, expense.Date, expense.Description, expense.RecurringRuleID, expense.AccountID, ledgerID,
+	))
+}
+
+// ValidateUpdateExpense validates fields for updating an expense.
+func ValidateUpdateExpense(expense Expense, existingExpense Expense) error {
 	if expense.Amount <= 0 && expense.Amount != existingExpense.Amount {
-		return errors.New("amount must be greater than zero if provided")
+		return NewValidationError("amount", "amount must be greater than zero if provided")
 	}
 	if !expense.Date.IsZero() && (expense.Date.Before(existingExpense.Date) || expense.Date.After(time.Now())) {
-		return errors.New("date must be within the budget period and cannot be in the future")
+		return NewValidationError("date", "date must be within the budget period and cannot be in the future")
+	}
+	if _, err := NormalizeCurrency(expense.Currency); err != nil {
+		return err
 	}
 	return nil
 }
 
 // UpdateExpense updates an existing expense in the database and updates the associated budget.
-func UpdateExpense(db *sql.DB, expense Expense) (Expense, error) {
+// Its tags and split lines are replaced when Tags and Splits are not nil; an
+// empty Splits list removes the split.
+func UpdateExpense(db *sql.DB, ledgerID int64, expense Expense) (Expense, error) {
+	tags, err := NormalizeTags(expense.Tags)
+	if err != nil {
+		return Expense{}, err
+	}
+	expense.Tags = tags
+
+	var updatedExpense Expense
+	err = withTx(db, func(tx *sql.Tx) error {
+		var err error
+		updatedExpense, err = updateExpense(tx, ledgerID, expense)
+		return err
+	})
+	if err != nil {
+		return Expense{}, missingCategory(err, expense.CategoryID)
+	}
+	return updatedExpense, nil
+}
+
+func updateExpense(tx *sql.Tx, ledgerID int64, expense Expense) (Expense, error) {
 	// Get current expense first
-	currentExpense, err := GetExpenseByID(db, expense.ID)
+	currentExpense, err := getExpenseByID(tx, ledgerID, expense.ID)
 	if err != nil {
 		return Expense{}, err
 	}
 
 	// Validate fields
-	if err := validateUpdateExpense(expense, currentExpense); err != nil {
+	if err := ValidateUpdateExpense(expense, currentExpense); err != nil {
 		return Expense{}, err
 	}
 
+	// Split lines must add up to the amount, whether they are replaced or kept
+	amount := currentExpense.Amount
+	if expense.Amount != 0 {
+		amount = expense.Amount
+	}
+	splitsChanged := expense.Splits != nil
+	if splitsChanged {
+		if expense.Splits, err = ValidateSplits(amount, expense.Splits); err != nil {
+			return Expense{}, err
+		}
+		if err := checkSplitCategories(tx, ledgerID, expense.Splits); err != nil {
+			return Expense{}, err
+		}
+	} else if _, err := ValidateSplits(amount, currentExpense.Splits); err != nil {
+		return Expense{}, err
+	}
+
+	// Tags do not affect budgets, so they are settled on the current expense
+	// and carried over to the updated one
+	if expense.Tags != nil {
+		if err := expenseTagLink.set(tx, ledgerID, expense.ID, expense.Tags); err != nil {
+			return Expense{}, err
+		}
+		currentExpense.Tags = expense.Tags
+	} else {
+		tags, err := expenseTagLink.load(tx, []int64{expense.ID})
+		if err != nil {
+			return Expense{}, err
+		}
+		currentExpense.Tags = tags[expense.ID]
+	}
+
+	// An expense on an account must stay in the account's currency
+	if expense.AccountID != nil || (expense.Currency != "" && currentExpense.AccountID != nil) {
+		accountID := expense.AccountID
+		if accountID == nil {
+			accountID = currentExpense.AccountID
+		}
+		currency := expense.Currency
+		if currency == "" {
+			currency = currentExpense.Currency
+		}
+		if _, err := entryCurrency(tx, ledgerID, accountID, currency); err != nil {
+			return Expense{}, err
+		}
+	}
+
 	// Build update query
 	query := "UPDATE Expense SET"
 	args := []interface{}{}
@@ -140,32 +369,39 @@ func UpdateExpense(db *sql.DB, expense Expense) (Expense, error) {
 		argCount++
 	}
 	if expense.CategoryID != 0 && expense.CategoryID != currentExpense.CategoryID {
+		if err := checkCategory(tx, ledgerID, expense.CategoryID); err != nil {
+			return Expense{}, err
+		}
 		updates = append(updates, fmt.Sprintf("category_id = $%d", argCount))
 		args = append(args, expense.CategoryID)
 		argCount++
 	}
 
-	if len(updates) == 0 {
-		return currentExpense, nil // No changes made
+	if expense.Currency != "" {
+		expense.Currency, _ = NormalizeCurrency(expense.Currency)
+		updates = append(updates, fmt.Sprintf("currency = $%d", argCount))
+		args = append(args, expense.Currency)
+		argCount++
 	}
 
-	// Finalize the query
-	query += " " + strings.Join(updates, ", ")
-	query += fmt.Sprintf(" WHERE id = $%d", argCount)
-	args = append(args, expense.ID)
+	if expense.AccountID != nil {
+		updates = append(updates, fmt.Sprintf("account_id = $%d", argCount))
+		args = append(args, *expense.AccountID)
+		argCount++
+	}
 
-	if _, err := db.Exec(query, args...); err != nil {
-		return Expense{}, err
+	if len(updates) == 0 && !splitsChanged {
+		return currentExpense, nil // No changes made
 	}
 
-	// Update the budget if amount changed
-	if expense.Amount != 0 && expense.Amount != currentExpense.Amount {
-		if budgetExists, err := checkBudgetExists(db, currentExpense.CategoryID); err != nil {
+	// Finalize the query
+	if len(updates) > 0 {
+		query += " " + strings.Join(updates, ", ")
+		query += fmt.Sprintf(" WHERE id = $%d", argCount)
+		args = append(args, expense.ID)
+
+		if _, err := tx.Exec(query, args...); err != nil {
 			return Expense{}, err
-		} else if budgetExists {
-			if err := updateBudgetSpent(db, currentExpense.CategoryID, expense.Amount-currentExpense.Amount); err != nil {
-				return Expense{}, err
-			}
 		}
 	}
 
@@ -180,51 +416,117 @@ func UpdateExpense(db *sql.DB, expense Expense) (Expense, error) {
 	if !expense.Date.IsZero() {
 		updatedExpense.Date = expense.Date
 	}
+	if expense.CategoryID != 0 {
+		updatedExpense.CategoryID = expense.CategoryID
+	}
+	if expense.Currency != "" {
+		updatedExpense.Currency = expense.Currency
+	}
+	if expense.AccountID != nil {
+		updatedExpense.AccountID = expense.AccountID
+	}
+	if splitsChanged {
+		if updatedExpense.Splits, err = setExpenseSplits(tx, expense.ID, expense.Splits); err != nil {
+			return Expense{}, err
+		}
+	}
+
+	// Move the expense's contribution between budgets if anything it depends on changed
+	if updatedExpense.Amount != currentExpense.Amount || updatedExpense.Currency != currentExpense.Currency ||
+		!updatedExpense.Date.Equal(currentExpense.Date) || updatedExpense.CategoryID != currentExpense.CategoryID || splitsChanged {
+		if err := adjustBudgetSpent(tx, ledgerID, currentExpense, -1); err != nil {
+			return Expense{}, err
+		}
+		if err := adjustBudgetSpent(tx, ledgerID, updatedExpense, 1); err != nil {
+			return Expense{}, err
+		}
+	}
 
 	return updatedExpense, nil
 }
 
 // DeleteExpense removes an expense from the database and updates the associated budget.
-func DeleteExpense(db *sql.DB, id int64) error {
-	currentExpense, err := GetExpenseByID(db, id)
-	if err != nil {
-		return err
-	}
+func DeleteExpense(db *sql.DB, ledgerID, id int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		return deleteExpense(tx, ledgerID, id)
+	})
+}
 
-	// Delete the expense from the database
-	_, err = db.Exec("DELETE FROM Expense WHERE id = $1", id)
+func deleteExpense(tx *sql.Tx, ledgerID, id int64) error {
+	currentExpense, err := getExpenseByID(tx, ledgerID, id)
 	if err != nil {
 		return err
 	}
 
-	// Check if budget exists before updating it
-	budgetExists, err := checkBudgetExists(db, currentExpense.CategoryID)
+	// Delete the expense from the database
+	_, err = tx.Exec("DELETE FROM Expense WHERE id = $1", id)
 	if err != nil {
 		return err
 	}
 
 	// Update the associated budget by deducting the amount
-	if budgetExists {
-		if err := updateBudgetSpent(db, currentExpense.CategoryID, -currentExpense.Amount); err != nil {
-			return err // Deduct amount from the budget
+	return adjustBudgetSpent(tx, ledgerID, currentExpense, -1)
+}
+
+// adjustBudgetSpent adds (sign 1) or removes (sign -1) an expense from the
+// spent amount of every budget of its category, or of a category above it,
+// whose period contains the expense date, converted into each budget's
+// currency at that date's rate. Each split line counts toward the budgets
+// of its own category. Only the ledger's own budgets count the expense. A
+// budget whose currency no stored rate converts into is marked stale instead.
+func adjustBudgetSpent(q querier, ledgerID int64, expense Expense, sign int64) error {
+	rates := rateCache(q)
+	for _, allocation := range expense.Allocations() {
+		rows, err := q.Query("WITH RECURSIVE "+parentCategoriesCTE+`
+			SELECT id, currency FROM Budget
+			WHERE category_id IN (SELECT id FROM parent_categories) AND start_date <= $2 AND end_date >= $2 AND ledger_id = $3`,
+			allocation.CategoryID, expense.Date, ledgerID)
+		if err != nil {
+			return err
+		}
+
+		// Read every budget before converting, since the rate lookups need the connection
+		type budgetCurrency struct {
+			id       int64
+			currency string
+		}
+		var budgets []budgetCurrency
+		for rows.Next() {
+			var budget budgetCurrency
+			if err := rows.Scan(&budget.id, &budget.currency); err != nil {
+				rows.Close()
+				return err
+			}
+			budgets = append(budgets, budget)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
 		}
-	}
 
+		for _, budget := range budgets {
+			amount, err := convertWith(rates, allocation.Amount, expense.Currency, budget.currency, expense.Date)
+			if errors.Is(err, ErrNoExchangeRate) {
+				// Leave the expense out until the rate is imported rather than
+				// rejecting it over a budget
+				if _, err := q.Exec("UPDATE Budget SET stale = TRUE WHERE id = $1", budget.id); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := updateBudgetSpent(q, budget.id, amount.Mul(sign)); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-// updateBudgetSpent updates the spent amount for the associated budget category.
-func updateBudgetSpent(db *sql.DB, categoryID int64, amount float64) error {
-	_, err := db.Exec("UPDATE Budget SET spent = spent + $1 WHERE category_id = $2", amount, categoryID)
+// updateBudgetSpent updates the spent amount of a budget.
+func updateBudgetSpent(q querier, budgetID int64, amount Money) error {
+	_, err := q.Exec("UPDATE Budget SET spent = spent + $1 WHERE id = $2", amount, budgetID)
 	return err
 }
-
-// checkBudgetExists checks if a budget exists for the given category ID.
-func checkBudgetExists(db *sql.DB, categoryID int64) (bool, error) {
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM Budget WHERE category_id = $1", categoryID).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
-}
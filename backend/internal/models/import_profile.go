@@ -0,0 +1,297 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// SignConvention says how a bank writes the sign of a single amount column.
+type SignConvention string
+
+const (
+	// SignNegativeExpense treats negative amounts as money leaving the account.
+	SignNegativeExpense SignConvention = "negative_expense"
+	// SignPositiveExpense treats positive amounts as money leaving the account,
+	// as most credit card statements do.
+	SignPositiveExpense SignConvention = "positive_expense"
+)
+
+// ImportProfile describes how to read one bank's CSV statements. Columns are
+// named by their header, matched case-insensitively. Amounts come either from
+// AmountColumn, read with SignConvention, or from DebitColumn and
+// CreditColumn, which hold unsigned expenses and incomes.
+type ImportProfile struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Delimiter string `json:"delimiter"`
+
+	// SkipRows is the number of lines before the header row.
+	SkipRows int `json:"skip_rows"`
+
+	DateColumn string `json:"date_column"`
+
+	// DateFormat spells dates with YYYY, YY, MMM, MM, M, DD and D, such as
+	// "DD/MM/YYYY" or "D MMM YYYY".
+	DateFormat        string `json:"date_format"`
+	DescriptionColumn string `json:"description_column"`
+
+	AmountColumn     string         `json:"amount_column,omitempty"`
+	DebitColumn      string         `json:"debit_column,omitempty"`
+	CreditColumn     string         `json:"credit_column,omitempty"`
+	SignConvention   SignConvention `json:"sign_convention"`
+	DecimalSeparator string         `json:"decimal_separator"`
+
+	// CurrencyColumn, if set, holds each row's currency. Otherwise rows are in
+	// Currency, or in the account's currency when Currency is empty.
+	CurrencyColumn string `json:"currency_column,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+
+	// CategoryID is given to imported expenses. AccountID, if set, is the
+	// account imported expenses and incomes are recorded against.
+	CategoryID int64  `json:"category_id"`
+	AccountID  *int64 `json:"account_id,omitempty"`
+}
+
+// importProfileColumns lists the columns read by scanImportProfile, in order.
+const importProfileColumns = "id, name, delimiter, skip_rows, date_column, date_format, description_column, " +
+	"amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column, currency, " +
+	"category_id, account_id"
+
+func scanImportProfile(row rowScanner) (ImportProfile, error) {
+	var profile ImportProfile
+	err := row.Scan(&profile.ID, &profile.Name, &profile.Delimiter, &profile.SkipRows, &profile.DateColumn,
+		&profile.DateFormat, &profile.DescriptionColumn, &profile.AmountColumn, &profile.DebitColumn,
+		&profile.CreditColumn, &profile.SignConvention, &profile.DecimalSeparator, &profile.CurrencyColumn,
+		&profile.Currency, &profile.CategoryID, &profile.AccountID)
+	return profile, err
+}
+
+// dateFormatTokens turns a profile's date format into a Go time layout. The
+// longer tokens come first so "MMM" is not read as "MM" followed by "M".
+var dateFormatTokens = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06",
+	"MMM", "Jan", "MM", "01", "M", "1",
+	"DD", "02", "D", "2",
+)
+
+// dateLayout returns the Go time layout for a profile date format, checking
+// that it spells out a whole date.
+func dateLayout(format string) (string, error) {
+	layout := dateFormatTokens.Replace(format)
+	// A layout that loses the year, month or day cannot round-trip a date
+	// whose day is past 12
+	reference := time.Date(2023, time.November, 23, 0, 0, 0, 0, time.UTC)
+	if parsed, err := time.Parse(layout, reference.Format(layout)); err != nil || !parsed.Equal(reference) {
+		return "", NewValidationError("date_format", "date format %q must contain a year, month and day", format)
+	}
+	return layout, nil
+}
+
+// NormalizeImportProfile fills in a profile's defaults and checks its fields.
+func NormalizeImportProfile(profile ImportProfile) (ImportProfile, error) {
+	if profile.Delimiter == "" {
+		profile.Delimiter = ","
+	}
+	if profile.DateFormat == "" {
+		profile.DateFormat = "YYYY-MM-DD"
+	}
+	if profile.SignConvention == "" {
+		profile.SignConvention = SignNegativeExpense
+	}
+	if profile.DecimalSeparator == "" {
+		profile.DecimalSeparator = "."
+	}
+	if profile.CategoryID == 0 {
+		profile.CategoryID = 1
+	}
+
+	if profile.Name == "" {
+		return ImportProfile{}, NewValidationError("name", "name must be provided")
+	}
+	if len(profile.Name) > 255 {
+		return ImportProfile{}, NewValidationError("name", "name is too long (max 255 characters)")
+	}
+	if len(profile.Delimiter) != 1 || strings.ContainsAny(profile.Delimiter, "\"\r\n") {
+		return ImportProfile{}, NewValidationError("delimiter", "delimiter must be a single character other than a quote or newline")
+	}
+	if profile.SkipRows < 0 {
+		return ImportProfile{}, NewValidationError("skip_rows", "skip rows cannot be negative")
+	}
+	if profile.DateColumn == "" {
+		return ImportProfile{}, NewValidationError("date_column", "date column must be provided")
+	}
+	if _, err := dateLayout(profile.DateFormat); err != nil {
+		return ImportProfile{}, err
+	}
+	if profile.DescriptionColumn == "" {
+		return ImportProfile{}, NewValidationError("description_column", "description column must be provided")
+	}
+	if profile.AmountColumn != "" && (profile.DebitColumn != "" || profile.CreditColumn != "") {
+		return ImportProfile{}, NewValidationError("amount_column", "use either an amount column or debit and credit columns, not both")
+	}
+	if profile.AmountColumn == "" && (profile.DebitColumn == "" || profile.CreditColumn == "") {
+		return ImportProfile{}, NewValidationError("amount_column", "an amount column or both debit and credit columns must be provided")
+	}
+	switch profile.SignConvention {
+	case SignNegativeExpense, SignPositiveExpense:
+	default:
+		return ImportProfile{}, NewValidationError("sign_convention", "sign convention must be negative_expense or positive_expense")
+	}
+	if profile.DecimalSeparator != "." && profile.DecimalSeparator != "," {
+		return ImportProfile{}, NewValidationError("decimal_separator", "decimal separator must be \".\" or \",\"")
+	}
+	if profile.DecimalSeparator == profile.Delimiter {
+		return ImportProfile{}, NewValidationError("decimal_separator", "decimal separator must differ from the delimiter")
+	}
+	if profile.Currency != "" {
+		currency, err := NormalizeCurrency(profile.Currency)
+		if err != nil {
+			return ImportProfile{}, err
+		}
+		profile.Currency = currency
+	}
+	if profile.CategoryID < 0 {
+		return ImportProfile{}, NewValidationError("category_id", "category ID must be positive")
+	}
+	return profile, nil
+}
+
+func GetImportProfiles(db *sql.DB, ledgerID int64) ([]ImportProfile, error) {
+	rows, err := db.Query("SELECT "+importProfileColumns+" FROM ImportProfile WHERE ledger_id = $1 ORDER BY name", ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []ImportProfile
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func GetImportProfileByID(db *sql.DB, ledgerID, id int64) (ImportProfile, error) {
+	profile, err := scanImportProfile(db.QueryRow("SELECT "+importProfileColumns+" FROM ImportProfile WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return ImportProfile{}, notFound(err, "import profile")
+	}
+	return profile, nil
+}
+
+func GetImportProfileByName(db *sql.DB, ledgerID int64, name string) (ImportProfile, error) {
+	profile, err := scanImportProfile(db.QueryRow("SELECT "+importProfileColumns+" FROM ImportProfile WHERE name = $1 AND ledger_id = $2", name, ledgerID))
+	if err != nil {
+		return ImportProfile{}, notFound(err, "import profile")
+	}
+	return profile, nil
+}
+
+// checkProfileReferences reports a profile's missing category or account as
+// a validation error.
+func checkProfileReferences(q querier, ledgerID int64, profile ImportProfile) error {
+	if err := checkCategory(q, ledgerID, profile.CategoryID); err != nil {
+		return err
+	}
+	if profile.AccountID != nil {
+		_, err := entryCurrency(q, ledgerID, profile.AccountID, profile.Currency)
+		return err
+	}
+	return nil
+}
+
+// duplicateImportProfile reports a clash with an existing profile's name as a
+// conflict, and returns any other error unchanged.
+func duplicateImportProfile(err error, name string) error {
+	if isUniqueViolation(err) {
+		return NewConflictError("import profile %q already exists", name)
+	}
+	return err
+}
+
+func CreateImportProfile(db *sql.DB, ledgerID int64, profile ImportProfile) (ImportProfile, error) {
+	profile, err := NormalizeImportProfile(profile)
+	if err != nil {
+		return ImportProfile{}, err
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if err := checkProfileReferences(tx, ledgerID, profile); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO ImportProfile (name, delimiter, skip_rows, date_column, date_format, description_column,
+				amount_column, debit_column, credit_column, sign_convention, decimal_separator, currency_column,
+				currency, category_id, account_id, ledger_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id`,
+			profile.Name, profile.Delimiter, profile.SkipRows, profile.DateColumn, profile.DateFormat,
+			profile.DescriptionColumn, profile.AmountColumn, profile.DebitColumn, profile.CreditColumn,
+			profile.SignConvention, profile.DecimalSeparator, profile.CurrencyColumn, profile.Currency,
+			profile.CategoryID, profile.AccountID, ledgerID,
+		).Scan(&profile.ID)
+	})
+	if err != nil {
+		return ImportProfile{}, duplicateImportProfile(err, profile.Name)
+	}
+	return profile, nil
+}
+
+// UpdateImportProfile replaces a profile's fields.
+func UpdateImportProfile(db *sql.DB, ledgerID int64, profile ImportProfile) (ImportProfile, error) {
+	profile, err := NormalizeImportProfile(profile)
+	if err != nil {
+		return ImportProfile{}, err
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		if err := checkProfileReferences(tx, ledgerID, profile); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+			UPDATE ImportProfile SET name = $1, delimiter = $2, skip_rows = $3, date_column = $4, date_format = $5,
+				description_column = $6, amount_column = $7, debit_column = $8, credit_column = $9,
+				sign_convention = $10, decimal_separator = $11, currency_column = $12, currency = $13,
+				category_id = $14, account_id = $15
+			WHERE id = $16 AND ledger_id = $17`,
+			profile.Name, profile.Delimiter, profile.SkipRows, profile.DateColumn, profile.DateFormat,
+			profile.DescriptionColumn, profile.AmountColumn, profile.DebitColumn, profile.CreditColumn,
+			profile.SignConvention, profile.DecimalSeparator, profile.CurrencyColumn, profile.Currency,
+			profile.CategoryID, profile.AccountID, profile.ID, ledgerID,
+		)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return NewNotFoundError("import profile")
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportProfile{}, duplicateImportProfile(err, profile.Name)
+	}
+	return profile, nil
+}
+
+func DeleteImportProfile(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM ImportProfile WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("import profile")
+	}
+	return nil
+}
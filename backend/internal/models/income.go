@@ -2,21 +2,42 @@ package models
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"strings"
 	"time"
 )
 
 type Income struct {
-	ID     int64     `json:"id"`
-	Amount float64   `json:"amount"`
-	Date   time.Time `json:"date"`
-	Source string    `json:"source"`
+	ID       int64     `json:"id"`
+	Amount   Money     `json:"amount"`
+	Currency string    `json:"currency"`
+	Date     time.Time `json:"date"`
+	Source   string    `json:"source"`
+
+	// RecurringRuleID is set on incomes generated by a recurring rule.
+	RecurringRuleID *int64 `json:"recurring_rule_id,omitempty"`
+
+	// AccountID is the account the income was paid into, if recorded.
+	AccountID *int64 `json:"account_id,omitempty"`
+
+	// Converted is filled in when a caller asks for amounts in another currency.
+	Converted *Conversion `json:"converted,omitempty"`
+
+	// Tags are the names of the tags the income carries, in order.
+	Tags []string `json:"tags,omitempty"`
 }
 
-func GetIncomes(db *sql.DB) ([]Income, error) {
-	rows, err := db.Query("SELECT id, amount, date, source FROM Income")
+// incomeColumns lists the columns read by scanIncome, in order.
+const incomeColumns = "id, amount, currency, date, source, recurring_rule_id, account_id"
+
+func scanIncome(row rowScanner) (Income, error) {
+	var income Income
+	err := row.Scan(&income.ID, &income.Amount, &income.Currency, &income.Date, &income.Source, &income.RecurringRuleID, &income.AccountID)
+	return income, err
+}
+
+func GetIncomes(db *sql.DB, ledgerID int64) ([]Income, error) {
+	rows, err := db.Query("SELECT "+incomeColumns+" FROM Income WHERE ledger_id = $1 ORDER BY id", ledgerID)
 	if err != nil {
 		return nil, err
 	}
@@ -24,64 +45,167 @@ func GetIncomes(db *sql.DB) ([]Income, error) {
 
 	var incomes []Income
 	for rows.Next() {
-		var income Income
-		err := rows.Scan(&income.ID, &income.Amount, &income.Date, &income.Source)
+		income, err := scanIncome(rows)
 		if err != nil {
 			return nil, err
 		}
 		incomes = append(incomes, income)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	return loadIncomeTags(db, incomes)
+}
+
+// loadIncomeTags fills in the tags of the incomes.
+func loadIncomeTags(q querier, incomes []Income) ([]Income, error) {
+	if len(incomes) == 0 {
+		return incomes, nil
+	}
+	ids := make([]int64, len(incomes))
+	for i, income := range incomes {
+		ids[i] = income.ID
+	}
+	tags, err := incomeTagLink.load(q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range incomes {
+		incomes[i].Tags = tags[incomes[i].ID]
+	}
 	return incomes, nil
 }
 
-func GetIncomeByID(db *sql.DB, id int64) (Income, error) {
-	var income Income
-	err := db.QueryRow("SELECT id, amount, date, source FROM Income WHERE id = $1", id).
-		Scan(&income.ID, &income.Amount, &income.Date, &income.Source)
+// ListIncomes returns one page of the incomes matching the date range,
+// account, amount range, tags and source search in opts, along with the total
+// number of matches.
+func ListIncomes(db *sql.DB, ledgerID int64, opts ListOptions) ([]Income, int, error) {
+	var q listQuery
+	q.where("ledger_id = ?", ledgerID)
+	q.dateRange("date", opts)
+	if opts.AccountID != 0 {
+		q.where("account_id = ?", opts.AccountID)
+	}
+	q.amountRange("amount", opts)
+	q.search(opts, "source")
+	incomeTagLink.filter(&q, opts.Tags)
+	incomes, total, err := list(db, "Income", incomeColumns, IncomeSortFields, q, opts, scanIncome)
+	if err != nil {
+		return nil, 0, err
+	}
+	incomes, err = loadIncomeTags(db, incomes)
+	return incomes, total, err
+}
+
+func GetIncomeByID(db *sql.DB, ledgerID, id int64) (Income, error) {
+	income, err := scanIncome(db.QueryRow("SELECT "+incomeColumns+" FROM Income WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return Income{}, notFound(err, "income")
+	}
+	incomes, err := loadIncomeTags(db, []Income{income})
 	if err != nil {
 		return Income{}, err
 	}
-	return income, nil
+	return incomes[0], nil
 }
 
-func CreateIncome(db *sql.DB, income Income) (Income, error) {
+// ValidateIncome validates fields for creating an income.
+func ValidateIncome(income Income) error {
 	// Validate Amount
 	if income.Amount <= 0 {
-		return Income{}, errors.New("amount must be greater than zero")
+		return NewValidationError("amount", "amount must be greater than zero")
 	}
 
 	// Validate Date
 	if income.Date.IsZero() {
-		return Income{}, errors.New("date must be provided")
+		return NewValidationError("date", "date must be provided")
 	}
 	if income.Date.After(time.Now()) {
-		return Income{}, errors.New("date cannot be in the future")
+		return NewValidationError("date", "date cannot be in the future")
 	}
 
 	// Validate Source
 	if income.Source == "" {
-		return Income{}, errors.New("source must be provided")
+		return NewValidationError("source", "source must be provided")
+	}
+	if len(income.Source) > 255 { // Assuming a reasonable max length for the source field
+		return NewValidationError("source", "source is too long (max 255 characters)")
+	}
+
+	// Validate Currency
+	if _, err := NormalizeCurrency(income.Currency); err != nil {
+		return err
+	}
+	return nil
+}
+
+func CreateIncome(db *sql.DB, ledgerID int64, income Income) (Income, error) {
+	if err := ValidateIncome(income); err != nil {
+		return Income{}, err
+	}
+	tags, err := NormalizeTags(income.Tags)
+	if err != nil {
+		return Income{}, err
 	}
-	if len(income.Source) > 255 {  // Assuming a reasonable max length for the source field
-		return Income{}, errors.New("source is too long (max 255 characters)")
+	currency, err := entryCurrency(db, ledgerID, income.AccountID, income.Currency)
+	if err != nil {
+		return Income{}, err
 	}
+	income.Currency = currency
+	income.Tags = tags
 
-	// If all validations pass, insert into database
-	err := db.QueryRow("INSERT INTO Income (amount, date, source) VALUES ($1, $2, $3) RETURNING id",
-		income.Amount, income.Date, income.Source).Scan(&income.ID)
+	// If all validations pass, insert into database together with the tags
+	err = withTx(db, func(tx *sql.Tx) error {
+		var err error
+		if income, err = insertIncome(tx, ledgerID, income); err != nil {
+			return err
+		}
+		if len(income.Tags) == 0 {
+			return nil
+		}
+		return incomeTagLink.set(tx, ledgerID, income.ID, income.Tags)
+	})
 	if err != nil {
 		return Income{}, err
 	}
 	return income, nil
 }
 
-func UpdateIncome(db *sql.DB, income Income) (Income, error) {
-	// Fetch the current income data
-	currentIncome, err := GetIncomeByID(db, income.ID)
+// insertIncome inserts an income and returns it with its new ID.
+func insertIncome(q querier, ledgerID int64, income Income) (Income, error) {
+	err := q.QueryRow("INSERT INTO Income (amount, currency, date, source, recurring_rule_id, account_id, ledger_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		income.Amount, income.Currency, income.Date, income.Source, income.RecurringRuleID, income.AccountID, ledgerID).Scan(&income.ID)
+	return income, err
+}
+
+// UpdateIncome updates the fields of an income that are provided, replacing
+// its tags when Tags is not nil.
+func UpdateIncome(db *sql.DB, ledgerID int64, income Income) (Income, error) {
+	tags, err := NormalizeTags(income.Tags)
+	if err != nil {
+		return Income{}, err
+	}
+	income.Tags = tags
+
+	var updatedIncome Income
+	err = withTx(db, func(tx *sql.Tx) error {
+		var err error
+		updatedIncome, err = updateIncome(tx, ledgerID, income)
+		return err
+	})
 	if err != nil {
 		return Income{}, err
 	}
+	return updatedIncome, nil
+}
+
+func updateIncome(tx *sql.Tx, ledgerID int64, income Income) (Income, error) {
+	// Fetch the current income data
+	currentIncome, err := scanIncome(tx.QueryRow("SELECT "+incomeColumns+" FROM Income WHERE id = $1 AND ledger_id = $2", income.ID, ledgerID))
+	if err != nil {
+		return Income{}, notFound(err, "income")
+	}
 
 	// Prepare the update query and arguments
 	query := "UPDATE Income SET"
@@ -113,21 +237,73 @@ func UpdateIncome(db *sql.DB, income Income) (Income, error) {
 		income.Source = currentIncome.Source
 	}
 
-	// Remove the trailing comma and add the WHERE clause
-	query = strings.TrimSuffix(query, ",")
-	query += fmt.Sprintf(" WHERE id = $%d", argCount)
-	args = append(args, income.ID)
+	if income.Currency != "" {
+		currency, err := NormalizeCurrency(income.Currency)
+		if err != nil {
+			return Income{}, err
+		}
+		query += fmt.Sprintf(" currency = $%d,", argCount)
+		args = append(args, currency)
+		argCount++
+		income.Currency = currency
+	} else {
+		income.Currency = currentIncome.Currency
+	}
 
-	// Execute the update query
-	_, err = db.Exec(query, args...)
-	if err != nil {
+	if income.AccountID != nil {
+		query += fmt.Sprintf(" account_id = $%d,", argCount)
+		args = append(args, *income.AccountID)
+		argCount++
+	} else {
+		income.AccountID = currentIncome.AccountID
+	}
+
+	// An income on an account must stay in the account's currency
+	if _, err := entryCurrency(tx, ledgerID, income.AccountID, income.Currency); err != nil {
 		return Income{}, err
 	}
 
+	income.RecurringRuleID = currentIncome.RecurringRuleID
+
+	// Execute the update query, unless only the tags are being changed
+	if len(args) > 0 {
+		query = strings.TrimSuffix(query, ",")
+		query += fmt.Sprintf(" WHERE id = $%d", argCount)
+		args = append(args, income.ID)
+
+		_, err = tx.Exec(query, args...)
+		if err != nil {
+			return Income{}, err
+		}
+	}
+
+	// Replace the tags if given, and otherwise report the current ones
+	if income.Tags != nil {
+		if err := incomeTagLink.set(tx, ledgerID, income.ID, income.Tags); err != nil {
+			return Income{}, err
+		}
+	} else {
+		tags, err := incomeTagLink.load(tx, []int64{income.ID})
+		if err != nil {
+			return Income{}, err
+		}
+		income.Tags = tags[income.ID]
+	}
+
 	return income, nil
 }
 
-func DeleteIncome(db *sql.DB, id int64) error {
-	_, err := db.Exec("DELETE FROM Income WHERE id = $1", id)
-	return err
-}
\ No newline at end of file
+func DeleteIncome(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM Income WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("income")
+	}
+	return nil
+}
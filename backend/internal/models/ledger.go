@@ -0,0 +1,321 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Ledger is a set of categories, budgets, expenses and everything else
+// recorded, shared by its members. Role is the role of the member the
+// ledger was looked up for.
+type Ledger struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      Role      `json:"role,omitempty"`
+}
+
+// Role is what a member may do with a ledger.
+type Role string
+
+const (
+	// RoleOwner can do anything, including deleting categories and budgets
+	// and managing members and invitations.
+	RoleOwner Role = "owner"
+	// RoleEditor can change the data of the ledger.
+	RoleEditor Role = "editor"
+	// RoleViewer can only read the ledger.
+	RoleViewer Role = "viewer"
+)
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanWrite reports whether r may change the data of a ledger.
+func (r Role) CanWrite() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Member is a user who belongs to a ledger.
+type Member struct {
+	UserID   int64     `json:"user_id"`
+	Email    string    `json:"email"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Invitation lets whoever holds its token join a ledger with a role. Only a
+// hash of the token is stored, so Token is only known when the invitation
+// is created.
+type Invitation struct {
+	ID        int64     `json:"id"`
+	LedgerID  int64     `json:"ledger_id"`
+	Role      Role      `json:"role"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InvitationLifetime is how long an invitation can be accepted.
+const InvitationLifetime = 7 * 24 * time.Hour
+
+// PersonalLedgerName is the name of the ledger every user gets when they
+// register.
+const PersonalLedgerName = "Personal"
+
+// ValidateLedgerName trims a ledger name and checks it.
+func ValidateLedgerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", NewValidationError("name", "name must be provided")
+	}
+	if len(name) > 255 {
+		return "", NewValidationError("name", "name is too long (max 255 characters)")
+	}
+	return name, nil
+}
+
+// ValidateRole checks a role given for a member or an invitation.
+func ValidateRole(role Role) error {
+	if !role.Valid() {
+		return NewValidationError("role", "role must be one of owner, editor or viewer")
+	}
+	return nil
+}
+
+func scanLedger(row rowScanner) (Ledger, error) {
+	var ledger Ledger
+	if err := row.Scan(&ledger.ID, &ledger.Name, &ledger.CreatedAt, &ledger.Role); err != nil {
+		return Ledger{}, err
+	}
+	ledger.CreatedAt = ledger.CreatedAt.UTC()
+	return ledger, nil
+}
+
+func queryLedgers(db *sql.DB, query string, args ...any) ([]Ledger, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ledgers []Ledger
+	for rows.Next() {
+		ledger, err := scanLedger(rows)
+		if err != nil {
+			return nil, err
+		}
+		ledgers = append(ledgers, ledger)
+	}
+	return ledgers, rows.Err()
+}
+
+// GetLedgers returns every ledger, without a role.
+func GetLedgers(db *sql.DB) ([]Ledger, error) {
+	return queryLedgers(db, "SELECT id, name, created_at, '' FROM Ledger ORDER BY id")
+}
+
+// GetUserLedgers returns the ledgers a user belongs to with their role, in
+// the order they joined them, so their personal ledger comes first.
+func GetUserLedgers(db *sql.DB, userID int64) ([]Ledger, error) {
+	return queryLedgers(db, `
+		SELECT l.id, l.name, l.created_at, m.role FROM LedgerMember m JOIN Ledger l ON l.id = m.ledger_id
+		WHERE m.user_id = $1 ORDER BY m.created_at, l.id`, userID)
+}
+
+// GetUserLedger returns a ledger with the role of a user. A ledger the user
+// does not belong to is reported as missing.
+func GetUserLedger(db *sql.DB, ledgerID, userID int64) (Ledger, error) {
+	ledger, err := scanLedger(db.QueryRow(`
+		SELECT l.id, l.name, l.created_at, m.role FROM LedgerMember m JOIN Ledger l ON l.id = m.ledger_id
+		WHERE m.ledger_id = $1 AND m.user_id = $2`, ledgerID, userID))
+	if err != nil {
+		return Ledger{}, notFound(err, "ledger")
+	}
+	return ledger, nil
+}
+
+// CreateLedger creates a ledger owned by a user.
+func CreateLedger(db *sql.DB, userID int64, name string) (Ledger, error) {
+	name, err := ValidateLedgerName(name)
+	if err != nil {
+		return Ledger{}, err
+	}
+
+	var ledger Ledger
+	err = withTx(db, func(tx *sql.Tx) error {
+		var err error
+		ledger, err = createLedger(tx, userID, name, time.Now().UTC().Truncate(time.Second))
+		return err
+	})
+	if err != nil {
+		return Ledger{}, err
+	}
+	return ledger, nil
+}
+
+func createLedger(tx *sql.Tx, userID int64, name string, now time.Time) (Ledger, error) {
+	ledger := Ledger{Name: name, CreatedAt: now, Role: RoleOwner}
+	err := tx.QueryRow("INSERT INTO Ledger (name, created_at) VALUES ($1, $2) RETURNING id", name, now).Scan(&ledger.ID)
+	if err != nil {
+		return Ledger{}, err
+	}
+	_, err = tx.Exec("INSERT INTO LedgerMember (ledger_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+		ledger.ID, userID, RoleOwner, now)
+	if err != nil {
+		return Ledger{}, err
+	}
+	return ledger, nil
+}
+
+// GetMembers returns the members of a ledger with their emails, in the order
+// they joined.
+func GetMembers(db *sql.DB, ledgerID int64) ([]Member, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.email, m.role, m.created_at FROM LedgerMember m JOIN UserAccount u ON u.id = m.user_id
+		WHERE m.ledger_id = $1 ORDER BY m.created_at, u.id`, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []Member
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.UserID, &member.Email, &member.Role, &member.JoinedAt); err != nil {
+			return nil, err
+		}
+		member.JoinedAt = member.JoinedAt.UTC()
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// UpdateMemberRole changes the role of a member. The last owner of a ledger
+// cannot step down.
+func UpdateMemberRole(db *sql.DB, ledgerID, userID int64, role Role) (Member, error) {
+	if err := ValidateRole(role); err != nil {
+		return Member{}, err
+	}
+
+	var member Member
+	err := withTx(db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			SELECT u.id, u.email, m.role, m.created_at FROM LedgerMember m JOIN UserAccount u ON u.id = m.user_id
+			WHERE m.ledger_id = $1 AND m.user_id = $2`, ledgerID, userID).
+			Scan(&member.UserID, &member.Email, &member.Role, &member.JoinedAt)
+		if err != nil {
+			return notFound(err, "member")
+		}
+		if member.Role == RoleOwner && role != RoleOwner {
+			if err := checkOtherOwner(tx, ledgerID, userID); err != nil {
+				return err
+			}
+		}
+		member.Role = role
+		member.JoinedAt = member.JoinedAt.UTC()
+		_, err = tx.Exec("UPDATE LedgerMember SET role = $1 WHERE ledger_id = $2 AND user_id = $3", role, ledgerID, userID)
+		return err
+	})
+	if err != nil {
+		return Member{}, err
+	}
+	return member, nil
+}
+
+// RemoveMember takes a user out of a ledger. The last owner of a ledger
+// cannot leave it.
+func RemoveMember(db *sql.DB, ledgerID, userID int64) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		var role Role
+		err := tx.QueryRow("SELECT role FROM LedgerMember WHERE ledger_id = $1 AND user_id = $2", ledgerID, userID).Scan(&role)
+		if err != nil {
+			return notFound(err, "member")
+		}
+		if role == RoleOwner {
+			if err := checkOtherOwner(tx, ledgerID, userID); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec("DELETE FROM LedgerMember WHERE ledger_id = $1 AND user_id = $2", ledgerID, userID)
+		return err
+	})
+}
+
+// checkOtherOwner reports a conflict unless the ledger has an owner other
+// than userID.
+func checkOtherOwner(tx *sql.Tx, ledgerID, userID int64) error {
+	var others bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM LedgerMember WHERE ledger_id = $1 AND user_id <> $2 AND role = $3)",
+		ledgerID, userID, RoleOwner).Scan(&others)
+	if err != nil {
+		return err
+	}
+	if !others {
+		return NewConflictError("a ledger must keep at least one owner")
+	}
+	return nil
+}
+
+// CreateInvitation invites whoever receives the returned token to join a
+// ledger with a role, and clears away the ledger's expired invitations.
+func CreateInvitation(db *sql.DB, ledgerID, invitedBy int64, role Role) (Invitation, error) {
+	if err := ValidateRole(role); err != nil {
+		return Invitation{}, err
+	}
+	token, err := NewSessionToken()
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	invitation := Invitation{LedgerID: ledgerID, Role: role, Token: token, CreatedAt: now, ExpiresAt: now.Add(InvitationLifetime)}
+	err = withTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM LedgerInvitation WHERE ledger_id = $1 AND expires_at <= $2", ledgerID, now); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO LedgerInvitation (ledger_id, token_hash, role, invited_by, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			ledgerID, HashToken(token), role, invitedBy, now, invitation.ExpiresAt).Scan(&invitation.ID)
+	})
+	if err != nil {
+		return Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// AcceptInvitation makes a user a member of the ledger an invitation is for
+// and uses the invitation up. Members cannot accept another invitation to
+// the same ledger.
+func AcceptInvitation(db *sql.DB, token string, userID int64) (Ledger, error) {
+	var ledgerID int64
+	now := time.Now().UTC().Truncate(time.Second)
+	err := withTx(db, func(tx *sql.Tx) error {
+		var role Role
+		err := tx.QueryRow("DELETE FROM LedgerInvitation WHERE token_hash = $1 AND expires_at > $2 RETURNING ledger_id, role",
+			HashToken(token), now).Scan(&ledgerID, &role)
+		if err != nil {
+			return notFound(err, "invitation")
+		}
+		var member bool
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM LedgerMember WHERE ledger_id = $1 AND user_id = $2)",
+			ledgerID, userID).Scan(&member)
+		if err != nil {
+			return err
+		}
+		if member {
+			return NewConflictError("you are already a member of this ledger")
+		}
+		_, err = tx.Exec("INSERT INTO LedgerMember (ledger_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)",
+			ledgerID, userID, role, now)
+		return err
+	})
+	if err != nil {
+		return Ledger{}, err
+	}
+	return GetUserLedger(db, ledgerID, userID)
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxListLimit caps the number of rows a single list request can return.
+const MaxListLimit = 1000
+
+// SortField orders a list by one field, ascending unless Desc is set.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions narrows, orders and pages a list. Zero values apply no filter,
+// and a zero Limit returns every matching row. Each list supports a subset of
+// the filters; the others are ignored.
+type ListOptions struct {
+	// From and To bound the date, or for budgets the period, inclusively.
+	From time.Time
+	To   time.Time
+
+	CategoryID int64
+	AccountID  int64
+	MinAmount  *Money
+	MaxAmount  *Money
+
+	// Search matches text columns case-insensitively.
+	Search string
+
+	// Tags limits expenses and incomes to those carrying every one of them.
+	Tags []string
+
+	Sort   []SortField
+	Limit  int
+	Offset int
+}
+
+// Sortable fields of each list, mapped to their columns.
+var (
+	ExpenseSortFields = map[string]string{
+		"id": "id", "date": "date", "amount": "amount", "currency": "currency",
+		"category_id": "category_id", "account_id": "account_id", "description": "description",
+	}
+	IncomeSortFields = map[string]string{
+		"id": "id", "date": "date", "amount": "amount", "currency": "currency",
+		"account_id": "account_id", "source": "source",
+	}
+	BudgetSortFields = map[string]string{
+		"id": "id", "category_id": "category_id", "amount": "amount", "spent": "spent",
+		"currency": "currency", "start_date": "start_date", "end_date": "end_date",
+	}
+	CategorySortFields = map[string]string{
+		"id": "id", "name": "name", "description": "description",
+	}
+	AccountSortFields = map[string]string{
+		"id": "id", "name": "name", "type": "type", "currency": "currency",
+		"opening_balance": "opening_balance", "balance": "balance",
+	}
+	TransferSortFields = map[string]string{
+		"id": "id", "date": "date", "amount": "amount", "from_account_id": "from_account_id",
+		"to_account_id": "to_account_id", "description": "description",
+	}
+	TransactionSortFields = map[string]string{
+		"date": "date", "type": "type", "amount": "amount", "currency": "currency",
+		"category_id": "category_id", "account_id": "account_id", "description": "description",
+	}
+)
+
+// ParseSort reads a comma-separated list of fields, each optionally prefixed
+// with '-' for descending order, and checks them against the sortable fields.
+func ParseSort(value string, sortable map[string]string) ([]SortField, error) {
+	var fields []SortField
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		field := SortField{Field: strings.TrimPrefix(name, "-"), Desc: strings.HasPrefix(name, "-")}
+		if _, ok := sortable[field.Field]; !ok {
+			return nil, NewValidationError("sort", "cannot sort by %q", field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// ValidateListOptions checks the parts of ListOptions that do not depend on
+// the list being queried.
+func ValidateListOptions(opts ListOptions) error {
+	if opts.Limit < 0 || opts.Limit > MaxListLimit {
+		return NewValidationError("limit", "limit must be between 1 and %d", MaxListLimit)
+	}
+	if opts.Offset < 0 {
+		return NewValidationError("offset", "offset must not be negative")
+	}
+	if opts.Offset > 0 && opts.Limit == 0 {
+		return NewValidationError("offset", "offset requires a limit")
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && opts.To.Before(opts.From) {
+		return NewValidationError("to", "from must not be after to")
+	}
+	if opts.MinAmount != nil && opts.MaxAmount != nil && *opts.MaxAmount < *opts.MinAmount {
+		return NewValidationError("max_amount", "min_amount must not be greater than max_amount")
+	}
+	return nil
+}
+
+// listQuery collects the WHERE conditions of a list query and their
+// arguments. A '?' in a condition stands for the condition's argument.
+type listQuery struct {
+	conditions []string
+	args       []any
+
+	// key lists the columns that identify a row, which end the ORDER BY
+	// clause so that pages are stable. It defaults to id.
+	key string
+}
+
+func (q *listQuery) where(condition string, arg any) {
+	q.args = append(q.args, arg)
+	q.conditions = append(q.conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(q.args))))
+}
+
+// dateRange restricts column to the options' date bounds.
+func (q *listQuery) dateRange(column string, opts ListOptions) {
+	if !opts.From.IsZero() {
+		q.where(column+" >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		q.where(column+" <= ?", opts.To)
+	}
+}
+
+// amountRange restricts column to the options' amount bounds.
+func (q *listQuery) amountRange(column string, opts ListOptions) {
+	if opts.MinAmount != nil {
+		q.where(column+" >= ?", *opts.MinAmount)
+	}
+	if opts.MaxAmount != nil {
+		q.where(column+" <= ?", *opts.MaxAmount)
+	}
+}
+
+// search matches the options' search text anywhere in any of the columns.
+func (q *listQuery) search(opts ListOptions, columns ...string) {
+	if opts.Search == "" {
+		return
+	}
+	var matches []string
+	for _, column := range columns {
+		matches = append(matches, "LOWER("+column+`) LIKE ? ESCAPE '\'`)
+	}
+	q.where("("+strings.Join(matches, " OR ")+")", likePattern(opts.Search))
+}
+
+// likePattern escapes LIKE wildcards in s and wraps it in '%'.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(s))
+	return "%" + s + "%"
+}
+
+func (q *listQuery) whereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// orderBy builds the ORDER BY clause, ending with the key columns so that
+// pages are stable.
+func orderBy(sort []SortField, sortable map[string]string, key string) string {
+	var terms []string
+	for _, field := range sort {
+		term := sortable[field.Field]
+		if field.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	if key == "" {
+		key = "id"
+	}
+	return " ORDER BY " + strings.Join(append(terms, key), ", ")
+}
+
+// list runs a filtered, sorted and paged query against table, returning the
+// page together with the number of rows matching the filters.
+func list[T any](db *sql.DB, table, columns string, sortable map[string]string, q listQuery, opts ListOptions, scan func(rowScanner) (T, error)) ([]T, int, error) {
+	if err := ValidateListOptions(opts); err != nil {
+		return nil, 0, err
+	}
+	for _, field := range opts.Sort {
+		if _, ok := sortable[field.Field]; !ok {
+			return nil, 0, NewValidationError("sort", "cannot sort by %q", field.Field)
+		}
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+table+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + columns + " FROM " + table + q.whereClause() + orderBy(opts.Sort, sortable, q.key)
+	args := q.args
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit, opts.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+)
+
+// Money is an exact monetary amount stored as integer minor units (cents),
+// matching the NUMERIC(10, 2) amount columns. Using integers avoids the
+// rounding drift float64 picks up when spent totals are updated repeatedly.
+type Money int64
+
+// moneyScale is the number of minor units per major unit.
+const moneyScale = 100
+
+// decimalPattern matches plain decimal numbers, as written in JSON or returned by the database.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// parseRat parses a decimal into an exact ratio.
+func parseRat(s string) (*big.Rat, error) {
+	if !decimalPattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return r, nil
+}
+
+// ParseMoney parses a decimal string such as "12", "12.5" or "-0.25".
+// Amounts with more than two decimal places are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+	cents := new(big.Rat).Mul(r, big.NewRat(moneyScale, 1))
+	if !cents.IsInt() {
+		return 0, fmt.Errorf("amount %q has more than two decimal places", s)
+	}
+	if !cents.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Money(cents.Num().Int64()), nil
+}
+
+// parseDecimal parses a decimal computed by the database, such as an
+// average, rounding it to the nearest cent instead of rejecting it.
+func parseDecimal(s string) (Money, error) {
+	r, err := parseRat(s)
+	if err != nil {
+		return 0, err
+	}
+	return roundRat(r.Mul(r, big.NewRat(moneyScale, 1))), nil
+}
+
+// MustParseMoney is like ParseMoney but panics on invalid input. It is meant
+// for constants and tests.
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MoneyFromFloat converts a float to the nearest cent. It is only meant for
+// values that are already floats, such as SQLite REAL results.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyScale))
+}
+
+// Cents returns the amount in minor units.
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Add returns m + other.
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Sub returns m - other.
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return -m
+}
+
+// Abs returns the magnitude of m.
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Mul returns m multiplied by a whole number.
+func (m Money) Mul(n int64) Money {
+	return m * Money(n)
+}
+
+// MulRat returns m multiplied by an exact ratio, such as an exchange rate,
+// rounded half away from zero to the nearest cent.
+func (m Money) MulRat(r *big.Rat) Money {
+	return roundRat(new(big.Rat).Mul(big.NewRat(int64(m), 1), r))
+}
+
+// roundRat rounds a number of cents half away from zero.
+func roundRat(product *big.Rat) Money {
+	quotient, remainder := new(big.Int).QuoRem(product.Num(), product.Denom(), new(big.Int))
+	// Round when the remainder is at least half of the denominator
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(product.Denom()) >= 0 {
+		if product.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return Money(quotient.Int64())
+}
+
+// Sum adds up a list of amounts.
+func Sum(amounts ...Money) Money {
+	var total Money
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// Float64 returns the amount as a float, for charting and other display use.
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String formats the amount with exactly two decimal places.
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/moneyScale, cents%moneyScale)
+}
+
+// MarshalJSON emits the amount as a JSON number with two decimal places.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts the amount as a JSON number or a decimal string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = 0
+		return nil
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner. Postgres returns NUMERIC values as text;
+// SQLite returns them as integers or floats.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v * moneyScale)
+	case float64:
+		*m = MoneyFromFloat(v)
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		parsed, err := parseDecimal(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, sending the amount as an exact decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
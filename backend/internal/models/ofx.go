@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ofxEntities decodes the character entities OFX files may contain.
+var ofxEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ", "&amp;", "&")
+
+// ParseStatementOFX reads the bank and credit card transactions of an OFX
+// or QFX file, in either the SGML form of OFX 1.x, where leaf elements are
+// not closed, or the XML form of OFX 2.x. Each transaction's FITID, together
+// with its account, becomes the row's ExternalID. Rows are in the
+// statement's default currency (CURDEF).
+func ParseStatementOFX(r io.Reader) (Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Statement{}, err
+	}
+	text := string(data)
+	pos := strings.Index(strings.ToUpper(text), "<OFX>")
+	if pos < 0 {
+		return Statement{}, NewValidationError("file", "file is not an OFX statement")
+	}
+
+	var statement Statement
+	var account, currency string
+	var transaction map[string]string
+	transactionLine := 0
+	line := 1 + strings.Count(text[:pos], "\n")
+	for {
+		open := strings.IndexByte(text[pos:], '<')
+		if open < 0 {
+			break
+		}
+		line += strings.Count(text[pos:pos+open], "\n")
+		pos += open
+		end := strings.IndexByte(text[pos:], '>')
+		if end < 0 {
+			return Statement{}, NewValidationError("file", "unterminated OFX tag on line %d", line)
+		}
+		tag := strings.ToUpper(strings.TrimSpace(text[pos+1 : pos+end]))
+		pos += end + 1
+		value := text[pos:]
+		if next := strings.IndexByte(value, '<'); next >= 0 {
+			value = value[:next]
+		}
+		value = ofxEntities.Replace(strings.TrimSpace(value))
+
+		switch {
+		case tag == "STMTTRN":
+			transaction, transactionLine = map[string]string{}, line
+		case tag == "/STMTTRN":
+			if transaction != nil {
+				statement.Rows = append(statement.Rows, ofxRow(transaction, account, currency, transactionLine))
+			}
+			transaction = nil
+		case strings.HasPrefix(tag, "/"):
+		case transaction != nil:
+			transaction[tag] = value
+		case tag == "ACCTID":
+			account = value
+		case tag == "CURDEF":
+			currency = value
+		}
+	}
+	return statement, nil
+}
+
+// ofxRow turns the elements of one STMTTRN aggregate into a statement row.
+func ofxRow(transaction map[string]string, account, currency string, line int) StatementRow {
+	row := StatementRow{Line: line, Description: transaction["NAME"], Currency: currency}
+	if row.Description == "" {
+		row.Description = transaction["MEMO"]
+	}
+	if fitID := transaction["FITID"]; fitID != "" {
+		row.ExternalID = "ofx:" + account + ":" + fitID
+	}
+
+	// Dates look like 20240305 or 20240305120000.000[-5:EST]; only the day matters
+	posted := transaction["DTPOSTED"]
+	date, err := time.Parse("20060102", posted[:min(len(posted), 8)])
+	if err != nil {
+		row.Error = fmt.Sprintf("invalid date %q", posted)
+		return row
+	}
+	row.Date = date
+
+	amount := transaction["TRNAMT"]
+	if !strings.Contains(amount, ".") {
+		// Some banks write a decimal comma
+		amount = strings.Replace(amount, ",", ".", 1)
+	}
+	money, err := ParseMoney(strings.TrimSpace(amount))
+	if err != nil {
+		row.Error = fmt.Sprintf("invalid amount %q", transaction["TRNAMT"])
+		return row
+	}
+	if money == 0 {
+		row.Error = "amount is missing or zero"
+		return row
+	}
+	row.Type = TransactionIncome
+	if money < 0 {
+		row.Type = TransactionExpense
+	}
+	row.Amount = money.Abs()
+	return row
+}
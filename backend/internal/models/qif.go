@@ -0,0 +1,128 @@
+package models
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultQIFDateFormat is how QIF dates are read unless told otherwise.
+const DefaultQIFDateFormat = "M/D/YYYY"
+
+// qifTransactionTypes are the QIF sections that hold bank-style transactions.
+var qifTransactionTypes = map[string]bool{"bank": true, "cash": true, "ccard": true, "oth a": true, "oth l": true}
+
+// ParseStatementQIF reads the bank, cash and credit card transactions of a
+// QIF file, skipping other sections such as investments and category lists.
+// Dates are read with dateFormat, written like an import profile's (default
+// DefaultQIFDateFormat); two-digit years and the apostrophe some programs
+// put before the year are accepted too.
+//
+// QIF has no transaction IDs, so each row's ExternalID hashes its contents.
+// Identical transactions within one file are numbered so they stay apart.
+func ParseStatementQIF(r io.Reader, dateFormat string) (Statement, error) {
+	if dateFormat == "" {
+		dateFormat = DefaultQIFDateFormat
+	}
+	layout, err := dateLayout(dateFormat)
+	if err != nil {
+		return Statement{}, err
+	}
+	layouts := []string{layout}
+	if strings.Contains(dateFormat, "YYYY") {
+		layouts = append(layouts, dateFormatTokens.Replace(strings.Replace(dateFormat, "YYYY", "YY", 1)))
+	} else {
+		layouts = append(layouts, dateFormatTokens.Replace(strings.Replace(dateFormat, "YY", "YYYY", 1)))
+	}
+
+	var statement Statement
+	scanner := bufio.NewScanner(r)
+	record := map[byte]string{}
+	recordLine := 0
+	inTransactions := true
+	repeats := map[string]int{}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if text[0] == '!' {
+			if section, ok := strings.CutPrefix(strings.ToLower(text), "!type:"); ok {
+				inTransactions = qifTransactionTypes[strings.TrimSpace(section)]
+			}
+			continue
+		}
+		if text[0] == '^' {
+			if inTransactions && len(record) > 0 {
+				row := qifRow(record, layouts, dateFormat, recordLine)
+				if row.Error == "" {
+					key := strings.Join([]string{record['D'], record['T'], record['P'], record['M'], record['N']}, "\x00")
+					repeats[key]++
+					sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, repeats[key])))
+					row.ExternalID = "qif:" + hex.EncodeToString(sum[:])
+				}
+				statement.Rows = append(statement.Rows, row)
+			}
+			record = map[byte]string{}
+			continue
+		}
+		if len(record) == 0 {
+			recordLine = line
+		}
+		// Split lines (S, E, $) repeat within a record; only the first of each is kept
+		if _, ok := record[text[0]]; !ok {
+			record[text[0]] = strings.TrimSpace(text[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Statement{}, err
+	}
+	return statement, nil
+}
+
+// qifRow turns the fields of one QIF record into a statement row.
+func qifRow(record map[byte]string, layouts []string, dateFormat string, line int) StatementRow {
+	row := StatementRow{Line: line, Description: record['P']}
+	if row.Description == "" {
+		row.Description = record['M']
+	}
+
+	date := strings.NewReplacer("'", "/", " ", "").Replace(record['D'])
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			row.Date = parsed
+			break
+		}
+	}
+	if row.Date.IsZero() {
+		row.Error = fmt.Sprintf("invalid date %q (expected %s)", record['D'], dateFormat)
+		return row
+	}
+
+	amount, ok := record['T']
+	if !ok {
+		amount = record['U']
+	}
+	money, err := parseStatementAmount(amount, ".")
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	if money == 0 {
+		row.Error = "amount is missing or zero"
+		return row
+	}
+	row.Type = TransactionIncome
+	if money < 0 {
+		row.Type = TransactionExpense
+	}
+	row.Amount = money.Abs()
+	return row
+}
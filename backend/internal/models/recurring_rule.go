@@ -0,0 +1,466 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of entry a recurring rule can generate.
+const (
+	RecurringExpense = "expense"
+	RecurringIncome  = "income"
+)
+
+// Frequencies supported by recurring rules, following RRULE's FREQ values.
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// RecurringRule is a schedule together with the template of the expense or
+// income it generates. Every Interval periods of Frequency, starting at
+// StartDate and ending at EndDate if set, a copy of the template is
+// recorded. Monthly and yearly rules fall on DayOfMonth (the start date's
+// day by default), moved to the last day of shorter months.
+//
+// NextDate is the first occurrence that has not been generated yet, or nil
+// once the schedule has ended.
+type RecurringRule struct {
+	ID         int64      `json:"id"`
+	Kind       string     `json:"kind"`
+	Frequency  string     `json:"frequency"`
+	Interval   int        `json:"interval"`
+	DayOfMonth int        `json:"day_of_month,omitempty"`
+	StartDate  time.Time  `json:"start_date"`
+	EndDate    *time.Time `json:"end_date,omitempty"`
+	NextDate   *time.Time `json:"next_date"`
+
+	// Template of the generated entries. Description becomes an expense's
+	// description or an income's source; CategoryID applies to expenses only.
+	CategoryID  int64  `json:"category_id,omitempty"`
+	Amount      Money  `json:"amount"`
+	Currency    string `json:"currency"`
+	Description string `json:"description"`
+}
+
+// recurringRuleColumns lists the columns read by scanRecurringRule, in order.
+const recurringRuleColumns = "id, kind, frequency, interval_count, day_of_month, start_date, end_date, next_date, category_id, amount, currency, description"
+
+func scanRecurringRule(row rowScanner) (RecurringRule, error) {
+	var rule RecurringRule
+	var dayOfMonth, categoryID sql.NullInt64
+	err := row.Scan(&rule.ID, &rule.Kind, &rule.Frequency, &rule.Interval, &dayOfMonth, &rule.StartDate,
+		&rule.EndDate, &rule.NextDate, &categoryID, &rule.Amount, &rule.Currency, &rule.Description)
+	if err != nil {
+		return RecurringRule{}, err
+	}
+	rule.DayOfMonth = int(dayOfMonth.Int64)
+	rule.CategoryID = categoryID.Int64
+	return rule, nil
+}
+
+// ValidateRecurringRule checks a rule and fills in its defaults.
+func ValidateRecurringRule(rule RecurringRule) (RecurringRule, error) {
+	rule.Kind = strings.ToLower(rule.Kind)
+	rule.Frequency = strings.ToLower(rule.Frequency)
+
+	switch rule.Kind {
+	case RecurringExpense:
+		if rule.CategoryID <= 0 {
+			return RecurringRule{}, NewValidationError("category_id", "category ID must be provided for recurring expenses")
+		}
+	case RecurringIncome:
+		if rule.CategoryID != 0 {
+			return RecurringRule{}, NewValidationError("category_id", "recurring incomes do not have a category")
+		}
+	default:
+		return RecurringRule{}, NewValidationError("kind", "kind must be expense or income")
+	}
+
+	switch rule.Frequency {
+	case FrequencyDaily, FrequencyWeekly:
+		if rule.DayOfMonth != 0 {
+			return RecurringRule{}, NewValidationError("day_of_month", "day of month only applies to monthly and yearly rules")
+		}
+	case FrequencyMonthly, FrequencyYearly:
+		if rule.DayOfMonth < 0 || rule.DayOfMonth > 31 {
+			return RecurringRule{}, NewValidationError("day_of_month", "day of month must be between 1 and 31")
+		}
+	default:
+		return RecurringRule{}, NewValidationError("frequency", "frequency must be daily, weekly, monthly or yearly")
+	}
+
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+	if rule.Interval < 0 {
+		return RecurringRule{}, NewValidationError("interval", "interval must be greater than zero")
+	}
+	if rule.StartDate.IsZero() {
+		return RecurringRule{}, NewValidationError("start_date", "start date must be provided")
+	}
+	if rule.EndDate != nil && rule.EndDate.Before(rule.StartDate) {
+		return RecurringRule{}, NewValidationError("end_date", "end date must be after start date")
+	}
+	if rule.Amount <= 0 {
+		return RecurringRule{}, NewValidationError("amount", "amount must be greater than zero")
+	}
+	if rule.Description == "" {
+		return RecurringRule{}, NewValidationError("description", "description is required")
+	}
+	if len(rule.Description) > 255 {
+		return RecurringRule{}, NewValidationError("description", "description is too long (max 255 characters)")
+	}
+
+	currency, err := NormalizeCurrency(rule.Currency)
+	if err != nil {
+		return RecurringRule{}, err
+	}
+	rule.Currency = currency
+	return rule, nil
+}
+
+// occurrence returns the n-th occurrence of the rule, counting from zero.
+// Each occurrence is computed from the start date rather than from the
+// previous one, so a short month does not pull later dates forward.
+func (r RecurringRule) occurrence(n int) time.Time {
+	start := time.Date(r.StartDate.Year(), r.StartDate.Month(), r.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	day := r.DayOfMonth
+	if day == 0 {
+		day = start.Day()
+	}
+
+	switch r.Frequency {
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n*r.Interval)
+	case FrequencyMonthly:
+		// Anchor on the first month whose occurrence is not before the start date
+		first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if clampDay(first.Year(), first.Month(), day) < start.Day() {
+			first = first.AddDate(0, 1, 0)
+		}
+		month := first.AddDate(0, n*r.Interval, 0)
+		return time.Date(month.Year(), month.Month(), clampDay(month.Year(), month.Month(), day), 0, 0, 0, 0, time.UTC)
+	case FrequencyYearly:
+		year := start.Year()
+		if clampDay(year, start.Month(), day) < start.Day() {
+			year++
+		}
+		year += n * r.Interval
+		return time.Date(year, start.Month(), clampDay(year, start.Month(), day), 0, 0, 0, 0, time.UTC)
+	default:
+		return start.AddDate(0, 0, n*r.Interval)
+	}
+}
+
+// clampDay moves day back to the last day of the month when the month is shorter.
+func clampDay(year int, month time.Month, day int) int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return min(day, last)
+}
+
+// Occurrences lists up to limit occurrences on or after from, stopping at
+// the rule's end date and, when it is not zero, after until. A limit of zero
+// or less means no limit.
+func (r RecurringRule) Occurrences(from, until time.Time, limit int) []time.Time {
+	var dates []time.Time
+	for n := 0; limit <= 0 || len(dates) < limit; n++ {
+		date := r.occurrence(n)
+		if r.EndDate != nil && date.After(*r.EndDate) {
+			break
+		}
+		if !until.IsZero() && date.After(until) {
+			break
+		}
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// Upcoming lists up to limit occurrences that have not been generated yet.
+func (r RecurringRule) Upcoming(limit int) []time.Time {
+	if r.NextDate == nil || limit <= 0 {
+		return nil
+	}
+	return r.Occurrences(*r.NextDate, time.Time{}, limit)
+}
+
+// NextOnOrAfter returns the first occurrence on or after date, or nil when
+// the schedule has ended by then.
+func (r RecurringRule) NextOnOrAfter(date time.Time) *time.Time {
+	dates := r.Occurrences(date, time.Time{}, 1)
+	if len(dates) == 0 {
+		return nil
+	}
+	return &dates[0]
+}
+
+// Expense returns the expense generated for an occurrence.
+func (r RecurringRule) Expense(date time.Time) Expense {
+	return Expense{CategoryID: r.CategoryID, Amount: r.Amount, Currency: r.Currency, Date: date, Description: r.Description, RecurringRuleID: &r.ID}
+}
+
+// Income returns the income generated for an occurrence.
+func (r RecurringRule) Income(date time.Time) Income {
+	return Income{Amount: r.Amount, Currency: r.Currency, Date: date, Source: r.Description, RecurringRuleID: &r.ID}
+}
+
+// nullableID stores a zero id as NULL.
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func GetRecurringRules(db *sql.DB, ledgerID int64) ([]RecurringRule, error) {
+	rows, err := db.Query("SELECT "+recurringRuleColumns+" FROM RecurringRule WHERE ledger_id = $1 ORDER BY id", ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []RecurringRule
+	for rows.Next() {
+		rule, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func GetRecurringRuleByID(db *sql.DB, ledgerID, id int64) (RecurringRule, error) {
+	rule, err := getRecurringRuleByID(db, ledgerID, id)
+	if err != nil {
+		return RecurringRule{}, notFound(err, "recurring rule")
+	}
+	return rule, nil
+}
+
+func getRecurringRuleByID(q querier, ledgerID, id int64) (RecurringRule, error) {
+	return scanRecurringRule(q.QueryRow("SELECT "+recurringRuleColumns+" FROM RecurringRule WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+}
+
+// CreateRecurringRule stores a new rule. Its first occurrence is generated
+// by the next materialization run, even if it lies in the past.
+func CreateRecurringRule(db *sql.DB, ledgerID int64, rule RecurringRule) (RecurringRule, error) {
+	rule, err := ValidateRecurringRule(rule)
+	if err != nil {
+		return RecurringRule{}, err
+	}
+	rule.NextDate = rule.NextOnOrAfter(rule.StartDate)
+	if rule.CategoryID != 0 {
+		if err := checkCategory(db, ledgerID, rule.CategoryID); err != nil {
+			return RecurringRule{}, err
+		}
+	}
+
+	err = db.QueryRow(`
+		INSERT INTO RecurringRule (kind, frequency, interval_count, day_of_month, start_date, end_date, next_date, category_id, amount, currency, description, ledger_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, rule.Kind, rule.Frequency, rule.Interval, nullableID(int64(rule.DayOfMonth)), rule.StartDate, rule.EndDate,
+		rule.NextDate, nullableID(rule.CategoryID), rule.Amount, rule.Currency, rule.Description, ledgerID).Scan(&rule.ID)
+	if err != nil {
+		return RecurringRule{}, missingCategory(err, rule.CategoryID)
+	}
+	return rule, nil
+}
+
+// UpdateRecurringRule replaces a rule's schedule and template. Entries that
+// were already generated are kept; generation resumes with the first
+// occurrence of the new schedule after the latest generated entry.
+func UpdateRecurringRule(db *sql.DB, ledgerID int64, rule RecurringRule) (RecurringRule, error) {
+	rule, err := ValidateRecurringRule(rule)
+	if err != nil {
+		return RecurringRule{}, err
+	}
+
+	err = withTx(db, func(tx *sql.Tx) error {
+		current, err := getRecurringRuleByID(tx, ledgerID, rule.ID)
+		if err != nil {
+			return notFound(err, "recurring rule")
+		}
+		if current.Kind != rule.Kind {
+			return NewValidationError("kind", "kind of a recurring rule cannot be changed")
+		}
+		if rule.CategoryID != 0 {
+			if err := checkCategory(tx, ledgerID, rule.CategoryID); err != nil {
+				return err
+			}
+		}
+
+		from := rule.StartDate
+		last, err := lastGeneratedDate(tx, rule)
+		if err != nil {
+			return err
+		}
+		if last != nil && !last.Before(from) {
+			from = last.AddDate(0, 0, 1)
+		}
+		rule.NextDate = rule.NextOnOrAfter(from)
+
+		_, err = tx.Exec(`
+			UPDATE RecurringRule
+			SET frequency = $1, interval_count = $2, day_of_month = $3, start_date = $4, end_date = $5, next_date = $6,
+				category_id = $7, amount = $8, currency = $9, description = $10
+			WHERE id = $11
+		`, rule.Frequency, rule.Interval, nullableID(int64(rule.DayOfMonth)), rule.StartDate, rule.EndDate, rule.NextDate,
+			nullableID(rule.CategoryID), rule.Amount, rule.Currency, rule.Description, rule.ID)
+		return err
+	})
+	if err != nil {
+		return RecurringRule{}, missingCategory(err, rule.CategoryID)
+	}
+	return rule, nil
+}
+
+// lastGeneratedDate returns the date of the latest entry generated by a rule.
+func lastGeneratedDate(q querier, rule RecurringRule) (*time.Time, error) {
+	table := "Expense"
+	if rule.Kind == RecurringIncome {
+		table = "Income"
+	}
+
+	var date time.Time
+	err := q.QueryRow("SELECT date FROM "+table+" WHERE recurring_rule_id = $1 ORDER BY date DESC LIMIT 1", rule.ID).Scan(&date)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// DeleteRecurringRule removes a rule. Entries it generated are kept and
+// simply lose their link to it.
+func DeleteRecurringRule(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM RecurringRule WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("recurring rule")
+	}
+	return nil
+}
+
+// MaterializeRecurringRules generates every occurrence of the ledger's rules
+// due on or before asOf and returns how many entries were created. Each rule is handled in
+// its own transaction, so one failing rule (for example for lack of an
+// exchange rate) does not hold back the others. Running it again, or from
+// several processes at once, never creates duplicates: the unique
+// (recurring_rule_id, date) indexes reject an occurrence that already exists.
+func MaterializeRecurringRules(db *sql.DB, ledgerID int64, asOf time.Time) (int, error) {
+	asOf = time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	rows, err := db.Query("SELECT id FROM RecurringRule WHERE next_date IS NOT NULL AND next_date <= $1 AND ledger_id = $2 ORDER BY id", asOf, ledgerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due recurring rules: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, id := range ids {
+		var count int
+		err := withTx(db, func(tx *sql.Tx) error {
+			var err error
+			count, err = materializeRule(tx, ledgerID, id, asOf)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recurring rule %d: %w", id, err))
+			continue
+		}
+		created += count
+	}
+	return created, errors.Join(errs...)
+}
+
+func materializeRule(tx *sql.Tx, ledgerID, id int64, asOf time.Time) (int, error) {
+	// Re-read the rule, since another process may have just advanced it
+	rule, err := getRecurringRuleByID(tx, ledgerID, id)
+	if err != nil {
+		return 0, err
+	}
+	if rule.NextDate == nil {
+		return 0, nil
+	}
+
+	created := 0
+	for _, date := range rule.Occurrences(*rule.NextDate, asOf, 0) {
+		inserted, err := insertOccurrence(tx, ledgerID, rule, date)
+		if err != nil {
+			return 0, err
+		}
+		if inserted {
+			created++
+		}
+	}
+
+	next := rule.NextOnOrAfter(asOf.AddDate(0, 0, 1))
+	if _, err := tx.Exec("UPDATE RecurringRule SET next_date = $1 WHERE id = $2", next, rule.ID); err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// insertOccurrence records one occurrence unless it already exists.
+func insertOccurrence(tx *sql.Tx, ledgerID int64, rule RecurringRule, date time.Time) (bool, error) {
+	if rule.Kind == RecurringIncome {
+		income := rule.Income(date)
+		var id int64
+		err := tx.QueryRow(`
+			INSERT INTO Income (amount, currency, date, source, recurring_rule_id, ledger_id) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (recurring_rule_id, date) DO NOTHING
+			RETURNING id
+		`, income.Amount, income.Currency, income.Date, income.Source, income.RecurringRuleID, ledgerID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	expense := rule.Expense(date)
+	expense, err := scanExpense(tx.QueryRow(`
+		INSERT INTO Expense (category_id, amount, currency, date, description, recurring_rule_id, ledger_id) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (recurring_rule_id, date) DO NOTHING
+		RETURNING `+expenseColumns,
+		expense.CategoryID, expense.Amount, expense.Currency, expense.Date, expense.Description, expense.RecurringRuleID, ledgerID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, adjustBudgetSpent(tx, ledgerID, expense, 1)
+}
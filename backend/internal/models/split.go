@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// ExpenseSplit is one line of an expense split across categories, such as
+// the household items on a supermarket receipt.
+type ExpenseSplit struct {
+	ID         int64  `json:"id"`
+	CategoryID int64  `json:"category_id"`
+	Amount     Money  `json:"amount"`
+	Memo       string `json:"memo,omitempty"`
+}
+
+// Allocations returns how an expense's amount is spread over categories:
+// its split lines, or the whole amount in its own category if it is not
+// split. Budgets and reports count each allocation under its category.
+func (expense Expense) Allocations() []ExpenseSplit {
+	if len(expense.Splits) > 0 {
+		return expense.Splits
+	}
+	return []ExpenseSplit{{CategoryID: expense.CategoryID, Amount: expense.Amount}}
+}
+
+// ValidateSplits checks the split lines of an expense of the given amount,
+// which they must add up to, and trims their memos. A nil list stays nil,
+// so updates can tell an omitted list from an empty one that removes the
+// split.
+func ValidateSplits(amount Money, splits []ExpenseSplit) ([]ExpenseSplit, error) {
+	if len(splits) == 0 {
+		return splits, nil
+	}
+	validated := make([]ExpenseSplit, len(splits))
+	var total Money
+	for i, split := range splits {
+		if split.CategoryID <= 0 {
+			return nil, NewValidationError("splits", "split %d needs a category ID", i+1)
+		}
+		if split.Amount <= 0 {
+			return nil, NewValidationError("splits", "split %d amount must be greater than zero", i+1)
+		}
+		split.Memo = strings.TrimSpace(split.Memo)
+		if len(split.Memo) > 255 {
+			return nil, NewValidationError("splits", "split %d memo is too long (max 255 characters)", i+1)
+		}
+		split.ID = 0
+		validated[i] = split
+		total += split.Amount
+	}
+	if total != amount {
+		return nil, NewValidationError("splits", "split amounts add up to %s, not the expense amount of %s", total, amount)
+	}
+	return validated, nil
+}
+
+// checkSplitCategories reports a split line's missing category as a
+// validation error.
+func checkSplitCategories(q querier, ledgerID int64, splits []ExpenseSplit) error {
+	for _, split := range splits {
+		var exists bool
+		if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM Category WHERE id = $1 AND (ledger_id = $2 OR id = 1))", split.CategoryID, ledgerID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return NewValidationError("splits", "category %d does not exist", split.CategoryID)
+		}
+	}
+	return nil
+}
+
+// setExpenseSplits replaces the split lines of an expense and returns them
+// with their new ids.
+func setExpenseSplits(q querier, expenseID int64, splits []ExpenseSplit) ([]ExpenseSplit, error) {
+	if _, err := q.Exec("DELETE FROM ExpenseSplit WHERE expense_id = $1", expenseID); err != nil {
+		return nil, err
+	}
+	if len(splits) == 0 {
+		return nil, nil
+	}
+	saved := make([]ExpenseSplit, len(splits))
+	for i, split := range splits {
+		err := q.QueryRow("INSERT INTO ExpenseSplit (expense_id, category_id, amount, memo) VALUES ($1, $2, $3, $4) RETURNING id",
+			expenseID, split.CategoryID, split.Amount, split.Memo).Scan(&split.ID)
+		if err != nil {
+			return nil, err
+		}
+		saved[i] = split
+	}
+	return saved, nil
+}
+
+// loadExpenseSplits fills in the split lines of the expenses.
+func loadExpenseSplits(q querier, expenses []Expense) ([]Expense, error) {
+	if len(expenses) == 0 {
+		return expenses, nil
+	}
+	ids := make([]int64, len(expenses))
+	for i, expense := range expenses {
+		ids[i] = expense.ID
+	}
+	splits := map[int64][]ExpenseSplit{}
+	err := queryByIDs(q, "SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit WHERE expense_id IN (%s) ORDER BY id", ids, func(rows *sql.Rows) error {
+		var split ExpenseSplit
+		var expenseID int64
+		if err := rows.Scan(&split.ID, &expenseID, &split.CategoryID, &split.Amount, &split.Memo); err != nil {
+			return err
+		}
+		splits[expenseID] = append(splits[expenseID], split)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range expenses {
+		expenses[i].Splits = splits[expenses[i].ID]
+	}
+	return expenses, nil
+}
+
+// allocatedTo is the condition matching the expenses, with their id in
+// idColumn, that allocate some of their amount to the category given as its
+// argument: through a split line, or whole if they are not split.
+func allocatedTo(idColumn string) string {
+	return "((category_id = ? AND NOT EXISTS (SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = " + idColumn + "))" +
+		" OR EXISTS (SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = " + idColumn + " AND s.category_id = ?))"
+}
+
+// expenseAllocationsCTE defines expense_allocations(ledger_id, category_id,
+// currency, date, amount): the lines of every split expense and the other
+// expenses whole, for totalling spending by category.
+const expenseAllocationsCTE = `expense_allocations(ledger_id, category_id, currency, date, amount) AS (
+			SELECT ledger_id, category_id, currency, date, amount FROM Expense e
+			WHERE NOT EXISTS (SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = e.id)
+			UNION ALL
+			SELECT e.ledger_id, s.category_id, e.currency, e.date, s.amount FROM ExpenseSplit s JOIN Expense e ON e.id = s.expense_id
+		)`
@@ -0,0 +1,389 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxStatementSize is the largest bank statement, in bytes, that can be
+// imported.
+const MaxStatementSize = 10 << 20
+
+// StatementRow is one line of a bank statement, read as an expense or an
+// income. Error explains why the row cannot be imported.
+type StatementRow struct {
+	Line        int             `json:"line"`
+	Type        TransactionType `json:"type,omitempty"`
+	Date        time.Time       `json:"date"`
+	Description string          `json:"description"`
+	Amount      Money           `json:"amount"`
+	Currency    string          `json:"currency,omitempty"`
+	Error       string          `json:"error,omitempty"`
+
+	// ExternalID identifies the transaction across imports of the same
+	// statement, if the format allows it. Duplicate rows were imported
+	// before, or appear earlier in the same statement, and are skipped.
+	ExternalID string `json:"external_id,omitempty"`
+	Duplicate  bool   `json:"duplicate,omitempty"`
+
+	// CategoryID is the category an expense row is given.
+	CategoryID int64 `json:"category_id,omitempty"`
+}
+
+// Statement is a parsed bank statement together with where its rows go.
+// Expense rows matching one of Rules get its category instead of
+// CategoryID.
+type Statement struct {
+	CategoryID int64
+	AccountID  *int64
+	Rows       []StatementRow
+	Rules      []CategoryRule
+}
+
+// Expense returns the expense an expense row becomes.
+func (s Statement) Expense(row StatementRow) Expense {
+	expense := Expense{Amount: row.Amount, Currency: row.Currency, Date: row.Date, Description: row.Description, AccountID: s.AccountID}
+	expense.CategoryID = Categorize(s.Rules, expense, s.CategoryID)
+	return expense
+}
+
+// Income returns the income an income row becomes.
+func (s Statement) Income(row StatementRow) Income {
+	return Income{Amount: row.Amount, Currency: row.Currency, Date: row.Date, Source: row.Description, AccountID: s.AccountID}
+}
+
+// StatementImport reports what an import created, or would create on a dry
+// run, row by row.
+type StatementImport struct {
+	DryRun     bool           `json:"dry_run"`
+	Expenses   int            `json:"expenses"`
+	Incomes    int            `json:"incomes"`
+	Duplicates int            `json:"duplicates"`
+	Errors     int            `json:"errors"`
+	Rows       []StatementRow `json:"rows"`
+}
+
+// ParseStatementCSV reads a CSV bank statement as described by profile. A
+// file that does not match the profile is an error; a row that cannot be
+// read is returned with its Error set.
+func ParseStatementCSV(r io.Reader, profile ImportProfile) (Statement, error) {
+	profile, err := NormalizeImportProfile(profile)
+	if err != nil {
+		return Statement{}, err
+	}
+	layout, _ := dateLayout(profile.DateFormat)
+
+	reader := csv.NewReader(r)
+	reader.Comma = rune(profile.Delimiter[0])
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	for i := 0; i < profile.SkipRows; i++ {
+		if _, err := reader.Read(); err != nil {
+			return Statement{}, fmt.Errorf("failed to skip line %d: %w", i+1, err)
+		}
+	}
+	header, err := reader.Read()
+	if err == io.EOF {
+		return Statement{}, NewValidationError("file", "CSV file has no header row")
+	}
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(field, name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, NewValidationError(field, "CSV header is missing the %q column", name)
+		}
+		return i, nil
+	}
+
+	var mapping statementMapping
+	for _, c := range []struct {
+		index *int
+		field string
+		name  string
+	}{
+		{&mapping.date, "date_column", profile.DateColumn},
+		{&mapping.description, "description_column", profile.DescriptionColumn},
+		{&mapping.amount, "amount_column", profile.AmountColumn},
+		{&mapping.debit, "debit_column", profile.DebitColumn},
+		{&mapping.credit, "credit_column", profile.CreditColumn},
+		{&mapping.currency, "currency_column", profile.CurrencyColumn},
+	} {
+		if *c.index, err = column(c.field, c.name); err != nil {
+			return Statement{}, err
+		}
+	}
+
+	statement := Statement{CategoryID: profile.CategoryID, AccountID: profile.AccountID}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Statement{}, err
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		row, err := mapping.row(record, profile, layout)
+		if err != nil {
+			row.Error = err.Error()
+		}
+		row.Line = line
+		statement.Rows = append(statement.Rows, row)
+	}
+	return statement, nil
+}
+
+// statementMapping holds the index of each profile column in a CSV record,
+// or -1 for columns the profile does not use.
+type statementMapping struct {
+	date, description, amount, debit, credit, currency int
+}
+
+func (m statementMapping) row(record []string, profile ImportProfile, layout string) (StatementRow, error) {
+	field := func(i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := StatementRow{Description: field(m.description), Currency: profile.Currency}
+	if m.currency >= 0 && field(m.currency) != "" {
+		row.Currency = field(m.currency)
+	}
+
+	date, err := time.Parse(layout, field(m.date))
+	if err != nil {
+		return row, fmt.Errorf("invalid date %q (expected %s)", field(m.date), profile.DateFormat)
+	}
+	row.Date = date
+
+	if m.amount >= 0 {
+		amount, err := parseStatementAmount(field(m.amount), profile.DecimalSeparator)
+		if err != nil {
+			return row, err
+		}
+		if amount == 0 {
+			return row, errors.New("amount is missing or zero")
+		}
+		row.Type = TransactionIncome
+		if (amount < 0) == (profile.SignConvention == SignNegativeExpense) {
+			row.Type = TransactionExpense
+		}
+		row.Amount = amount.Abs()
+		return row, nil
+	}
+
+	debit, err := parseStatementAmount(field(m.debit), profile.DecimalSeparator)
+	if err != nil {
+		return row, err
+	}
+	credit, err := parseStatementAmount(field(m.credit), profile.DecimalSeparator)
+	if err != nil {
+		return row, err
+	}
+	switch {
+	case debit != 0 && credit != 0:
+		return row, errors.New("row has both a debit and a credit")
+	case debit != 0:
+		row.Type, row.Amount = TransactionExpense, debit.Abs()
+	case credit != 0:
+		row.Type, row.Amount = TransactionIncome, credit.Abs()
+	default:
+		return row, errors.New("debit and credit are both missing or zero")
+	}
+	return row, nil
+}
+
+// parseStatementAmount reads an amount as banks write it: with thousands
+// separators, a currency symbol, or parentheses around negative amounts. An
+// empty amount is zero.
+func parseStatementAmount(s, decimalSeparator string) (Money, error) {
+	amount := strings.TrimSpace(s)
+	if amount == "" {
+		return 0, nil
+	}
+	sign := ""
+	if strings.HasPrefix(amount, "(") && strings.HasSuffix(amount, ")") {
+		sign, amount = "-", amount[1:len(amount)-1]
+	}
+	if strings.HasPrefix(amount, "-") || strings.HasPrefix(amount, "+") {
+		if amount[:1] == "-" {
+			sign = "-"
+		}
+		amount = amount[1:]
+	}
+	amount = strings.TrimLeft(amount, "$€£¥ ")
+
+	thousandsSeparator := ","
+	if decimalSeparator == "," {
+		thousandsSeparator = "."
+	}
+	amount = strings.NewReplacer(thousandsSeparator, "", " ", "", "\u00a0", "", "'", "").Replace(amount)
+	amount = strings.Replace(amount, decimalSeparator, ".", 1)
+
+	money, err := ParseMoney(sign + amount)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return money, nil
+}
+
+// ReviewStatement checks every row of a statement as the expense or income
+// it becomes, filling in its currency with resolveCurrency, marks the rows
+// that imported reports as imported before, and counts the rows of each
+// kind. If any row cannot be imported, it returns the report together with
+// a validation error.
+func ReviewStatement(statement Statement, dryRun bool, resolveCurrency func(accountID *int64, currency string) (string, error),
+	imported func(externalID string) (bool, error)) (StatementImport, error) {
+	report := StatementImport{DryRun: dryRun, Rows: make([]StatementRow, 0, len(statement.Rows))}
+	seen := map[string]bool{}
+	for _, row := range statement.Rows {
+		if row.Error == "" && row.ExternalID != "" {
+			duplicate, err := imported(row.ExternalID)
+			if err != nil {
+				return StatementImport{}, err
+			}
+			row.Duplicate = duplicate || seen[row.ExternalID]
+			seen[row.ExternalID] = true
+		}
+		if row.Error == "" && !row.Duplicate {
+			if err := reviewStatementRow(&row, statement, resolveCurrency); err != nil {
+				if !errors.Is(err, ErrValidation) {
+					return StatementImport{}, err
+				}
+				row.Error = err.Error()
+			}
+		}
+		switch {
+		case row.Error != "":
+			report.Errors++
+		case row.Duplicate:
+			report.Duplicates++
+		case row.Type == TransactionExpense:
+			report.Expenses++
+		default:
+			report.Incomes++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if report.Errors > 0 {
+		return report, NewValidationError("rows", "%d of %d rows cannot be imported", report.Errors, len(report.Rows))
+	}
+	return report, nil
+}
+
+func reviewStatementRow(row *StatementRow, statement Statement, resolveCurrency func(accountID *int64, currency string) (string, error)) error {
+	currency, err := resolveCurrency(statement.AccountID, row.Currency)
+	if err != nil {
+		return err
+	}
+	row.Currency = currency
+	if row.Type == TransactionExpense {
+		expense := statement.Expense(*row)
+		row.CategoryID = expense.CategoryID
+		return ValidateCreateExpense(expense)
+	}
+	return ValidateIncome(statement.Income(*row))
+}
+
+// checkCategory reports a category that is missing, or that belongs to
+// another ledger, as a validation error.
+func checkCategory(q querier, ledgerID, id int64) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM Category WHERE id = $1 AND (ledger_id = $2 OR id = 1))", id, ledgerID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return NewValidationError("category_id", "category %d does not exist", id)
+	}
+	return nil
+}
+
+// ImportStatement creates the expenses and incomes of a statement in one
+// transaction, keeping budgets in step, and skips rows that were imported
+// before. Nothing is created if any row cannot be imported, or on a dry run;
+// either way the report lists every row.
+func ImportStatement(db *sql.DB, ledgerID int64, statement Statement, dryRun bool) (StatementImport, error) {
+	var report StatementImport
+	err := withTx(db, func(tx *sql.Tx) error {
+		if err := checkCategory(tx, ledgerID, statement.CategoryID); err != nil {
+			return err
+		}
+		var err error
+		if statement.Rules, err = getCategoryRules(tx, ledgerID); err != nil {
+			return err
+		}
+		report, err = ReviewStatement(statement, dryRun, func(accountID *int64, currency string) (string, error) {
+			return entryCurrency(tx, ledgerID, accountID, currency)
+		}, func(externalID string) (bool, error) {
+			var exists bool
+			err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM ImportedTransaction WHERE external_id = $1 AND ledger_id = $2)", externalID, ledgerID).Scan(&exists)
+			return exists, err
+		})
+		if err != nil || dryRun {
+			return err
+		}
+
+		for _, row := range report.Rows {
+			if row.Duplicate {
+				continue
+			}
+			if err := importStatementRow(tx, ledgerID, statement, row); err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// importStatementRow creates the expense or income for a reviewed row and
+// records its external ID.
+func importStatementRow(tx *sql.Tx, ledgerID int64, statement Statement, row StatementRow) error {
+	var expenseID, incomeID *int64
+	if row.Type == TransactionIncome {
+		income, err := insertIncome(tx, ledgerID, statement.Income(row))
+		if err != nil {
+			return err
+		}
+		incomeID = &income.ID
+	} else {
+		expense, err := insertExpense(tx, ledgerID, statement.Expense(row))
+		if err != nil {
+			return err
+		}
+		if err := adjustBudgetSpent(tx, ledgerID, expense, 1); err != nil {
+			return err
+		}
+		expenseID = &expense.ID
+	}
+
+	if row.ExternalID == "" {
+		return nil
+	}
+	_, err := tx.Exec("INSERT INTO ImportedTransaction (external_id, expense_id, income_id, ledger_id) VALUES ($1, $2, $3, $4)",
+		row.ExternalID, expenseID, incomeID, ledgerID)
+	return err
+}
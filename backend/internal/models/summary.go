@@ -0,0 +1,189 @@
+package models
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CurrencyAmount is a total in a single currency, before conversion.
+type CurrencyAmount struct {
+	Currency string `json:"currency"`
+	Amount   Money  `json:"amount"`
+}
+
+// Total pairs the original per-currency amounts with their sum in the
+// summary currency.
+type Total struct {
+	Original  []CurrencyAmount `json:"original"`
+	Converted Money            `json:"converted"`
+}
+
+// CategoryTotal is the expense total of one category.
+type CategoryTotal struct {
+	CategoryID int64 `json:"category_id"`
+	Total
+}
+
+// Summary totals expenses and incomes over a period in a single currency.
+type Summary struct {
+	Currency   string          `json:"currency"`
+	From       *time.Time      `json:"from,omitempty"`
+	To         *time.Time      `json:"to,omitempty"`
+	Expenses   Total           `json:"expenses"`
+	Incomes    Total           `json:"incomes"`
+	Net        Money           `json:"net"`
+	Categories []CategoryTotal `json:"categories"`
+}
+
+// add records an amount in its original currency and its converted value.
+func (t *Total) add(amount Money, currency string, converted Money) {
+	t.Converted += converted
+	for i := range t.Original {
+		if t.Original[i].Currency == currency {
+			t.Original[i].Amount += amount
+			return
+		}
+	}
+	t.Original = append(t.Original, CurrencyAmount{Currency: currency, Amount: amount})
+	slices.SortFunc(t.Original, func(a, b CurrencyAmount) int { return strings.Compare(a.Currency, b.Currency) })
+}
+
+// BuildSummary converts every expense and income into the summary currency
+// at the rate for its own date and totals them, counting each split line of
+// an expense under its own category.
+func BuildSummary(currency string, expenses []Expense, incomes []Income, rates RateFunc) (Summary, error) {
+	summary := Summary{
+		Currency:   currency,
+		Expenses:   Total{Original: []CurrencyAmount{}},
+		Incomes:    Total{Original: []CurrencyAmount{}},
+		Categories: []CategoryTotal{},
+	}
+
+	byCategory := map[int64]*Total{}
+	for _, expense := range expenses {
+		converted, err := convertWith(rates, expense.Amount, expense.Currency, currency, expense.Date)
+		if err != nil {
+			return Summary{}, err
+		}
+		summary.Expenses.add(expense.Amount, expense.Currency, converted)
+
+		for _, allocation := range expense.Allocations() {
+			if len(expense.Splits) > 0 {
+				if converted, err = convertWith(rates, allocation.Amount, expense.Currency, currency, expense.Date); err != nil {
+					return Summary{}, err
+				}
+			}
+			total, ok := byCategory[allocation.CategoryID]
+			if !ok {
+				total = &Total{}
+				byCategory[allocation.CategoryID] = total
+			}
+			total.add(allocation.Amount, expense.Currency, converted)
+		}
+	}
+	for _, income := range incomes {
+		converted, err := convertWith(rates, income.Amount, income.Currency, currency, income.Date)
+		if err != nil {
+			return Summary{}, err
+		}
+		summary.Incomes.add(income.Amount, income.Currency, converted)
+	}
+
+	for categoryID, total := range byCategory {
+		summary.Categories = append(summary.Categories, CategoryTotal{CategoryID: categoryID, Total: *total})
+	}
+	slices.SortFunc(summary.Categories, func(a, b CategoryTotal) int { return cmp.Compare(a.CategoryID, b.CategoryID) })
+	summary.Net = summary.Incomes.Converted - summary.Expenses.Converted
+	return summary, nil
+}
+
+// GetSummary totals the ledger's expenses and incomes dated within [from, to]
+// in the given currency. A zero from or to leaves that end of the period
+// open.
+func GetSummary(db *sql.DB, ledgerID int64, currency string, from, to time.Time) (Summary, error) {
+	where, args := periodFilter("", ledgerID, from, to)
+
+	// Amounts are grouped by day and currency, which is all conversion needs,
+	// with each split line under its own category
+	rows, err := db.Query(`
+		WITH `+expenseAllocationsCTE+`
+		SELECT category_id, currency, date, SUM(amount)
+		FROM expense_allocations`+where+`
+		GROUP BY category_id, currency, date
+	`, args...)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to total expenses: %w", err)
+	}
+	var expenses []Expense
+	for rows.Next() {
+		var expense Expense
+		if err := rows.Scan(&expense.CategoryID, &expense.Currency, &expense.Date, &expense.Amount); err != nil {
+			rows.Close()
+			return Summary{}, fmt.Errorf("failed to total expenses: %w", err)
+		}
+		expenses = append(expenses, expense)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Summary{}, fmt.Errorf("failed to total expenses: %w", err)
+	}
+
+	rows, err = db.Query(`
+		SELECT currency, date, SUM(amount)
+		FROM Income`+where+`
+		GROUP BY currency, date
+	`, args...)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to total incomes: %w", err)
+	}
+	var incomes []Income
+	for rows.Next() {
+		var income Income
+		if err := rows.Scan(&income.Currency, &income.Date, &income.Amount); err != nil {
+			rows.Close()
+			return Summary{}, fmt.Errorf("failed to total incomes: %w", err)
+		}
+		incomes = append(incomes, income)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Summary{}, fmt.Errorf("failed to total incomes: %w", err)
+	}
+
+	summary, err := BuildSummary(currency, expenses, incomes, rateCache(db))
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.SetPeriod(from, to)
+	return summary, nil
+}
+
+// SetPeriod records the bounds a summary was computed over.
+func (s *Summary) SetPeriod(from, to time.Time) {
+	if !from.IsZero() {
+		s.From = &from
+	}
+	if !to.IsZero() {
+		s.To = &to
+	}
+}
+
+// periodFilter builds the WHERE clause restricting the ledger's rows to
+// [from, to], with the columns qualified by prefix.
+func periodFilter(prefix string, ledgerID int64, from, to time.Time) (string, []any) {
+	conditions := []string{prefix + "ledger_id = $1"}
+	args := []any{ledgerID}
+	if !from.IsZero() {
+		args = append(args, from)
+		conditions = append(conditions, fmt.Sprintf("%sdate >= $%d", prefix, len(args)))
+	}
+	if !to.IsZero() {
+		args = append(args, to)
+		conditions = append(conditions, fmt.Sprintf("%sdate <= $%d", prefix, len(args)))
+	}
+	return "\n\t\tWHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Tag is a free-form label, such as "vacation-2026" or "reimbursable", that
+// expenses and incomes can carry alongside their category.
+type Tag struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// NormalizeTagName trims and lowercases a tag name and checks it.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", NewValidationError("name", "tag name must be provided")
+	}
+	if len(name) > 64 {
+		return "", NewValidationError("name", "tag name is too long (max 64 characters)")
+	}
+	if strings.Contains(name, ",") {
+		return "", NewValidationError("name", "tag name cannot contain a comma")
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes the tag names of an expense or income, sorting
+// them and dropping repeats. A nil list stays nil, so updates can tell an
+// omitted list from an empty one.
+func NormalizeTags(names []string) ([]string, error) {
+	if names == nil {
+		return nil, nil
+	}
+	tags := []string{}
+	for _, name := range names {
+		tag, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, NewValidationError("tags", "%s", err.Error())
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags), nil
+}
+
+// TagTotal is the spending and income carrying one tag.
+type TagTotal struct {
+	TagID    int64  `json:"tag_id"`
+	Tag      string `json:"tag"`
+	Expenses Total  `json:"expenses"`
+	Incomes  Total  `json:"incomes"`
+	Net      Money  `json:"net"`
+}
+
+// BuildTagSummary converts every tagged expense and income into currency at
+// the rate for its own date and totals them under each of their tags. Every
+// tag is listed, in name order, even if nothing carries it.
+func BuildTagSummary(currency string, tags []Tag, expenses []Expense, incomes []Income, rates RateFunc) ([]TagTotal, error) {
+	totals := make([]TagTotal, len(tags))
+	byName := map[string]*TagTotal{}
+	for i, tag := range tags {
+		totals[i] = TagTotal{TagID: tag.ID, Tag: tag.Name, Expenses: Total{Original: []CurrencyAmount{}}, Incomes: Total{Original: []CurrencyAmount{}}}
+		byName[tag.Name] = &totals[i]
+	}
+
+	for _, expense := range expenses {
+		converted, err := convertWith(rates, expense.Amount, expense.Currency, currency, expense.Date)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range expense.Tags {
+			if total, ok := byName[name]; ok {
+				total.Expenses.add(expense.Amount, expense.Currency, converted)
+			}
+		}
+	}
+	for _, income := range incomes {
+		converted, err := convertWith(rates, income.Amount, income.Currency, currency, income.Date)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range income.Tags {
+			if total, ok := byName[name]; ok {
+				total.Incomes.add(income.Amount, income.Currency, converted)
+			}
+		}
+	}
+
+	for i := range totals {
+		totals[i].Net = totals[i].Incomes.Converted - totals[i].Expenses.Converted
+	}
+	slices.SortFunc(totals, func(a, b TagTotal) int { return cmp.Compare(a.Tag, b.Tag) })
+	return totals, nil
+}
+
+func GetTags(db *sql.DB, ledgerID int64) ([]Tag, error) {
+	rows, err := db.Query("SELECT id, name FROM Tag WHERE ledger_id = $1 ORDER BY name", ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func GetTagByID(db *sql.DB, ledgerID, id int64) (Tag, error) {
+	var tag Tag
+	if err := db.QueryRow("SELECT id, name FROM Tag WHERE id = $1 AND ledger_id = $2", id, ledgerID).Scan(&tag.ID, &tag.Name); err != nil {
+		return Tag{}, notFound(err, "tag")
+	}
+	return tag, nil
+}
+
+// duplicateTag reports a clash with an existing tag's name as a conflict,
+// and returns any other error unchanged.
+func duplicateTag(err error, name string) error {
+	if isUniqueViolation(err) {
+		return NewConflictError("tag %q already exists", name)
+	}
+	return err
+}
+
+func CreateTag(db *sql.DB, ledgerID int64, tag Tag) (Tag, error) {
+	name, err := NormalizeTagName(tag.Name)
+	if err != nil {
+		return Tag{}, err
+	}
+	tag.Name = name
+
+	if err := db.QueryRow("INSERT INTO Tag (name, ledger_id) VALUES ($1, $2) RETURNING id", tag.Name, ledgerID).Scan(&tag.ID); err != nil {
+		return Tag{}, duplicateTag(err, tag.Name)
+	}
+	return tag, nil
+}
+
+// UpdateTag renames a tag on everything that carries it.
+func UpdateTag(db *sql.DB, ledgerID int64, tag Tag) (Tag, error) {
+	name, err := NormalizeTagName(tag.Name)
+	if err != nil {
+		return Tag{}, err
+	}
+	tag.Name = name
+
+	result, err := db.Exec("UPDATE Tag SET name = $1 WHERE id = $2 AND ledger_id = $3", tag.Name, tag.ID, ledgerID)
+	if err != nil {
+		return Tag{}, duplicateTag(err, tag.Name)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return Tag{}, err
+	} else if rowsAffected == 0 {
+		return Tag{}, NewNotFoundError("tag")
+	}
+	return tag, nil
+}
+
+// DeleteTag removes a tag from everything that carries it.
+func DeleteTag(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM Tag WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("tag")
+	}
+	return nil
+}
+
+// GetTagSummary totals the ledger's expenses and incomes dated within
+// [from, to] under each of their tags in the given currency. A zero from or
+// to leaves that end of the period open.
+func GetTagSummary(db *sql.DB, ledgerID int64, currency string, from, to time.Time) ([]TagTotal, error) {
+	tags, err := GetTags(db, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Amounts are grouped by tag, day and currency, which is all conversion needs
+	var expenses []Expense
+	where, args := periodFilter("e.", ledgerID, from, to)
+	err = queryTagged(db, `
+		SELECT t.name, e.currency, e.date, SUM(e.amount)
+		FROM Expense e JOIN ExpenseTag l ON l.expense_id = e.id JOIN Tag t ON t.id = l.tag_id`+where+`
+		GROUP BY t.name, e.currency, e.date
+	`, args, func(tag, currency string, date time.Time, amount Money) {
+		expenses = append(expenses, Expense{Tags: []string{tag}, Currency: currency, Date: date, Amount: amount})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to total tagged expenses: %w", err)
+	}
+	var incomes []Income
+	where, args = periodFilter("i.", ledgerID, from, to)
+	err = queryTagged(db, `
+		SELECT t.name, i.currency, i.date, SUM(i.amount)
+		FROM Income i JOIN IncomeTag l ON l.income_id = i.id JOIN Tag t ON t.id = l.tag_id`+where+`
+		GROUP BY t.name, i.currency, i.date
+	`, args, func(tag, currency string, date time.Time, amount Money) {
+		incomes = append(incomes, Income{Tags: []string{tag}, Currency: currency, Date: date, Amount: amount})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to total tagged incomes: %w", err)
+	}
+
+	return BuildTagSummary(currency, tags, expenses, incomes, rateCache(db))
+}
+
+// queryTagged reads the tag, currency, date and amount rows of a tag summary
+// query.
+func queryTagged(db *sql.DB, query string, args []any, add func(tag, currency string, date time.Time, amount Money)) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag, currency string
+		var date time.Time
+		var amount Money
+		if err := rows.Scan(&tag, &currency, &date, &amount); err != nil {
+			return err
+		}
+		add(tag, currency, date, amount)
+	}
+	return rows.Err()
+}
+
+// tagLink is the table linking tags to expenses or to incomes.
+type tagLink struct {
+	table  string
+	column string
+}
+
+var (
+	expenseTagLink = tagLink{table: "ExpenseTag", column: "expense_id"}
+	incomeTagLink  = tagLink{table: "IncomeTag", column: "income_id"}
+)
+
+// load returns the tag names of each of the entries with the given ids.
+func (link tagLink) load(q querier, ids []int64) (map[int64][]string, error) {
+	tags := map[int64][]string{}
+	err := queryByIDs(q, "SELECT l."+link.column+", t.name FROM "+link.table+" l JOIN Tag t ON t.id = l.tag_id WHERE l."+link.column+
+		" IN (%s) ORDER BY t.name", ids, func(rows *sql.Rows) error {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		tags[id] = append(tags[id], name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// set replaces the tags of an entry, creating the ledger's tags that do not
+// exist yet.
+func (link tagLink) set(q querier, ledgerID, id int64, names []string) error {
+	if _, err := q.Exec("DELETE FROM "+link.table+" WHERE "+link.column+" = $1", id); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var tagID int64
+		err := q.QueryRow("SELECT id FROM Tag WHERE name = $1 AND ledger_id = $2", name, ledgerID).Scan(&tagID)
+		if err == sql.ErrNoRows {
+			err = q.QueryRow("INSERT INTO Tag (name, ledger_id) VALUES ($1, $2) RETURNING id", name, ledgerID).Scan(&tagID)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := q.Exec("INSERT INTO "+link.table+" ("+link.column+", tag_id) VALUES ($1, $2)", id, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// carrying is the condition matching the entries carrying the tag given as
+// its argument.
+func (link tagLink) carrying() string {
+	return "id IN (SELECT l." + link.column + " FROM " + link.table + " l JOIN Tag t ON t.id = l.tag_id WHERE t.name = ?)"
+}
+
+// filter restricts a list to the entries carrying every one of the tags.
+func (link tagLink) filter(q *listQuery, tags []string) {
+	for _, tag := range tags {
+		q.where(link.carrying(), strings.ToLower(tag))
+	}
+}
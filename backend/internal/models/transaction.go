@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// TransactionType tells whether a ledger entry is an expense or an income.
+type TransactionType string
+
+const (
+	TransactionExpense  TransactionType = "expense"
+	TransactionIncome   TransactionType = "income"
+	TransactionTransfer TransactionType = "transfer"
+)
+
+// Transaction is one entry of the ledger: an expense, an income or one side
+// of a transfer, with a signed amount that is negative when money leaves.
+// ID is the id of the expense, income or transfer, so it is only unique
+// together with Type; a transfer appears once for each of its accounts.
+type Transaction struct {
+	Type        TransactionType `json:"type"`
+	ID          int64           `json:"id"`
+	Date        time.Time       `json:"date"`
+	Description string          `json:"description"`
+	Amount      Money           `json:"amount"`
+	Currency    string          `json:"currency"`
+
+	AccountID *int64 `json:"account_id,omitempty"`
+
+	// TransferAccountID is the account on the other side of a transfer.
+	TransferAccountID *int64 `json:"transfer_account_id,omitempty"`
+
+	// CategoryID and Category are only set on expenses that are not split.
+	CategoryID *int64 `json:"category_id,omitempty"`
+	Category   string `json:"category,omitempty"`
+
+	// Balance is the running total, in Currency, of the matching
+	// transactions in that currency up to and including this one.
+	Balance Money `json:"balance"`
+
+	RecurringRuleID *int64 `json:"recurring_rule_id,omitempty"`
+}
+
+// ledgerTable merges expenses, incomes and both sides of each transfer into
+// one signed stream, along with their owner. Incomes read their source as the
+// description, and split expenses have no category of their own.
+const ledgerTable = `(
+	SELECT 'expense' AS type, e.id, e.date, e.description, -e.amount AS amount, e.currency, e.account_id,
+		CAST(NULL AS INT) AS transfer_account_id, c.id AS category_id, c.name AS category, e.recurring_rule_id, e.ledger_id
+	FROM Expense e LEFT JOIN Category c ON c.id = e.category_id AND NOT EXISTS (SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = e.id)
+	UNION ALL
+	SELECT 'income' AS type, i.id, i.date, i.source, i.amount, i.currency, i.account_id,
+		NULL, NULL, NULL, i.recurring_rule_id, i.ledger_id
+	FROM Income i
+	UNION ALL
+	SELECT 'transfer' AS type, t.id, t.date, t.description, -t.amount, a.currency, t.from_account_id,
+		t.to_account_id, NULL, NULL, NULL, t.ledger_id
+	FROM Transfer t JOIN Account a ON a.id = t.from_account_id
+	UNION ALL
+	SELECT 'transfer' AS type, t.id, t.date, t.description, t.to_amount, a.currency, t.to_account_id,
+		t.from_account_id, NULL, NULL, NULL, t.ledger_id
+	FROM Transfer t JOIN Account a ON a.id = t.to_account_id
+) AS Ledger`
+
+// ledgerOrder is the chronological order of the ledger. Entries on the same
+// day list expenses, then incomes, then transfers, each in creation order;
+// the side of a transfer leaving its account comes first.
+const ledgerOrder = "date, type, id, amount"
+
+// transactionColumns lists the columns read by scanTransaction, in order.
+// The window runs after the WHERE clause and before LIMIT, so the balance
+// covers every match, including those on earlier pages.
+const transactionColumns = "type, id, date, description, amount, currency, account_id, transfer_account_id, category_id, category, " +
+	"SUM(amount) OVER (PARTITION BY currency ORDER BY " + ledgerOrder + " ROWS UNBOUNDED PRECEDING), recurring_rule_id"
+
+func scanTransaction(row rowScanner) (Transaction, error) {
+	var transaction Transaction
+	var category sql.NullString
+	err := row.Scan(&transaction.Type, &transaction.ID, &transaction.Date, &transaction.Description, &transaction.Amount,
+		&transaction.Currency, &transaction.AccountID, &transaction.TransferAccountID, &transaction.CategoryID, &category, &transaction.Balance, &transaction.RecurringRuleID)
+	transaction.Category = category.String
+	return transaction, err
+}
+
+// ListTransactions returns one page of the ledger entries matching
+// the date range, category, account, amount range, tags and search in opts,
+// along with the total number of matches. The amount range applies to the
+// unsigned amount, a category only matches expenses, including those with a
+// split line in it, tags only match expenses and incomes, and the search
+// covers descriptions, income sources and category names. Without a sort the
+// ledger is listed chronologically.
+func ListTransactions(db *sql.DB, ledgerID int64, opts ListOptions) ([]Transaction, int, error) {
+	q := listQuery{key: ledgerOrder}
+	q.where("ledger_id = ?", ledgerID)
+	q.dateRange("date", opts)
+	// ABS has no column affinity in SQLite, so the bounds are cast to compare
+	// as numbers rather than text
+	if opts.MinAmount != nil {
+		q.where("ABS(amount) >= CAST(? AS NUMERIC)", *opts.MinAmount)
+	}
+	if opts.MaxAmount != nil {
+		q.where("ABS(amount) <= CAST(? AS NUMERIC)", *opts.MaxAmount)
+	}
+	if opts.CategoryID != 0 {
+		q.where("type = 'expense' AND "+allocatedTo("Ledger.id"), opts.CategoryID)
+	}
+	if opts.AccountID != 0 {
+		q.where("account_id = ?", opts.AccountID)
+	}
+	q.search(opts, "description", "category")
+	for _, tag := range opts.Tags {
+		q.where("((type = 'expense' AND "+expenseTagLink.carrying()+") OR (type = 'income' AND "+incomeTagLink.carrying()+"))", strings.ToLower(tag))
+	}
+	return list(db, ledgerTable, transactionColumns, TransactionSortFields, q, opts, scanTransaction)
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Transfer moves money between two accounts. It is neither an expense nor
+// an income: budgets and summaries ignore it, while account balances and
+// the transaction ledger include it.
+type Transfer struct {
+	ID            int64 `json:"id"`
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+
+	// Amount leaves the source account in its currency. ToAmount arrives in
+	// the destination account's currency; it defaults to Amount and must be
+	// given when the currencies differ.
+	Amount   Money `json:"amount"`
+	ToAmount Money `json:"to_amount"`
+
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+}
+
+// transferColumns lists the columns read by scanTransfer, in order.
+const transferColumns = "id, from_account_id, to_account_id, amount, to_amount, date, description"
+
+func scanTransfer(row rowScanner) (Transfer, error) {
+	var transfer Transfer
+	err := row.Scan(&transfer.ID, &transfer.FromAccountID, &transfer.ToAccountID, &transfer.Amount, &transfer.ToAmount,
+		&transfer.Date, &transfer.Description)
+	return transfer, err
+}
+
+func GetTransfers(db *sql.DB, ledgerID int64) ([]Transfer, error) {
+	transfers, _, err := ListTransfers(db, ledgerID, ListOptions{})
+	return transfers, err
+}
+
+// ListTransfers returns one page of the transfers matching the date range,
+// account (on either side), amount range and description search in opts,
+// along with the total number of matches.
+func ListTransfers(db *sql.DB, ledgerID int64, opts ListOptions) ([]Transfer, int, error) {
+	var q listQuery
+	q.where("ledger_id = ?", ledgerID)
+	q.dateRange("date", opts)
+	if opts.AccountID != 0 {
+		q.where("(from_account_id = ? OR to_account_id = ?)", opts.AccountID)
+	}
+	q.amountRange("amount", opts)
+	q.search(opts, "description")
+	return list(db, "Transfer", transferColumns, TransferSortFields, q, opts, scanTransfer)
+}
+
+func GetTransferByID(db *sql.DB, ledgerID, id int64) (Transfer, error) {
+	transfer, err := scanTransfer(db.QueryRow("SELECT "+transferColumns+" FROM Transfer WHERE id = $1 AND ledger_id = $2", id, ledgerID))
+	if err != nil {
+		return Transfer{}, notFound(err, "transfer")
+	}
+	return transfer, nil
+}
+
+// ValidateTransfer validates fields for creating or replacing a transfer.
+func ValidateTransfer(transfer Transfer) error {
+	if transfer.FromAccountID <= 0 {
+		return NewValidationError("from_account_id", "from account ID must be provided")
+	}
+	if transfer.ToAccountID <= 0 {
+		return NewValidationError("to_account_id", "to account ID must be provided")
+	}
+	if transfer.FromAccountID == transfer.ToAccountID {
+		return NewValidationError("to_account_id", "cannot transfer to the same account")
+	}
+	if transfer.Amount <= 0 {
+		return NewValidationError("amount", "amount must be greater than zero")
+	}
+	if transfer.ToAmount < 0 {
+		return NewValidationError("to_amount", "to amount must be greater than zero")
+	}
+	if transfer.Date.IsZero() || transfer.Date.After(time.Now()) {
+		return NewValidationError("date", "date must be provided and cannot be in the future")
+	}
+	if len(transfer.Description) > 255 {
+		return NewValidationError("description", "description is too long (max 255 characters)")
+	}
+	return nil
+}
+
+// MatchTransferCurrencies fills in the amount arriving in the destination
+// account, given the currencies of both accounts.
+func MatchTransferCurrencies(transfer Transfer, fromCurrency, toCurrency string) (Transfer, error) {
+	if fromCurrency == toCurrency {
+		if transfer.ToAmount != 0 && transfer.ToAmount != transfer.Amount {
+			return Transfer{}, NewValidationError("to_amount", "to amount must equal amount between accounts in the same currency")
+		}
+		transfer.ToAmount = transfer.Amount
+		return transfer, nil
+	}
+	if transfer.ToAmount == 0 {
+		return Transfer{}, NewValidationError("to_amount", "to amount must be provided to transfer from %s to %s", fromCurrency, toCurrency)
+	}
+	return transfer, nil
+}
+
+// resolveTransfer checks that both accounts are the ledger's and fills in
+// ToAmount.
+func resolveTransfer(q querier, ledgerID int64, transfer Transfer) (Transfer, error) {
+	currencies := make([]string, 2)
+	for i, side := range []struct {
+		field string
+		id    int64
+	}{{"from_account_id", transfer.FromAccountID}, {"to_account_id", transfer.ToAccountID}} {
+		err := q.QueryRow("SELECT currency FROM Account WHERE id = $1 AND ledger_id = $2", side.id, ledgerID).Scan(&currencies[i])
+		if err == sql.ErrNoRows {
+			return Transfer{}, NewValidationError(side.field, "account %d does not exist", side.id)
+		}
+		if err != nil {
+			return Transfer{}, err
+		}
+	}
+	return MatchTransferCurrencies(transfer, currencies[0], currencies[1])
+}
+
+func CreateTransfer(db *sql.DB, ledgerID int64, transfer Transfer) (Transfer, error) {
+	if err := ValidateTransfer(transfer); err != nil {
+		return Transfer{}, err
+	}
+
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		if transfer, err = resolveTransfer(tx, ledgerID, transfer); err != nil {
+			return err
+		}
+		return tx.QueryRow(
+			"INSERT INTO Transfer (from_account_id, to_account_id, amount, to_amount, date, description, ledger_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+			transfer.FromAccountID, transfer.ToAccountID, transfer.Amount, transfer.ToAmount, transfer.Date, transfer.Description, ledgerID,
+		).Scan(&transfer.ID)
+	})
+	if err != nil {
+		return Transfer{}, err
+	}
+	return transfer, nil
+}
+
+// UpdateTransfer replaces a transfer's fields.
+func UpdateTransfer(db *sql.DB, ledgerID int64, transfer Transfer) (Transfer, error) {
+	if err := ValidateTransfer(transfer); err != nil {
+		return Transfer{}, err
+	}
+
+	err := withTx(db, func(tx *sql.Tx) error {
+		var err error
+		if transfer, err = resolveTransfer(tx, ledgerID, transfer); err != nil {
+			return err
+		}
+		result, err := tx.Exec(
+			"UPDATE Transfer SET from_account_id = $1, to_account_id = $2, amount = $3, to_amount = $4, date = $5, description = $6 WHERE id = $7 AND ledger_id = $8",
+			transfer.FromAccountID, transfer.ToAccountID, transfer.Amount, transfer.ToAmount, transfer.Date, transfer.Description, transfer.ID, ledgerID,
+		)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return NewNotFoundError("transfer")
+		}
+		return nil
+	})
+	if err != nil {
+		return Transfer{}, err
+	}
+	return transfer, nil
+}
+
+func DeleteTransfer(db *sql.DB, ledgerID, id int64) error {
+	result, err := db.Exec("DELETE FROM Transfer WHERE id = $1 AND ledger_id = $2", id, ledgerID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return NewNotFoundError("transfer")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// maxTxAttempts bounds how often a transaction is retried after a serialization conflict.
+const maxTxAttempts = 3
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so helpers can run
+// inside or outside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// idBatchSize caps the ids bound in one query by queryByIDs.
+const idBatchSize = 500
+
+// queryByIDs runs a query over the given ids in batches, with each batch's
+// placeholders in place of the %s in query, and scans every row returned.
+func queryByIDs(q querier, query string, ids []int64, scan func(rows *sql.Rows) error) error {
+	for start := 0; start < len(ids); start += idBatchSize {
+		batch := ids[start:min(start+idBatchSize, len(ids))]
+		placeholders := make([]string, len(batch))
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = id
+		}
+		rows, err := q.Query(fmt.Sprintf(query, strings.Join(placeholders, ", ")), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTx runs fn in a serializable transaction and commits it, rolling back
+// if fn fails. Concurrent writers touching the same budget rows can make
+// Postgres abort one of them with a serialization failure; those attempts
+// are retried from the start.
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	var err error
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err = runTx(db, fn)
+		if !isSerializationFailure(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func runTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// isSerializationFailure reports whether Postgres aborted the transaction
+// because of a conflicting concurrent transaction.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+	return false
+}
+
+// SQLite result codes for constraint violations, as reported by the driver.
+const (
+	sqliteConstraint           = 19
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintUnique     = 2067
+)
+
+// isUniqueViolation reports whether a write failed on a unique constraint.
+func isUniqueViolation(err error) bool {
+	return isConstraintViolation(err, "23505", sqliteConstraintUnique, "UNIQUE")
+}
+
+// isForeignKeyViolation reports whether a write referenced a missing row.
+func isForeignKeyViolation(err error) bool {
+	return isConstraintViolation(err, "23503", sqliteConstraintForeignKey, "FOREIGN KEY")
+}
+
+func isConstraintViolation(err error, pqCode pq.ErrorCode, sqliteCode int, sqliteMessage string) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqCode
+	}
+	// The SQLite driver may report either the extended or the primary result code
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqliteCode || (code == sqliteConstraint && strings.Contains(err.Error(), sqliteMessage))
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// User is someone who can sign in. What users record belongs to the
+// ledgers they are members of.
+type User struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is a signed-in user's access token, sent as a bearer token. Only
+// a hash of the token is stored, so Token is only known when the session is
+// created.
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+// SessionLifetime is how long a session lasts after signing in.
+const SessionLifetime = 30 * 24 * time.Hour
+
+// MinPasswordLength is the shortest password a user can register with.
+const MinPasswordLength = 8
+
+// PasswordHashIterations is the PBKDF2 work factor of new password hashes.
+// Each hash records its own, so raising it only affects new passwords.
+var PasswordHashIterations = 600_000
+
+// userColumns lists the columns read by scanUser, in order.
+const userColumns = "id, email, created_at"
+
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	if err := row.Scan(&user.ID, &user.Email, &user.CreatedAt); err != nil {
+		return User{}, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	return user, nil
+}
+
+// NormalizeEmail trims and lowercases an email address and checks it.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", NewValidationError("email", "email must be provided")
+	}
+	if len(email) > 254 {
+		return "", NewValidationError("email", "email is too long (max 254 characters)")
+	}
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return "", NewValidationError("email", "invalid email address %q", email)
+	}
+	return email, nil
+}
+
+// ValidatePassword checks the length of a new password.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return NewValidationError("password", "password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > 256 {
+		return NewValidationError("password", "password is too long (max 256 characters)")
+	}
+	return nil
+}
+
+// passwordHashScheme starts every password hash made by HashPassword.
+// CheckPassword picks the algorithm by it, so a stronger one can be added
+// later while older hashes keep working.
+const passwordHashScheme = "pbkdf2-sha256"
+
+// HashPassword derives a salted PBKDF2-HMAC-SHA256 hash of a password,
+// encoded as pbkdf2-sha256$<iterations>$<salt>$<hash>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), salt, PasswordHashIterations, sha256.Size, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordHashScheme, PasswordHashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether a password matches a hash made by
+// HashPassword, taking the same time whichever byte differs.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordHashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got := pbkdf2.Key([]byte(password), salt, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// unknownUserHash is checked against the password of a sign-in for an
+// unknown email, so that it takes as long as one for a known email.
+var unknownUserHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("")
+	return hash
+})
+
+// NewSessionToken returns a random token for a new session.
+func NewSessionToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// HashToken returns the hash a token is stored under.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ownedTables are the tables whose rows belong to a ledger.
+var ownedTables = []string{
+	"Category", "Expense", "Income", "Budget", "Account", "Transfer", "RecurringRule", "ImportProfile",
+	"ImportedTransaction", "CategoryRule", "Tag",
+}
+
+// GetUsers returns every user, oldest first.
+func GetUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query("SELECT " + userColumns + " FROM UserAccount ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetUserByEmail returns the user with an email, compared after
+// NormalizeEmail.
+func GetUserByEmail(db *sql.DB, email string) (User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := scanUser(db.QueryRow("SELECT "+userColumns+" FROM UserAccount WHERE email = $1", email))
+	if err != nil {
+		return User{}, notFound(err, "user")
+	}
+	return user, nil
+}
+
+// CreateUser registers a user with a password and gives them a personal
+// ledger.
+func CreateUser(db *sql.DB, email, password string) (User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return User{}, err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+
+	user := User{Email: email, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	err = withTx(db, func(tx *sql.Tx) error {
+		err := tx.QueryRow("INSERT INTO UserAccount (email, password_hash, created_at) VALUES ($1, $2, $3) RETURNING id",
+			user.Email, hash, user.CreatedAt).Scan(&user.ID)
+		if err != nil {
+			return err
+		}
+		_, err = createLedger(tx, user.ID, PersonalLedgerName, user.CreatedAt)
+		return err
+	})
+	if isUniqueViolation(err) {
+		return User{}, NewConflictError("a user with email %q already exists", email)
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// AdoptUnownedData gives whatever was recorded before there were users to
+// the first ledger of the user with an email, normally their personal
+// ledger, and returns how many rows it moved. The shared 'Other' category
+// stays shared. Operators run it once, through "migrate adopt", so that no
+// one takes over existing data just by registering first.
+func AdoptUnownedData(db *sql.DB, email string) (int64, error) {
+	user, err := GetUserByEmail(db, email)
+	if err != nil {
+		return 0, err
+	}
+	ledgers, err := GetUserLedgers(db, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	if len(ledgers) == 0 {
+		return 0, NewNotFoundError("ledger")
+	}
+
+	var adopted int64
+	err = withTx(db, func(tx *sql.Tx) error {
+		adopted = 0
+		for _, table := range ownedTables {
+			query := "UPDATE " + table + " SET ledger_id = $1 WHERE ledger_id IS NULL"
+			if table == "Category" {
+				query += " AND id <> 1"
+			}
+			result, err := tx.Exec(query, ledgers[0].ID)
+			if err != nil {
+				return err
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			adopted += rows
+		}
+		return nil
+	})
+	return adopted, err
+}
+
+// CreateSession signs a user in with their email and password, and clears
+// away their expired sessions.
+func CreateSession(db *sql.DB, email, password string) (Session, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return Session{}, NewUnauthorizedError("invalid email or password")
+	}
+	var user User
+	var hash string
+	err = db.QueryRow("SELECT "+userColumns+", password_hash FROM UserAccount WHERE email = $1", email).
+		Scan(&user.ID, &user.Email, &user.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		CheckPassword(unknownUserHash(), password)
+		return Session{}, NewUnauthorizedError("invalid email or password")
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	if !CheckPassword(hash, password) {
+		return Session{}, NewUnauthorizedError("invalid email or password")
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	token, err := NewSessionToken()
+	if err != nil {
+		return Session{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	session := Session{Token: token, ExpiresAt: now.Add(SessionLifetime), User: user}
+	err = withTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM Session WHERE user_id = $1 AND expires_at <= $2", user.ID, now); err != nil {
+			return err
+		}
+		_, err := tx.Exec("INSERT INTO Session (token_hash, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)",
+			HashToken(token), user.ID, now, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// GetSessionUser returns the user signed in with a session token.
+func GetSessionUser(db *sql.DB, token string) (User, error) {
+	user, err := scanUser(db.QueryRow(`
+		SELECT u.id, u.email, u.created_at FROM Session s JOIN UserAccount u ON u.id = s.user_id
+		WHERE s.token_hash = $1 AND s.expires_at > $2`, HashToken(token), time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return User{}, NewUnauthorizedError("session is invalid or has expired")
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// DeleteSession signs a session out.
+func DeleteSession(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM Session WHERE token_hash = $1", HashToken(token))
+	return err
+}
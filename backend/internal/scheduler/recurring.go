@@ -0,0 +1,50 @@
+// Package scheduler runs periodic background jobs alongside the HTTP server.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"expense-tracker/internal/store"
+)
+
+// RunRecurring generates due recurring expenses and incomes of every ledger
+// once at startup and then every interval until ctx is cancelled. Failures
+// are logged and retried on the next tick; materialization is idempotent, so
+// running several instances against one database is safe.
+func RunRecurring(ctx context.Context, backend store.Backend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		materializeAll(backend, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// materializeAll generates the entries of every ledger due by asOf.
+func materializeAll(backend store.Backend, asOf time.Time) {
+	ledgers, err := backend.Ledgers().GetLedgers()
+	if err != nil {
+		log.Printf("Failed to list ledgers for recurring rules: %v", err)
+		return
+	}
+
+	created := 0
+	for _, ledger := range ledgers {
+		n, err := backend.Stores(ledger.ID).Recurring.MaterializeRecurringRules(asOf)
+		if err != nil {
+			log.Printf("Failed to materialize some recurring rules of ledger %d: %v", ledger.ID, err)
+		}
+		created += n
+	}
+	if created > 0 {
+		log.Printf("Generated %d recurring entries", created)
+	}
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"io"
+	"log"
+
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/models"
+)
+
+// saveAttachment stores the content of an attachment in blobs and records
+// its metadata with create. Content beyond models.MaxAttachmentSize is not
+// read, and the stored content is removed again if the metadata is
+// rejected.
+func saveAttachment(blobs blob.Store, attachment models.Attachment, content io.Reader, create func(models.Attachment) (models.Attachment, error)) (models.Attachment, error) {
+	info, err := blob.Save(blobs, io.LimitReader(content, models.MaxAttachmentSize+1))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment.Key, attachment.Size, attachment.SHA256 = info.Key, info.Size, info.SHA256
+	created, err := create(attachment)
+	if err != nil {
+		removeBlobs(blobs, []models.Attachment{attachment})
+		return models.Attachment{}, err
+	}
+	return created, nil
+}
+
+// removeBlobs removes the content of attachments whose metadata is already
+// gone. A failure only leaves unreferenced content behind, so it is logged
+// rather than failing the request.
+func removeBlobs(blobs blob.Store, attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		if err := blobs.Delete(attachment.Key); err != nil {
+			log.Printf("Failed to remove attachment content %s: %v", attachment.Key, err)
+		}
+	}
+}
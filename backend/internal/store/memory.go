@@ -0,0 +1,2494 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/models"
+)
+
+// Memory implements every store in process memory. It applies the same
+// validation and budget bookkeeping as the SQL store, which makes it
+// suitable for handler tests and throwaway instances. Attachment content is
+// kept in an in-memory blob store. A Memory holds the data of one ledger; the
+// stores of a MemoryBackend share ids, exchange rates and blobs.
+type Memory struct {
+	mu         sync.Mutex
+	shared     *memoryShared
+	expenses   map[int64]models.Expense
+	incomes    map[int64]models.Income
+	categories map[int64]models.Category
+	budgets    map[int64]models.Budget
+	rules      map[int64]models.RecurringRule
+	accounts   map[int64]models.Account
+	transfers  map[int64]models.Transfer
+	profiles   map[int64]models.ImportProfile
+	imported   map[string]bool
+
+	categoryRules map[int64]models.CategoryRule
+	dismissed     map[[2]int64]bool
+	tags          map[int64]models.Tag
+	attachments   map[int64]models.Attachment
+	blobs         blob.Store
+}
+
+// memoryShared holds what every ledger of a MemoryBackend shares. Its lock is
+// taken after that of a Memory.
+type memoryShared struct {
+	mu     sync.Mutex
+	nextID int64
+	rates  []models.ExchangeRate
+	// memories lists every ledger's store, whose stale budgets a rate import
+	// recalculates.
+	memories []*Memory
+}
+
+// NewMemory returns an empty in-memory store seeded with the 'Other' category.
+func NewMemory() *Memory {
+	return newMemory(&memoryShared{nextID: 1}, blob.NewMemory())
+}
+
+func newMemory(shared *memoryShared, blobs blob.Store) *Memory {
+	m := &Memory{
+		shared:   shared,
+		expenses: map[int64]models.Expense{},
+		incomes:  map[int64]models.Income{},
+		categories: map[int64]models.Category{
+			1: {ID: 1, Name: "Other", Description: "Default category for uncategorized items"},
+		},
+		budgets:   map[int64]models.Budget{},
+		rules:     map[int64]models.RecurringRule{},
+		accounts:  map[int64]models.Account{},
+		transfers: map[int64]models.Transfer{},
+		profiles:  map[int64]models.ImportProfile{},
+		imported:  map[string]bool{},
+
+		categoryRules: map[int64]models.CategoryRule{},
+		dismissed:     map[[2]int64]bool{},
+		tags:          map[int64]models.Tag{},
+		attachments:   map[int64]models.Attachment{},
+		blobs:         blobs,
+	}
+	shared.mu.Lock()
+	shared.memories = append(shared.memories, m)
+	shared.mu.Unlock()
+	return m
+}
+
+// Stores returns the in-memory implementation of every store.
+func (m *Memory) Stores() Stores {
+	return Stores{Expenses: m, Incomes: m, Categories: m, CategoryRules: m, Budgets: m, Transactions: m, Accounts: m, Transfers: m, ExchangeRates: m, Imports: m, Reports: m, Recurring: m, Tags: m, Attachments: m}
+}
+
+// newID hands out ids from a single sequence, starting after the seeded 'Other' category.
+func (m *Memory) newID() int64 {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+	m.shared.nextID++
+	return m.shared.nextID
+}
+
+// sortedValues returns the values of a map ordered by id, or nil when it is empty.
+func sortedValues[T any](items map[int64]T) []T {
+	var values []T
+	for _, id := range slices.Sorted(maps.Keys(items)) {
+		values = append(values, items[id])
+	}
+	return values
+}
+
+// listValues applies list options to values ordered by id, mirroring
+// models.ListOptions: match filters a value and field returns the value of a
+// sortable field.
+func listValues[T any](values []T, opts models.ListOptions, sortable map[string]string, match func(T) bool, field func(T, string) any) ([]T, int, error) {
+	if err := models.ValidateListOptions(opts); err != nil {
+		return nil, 0, err
+	}
+	for _, sort := range opts.Sort {
+		if _, ok := sortable[sort.Field]; !ok {
+			return nil, 0, models.NewValidationError("sort", "cannot sort by %q", sort.Field)
+		}
+	}
+
+	matched := []T{}
+	for _, value := range values {
+		if match(value) {
+			matched = append(matched, value)
+		}
+	}
+	// The stable sort keeps id order as the final tiebreaker
+	slices.SortStableFunc(matched, func(a, b T) int {
+		for _, sort := range opts.Sort {
+			c := compareValues(field(a, sort.Field), field(b, sort.Field))
+			if sort.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	total := len(matched)
+	if opts.Limit > 0 {
+		start := min(opts.Offset, total)
+		matched = matched[start:min(start+opts.Limit, total)]
+	}
+	return matched, total, nil
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case models.Money:
+		return cmp.Compare(a, b.(models.Money))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// inRange reports whether a value lies within the optional bounds.
+func inRange[T any](value T, min, max *T, compare func(a, b T) int) bool {
+	return (min == nil || compare(value, *min) >= 0) && (max == nil || compare(value, *max) <= 0)
+}
+
+// matchesSearch reports whether any text contains the search case-insensitively.
+// matchesTags reports whether an entry carries every one of the tags.
+func matchesTags(entryTags, tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(entryTags, strings.ToLower(tag)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesCategory mirrors models.allocatedTo: a split expense matches the
+// categories of each of its lines.
+func matchesCategory(expense models.Expense, opts models.ListOptions) bool {
+	return opts.CategoryID == 0 || slices.ContainsFunc(expense.Allocations(), func(allocation models.ExpenseSplit) bool {
+		return allocation.CategoryID == opts.CategoryID
+	})
+}
+
+func matchesSearch(search string, texts ...string) bool {
+	if search == "" {
+		return true
+	}
+	search = strings.ToLower(search)
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesDates reports whether a date lies within the options' date bounds,
+// ignoring the time of day as a DATE column would.
+func matchesDates(date time.Time, opts models.ListOptions) bool {
+	date = dateOnly(date)
+	return (opts.From.IsZero() || !date.Before(opts.From)) && (opts.To.IsZero() || !date.After(opts.To))
+}
+
+func matchesAmount(amount models.Money, opts models.ListOptions) bool {
+	return inRange(amount, opts.MinAmount, opts.MaxAmount, cmp.Compare[models.Money])
+}
+
+func matchesAccount(accountID *int64, opts models.ListOptions) bool {
+	return opts.AccountID == 0 || (accountID != nil && *accountID == opts.AccountID)
+}
+
+// optionalID returns the id a nullable reference points to, or 0.
+func optionalID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func (m *Memory) GetExpenses() ([]models.Expense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedValues(m.expenses), nil
+}
+
+func (m *Memory) ListExpenses(opts models.ListOptions) ([]models.Expense, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return listValues(sortedValues(m.expenses), opts, models.ExpenseSortFields, func(expense models.Expense) bool {
+		return matchesDates(expense.Date, opts) && matchesAmount(expense.Amount, opts) && matchesAccount(expense.AccountID, opts) &&
+			matchesCategory(expense, opts) && matchesSearch(opts.Search, expense.Description) &&
+			matchesTags(expense.Tags, opts.Tags)
+	}, func(expense models.Expense, field string) any {
+		switch field {
+		case "date":
+			return expense.Date
+		case "amount":
+			return expense.Amount
+		case "currency":
+			return expense.Currency
+		case "category_id":
+			return expense.CategoryID
+		case "account_id":
+			return optionalID(expense.AccountID)
+		case "description":
+			return expense.Description
+		}
+		return expense.ID
+	})
+}
+
+func (m *Memory) GetExpenseByID(id int64) (models.Expense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expense, ok := m.expenses[id]
+	if !ok {
+		return models.Expense{}, models.NewNotFoundError("expense")
+	}
+	return expense, nil
+}
+
+func (m *Memory) CreateExpense(expense models.Expense) (models.Expense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if expense.CategoryID == 0 && len(expense.Splits) > 0 {
+		expense.CategoryID = expense.Splits[0].CategoryID
+	}
+	if expense.CategoryID == 0 {
+		expense.CategoryID = models.Categorize(m.sortedCategoryRules(), expense, 1)
+	}
+	if err := models.ValidateCreateExpense(expense); err != nil {
+		return models.Expense{}, err
+	}
+	tags, err := models.NormalizeTags(expense.Tags)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	splits, err := models.ValidateSplits(expense.Amount, expense.Splits)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if err := m.checkCategory(expense.CategoryID); err != nil {
+		return models.Expense{}, err
+	}
+	if err := m.checkSplitCategories(splits); err != nil {
+		return models.Expense{}, err
+	}
+	currency, err := m.entryCurrency(expense.AccountID, expense.Currency)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	expense.Currency = currency
+	expense.Tags = m.useTags(tags)
+	expense.Splits = m.newSplits(splits)
+	expense, err = m.insertExpense(expense)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	for _, other := range sortedValues(m.expenses) {
+		if models.IsDuplicate(expense, other) {
+			expense.PossibleDuplicates = append(expense.PossibleDuplicates, other.ID)
+		}
+	}
+	return expense, nil
+}
+
+// checkSplitCategories mirrors models.checkSplitCategories.
+func (m *Memory) checkSplitCategories(splits []models.ExpenseSplit) error {
+	for _, split := range splits {
+		if _, ok := m.categories[split.CategoryID]; !ok {
+			return models.NewValidationError("splits", "category %d does not exist", split.CategoryID)
+		}
+	}
+	return nil
+}
+
+// newSplits gives split lines their ids. An empty list is stored as nil.
+func (m *Memory) newSplits(splits []models.ExpenseSplit) []models.ExpenseSplit {
+	if len(splits) == 0 {
+		return nil
+	}
+	saved := make([]models.ExpenseSplit, len(splits))
+	for i, split := range splits {
+		split.ID = m.newID()
+		saved[i] = split
+	}
+	return saved
+}
+
+// moveExpenses moves the expenses and split lines of one category to
+// another.
+func (m *Memory) moveExpenses(fromID, toID int64) {
+	for expenseID, expense := range m.expenses {
+		moved := expense.CategoryID == fromID
+		if moved {
+			expense.CategoryID = toID
+		}
+		if slices.ContainsFunc(expense.Splits, func(split models.ExpenseSplit) bool { return split.CategoryID == fromID }) {
+			expense.Splits = slices.Clone(expense.Splits)
+			for i := range expense.Splits {
+				if expense.Splits[i].CategoryID == fromID {
+					expense.Splits[i].CategoryID = toID
+				}
+			}
+			moved = true
+		}
+		if moved {
+			m.expenses[expenseID] = expense
+		}
+	}
+}
+
+// checkCategory mirrors the category_id foreign keys.
+func (m *Memory) checkCategory(id int64) error {
+	if _, ok := m.categories[id]; !ok {
+		return models.NewValidationError("category_id", "category %d does not exist", id)
+	}
+	return nil
+}
+
+// entryCurrency mirrors models.entryCurrency.
+func (m *Memory) entryCurrency(accountID *int64, currency string) (string, error) {
+	if accountID == nil {
+		return models.NormalizeCurrency(currency)
+	}
+	account, ok := m.accounts[*accountID]
+	if !ok {
+		return "", models.NewValidationError("account_id", "account %d does not exist", *accountID)
+	}
+	return models.MatchAccountCurrency(account.Currency, currency)
+}
+
+// insertExpense stores a validated expense and adds it to its budgets.
+func (m *Memory) insertExpense(expense models.Expense) (models.Expense, error) {
+	deltas, err := m.budgetDeltas(nil, expense, 1)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	expense.ID = m.newID()
+	m.expenses[expense.ID] = expense
+	m.applyBudgetDeltas(deltas)
+	return expense, nil
+}
+
+func (m *Memory) UpdateExpense(expense models.Expense) (models.Expense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.expenses[expense.ID]
+	if !ok {
+		return models.Expense{}, models.NewNotFoundError("expense")
+	}
+	if err := models.ValidateUpdateExpense(expense, current); err != nil {
+		return models.Expense{}, err
+	}
+	tags, err := models.NormalizeTags(expense.Tags)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if expense.CategoryID != 0 {
+		if err := m.checkCategory(expense.CategoryID); err != nil {
+			return models.Expense{}, err
+		}
+	}
+	amount := current.Amount
+	if expense.Amount != 0 {
+		amount = expense.Amount
+	}
+	if expense.Splits != nil {
+		if expense.Splits, err = models.ValidateSplits(amount, expense.Splits); err != nil {
+			return models.Expense{}, err
+		}
+		if err := m.checkSplitCategories(expense.Splits); err != nil {
+			return models.Expense{}, err
+		}
+	} else if _, err := models.ValidateSplits(amount, current.Splits); err != nil {
+		return models.Expense{}, err
+	}
+
+	updated := current
+	if expense.Amount != 0 {
+		updated.Amount = expense.Amount
+	}
+	if expense.Description != "" {
+		updated.Description = expense.Description
+	}
+	if !expense.Date.IsZero() {
+		updated.Date = expense.Date
+	}
+	if expense.CategoryID != 0 {
+		updated.CategoryID = expense.CategoryID
+	}
+	if expense.Currency != "" {
+		updated.Currency, _ = models.NormalizeCurrency(expense.Currency)
+	}
+	if expense.AccountID != nil {
+		updated.AccountID = expense.AccountID
+	}
+	if expense.AccountID != nil || (expense.Currency != "" && current.AccountID != nil) {
+		if _, err := m.entryCurrency(updated.AccountID, updated.Currency); err != nil {
+			return models.Expense{}, err
+		}
+	}
+	if expense.Splits != nil {
+		updated.Splits = m.newSplits(expense.Splits)
+	}
+
+	deltas, err := m.budgetDeltas(nil, current, -1)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if deltas, err = m.budgetDeltas(deltas, updated, 1); err != nil {
+		return models.Expense{}, err
+	}
+	if tags != nil {
+		updated.Tags = m.useTags(tags)
+	}
+	m.expenses[expense.ID] = updated
+	m.applyBudgetDeltas(deltas)
+	return updated, nil
+}
+
+func (m *Memory) DeleteExpense(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.deleteExpense(id); err != nil {
+		return err
+	}
+
+	var removed []models.Attachment
+	for attachmentID, attachment := range m.attachments {
+		if attachment.ExpenseID == id {
+			removed = append(removed, attachment)
+			delete(m.attachments, attachmentID)
+		}
+	}
+	removeBlobs(m.blobs, removed)
+	return nil
+}
+
+func (m *Memory) deleteExpense(id int64) error {
+	current, ok := m.expenses[id]
+	if !ok {
+		return models.NewNotFoundError("expense")
+	}
+	deltas, err := m.budgetDeltas(nil, current, -1)
+	if err != nil {
+		return err
+	}
+	delete(m.expenses, id)
+	for pair := range m.dismissed {
+		if pair[0] == id || pair[1] == id {
+			delete(m.dismissed, pair)
+		}
+	}
+	m.applyBudgetDeltas(deltas)
+	return nil
+}
+
+func (m *Memory) GetDuplicateExpenses() ([]models.DuplicatePair, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return models.FindDuplicates(sortedValues(m.expenses), func(expenseID, duplicateID int64) bool {
+		return m.dismissed[[2]int64{expenseID, duplicateID}]
+	}), nil
+}
+
+func (m *Memory) DismissDuplicate(expenseID, duplicateID int64) error {
+	if expenseID == duplicateID {
+		return models.NewValidationError("duplicate", "an expense cannot duplicate itself")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range []int64{expenseID, duplicateID} {
+		if _, ok := m.expenses[id]; !ok {
+			return models.NewNotFoundError("expense")
+		}
+	}
+	m.dismissed[[2]int64{min(expenseID, duplicateID), max(expenseID, duplicateID)}] = true
+	return nil
+}
+
+func (m *Memory) MergeExpense(duplicateID, targetID int64) (models.Expense, error) {
+	if duplicateID == targetID {
+		return models.Expense{}, models.NewValidationError("target", "an expense cannot be merged into itself")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.expenses[targetID]
+	if !ok {
+		return models.Expense{}, models.NewNotFoundError("expense")
+	}
+	if err := m.deleteExpense(duplicateID); err != nil {
+		return models.Expense{}, err
+	}
+	for id, attachment := range m.attachments {
+		if attachment.ExpenseID == duplicateID {
+			attachment.ExpenseID = targetID
+			m.attachments[id] = attachment
+		}
+	}
+	return target, nil
+}
+
+// budgetDeltas mirrors models.adjustBudgetSpent: every budget of the
+// category, or of a category above it, whose period contains the expense
+// absorbs the converted amount, each split line in its own category, and
+// one whose currency no rate converts into is marked stale. The changes are
+// collected first so an error leaves nothing half applied.
+func (m *Memory) budgetDeltas(deltas map[int64]budgetDelta, expense models.Expense, sign int64) (map[int64]budgetDelta, error) {
+	if deltas == nil {
+		deltas = map[int64]budgetDelta{}
+	}
+	for _, allocation := range expense.Allocations() {
+		parents := m.parentCategories(allocation.CategoryID)
+		for id, budget := range m.budgets {
+			if !parents[budget.CategoryID] || expense.Date.Before(budget.StartDate) || expense.Date.After(budget.EndDate) {
+				continue
+			}
+			delta := deltas[id]
+			amount, err := m.convert(allocation.Amount, expense.Currency, budget.Currency, expense.Date)
+			switch {
+			case errors.Is(err, models.ErrNoExchangeRate):
+				delta.stale = true
+			case err != nil:
+				return nil, err
+			default:
+				delta.spent += amount.Mul(sign)
+			}
+			deltas[id] = delta
+		}
+	}
+	return deltas, nil
+}
+
+// budgetDelta is the change budgetDeltas works out for one budget.
+type budgetDelta struct {
+	spent models.Money
+	stale bool
+}
+
+func (m *Memory) applyBudgetDeltas(deltas map[int64]budgetDelta) {
+	for id, delta := range deltas {
+		budget := m.budgets[id]
+		budget.Spent += delta.spent
+		budget.Stale = budget.Stale || delta.stale
+		m.budgets[id] = budget
+	}
+}
+
+func (m *Memory) GetIncomes() ([]models.Income, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedValues(m.incomes), nil
+}
+
+func (m *Memory) ListIncomes(opts models.ListOptions) ([]models.Income, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return listValues(sortedValues(m.incomes), opts, models.IncomeSortFields, func(income models.Income) bool {
+		return matchesDates(income.Date, opts) && matchesAmount(income.Amount, opts) && matchesAccount(income.AccountID, opts) &&
+			matchesSearch(opts.Search, income.Source) && matchesTags(income.Tags, opts.Tags)
+	}, func(income models.Income, field string) any {
+		switch field {
+		case "date":
+			return income.Date
+		case "amount":
+			return income.Amount
+		case "currency":
+			return income.Currency
+		case "account_id":
+			return optionalID(income.AccountID)
+		case "source":
+			return income.Source
+		}
+		return income.ID
+	})
+}
+
+func (m *Memory) GetIncomeByID(id int64) (models.Income, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	income, ok := m.incomes[id]
+	if !ok {
+		return models.Income{}, models.NewNotFoundError("income")
+	}
+	return income, nil
+}
+
+func (m *Memory) CreateIncome(income models.Income) (models.Income, error) {
+	if err := models.ValidateIncome(income); err != nil {
+		return models.Income{}, err
+	}
+	tags, err := models.NormalizeTags(income.Tags)
+	if err != nil {
+		return models.Income{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	currency, err := m.entryCurrency(income.AccountID, income.Currency)
+	if err != nil {
+		return models.Income{}, err
+	}
+	income.Currency = currency
+	income.Tags = m.useTags(tags)
+	income.ID = m.newID()
+	m.incomes[income.ID] = income
+	return income, nil
+}
+
+func (m *Memory) UpdateIncome(income models.Income) (models.Income, error) {
+	tags, err := models.NormalizeTags(income.Tags)
+	if err != nil {
+		return models.Income{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.incomes[income.ID]
+	if !ok {
+		return models.Income{}, models.NewNotFoundError("income")
+	}
+	if income.Amount == 0 {
+		income.Amount = current.Amount
+	}
+	if income.Date.IsZero() {
+		income.Date = current.Date
+	}
+	if income.Source == "" {
+		income.Source = current.Source
+	}
+	income.RecurringRuleID = current.RecurringRuleID
+	if income.Currency == "" {
+		income.Currency = current.Currency
+	} else {
+		currency, err := models.NormalizeCurrency(income.Currency)
+		if err != nil {
+			return models.Income{}, err
+		}
+		income.Currency = currency
+	}
+	if income.AccountID == nil {
+		income.AccountID = current.AccountID
+	}
+	if _, err := m.entryCurrency(income.AccountID, income.Currency); err != nil {
+		return models.Income{}, err
+	}
+	if tags != nil {
+		income.Tags = m.useTags(tags)
+	} else {
+		income.Tags = current.Tags
+	}
+	m.incomes[income.ID] = income
+	return income, nil
+}
+
+func (m *Memory) DeleteIncome(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.incomes[id]; !ok {
+		return models.NewNotFoundError("income")
+	}
+	delete(m.incomes, id)
+	return nil
+}
+
+// accountBalance mirrors the balance column of models.accountColumns.
+func (m *Memory) accountBalance(account models.Account) models.Money {
+	balance := account.OpeningBalance
+	for _, movement := range m.accountMovements(account.ID) {
+		balance += movement.Inflow - movement.Outflow
+	}
+	return balance
+}
+
+// accountMovements totals the account's incomes, expenses and transfers
+// per day, in date order.
+func (m *Memory) accountMovements(id int64) []models.AccountMovement {
+	byDate := map[time.Time]models.AccountMovement{}
+	for _, income := range m.incomes {
+		if income.AccountID != nil && *income.AccountID == id {
+			movement := byDate[dateOnly(income.Date)]
+			movement.Inflow += income.Amount
+			byDate[dateOnly(income.Date)] = movement
+		}
+	}
+	for _, expense := range m.expenses {
+		if expense.AccountID != nil && *expense.AccountID == id {
+			movement := byDate[dateOnly(expense.Date)]
+			movement.Outflow += expense.Amount
+			byDate[dateOnly(expense.Date)] = movement
+		}
+	}
+	for _, transfer := range m.transfers {
+		date := dateOnly(transfer.Date)
+		if transfer.ToAccountID == id {
+			movement := byDate[date]
+			movement.Inflow += transfer.ToAmount
+			byDate[date] = movement
+		}
+		if transfer.FromAccountID == id {
+			movement := byDate[date]
+			movement.Outflow += transfer.Amount
+			byDate[date] = movement
+		}
+	}
+
+	var movements []models.AccountMovement
+	for _, date := range slices.SortedFunc(maps.Keys(byDate), time.Time.Compare) {
+		movement := byDate[date]
+		movement.Date = date
+		movements = append(movements, movement)
+	}
+	return movements
+}
+
+// accountInUse mirrors the foreign keys referencing accounts.
+func (m *Memory) accountInUse(id int64) bool {
+	for _, transfer := range m.transfers {
+		if transfer.FromAccountID == id || transfer.ToAccountID == id {
+			return true
+		}
+	}
+	for _, expense := range m.expenses {
+		if expense.AccountID != nil && *expense.AccountID == id {
+			return true
+		}
+	}
+	for _, income := range m.incomes {
+		if income.AccountID != nil && *income.AccountID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) accountNameTaken(name string, excludeID int64) bool {
+	for _, account := range m.accounts {
+		if account.Name == name && account.ID != excludeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) GetAccounts() ([]models.Account, error) {
+	accounts, _, err := m.ListAccounts(models.ListOptions{})
+	return accounts, err
+}
+
+func (m *Memory) ListAccounts(opts models.ListOptions) ([]models.Account, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accounts := sortedValues(m.accounts)
+	for i, account := range accounts {
+		accounts[i].Balance = m.accountBalance(account)
+	}
+	return listValues(accounts, opts, models.AccountSortFields, func(account models.Account) bool {
+		return matchesSearch(opts.Search, account.Name)
+	}, func(account models.Account, field string) any {
+		switch field {
+		case "name":
+			return account.Name
+		case "type":
+			return string(account.Type)
+		case "currency":
+			return account.Currency
+		case "opening_balance":
+			return account.OpeningBalance
+		case "balance":
+			return account.Balance
+		}
+		return account.ID
+	})
+}
+
+func (m *Memory) GetAccountByID(id int64) (models.Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[id]
+	if !ok {
+		return models.Account{}, models.NewNotFoundError("account")
+	}
+	account.Balance = m.accountBalance(account)
+	return account, nil
+}
+
+func (m *Memory) CreateAccount(account models.Account) (models.Account, error) {
+	if err := models.ValidateAccount(account); err != nil {
+		return models.Account{}, err
+	}
+	account.Currency, _ = models.NormalizeCurrency(account.Currency)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.accountNameTaken(account.Name, 0) {
+		return models.Account{}, models.NewConflictError("account %q already exists", account.Name)
+	}
+	account.ID = m.newID()
+	account.Balance = account.OpeningBalance
+	m.accounts[account.ID] = account
+	return account, nil
+}
+
+func (m *Memory) UpdateAccount(account models.Account) (models.Account, error) {
+	if err := models.ValidateAccount(account); err != nil {
+		return models.Account{}, err
+	}
+	account.Currency, _ = models.NormalizeCurrency(account.Currency)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.accounts[account.ID]
+	if !ok {
+		return models.Account{}, models.NewNotFoundError("account")
+	}
+	if account.Currency != current.Currency && m.accountInUse(account.ID) {
+		return models.Account{}, models.NewConflictError("cannot change the currency of an account with expenses, incomes or transfers")
+	}
+	if m.accountNameTaken(account.Name, account.ID) {
+		return models.Account{}, models.NewConflictError("account %q already exists", account.Name)
+	}
+	m.accounts[account.ID] = account
+	account.Balance = m.accountBalance(account)
+	return account, nil
+}
+
+func (m *Memory) DeleteAccount(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.accounts[id]; !ok {
+		return models.NewNotFoundError("account")
+	}
+	if m.accountInUse(id) {
+		return models.NewConflictError("cannot delete an account with expenses, incomes or transfers")
+	}
+	for profileID, profile := range m.profiles {
+		if profile.AccountID != nil && *profile.AccountID == id {
+			profile.AccountID = nil
+			m.profiles[profileID] = profile
+		}
+	}
+	for ruleID, rule := range m.categoryRules {
+		if rule.AccountID != nil && *rule.AccountID == id {
+			delete(m.categoryRules, ruleID)
+		}
+	}
+	delete(m.accounts, id)
+	return nil
+}
+
+func (m *Memory) GetAccountBalances(id int64, from, to time.Time, interval models.BalanceInterval) ([]models.AccountBalance, error) {
+	starts, err := models.BalancePeriods(from, to, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[id]
+	if !ok {
+		return nil, models.NewNotFoundError("account")
+	}
+	return models.BalanceHistory(account.OpeningBalance, m.accountMovements(id), starts, interval), nil
+}
+
+// resolveTransfer mirrors models.resolveTransfer.
+func (m *Memory) resolveTransfer(transfer models.Transfer) (models.Transfer, error) {
+	from, ok := m.accounts[transfer.FromAccountID]
+	if !ok {
+		return models.Transfer{}, models.NewValidationError("from_account_id", "account %d does not exist", transfer.FromAccountID)
+	}
+	to, ok := m.accounts[transfer.ToAccountID]
+	if !ok {
+		return models.Transfer{}, models.NewValidationError("to_account_id", "account %d does not exist", transfer.ToAccountID)
+	}
+	return models.MatchTransferCurrencies(transfer, from.Currency, to.Currency)
+}
+
+func (m *Memory) GetTransfers() ([]models.Transfer, error) {
+	transfers, _, err := m.ListTransfers(models.ListOptions{})
+	return transfers, err
+}
+
+func (m *Memory) ListTransfers(opts models.ListOptions) ([]models.Transfer, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return listValues(sortedValues(m.transfers), opts, models.TransferSortFields, func(transfer models.Transfer) bool {
+		return matchesDates(transfer.Date, opts) && matchesAmount(transfer.Amount, opts) &&
+			(opts.AccountID == 0 || transfer.FromAccountID == opts.AccountID || transfer.ToAccountID == opts.AccountID) &&
+			matchesSearch(opts.Search, transfer.Description)
+	}, func(transfer models.Transfer, field string) any {
+		switch field {
+		case "date":
+			return transfer.Date
+		case "amount":
+			return transfer.Amount
+		case "from_account_id":
+			return transfer.FromAccountID
+		case "to_account_id":
+			return transfer.ToAccountID
+		case "description":
+			return transfer.Description
+		}
+		return transfer.ID
+	})
+}
+
+func (m *Memory) GetTransferByID(id int64) (models.Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfer, ok := m.transfers[id]
+	if !ok {
+		return models.Transfer{}, models.NewNotFoundError("transfer")
+	}
+	return transfer, nil
+}
+
+func (m *Memory) CreateTransfer(transfer models.Transfer) (models.Transfer, error) {
+	if err := models.ValidateTransfer(transfer); err != nil {
+		return models.Transfer{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfer, err := m.resolveTransfer(transfer)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	transfer.ID = m.newID()
+	m.transfers[transfer.ID] = transfer
+	return transfer, nil
+}
+
+func (m *Memory) UpdateTransfer(transfer models.Transfer) (models.Transfer, error) {
+	if err := models.ValidateTransfer(transfer); err != nil {
+		return models.Transfer{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transfer, err := m.resolveTransfer(transfer)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	if _, ok := m.transfers[transfer.ID]; !ok {
+		return models.Transfer{}, models.NewNotFoundError("transfer")
+	}
+	m.transfers[transfer.ID] = transfer
+	return transfer, nil
+}
+
+func (m *Memory) DeleteTransfer(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.transfers[id]; !ok {
+		return models.NewNotFoundError("transfer")
+	}
+	delete(m.transfers, id)
+	return nil
+}
+
+// ListTransactions mirrors models.ListTransactions: the balance runs over
+// the matching entries of each currency in chronological order, before the
+// requested sort and page are applied.
+func (m *Memory) ListTransactions(opts models.ListOptions) ([]models.Transaction, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Transactions carry neither tags nor split lines, so those filters apply
+	// while the ledger is built, leaving transfers out when they are set
+	var ledger []models.Transaction
+	for _, expense := range sortedValues(m.expenses) {
+		if !matchesTags(expense.Tags, opts.Tags) || !matchesCategory(expense, opts) {
+			continue
+		}
+		transaction := models.Transaction{
+			Type: models.TransactionExpense, ID: expense.ID, Date: expense.Date, Description: expense.Description,
+			Amount: -expense.Amount, Currency: expense.Currency, AccountID: expense.AccountID, RecurringRuleID: expense.RecurringRuleID,
+		}
+		if len(expense.Splits) == 0 {
+			categoryID := expense.CategoryID
+			transaction.CategoryID, transaction.Category = &categoryID, m.categories[expense.CategoryID].Name
+		}
+		ledger = append(ledger, transaction)
+	}
+	for _, income := range sortedValues(m.incomes) {
+		if !matchesTags(income.Tags, opts.Tags) || opts.CategoryID != 0 {
+			continue
+		}
+		ledger = append(ledger, models.Transaction{
+			Type: models.TransactionIncome, ID: income.ID, Date: income.Date, Description: income.Source,
+			Amount: income.Amount, Currency: income.Currency, AccountID: income.AccountID, RecurringRuleID: income.RecurringRuleID,
+		})
+	}
+	for _, transfer := range sortedValues(m.transfers) {
+		if len(opts.Tags) > 0 || opts.CategoryID != 0 {
+			break
+		}
+		from, to := transfer.FromAccountID, transfer.ToAccountID
+		ledger = append(ledger, models.Transaction{
+			Type: models.TransactionTransfer, ID: transfer.ID, Date: transfer.Date, Description: transfer.Description,
+			Amount: -transfer.Amount, Currency: m.accounts[from].Currency, AccountID: &from, TransferAccountID: &to,
+		}, models.Transaction{
+			Type: models.TransactionTransfer, ID: transfer.ID, Date: transfer.Date, Description: transfer.Description,
+			Amount: transfer.ToAmount, Currency: m.accounts[to].Currency, AccountID: &to, TransferAccountID: &from,
+		})
+	}
+	slices.SortStableFunc(ledger, func(a, b models.Transaction) int {
+		return cmp.Or(dateOnly(a.Date).Compare(dateOnly(b.Date)), strings.Compare(string(a.Type), string(b.Type)))
+	})
+
+	var matched []models.Transaction
+	balances := map[string]models.Money{}
+	for _, transaction := range ledger {
+		amount := transaction.Amount
+		if amount < 0 {
+			amount = -amount
+		}
+		if !matchesDates(transaction.Date, opts) || !matchesAmount(amount, opts) || !matchesAccount(transaction.AccountID, opts) ||
+			!matchesSearch(opts.Search, transaction.Description, transaction.Category) {
+			continue
+		}
+		balances[transaction.Currency] += transaction.Amount
+		transaction.Balance = balances[transaction.Currency]
+		matched = append(matched, transaction)
+	}
+
+	return listValues(matched, opts, models.TransactionSortFields, func(models.Transaction) bool {
+		return true
+	}, func(transaction models.Transaction, field string) any {
+		switch field {
+		case "date":
+			return transaction.Date
+		case "type":
+			return string(transaction.Type)
+		case "amount":
+			return transaction.Amount
+		case "currency":
+			return transaction.Currency
+		case "category_id":
+			return optionalID(transaction.CategoryID)
+		case "account_id":
+			return optionalID(transaction.AccountID)
+		case "description":
+			return transaction.Description
+		}
+		return transaction.ID
+	})
+}
+
+func (m *Memory) GetCategories() ([]models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedValues(m.categories), nil
+}
+
+func (m *Memory) ListCategories(opts models.ListOptions) ([]models.Category, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return listValues(sortedValues(m.categories), opts, models.CategorySortFields, func(category models.Category) bool {
+		return matchesSearch(opts.Search, category.Name, category.Description)
+	}, func(category models.Category, field string) any {
+		switch field {
+		case "name":
+			return category.Name
+		case "description":
+			return category.Description
+		}
+		return category.ID
+	})
+}
+
+func (m *Memory) GetCategoryByID(id int64) (models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, ok := m.categories[id]
+	if !ok {
+		return models.Category{}, models.NewNotFoundError("category")
+	}
+	return category, nil
+}
+
+func (m *Memory) CreateCategory(category models.Category) (models.Category, error) {
+	if err := models.ValidateCategory(category); err != nil {
+		return models.Category{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCategoryParent(category); err != nil {
+		return models.Category{}, err
+	}
+	if m.categoryNameTaken(category.Name, 0) {
+		return models.Category{}, models.NewConflictError("category %q already exists", category.Name)
+	}
+	category.Children = nil
+	category.ID = m.newID()
+	m.categories[category.ID] = category
+	return category, nil
+}
+
+func (m *Memory) UpdateCategory(category models.Category) (models.Category, error) {
+	if category.ID == 0 {
+		return models.Category{}, models.NewValidationError("id", "id must be provided")
+	}
+	if category.ID == 1 {
+		return models.Category{}, models.NewForbiddenError("cannot change the 'Other' category")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.categories[category.ID]
+	if !ok {
+		return models.Category{}, models.NewNotFoundError("category")
+	}
+	if err := m.checkCategoryParent(category); err != nil {
+		return models.Category{}, err
+	}
+	if m.categoryNameTaken(category.Name, category.ID) {
+		return models.Category{}, models.NewConflictError("category %q already exists", category.Name)
+	}
+	category.Children = nil
+	m.categories[category.ID] = category
+
+	if !sameID(current.ParentID, category.ParentID) {
+		var moved []int64
+		for _, parentID := range []*int64{current.ParentID, category.ParentID} {
+			if parentID != nil {
+				moved = append(moved, *parentID)
+			}
+		}
+		if err := m.recalculateBudgets(moved...); err != nil {
+			m.categories[category.ID] = current
+			return models.Category{}, err
+		}
+	}
+	return category, nil
+}
+
+func (m *Memory) DeleteCategory(id int64) error {
+	// Prevent deletion of the "Other" category
+	if id == 1 {
+		return models.NewForbiddenError("cannot delete the 'Other' category")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category, ok := m.categories[id]
+	if !ok {
+		return models.NewNotFoundError("category")
+	}
+
+	categories, budgets, expenses := maps.Clone(m.categories), maps.Clone(m.budgets), maps.Clone(m.expenses)
+	rules, categoryRules, profiles := maps.Clone(m.rules), maps.Clone(m.categoryRules), maps.Clone(m.profiles)
+	if err := m.deleteCategory(category); err != nil {
+		m.categories, m.budgets, m.expenses = categories, budgets, expenses
+		m.rules, m.categoryRules, m.profiles = rules, categoryRules, profiles
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) deleteCategory(category models.Category) error {
+	id := category.ID
+	// Move the sub-categories and expenses up to the parent, or the expenses
+	// to the "Other" category, and drop the category's budgets
+	newCategoryID := int64(1)
+	if category.ParentID != nil {
+		newCategoryID = *category.ParentID
+	}
+	for childID, child := range m.categories {
+		if child.ParentID != nil && *child.ParentID == id {
+			child.ParentID = category.ParentID
+			m.categories[childID] = child
+		}
+	}
+	m.moveExpenses(id, newCategoryID)
+	for budgetID, budget := range m.budgets {
+		if budget.CategoryID == id {
+			delete(m.budgets, budgetID)
+		}
+	}
+	for ruleID, rule := range m.rules {
+		if rule.CategoryID == id {
+			rule.CategoryID = 1
+			m.rules[ruleID] = rule
+		}
+	}
+	for ruleID, rule := range m.categoryRules {
+		if rule.CategoryID == id {
+			delete(m.categoryRules, ruleID)
+		}
+	}
+	for profileID, profile := range m.profiles {
+		if profile.CategoryID == id {
+			profile.CategoryID = 1
+			m.profiles[profileID] = profile
+		}
+	}
+	delete(m.categories, id)
+
+	// The budgets of the category gaining the expenses count them
+	return m.recalculateBudgets(newCategoryID)
+}
+
+// MergeCategory mirrors models.MergeCategory, restoring the store as it was
+// if any step fails.
+func (m *Memory) MergeCategory(sourceID, targetID int64, strategy string) (models.Category, error) {
+	if err := models.ValidateMergeStrategy(strategy); err != nil {
+		return models.Category{}, err
+	}
+	if sourceID == targetID {
+		return models.Category{}, models.NewValidationError("target", "a category cannot be merged into itself")
+	}
+	if sourceID == 1 {
+		return models.Category{}, models.NewForbiddenError("cannot merge the 'Other' category")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, ok := m.categories[sourceID]
+	if !ok {
+		return models.Category{}, models.NewNotFoundError("category")
+	}
+	target, ok := m.categories[targetID]
+	if !ok {
+		return models.Category{}, models.NewNotFoundError("category")
+	}
+	if m.subcategories(sourceID)[targetID] {
+		return models.Category{}, models.NewValidationError("target", "a category cannot be merged into one of its own sub-categories")
+	}
+
+	categories, budgets, expenses := maps.Clone(m.categories), maps.Clone(m.budgets), maps.Clone(m.expenses)
+	rules, categoryRules, profiles := maps.Clone(m.rules), maps.Clone(m.categoryRules), maps.Clone(m.profiles)
+	err := m.mergeCategory(source, targetID, strategy)
+	if err != nil {
+		m.categories, m.budgets, m.expenses = categories, budgets, expenses
+		m.rules, m.categoryRules, m.profiles = rules, categoryRules, profiles
+		return models.Category{}, err
+	}
+	return target, nil
+}
+
+func (m *Memory) mergeCategory(source models.Category, targetID int64, strategy string) error {
+	for childID, child := range m.categories {
+		if child.ParentID != nil && *child.ParentID == source.ID {
+			child.ParentID = &targetID
+			m.categories[childID] = child
+		}
+	}
+	if err := m.mergeBudgets(source.ID, targetID, strategy); err != nil {
+		return err
+	}
+	m.moveExpenses(source.ID, targetID)
+	for ruleID, rule := range m.categoryRules {
+		if rule.CategoryID == source.ID {
+			rule.CategoryID = targetID
+			m.categoryRules[ruleID] = rule
+		}
+	}
+	for ruleID, rule := range m.rules {
+		if rule.CategoryID == source.ID {
+			rule.CategoryID = targetID
+			m.rules[ruleID] = rule
+		}
+	}
+	for profileID, profile := range m.profiles {
+		if profile.CategoryID == source.ID {
+			profile.CategoryID = targetID
+			m.profiles[profileID] = profile
+		}
+	}
+	delete(m.categories, source.ID)
+
+	recalculate := []int64{targetID}
+	if source.ParentID != nil {
+		recalculate = append(recalculate, *source.ParentID)
+	}
+	return m.recalculateBudgets(recalculate...)
+}
+
+// mergeBudgets mirrors models.mergeBudgets.
+func (m *Memory) mergeBudgets(sourceID, targetID int64, strategy string) error {
+	byStart := func(a, b models.Budget) int {
+		return cmp.Or(a.StartDate.Compare(b.StartDate), cmp.Compare(a.ID, b.ID))
+	}
+	family := m.subcategories(targetID)
+	maps.Copy(family, m.parentCategories(targetID))
+	var sources, targets []models.Budget
+	for _, budget := range m.budgets {
+		if budget.CategoryID == sourceID {
+			sources = append(sources, budget)
+		} else if family[budget.CategoryID] {
+			targets = append(targets, budget)
+		}
+	}
+	slices.SortFunc(sources, byStart)
+	slices.SortFunc(targets, byStart)
+
+	for _, budget := range sources {
+		i := slices.IndexFunc(targets, func(target models.Budget) bool {
+			return !target.StartDate.After(budget.EndDate) && !target.EndDate.Before(budget.StartDate)
+		})
+		if i < 0 {
+			budget.CategoryID = targetID
+			m.budgets[budget.ID] = budget
+			continue
+		}
+
+		switch strategy {
+		case models.MergeBudgetsFail:
+			return models.NewConflictError("budget %d overlaps budget %d of the target category", budget.ID, targets[i].ID)
+		case models.MergeBudgetsSum:
+			amount, err := m.convert(budget.Amount, budget.Currency, targets[i].Currency, targets[i].StartDate)
+			if err != nil {
+				return err
+			}
+			targets[i].Amount += amount
+			m.budgets[targets[i].ID] = targets[i]
+		}
+		delete(m.budgets, budget.ID)
+	}
+	return nil
+}
+
+// checkCategoryParent mirrors the SQL store's check that a category's parent
+// exists and is not the category or one of its sub-categories.
+func (m *Memory) checkCategoryParent(category models.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	if *category.ParentID == category.ID {
+		return models.NewValidationError("parent_id", "a category cannot be its own parent")
+	}
+	if _, ok := m.categories[*category.ParentID]; !ok {
+		return models.NewValidationError("parent_id", "parent category %d does not exist", *category.ParentID)
+	}
+	if category.ID != 0 && m.subcategories(category.ID)[*category.ParentID] {
+		return models.NewValidationError("parent_id", "a category cannot be moved under one of its own sub-categories")
+	}
+	return nil
+}
+
+// subcategories returns the ids of a category and every category below it.
+func (m *Memory) subcategories(id int64) map[int64]bool {
+	ids := map[int64]bool{id: true}
+	for grew := true; grew; {
+		grew = false
+		for _, category := range m.categories {
+			if category.ParentID != nil && ids[*category.ParentID] && !ids[category.ID] {
+				ids[category.ID] = true
+				grew = true
+			}
+		}
+	}
+	return ids
+}
+
+// parentCategories returns the ids of a category and every category above it.
+func (m *Memory) parentCategories(id int64) map[int64]bool {
+	ids := map[int64]bool{}
+	for current := &id; current != nil && !ids[*current]; {
+		ids[*current] = true
+		current = m.categories[*current].ParentID
+	}
+	return ids
+}
+
+// recalculateBudgets mirrors models.recalculateBudgets for each of the
+// categories, changing nothing if a spent amount cannot be worked out.
+func (m *Memory) recalculateBudgets(categoryIDs ...int64) error {
+	parents := map[int64]bool{}
+	for _, categoryID := range categoryIDs {
+		maps.Copy(parents, m.parentCategories(categoryID))
+	}
+	return m.recalculateBudgetsWhere(func(budget models.Budget) bool { return parents[budget.CategoryID] })
+}
+
+// recalculateBudgetsWhere recomputes the spent amount of the budgets that
+// match, marking those missing a rate stale as models.recalculateBudget does.
+func (m *Memory) recalculateBudgetsWhere(match func(budget models.Budget) bool) error {
+	recalculated := map[int64]models.Budget{}
+	for id, budget := range m.budgets {
+		if !match(budget) {
+			continue
+		}
+		total, err := m.totalSpent(budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
+		budget.Stale = errors.Is(err, models.ErrNoExchangeRate)
+		if err != nil && !budget.Stale {
+			return err
+		}
+		budget.Spent = total
+		recalculated[id] = budget
+	}
+	maps.Copy(m.budgets, recalculated)
+	return nil
+}
+
+func sameID(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func (m *Memory) categoryNameTaken(name string, excludeID int64) bool {
+	for _, category := range m.categories {
+		if category.Name == name && category.ID != excludeID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) GetBudgets() ([]models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedValues(m.budgets), nil
+}
+
+func (m *Memory) ListBudgets(opts models.ListOptions) ([]models.Budget, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return listValues(sortedValues(m.budgets), opts, models.BudgetSortFields, func(budget models.Budget) bool {
+		// A budget matches when its period overlaps the requested range
+		return (opts.From.IsZero() || !dateOnly(budget.EndDate).Before(opts.From)) && (opts.To.IsZero() || !dateOnly(budget.StartDate).After(opts.To)) &&
+			(opts.CategoryID == 0 || budget.CategoryID == opts.CategoryID) && matchesAmount(budget.Amount, opts)
+	}, func(budget models.Budget, field string) any {
+		switch field {
+		case "category_id":
+			return budget.CategoryID
+		case "amount":
+			return budget.Amount
+		case "spent":
+			return budget.Spent
+		case "currency":
+			return budget.Currency
+		case "start_date":
+			return budget.StartDate
+		case "end_date":
+			return budget.EndDate
+		}
+		return budget.ID
+	})
+}
+
+func (m *Memory) GetBudgetByID(id int64) (models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	budget, ok := m.budgets[id]
+	if !ok {
+		return models.Budget{}, models.NewNotFoundError("budget")
+	}
+	return budget, nil
+}
+
+func (m *Memory) GetBudgetsByCategoryID(categoryID int64) ([]models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.budgetsForCategory(categoryID), nil
+}
+
+func (m *Memory) GetBudgetsByCategoryName(categoryName string) ([]models.Budget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, category := range m.categories {
+		if category.Name == categoryName {
+			return m.budgetsForCategory(category.ID), nil
+		}
+	}
+	return nil, models.NewNotFoundError("category " + categoryName)
+}
+
+func (m *Memory) budgetsForCategory(categoryID int64) []models.Budget {
+	var budgets []models.Budget
+	for _, budget := range sortedValues(m.budgets) {
+		if budget.CategoryID == categoryID {
+			budgets = append(budgets, budget)
+		}
+	}
+	return budgets
+}
+
+func (m *Memory) CreateBudget(budget models.Budget) (models.Budget, error) {
+	if budget.CategoryID == 0 {
+		return models.Budget{}, models.NewValidationError("category_id", "category cannot be empty")
+	}
+	if err := models.ValidateBudget(budget); err != nil {
+		return models.Budget{}, err
+	}
+
+	budget.Currency, _ = models.NormalizeCurrency(budget.Currency)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCategory(budget.CategoryID); err != nil {
+		return models.Budget{}, err
+	}
+	if m.budgetOverlaps(budget.CategoryID, budget.StartDate, budget.EndDate, 0) {
+		return models.Budget{}, models.NewConflictError("budget dates overlap with an existing budget")
+	}
+	spent, err := m.totalSpent(budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
+	if err != nil {
+		return models.Budget{}, err
+	}
+	budget.Spent = spent
+	budget.Stale = false
+	budget.ID = m.newID()
+	m.budgets[budget.ID] = budget
+	return budget, nil
+}
+
+func (m *Memory) UpdateBudget(budget models.Budget) (models.Budget, error) {
+	if budget.CategoryID == 0 {
+		return models.Budget{}, models.NewValidationError("category_id", "category id must be provided")
+	}
+	if err := models.ValidateBudget(budget); err != nil {
+		return models.Budget{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.budgets[budget.ID]
+	if !ok {
+		return models.Budget{}, models.NewNotFoundError("budget")
+	}
+	if budget.Currency == "" {
+		budget.Currency = current.Currency
+	} else {
+		budget.Currency, _ = models.NormalizeCurrency(budget.Currency)
+	}
+	if err := m.checkCategory(budget.CategoryID); err != nil {
+		return models.Budget{}, err
+	}
+	if m.budgetOverlaps(budget.CategoryID, budget.StartDate, budget.EndDate, budget.ID) {
+		return models.Budget{}, models.NewConflictError("budget dates overlap with an existing budget")
+	}
+	spent, err := m.totalSpent(budget.CategoryID, budget.Currency, budget.StartDate, budget.EndDate)
+	if err != nil {
+		return models.Budget{}, err
+	}
+	budget.Spent = spent
+	budget.Stale = false
+	m.budgets[budget.ID] = budget
+	return budget, nil
+}
+
+func (m *Memory) DeleteBudget(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.budgets[id]; !ok {
+		return models.NewNotFoundError("budget")
+	}
+	delete(m.budgets, id)
+	return nil
+}
+
+// budgetOverlaps mirrors models.DoesBudgetOverlap.
+func (m *Memory) budgetOverlaps(categoryID int64, startDate, endDate time.Time, excludeBudgetID int64) bool {
+	subcategories, parents := m.subcategories(categoryID), m.parentCategories(categoryID)
+	for _, budget := range m.budgets {
+		if (subcategories[budget.CategoryID] || parents[budget.CategoryID]) && budget.ID != excludeBudgetID &&
+			!budget.StartDate.After(endDate) && !budget.EndDate.Before(startDate) {
+			return true
+		}
+	}
+	return false
+}
+
+// totalSpent mirrors models.CalculateTotalSpent, returning the total of what
+// it could convert along with the first missing rate error.
+func (m *Memory) totalSpent(categoryID int64, currency string, startDate, endDate time.Time) (models.Money, error) {
+	var total models.Money
+	var missing error
+	subcategories := m.subcategories(categoryID)
+	for _, expense := range m.expenses {
+		if expense.Date.Before(startDate) || expense.Date.After(endDate) {
+			continue
+		}
+		for _, allocation := range expense.Allocations() {
+			if subcategories[allocation.CategoryID] {
+				amount, err := m.convert(allocation.Amount, expense.Currency, currency, expense.Date)
+				if errors.Is(err, models.ErrNoExchangeRate) {
+					if missing == nil {
+						missing = err
+					}
+					continue
+				}
+				if err != nil {
+					return 0, err
+				}
+				total += amount
+			}
+		}
+	}
+	return total, missing
+}
+
+// rate looks up an exchange rate with the same rules as the SQL store.
+func (m *Memory) rate(from, to string, on time.Time) (*big.Rat, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+	return models.FindExchangeRate(m.shared.rates, from, to, on)
+}
+
+func (m *Memory) convert(amount models.Money, from, to string, on time.Time) (models.Money, error) {
+	if from == to {
+		return amount, nil
+	}
+	rate, err := m.rate(from, to, on)
+	if err != nil {
+		return 0, err
+	}
+	return amount.MulRat(rate), nil
+}
+
+func (m *Memory) GetExchangeRates(baseCurrency, quoteCurrency string) ([]models.ExchangeRate, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	var rates []models.ExchangeRate
+	for _, rate := range m.shared.rates {
+		if (baseCurrency == "" || rate.BaseCurrency == baseCurrency) && (quoteCurrency == "" || rate.QuoteCurrency == quoteCurrency) {
+			rates = append(rates, rate)
+		}
+	}
+	slices.SortFunc(rates, func(a, b models.ExchangeRate) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.BaseCurrency, b.BaseCurrency); c != 0 {
+			return c
+		}
+		return strings.Compare(a.QuoteCurrency, b.QuoteCurrency)
+	})
+	return rates, nil
+}
+
+func (m *Memory) ImportExchangeRates(rates []models.ExchangeRate) (int, error) {
+	validated := make([]models.ExchangeRate, 0, len(rates))
+	for _, rate := range rates {
+		rate, err := models.ValidateExchangeRate(rate)
+		if err != nil {
+			return 0, err
+		}
+		validated = append(validated, rate)
+	}
+
+	m.shared.mu.Lock()
+	// Replace the rate for the same day and currency pair, as the SQL upsert does
+	for _, rate := range validated {
+		i := slices.IndexFunc(m.shared.rates, func(existing models.ExchangeRate) bool {
+			return existing.Date.Equal(rate.Date) && existing.BaseCurrency == rate.BaseCurrency && existing.QuoteCurrency == rate.QuoteCurrency
+		})
+		if i >= 0 {
+			m.shared.rates[i].Rate = rate.Rate
+			continue
+		}
+		m.shared.nextID++
+		rate.ID = m.shared.nextID
+		m.shared.rates = append(m.shared.rates, rate)
+	}
+	memories := slices.Clone(m.shared.memories)
+	m.shared.mu.Unlock()
+
+	// Recalculate the stale budgets of every ledger, as the SQL import does.
+	// Each ledger's lock is taken after the shared one is released.
+	for _, mem := range memories {
+		mem.mu.Lock()
+		err := mem.recalculateBudgetsWhere(func(budget models.Budget) bool { return budget.Stale })
+		mem.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(validated), nil
+}
+
+func (m *Memory) ConvertAmount(amount models.Money, from, to string, on time.Time) (models.Conversion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rate, err := m.rate(from, to, on)
+	if err != nil {
+		return models.Conversion{}, err
+	}
+	return models.Convert(amount, to, rate), nil
+}
+
+func (m *Memory) GetCategoryRules() ([]models.CategoryRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedCategoryRules(), nil
+}
+
+// sortedCategoryRules returns the category rules in the order they are tried.
+func (m *Memory) sortedCategoryRules() []models.CategoryRule {
+	rules := sortedValues(m.categoryRules)
+	slices.SortStableFunc(rules, func(a, b models.CategoryRule) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+	return rules
+}
+
+func (m *Memory) GetCategoryRuleByID(id int64) (models.CategoryRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.categoryRules[id]
+	if !ok {
+		return models.CategoryRule{}, models.NewNotFoundError("category rule")
+	}
+	return rule, nil
+}
+
+// checkCategoryRule mirrors the checks shared by creating and updating a rule.
+func (m *Memory) checkCategoryRule(rule models.CategoryRule) (models.CategoryRule, error) {
+	rule, err := models.NormalizeCategoryRule(rule)
+	if err != nil {
+		return models.CategoryRule{}, err
+	}
+	if err := m.checkCategory(rule.CategoryID); err != nil {
+		return models.CategoryRule{}, err
+	}
+	if rule.AccountID != nil {
+		if _, ok := m.accounts[*rule.AccountID]; !ok {
+			return models.CategoryRule{}, models.NewValidationError("account_id", "account %d does not exist", *rule.AccountID)
+		}
+	}
+	return rule, nil
+}
+
+func (m *Memory) CreateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.checkCategoryRule(rule)
+	if err != nil {
+		return models.CategoryRule{}, err
+	}
+	rule.ID = m.newID()
+	m.categoryRules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *Memory) UpdateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.checkCategoryRule(rule)
+	if err != nil {
+		return models.CategoryRule{}, err
+	}
+	if _, ok := m.categoryRules[rule.ID]; !ok {
+		return models.CategoryRule{}, models.NewNotFoundError("category rule")
+	}
+	m.categoryRules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *Memory) DeleteCategoryRule(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.categoryRules[id]; !ok {
+		return models.NewNotFoundError("category rule")
+	}
+	delete(m.categoryRules, id)
+	return nil
+}
+
+func (m *Memory) TestCategoryRule(rule models.CategoryRule) ([]models.Expense, error) {
+	rule, err := models.ValidateCategoryRuleConditions(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []models.Expense
+	for _, expense := range sortedValues(m.expenses) {
+		if rule.Matches(expense) {
+			matches = append(matches, expense)
+		}
+	}
+	return matches, nil
+}
+
+func (m *Memory) ApplyCategoryRules() ([]models.Expense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := m.sortedCategoryRules()
+	// Collect the budget changes first so a missing rate moves nothing
+	var moved []models.Expense
+	deltas := map[int64]budgetDelta{}
+	for _, expense := range sortedValues(m.expenses) {
+		if expense.CategoryID != 1 || len(expense.Splits) > 0 {
+			continue
+		}
+		categoryID := models.Categorize(rules, expense, 1)
+		if categoryID == 1 {
+			continue
+		}
+		var err error
+		if deltas, err = m.budgetDeltas(deltas, expense, -1); err != nil {
+			return nil, err
+		}
+		expense.CategoryID = categoryID
+		if deltas, err = m.budgetDeltas(deltas, expense, 1); err != nil {
+			return nil, err
+		}
+		moved = append(moved, expense)
+	}
+	for _, expense := range moved {
+		m.expenses[expense.ID] = expense
+	}
+	m.applyBudgetDeltas(deltas)
+	return moved, nil
+}
+
+func (m *Memory) GetImportProfiles() ([]models.ImportProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profiles := sortedValues(m.profiles)
+	slices.SortStableFunc(profiles, func(a, b models.ImportProfile) int { return cmp.Compare(a.Name, b.Name) })
+	return profiles, nil
+}
+
+func (m *Memory) GetImportProfileByID(id int64) (models.ImportProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profile, ok := m.profiles[id]
+	if !ok {
+		return models.ImportProfile{}, models.NewNotFoundError("import profile")
+	}
+	return profile, nil
+}
+
+func (m *Memory) GetImportProfileByName(name string) (models.ImportProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, profile := range m.profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return models.ImportProfile{}, models.NewNotFoundError("import profile")
+}
+
+// checkImportProfile mirrors the ImportProfile unique name and foreign keys.
+func (m *Memory) checkImportProfile(profile models.ImportProfile) error {
+	for id, other := range m.profiles {
+		if id != profile.ID && other.Name == profile.Name {
+			return models.NewConflictError("import profile %q already exists", profile.Name)
+		}
+	}
+	if err := m.checkCategory(profile.CategoryID); err != nil {
+		return err
+	}
+	if profile.AccountID != nil {
+		_, err := m.entryCurrency(profile.AccountID, profile.Currency)
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) CreateImportProfile(profile models.ImportProfile) (models.ImportProfile, error) {
+	profile, err := models.NormalizeImportProfile(profile)
+	if err != nil {
+		return models.ImportProfile{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	profile.ID = 0
+	if err := m.checkImportProfile(profile); err != nil {
+		return models.ImportProfile{}, err
+	}
+	profile.ID = m.newID()
+	m.profiles[profile.ID] = profile
+	return profile, nil
+}
+
+func (m *Memory) UpdateImportProfile(profile models.ImportProfile) (models.ImportProfile, error) {
+	profile, err := models.NormalizeImportProfile(profile)
+	if err != nil {
+		return models.ImportProfile{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.profiles[profile.ID]; !ok {
+		return models.ImportProfile{}, models.NewNotFoundError("import profile")
+	}
+	if err := m.checkImportProfile(profile); err != nil {
+		return models.ImportProfile{}, err
+	}
+	m.profiles[profile.ID] = profile
+	return profile, nil
+}
+
+func (m *Memory) DeleteImportProfile(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.profiles[id]; !ok {
+		return models.NewNotFoundError("import profile")
+	}
+	delete(m.profiles, id)
+	return nil
+}
+
+func (m *Memory) ImportStatement(statement models.Statement, dryRun bool) (models.StatementImport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCategory(statement.CategoryID); err != nil {
+		return models.StatementImport{}, err
+	}
+	rows := make([]models.StatementRow, len(statement.Rows))
+	for i, row := range statement.Rows {
+		row.Date = dateOnly(row.Date)
+		rows[i] = row
+	}
+	statement.Rows = rows
+	statement.Rules = m.sortedCategoryRules()
+	report, err := models.ReviewStatement(statement, dryRun, m.entryCurrency, func(externalID string) (bool, error) {
+		return m.imported[externalID], nil
+	})
+	if err != nil || dryRun {
+		return report, err
+	}
+
+	// Collect the budget changes first so a missing rate imports nothing
+	deltas := map[int64]budgetDelta{}
+	for _, row := range report.Rows {
+		if row.Type == models.TransactionExpense && !row.Duplicate {
+			if deltas, err = m.budgetDeltas(deltas, statement.Expense(row), 1); err != nil {
+				return models.StatementImport{}, fmt.Errorf("line %d: %w", row.Line, err)
+			}
+		}
+	}
+	for _, row := range report.Rows {
+		if row.Duplicate {
+			continue
+		}
+		if row.ExternalID != "" {
+			m.imported[row.ExternalID] = true
+		}
+		if row.Type == models.TransactionIncome {
+			income := statement.Income(row)
+			income.ID = m.newID()
+			m.incomes[income.ID] = income
+			continue
+		}
+		expense := statement.Expense(row)
+		expense.ID = m.newID()
+		m.expenses[expense.ID] = expense
+	}
+	m.applyBudgetDeltas(deltas)
+	return report, nil
+}
+
+func (m *Memory) GetSummary(currency string, from, to time.Time) (models.Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inPeriod := func(date time.Time) bool {
+		return (from.IsZero() || !date.Before(from)) && (to.IsZero() || !date.After(to))
+	}
+	var expenses []models.Expense
+	for _, expense := range sortedValues(m.expenses) {
+		if inPeriod(expense.Date) {
+			expenses = append(expenses, expense)
+		}
+	}
+	var incomes []models.Income
+	for _, income := range sortedValues(m.incomes) {
+		if inPeriod(income.Date) {
+			incomes = append(incomes, income)
+		}
+	}
+
+	summary, err := models.BuildSummary(currency, expenses, incomes, m.rate)
+	if err != nil {
+		return models.Summary{}, err
+	}
+	summary.SetPeriod(from, to)
+	return summary, nil
+}
+
+func (m *Memory) GetRecurringRules() ([]models.RecurringRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedValues(m.rules), nil
+}
+
+func (m *Memory) GetRecurringRuleByID(id int64) (models.RecurringRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.rules[id]
+	if !ok {
+		return models.RecurringRule{}, models.NewNotFoundError("recurring rule")
+	}
+	return rule, nil
+}
+
+func (m *Memory) CreateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error) {
+	rule, err := models.ValidateRecurringRule(rule)
+	if err != nil {
+		return models.RecurringRule{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if rule.Kind == models.RecurringExpense {
+		if err := m.checkCategory(rule.CategoryID); err != nil {
+			return models.RecurringRule{}, err
+		}
+	}
+	rule.NextDate = rule.NextOnOrAfter(rule.StartDate)
+	rule.ID = m.newID()
+	m.rules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *Memory) UpdateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error) {
+	rule, err := models.ValidateRecurringRule(rule)
+	if err != nil {
+		return models.RecurringRule{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.rules[rule.ID]
+	if !ok {
+		return models.RecurringRule{}, models.NewNotFoundError("recurring rule")
+	}
+	if current.Kind != rule.Kind {
+		return models.RecurringRule{}, models.NewValidationError("kind", "kind of a recurring rule cannot be changed")
+	}
+	if rule.Kind == models.RecurringExpense {
+		if err := m.checkCategory(rule.CategoryID); err != nil {
+			return models.RecurringRule{}, err
+		}
+	}
+
+	// Resume after the latest generated entry, as models.UpdateRecurringRule does
+	from := rule.StartDate
+	if last := m.lastGeneratedDate(rule); last != nil && !last.Before(from) {
+		from = last.AddDate(0, 0, 1)
+	}
+	rule.NextDate = rule.NextOnOrAfter(from)
+	m.rules[rule.ID] = rule
+	return rule, nil
+}
+
+func (m *Memory) lastGeneratedDate(rule models.RecurringRule) *time.Time {
+	var last *time.Time
+	consider := func(ruleID *int64, date time.Time) {
+		if ruleID != nil && *ruleID == rule.ID && (last == nil || date.After(*last)) {
+			last = &date
+		}
+	}
+	if rule.Kind == models.RecurringIncome {
+		for _, income := range m.incomes {
+			consider(income.RecurringRuleID, income.Date)
+		}
+	} else {
+		for _, expense := range m.expenses {
+			consider(expense.RecurringRuleID, expense.Date)
+		}
+	}
+	return last
+}
+
+func (m *Memory) DeleteRecurringRule(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rules[id]; !ok {
+		return models.NewNotFoundError("recurring rule")
+	}
+	delete(m.rules, id)
+
+	// Generated entries are kept but lose their link, like ON DELETE SET NULL
+	for expenseID, expense := range m.expenses {
+		if expense.RecurringRuleID != nil && *expense.RecurringRuleID == id {
+			expense.RecurringRuleID = nil
+			m.expenses[expenseID] = expense
+		}
+	}
+	for incomeID, income := range m.incomes {
+		if income.RecurringRuleID != nil && *income.RecurringRuleID == id {
+			income.RecurringRuleID = nil
+			m.incomes[incomeID] = income
+		}
+	}
+	return nil
+}
+
+// MaterializeRecurringRules mirrors models.MaterializeRecurringRules. A rule
+// whose entries cannot all be generated is skipped as a whole.
+func (m *Memory) MaterializeRecurringRules(asOf time.Time) (int, error) {
+	asOf = dateOnly(asOf)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := 0
+	var errs []error
+	for _, rule := range sortedValues(m.rules) {
+		if rule.NextDate == nil || rule.NextDate.After(asOf) {
+			continue
+		}
+
+		var expenses []models.Expense
+		var incomes []models.Income
+		deltas := map[int64]budgetDelta{}
+		var err error
+		for _, date := range rule.Occurrences(*rule.NextDate, asOf, 0) {
+			if m.occurrenceExists(rule, date) {
+				continue
+			}
+			if rule.Kind == models.RecurringIncome {
+				incomes = append(incomes, rule.Income(date))
+				continue
+			}
+			expense := rule.Expense(date)
+			if deltas, err = m.budgetDeltas(deltas, expense, 1); err != nil {
+				break
+			}
+			expenses = append(expenses, expense)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("recurring rule %d: %w", rule.ID, err))
+			continue
+		}
+
+		for _, expense := range expenses {
+			expense.ID = m.newID()
+			m.expenses[expense.ID] = expense
+		}
+		for _, income := range incomes {
+			income.ID = m.newID()
+			m.incomes[income.ID] = income
+		}
+		m.applyBudgetDeltas(deltas)
+		created += len(expenses) + len(incomes)
+
+		rule.NextDate = rule.NextOnOrAfter(asOf.AddDate(0, 0, 1))
+		m.rules[rule.ID] = rule
+	}
+	return created, errors.Join(errs...)
+}
+
+// occurrenceExists mirrors the unique (recurring_rule_id, date) indexes.
+func (m *Memory) occurrenceExists(rule models.RecurringRule, date time.Time) bool {
+	if rule.Kind == models.RecurringIncome {
+		for _, income := range m.incomes {
+			if income.RecurringRuleID != nil && *income.RecurringRuleID == rule.ID && income.Date.Equal(date) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, expense := range m.expenses {
+		if expense.RecurringRuleID != nil && *expense.RecurringRuleID == rule.ID && expense.Date.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// useTags creates the tags among names that do not exist yet, mirroring
+// how the SQL store links them. An empty list is stored as nil.
+func (m *Memory) useTags(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	for _, name := range names {
+		if _, ok := m.tagByName(name); !ok {
+			id := m.newID()
+			m.tags[id] = models.Tag{ID: id, Name: name}
+		}
+	}
+	return names
+}
+
+func (m *Memory) tagByName(name string) (models.Tag, bool) {
+	for _, tag := range m.tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return models.Tag{}, false
+}
+
+// retag replaces a tag on every expense and income carrying it, dropping
+// it when to is empty.
+func (m *Memory) retag(from, to string) {
+	rename := func(tags []string) ([]string, bool) {
+		if !slices.Contains(tags, from) {
+			return tags, false
+		}
+		renamed := []string{}
+		for _, tag := range tags {
+			if tag != from {
+				renamed = append(renamed, tag)
+			}
+		}
+		if to != "" {
+			renamed = append(renamed, to)
+		}
+		slices.Sort(renamed)
+		if len(renamed) == 0 {
+			return nil, true
+		}
+		return slices.Compact(renamed), true
+	}
+	for id, expense := range m.expenses {
+		if tags, ok := rename(expense.Tags); ok {
+			expense.Tags = tags
+			m.expenses[id] = expense
+		}
+	}
+	for id, income := range m.incomes {
+		if tags, ok := rename(income.Tags); ok {
+			income.Tags = tags
+			m.incomes[id] = income
+		}
+	}
+}
+
+func (m *Memory) GetTags() ([]models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tags := sortedValues(m.tags)
+	slices.SortFunc(tags, func(a, b models.Tag) int { return cmp.Compare(a.Name, b.Name) })
+	return tags, nil
+}
+
+func (m *Memory) GetTagByID(id int64) (models.Tag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return models.Tag{}, models.NewNotFoundError("tag")
+	}
+	return tag, nil
+}
+
+func (m *Memory) CreateTag(tag models.Tag) (models.Tag, error) {
+	name, err := models.NormalizeTagName(tag.Name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tagByName(name); ok {
+		return models.Tag{}, models.NewConflictError("tag %q already exists", name)
+	}
+	tag = models.Tag{ID: m.newID(), Name: name}
+	m.tags[tag.ID] = tag
+	return tag, nil
+}
+
+func (m *Memory) UpdateTag(tag models.Tag) (models.Tag, error) {
+	name, err := models.NormalizeTagName(tag.Name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.tags[tag.ID]
+	if !ok {
+		return models.Tag{}, models.NewNotFoundError("tag")
+	}
+	if other, ok := m.tagByName(name); ok && other.ID != tag.ID {
+		return models.Tag{}, models.NewConflictError("tag %q already exists", name)
+	}
+	tag.Name = name
+	m.retag(current.Name, tag.Name)
+	m.tags[tag.ID] = tag
+	return tag, nil
+}
+
+func (m *Memory) DeleteTag(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tag, ok := m.tags[id]
+	if !ok {
+		return models.NewNotFoundError("tag")
+	}
+	m.retag(tag.Name, "")
+	delete(m.tags, id)
+	return nil
+}
+
+func (m *Memory) GetTagSummary(currency string, from, to time.Time) ([]models.TagTotal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inPeriod := func(date time.Time) bool {
+		return (from.IsZero() || !date.Before(from)) && (to.IsZero() || !date.After(to))
+	}
+	var expenses []models.Expense
+	for _, expense := range sortedValues(m.expenses) {
+		if len(expense.Tags) > 0 && inPeriod(expense.Date) {
+			expenses = append(expenses, expense)
+		}
+	}
+	var incomes []models.Income
+	for _, income := range sortedValues(m.incomes) {
+		if len(income.Tags) > 0 && inPeriod(income.Date) {
+			incomes = append(incomes, income)
+		}
+	}
+
+	tags := sortedValues(m.tags)
+	return models.BuildTagSummary(currency, tags, expenses, incomes, m.rate)
+}
+
+func (m *Memory) GetAttachments(expenseID int64) ([]models.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.expenses[expenseID]; !ok {
+		return nil, models.NewNotFoundError("expense")
+	}
+	var attachments []models.Attachment
+	for _, attachment := range sortedValues(m.attachments) {
+		if attachment.ExpenseID == expenseID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (m *Memory) GetAttachmentByID(expenseID, id int64) (models.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attachment, ok := m.attachments[id]
+	if !ok || attachment.ExpenseID != expenseID {
+		return models.Attachment{}, models.NewNotFoundError("attachment")
+	}
+	return attachment, nil
+}
+
+// CreateAttachment stores the content before taking the lock, so a slow
+// upload does not hold up other requests.
+func (m *Memory) CreateAttachment(attachment models.Attachment, content io.Reader) (models.Attachment, error) {
+	return saveAttachment(m.blobs, attachment, content, func(attachment models.Attachment) (models.Attachment, error) {
+		attachment, err := models.NormalizeAttachment(attachment)
+		if err != nil {
+			return models.Attachment{}, err
+		}
+		if attachment.UploadedAt.IsZero() {
+			attachment.UploadedAt = time.Now().UTC().Truncate(time.Second)
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		if _, ok := m.expenses[attachment.ExpenseID]; !ok {
+			return models.Attachment{}, models.NewNotFoundError("expense")
+		}
+		attachment.ID = m.newID()
+		m.attachments[attachment.ID] = attachment
+		return attachment, nil
+	})
+}
+
+func (m *Memory) OpenAttachment(expenseID, id int64) (models.Attachment, io.ReadCloser, error) {
+	attachment, err := m.GetAttachmentByID(expenseID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	content, err := m.blobs.Open(attachment.Key)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (m *Memory) DeleteAttachment(expenseID, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attachment, ok := m.attachments[id]
+	if !ok || attachment.ExpenseID != expenseID {
+		return models.NewNotFoundError("attachment")
+	}
+	delete(m.attachments, id)
+	removeBlobs(m.blobs, []models.Attachment{attachment})
+	return nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// newID hands out an id from the sequence shared with the stores.
+func (b *MemoryBackend) newID() int64 {
+	b.shared.mu.Lock()
+	defer b.shared.mu.Unlock()
+	b.shared.nextID++
+	return b.shared.nextID
+}
+
+func (b *MemoryBackend) GetLedgers() ([]models.Ledger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return sortedValues(b.ledgers), nil
+}
+
+// GetUserLedgers orders ledgers like the SQL store, by when the user joined
+// them.
+func (b *MemoryBackend) GetUserLedgers(userID int64) ([]models.Ledger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	type membership struct {
+		ledger models.Ledger
+		joined time.Time
+	}
+	var memberships []membership
+	for id, ledger := range b.ledgers {
+		if member, ok := b.members[id][userID]; ok {
+			ledger.Role = member.Role
+			memberships = append(memberships, membership{ledger, member.JoinedAt})
+		}
+	}
+	slices.SortFunc(memberships, func(a, c membership) int {
+		return cmp.Or(a.joined.Compare(c.joined), cmp.Compare(a.ledger.ID, c.ledger.ID))
+	})
+
+	var ledgers []models.Ledger
+	for _, m := range memberships {
+		ledgers = append(ledgers, m.ledger)
+	}
+	return ledgers, nil
+}
+
+func (b *MemoryBackend) GetUserLedger(ledgerID, userID int64) (models.Ledger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.userLedger(ledgerID, userID)
+}
+
+func (b *MemoryBackend) userLedger(ledgerID, userID int64) (models.Ledger, error) {
+	member, ok := b.members[ledgerID][userID]
+	if !ok {
+		return models.Ledger{}, models.NewNotFoundError("ledger")
+	}
+	ledger := b.ledgers[ledgerID]
+	ledger.Role = member.Role
+	return ledger, nil
+}
+
+func (b *MemoryBackend) CreateLedger(userID int64, name string) (models.Ledger, error) {
+	name, err := models.ValidateLedgerName(name)
+	if err != nil {
+		return models.Ledger{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.createLedger(userID, name, time.Now().UTC().Truncate(time.Second)), nil
+}
+
+func (b *MemoryBackend) createLedger(userID int64, name string, now time.Time) models.Ledger {
+	ledger := models.Ledger{ID: b.newID(), Name: name, CreatedAt: now}
+	b.ledgers[ledger.ID] = ledger
+	b.members[ledger.ID] = map[int64]models.Member{
+		userID: {UserID: userID, Email: b.users[userID].Email, Role: models.RoleOwner, JoinedAt: now},
+	}
+	ledger.Role = models.RoleOwner
+	return ledger
+}
+
+func (b *MemoryBackend) GetMembers(ledgerID int64) ([]models.Member, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var members []models.Member
+	for _, member := range b.members[ledgerID] {
+		members = append(members, member)
+	}
+	slices.SortFunc(members, func(a, c models.Member) int {
+		return cmp.Or(a.JoinedAt.Compare(c.JoinedAt), cmp.Compare(a.UserID, c.UserID))
+	})
+	return members, nil
+}
+
+func (b *MemoryBackend) UpdateMember(ledgerID, userID int64, role models.Role) (models.Member, error) {
+	if err := models.ValidateRole(role); err != nil {
+		return models.Member{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	member, ok := b.members[ledgerID][userID]
+	if !ok {
+		return models.Member{}, models.NewNotFoundError("member")
+	}
+	if member.Role == models.RoleOwner && role != models.RoleOwner {
+		if err := b.checkOtherOwner(ledgerID, userID); err != nil {
+			return models.Member{}, err
+		}
+	}
+	member.Role = role
+	b.members[ledgerID][userID] = member
+	return member, nil
+}
+
+func (b *MemoryBackend) RemoveMember(ledgerID, userID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	member, ok := b.members[ledgerID][userID]
+	if !ok {
+		return models.NewNotFoundError("member")
+	}
+	if member.Role == models.RoleOwner {
+		if err := b.checkOtherOwner(ledgerID, userID); err != nil {
+			return err
+		}
+	}
+	delete(b.members[ledgerID], userID)
+	return nil
+}
+
+func (b *MemoryBackend) checkOtherOwner(ledgerID, userID int64) error {
+	for id, member := range b.members[ledgerID] {
+		if id != userID && member.Role == models.RoleOwner {
+			return nil
+		}
+	}
+	return models.NewConflictError("a ledger must keep at least one owner")
+}
+
+func (b *MemoryBackend) CreateInvitation(ledgerID, invitedBy int64, role models.Role) (models.Invitation, error) {
+	if err := models.ValidateRole(role); err != nil {
+		return models.Invitation{}, err
+	}
+	token, err := models.NewSessionToken()
+	if err != nil {
+		return models.Invitation{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for key, existing := range b.invitations {
+		if existing.LedgerID == ledgerID && !existing.ExpiresAt.After(now) {
+			delete(b.invitations, key)
+		}
+	}
+	invitation := models.Invitation{ID: b.newID(), LedgerID: ledgerID, Role: role, CreatedAt: now, ExpiresAt: now.Add(models.InvitationLifetime)}
+	b.invitations[models.HashToken(token)] = invitation
+	invitation.Token = token
+	return invitation, nil
+}
+
+func (b *MemoryBackend) AcceptInvitation(token string, userID int64) (models.Ledger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	invitation, ok := b.invitations[models.HashToken(token)]
+	if !ok || !invitation.ExpiresAt.After(now) {
+		return models.Ledger{}, models.NewNotFoundError("invitation")
+	}
+	if _, ok := b.members[invitation.LedgerID][userID]; ok {
+		return models.Ledger{}, models.NewConflictError("you are already a member of this ledger")
+	}
+	delete(b.invitations, models.HashToken(token))
+	b.members[invitation.LedgerID][userID] = models.Member{UserID: userID, Email: b.users[userID].Email, Role: invitation.Role, JoinedAt: now}
+	return b.userLedger(invitation.LedgerID, userID)
+}
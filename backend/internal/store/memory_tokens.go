@@ -0,0 +1,91 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// memoryToken is an API token along with its user and the hash of its
+// secret.
+type memoryToken struct {
+	models.APIToken
+	userID int64
+	hash   string
+}
+
+func (b *MemoryBackend) Tokens() TokenStore {
+	return b
+}
+
+func (b *MemoryBackend) GetAPITokens(userID int64) ([]models.APIToken, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var tokens []models.APIToken
+	for _, token := range b.tokens {
+		if token.userID == userID {
+			tokens = append(tokens, token.APIToken)
+		}
+	}
+	slices.SortFunc(tokens, func(a, c models.APIToken) int { return cmp.Compare(a.ID, c.ID) })
+	return tokens, nil
+}
+
+func (b *MemoryBackend) CreateAPIToken(userID int64, token models.APIToken) (models.APIToken, error) {
+	if err := token.Validate(); err != nil {
+		return models.APIToken{}, err
+	}
+	secret, err := models.NewAPIToken()
+	if err != nil {
+		return models.APIToken{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	token.ID = b.newID()
+	token.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	token.LastUsedAt, token.RevokedAt = nil, nil
+	b.tokens[token.ID] = memoryToken{APIToken: token, userID: userID, hash: models.HashToken(secret)}
+	token.Token = secret
+	return token, nil
+}
+
+func (b *MemoryBackend) RevokeAPIToken(userID, id int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	token, ok := b.tokens[id]
+	if !ok || token.userID != userID {
+		return models.NewNotFoundError("API token")
+	}
+	if token.RevokedAt == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		token.RevokedAt = &now
+		b.tokens[id] = token
+	}
+	return nil
+}
+
+func (b *MemoryBackend) AuthenticateAPIToken(secret string) (models.User, models.APIToken, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	hash := models.HashToken(secret)
+	for id, token := range b.tokens {
+		if token.hash != hash {
+			continue
+		}
+		if token.RevokedAt != nil || (token.ExpiresAt != nil && !token.ExpiresAt.After(now)) {
+			break
+		}
+		token.LastUsedAt = &now
+		b.tokens[id] = token
+		return b.users[token.userID], token.APIToken, nil
+	}
+	return models.User{}, models.APIToken{}, models.NewUnauthorizedError("API token is invalid, revoked or has expired")
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/models"
+)
+
+// MemoryBackend keeps users, their sessions and API tokens, ledgers and a
+// Memory store per ledger in process memory. The stores share ids, exchange rates and
+// attachment content, like the tables of the SQL store.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	shared      *memoryShared
+	blobs       blob.Store
+	users       map[int64]models.User
+	passwords   map[int64]string
+	sessions    map[string]memorySession
+	tokens      map[int64]memoryToken
+	ledgers     map[int64]models.Ledger
+	members     map[int64]map[int64]models.Member
+	invitations map[string]models.Invitation
+	stores      map[int64]*Memory
+}
+
+// memorySession is a session stored under the hash of its token.
+type memorySession struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+// NewMemoryBackend returns an in-memory backend without users.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		shared:    &memoryShared{nextID: 1},
+		blobs:     blob.NewMemory(),
+		users:     map[int64]models.User{},
+		passwords: map[int64]string{},
+		sessions:  map[string]memorySession{},
+		tokens:    map[int64]memoryToken{},
+
+		ledgers:     map[int64]models.Ledger{},
+		members:     map[int64]map[int64]models.Member{},
+		invitations: map[string]models.Invitation{},
+		stores:      map[int64]*Memory{},
+	}
+}
+
+func (b *MemoryBackend) Users() UserStore {
+	return b
+}
+
+func (b *MemoryBackend) Ledgers() LedgerStore {
+	return b
+}
+
+// Stores returns the stores of a ledger, creating them on first use.
+func (b *MemoryBackend) Stores(ledgerID int64) Stores {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, ok := b.stores[ledgerID]
+	if !ok {
+		m = newMemory(b.shared, b.blobs)
+		b.stores[ledgerID] = m
+	}
+	return m.Stores()
+}
+
+func (b *MemoryBackend) GetUsers() ([]models.User, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return sortedValues(b.users), nil
+}
+
+func (b *MemoryBackend) GetUserByEmail(email string) (models.User, error) {
+	email, err := models.NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if user, ok := b.userByEmail(email); ok {
+		return user, nil
+	}
+	return models.User{}, models.NewNotFoundError("user")
+}
+
+func (b *MemoryBackend) userByEmail(email string) (models.User, bool) {
+	for _, user := range b.users {
+		if user.Email == email {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
+
+func (b *MemoryBackend) Register(email, password string) (models.User, error) {
+	email, err := models.NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := models.ValidatePassword(password); err != nil {
+		return models.User{}, err
+	}
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.userByEmail(email); ok {
+		return models.User{}, models.NewConflictError("a user with email %q already exists", email)
+	}
+	user := models.User{ID: b.newID(), Email: email, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	b.users[user.ID] = user
+	b.passwords[user.ID] = hash
+	b.createLedger(user.ID, models.PersonalLedgerName, user.CreatedAt)
+	return user, nil
+}
+
+func (b *MemoryBackend) Login(email, password string) (models.Session, error) {
+	email, err := models.NormalizeEmail(email)
+	if err != nil {
+		return models.Session{}, models.NewUnauthorizedError("invalid email or password")
+	}
+
+	b.mu.Lock()
+	user, ok := b.userByEmail(email)
+	hash := b.passwords[user.ID]
+	b.mu.Unlock()
+	if !ok || !models.CheckPassword(hash, password) {
+		return models.Session{}, models.NewUnauthorizedError("invalid email or password")
+	}
+
+	token, err := models.NewSessionToken()
+	if err != nil {
+		return models.Session{}, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	session := models.Session{Token: token, ExpiresAt: now.Add(models.SessionLifetime), User: user}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, existing := range b.sessions {
+		if existing.userID == user.ID && !existing.expiresAt.After(now) {
+			delete(b.sessions, key)
+		}
+	}
+	b.sessions[models.HashToken(token)] = memorySession{userID: user.ID, expiresAt: session.ExpiresAt}
+	return session, nil
+}
+
+func (b *MemoryBackend) Authenticate(token string) (models.User, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	session, ok := b.sessions[models.HashToken(token)]
+	if !ok || !session.expiresAt.After(time.Now()) {
+		return models.User{}, models.NewUnauthorizedError("session is invalid or has expired")
+	}
+	return b.users[session.userID], nil
+}
+
+func (b *MemoryBackend) Logout(token string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sessions, models.HashToken(token))
+	return nil
+}
@@ -0,0 +1,508 @@
+package store
+
+import (
+	"database/sql"
+	"io"
+	"time"
+
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/models"
+)
+
+// SQL implements every store on top of database/sql using the queries in the
+// models package, which run unchanged on Postgres and SQLite. Attachment
+// content is kept in a separate blob store. The stores returned by Stores
+// are scoped to one ledger; exchange rates are shared by everyone.
+type SQL struct {
+	db       *sql.DB
+	blobs    blob.Store
+	ledgerID int64
+}
+
+// NewSQL returns a store backed by a Postgres or SQLite database. SQLite
+// connections should enable foreign keys so category deletes cascade as in
+// Postgres.
+func NewSQL(db *sql.DB, blobs blob.Store) *SQL {
+	return &SQL{db: db, blobs: blobs}
+}
+
+// Users returns the SQL implementation of the user store.
+func (s *SQL) Users() UserStore {
+	return s
+}
+
+// Tokens returns the SQL implementation of the API token store.
+func (s *SQL) Tokens() TokenStore {
+	return s
+}
+
+// Ledgers returns the SQL implementation of the ledger store.
+func (s *SQL) Ledgers() LedgerStore {
+	return s
+}
+
+// Stores returns the SQL implementation of every store, scoped to a ledger.
+func (s *SQL) Stores(ledgerID int64) Stores {
+	scoped := &SQL{db: s.db, blobs: s.blobs, ledgerID: ledgerID}
+	return Stores{Expenses: scoped, Incomes: scoped, Categories: scoped, CategoryRules: scoped, Budgets: scoped, Transactions: scoped, Accounts: scoped, Transfers: scoped, ExchangeRates: scoped, Imports: scoped, Reports: scoped, Recurring: scoped, Tags: scoped, Attachments: scoped}
+}
+
+func (s *SQL) GetUsers() ([]models.User, error) {
+	return models.GetUsers(s.db)
+}
+
+func (s *SQL) GetUserByEmail(email string) (models.User, error) {
+	return models.GetUserByEmail(s.db, email)
+}
+
+func (s *SQL) Register(email, password string) (models.User, error) {
+	return models.CreateUser(s.db, email, password)
+}
+
+func (s *SQL) Login(email, password string) (models.Session, error) {
+	return models.CreateSession(s.db, email, password)
+}
+
+func (s *SQL) Authenticate(token string) (models.User, error) {
+	return models.GetSessionUser(s.db, token)
+}
+
+func (s *SQL) Logout(token string) error {
+	return models.DeleteSession(s.db, token)
+}
+
+func (s *SQL) GetAPITokens(userID int64) ([]models.APIToken, error) {
+	return models.GetAPITokens(s.db, userID)
+}
+
+func (s *SQL) CreateAPIToken(userID int64, token models.APIToken) (models.APIToken, error) {
+	return models.CreateAPIToken(s.db, userID, token)
+}
+
+func (s *SQL) RevokeAPIToken(userID, id int64) error {
+	return models.RevokeAPIToken(s.db, userID, id)
+}
+
+func (s *SQL) AuthenticateAPIToken(token string) (models.User, models.APIToken, error) {
+	return models.AuthenticateAPIToken(s.db, token)
+}
+
+func (s *SQL) GetLedgers() ([]models.Ledger, error) {
+	return models.GetLedgers(s.db)
+}
+
+func (s *SQL) GetUserLedgers(userID int64) ([]models.Ledger, error) {
+	return models.GetUserLedgers(s.db, userID)
+}
+
+func (s *SQL) GetUserLedger(ledgerID, userID int64) (models.Ledger, error) {
+	return models.GetUserLedger(s.db, ledgerID, userID)
+}
+
+func (s *SQL) CreateLedger(userID int64, name string) (models.Ledger, error) {
+	return models.CreateLedger(s.db, userID, name)
+}
+
+func (s *SQL) GetMembers(ledgerID int64) ([]models.Member, error) {
+	return models.GetMembers(s.db, ledgerID)
+}
+
+func (s *SQL) UpdateMember(ledgerID, userID int64, role models.Role) (models.Member, error) {
+	return models.UpdateMemberRole(s.db, ledgerID, userID, role)
+}
+
+func (s *SQL) RemoveMember(ledgerID, userID int64) error {
+	return models.RemoveMember(s.db, ledgerID, userID)
+}
+
+func (s *SQL) CreateInvitation(ledgerID, invitedBy int64, role models.Role) (models.Invitation, error) {
+	return models.CreateInvitation(s.db, ledgerID, invitedBy, role)
+}
+
+func (s *SQL) AcceptInvitation(token string, userID int64) (models.Ledger, error) {
+	return models.AcceptInvitation(s.db, token, userID)
+}
+
+// dateOnly drops the time of day, matching the DATE columns. Postgres does
+// this on insert; SQLite stores dates as text, so without it range checks
+// such as CalculateTotalSpent would compare timestamps instead of days.
+func dateOnly(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// listDatesOnly truncates the date bounds of list options like dateOnly.
+func listDatesOnly(opts models.ListOptions) models.ListOptions {
+	opts.From = dateOnly(opts.From)
+	opts.To = dateOnly(opts.To)
+	return opts
+}
+
+func (s *SQL) GetExpenses() ([]models.Expense, error) {
+	return models.GetExpenses(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListExpenses(opts models.ListOptions) ([]models.Expense, int, error) {
+	return models.ListExpenses(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetExpenseByID(id int64) (models.Expense, error) {
+	return models.GetExpenseByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateExpense(expense models.Expense) (models.Expense, error) {
+	expense.Date = dateOnly(expense.Date)
+	return models.CreateExpense(s.db, s.ledgerID, expense)
+}
+
+func (s *SQL) UpdateExpense(expense models.Expense) (models.Expense, error) {
+	expense.Date = dateOnly(expense.Date)
+	return models.UpdateExpense(s.db, s.ledgerID, expense)
+}
+
+func (s *SQL) DeleteExpense(id int64) error {
+	attachments, err := models.GetAttachments(s.db, s.ledgerID, id)
+	if err != nil {
+		return err
+	}
+	if err := models.DeleteExpense(s.db, s.ledgerID, id); err != nil {
+		return err
+	}
+	removeBlobs(s.blobs, attachments)
+	return nil
+}
+
+func (s *SQL) GetDuplicateExpenses() ([]models.DuplicatePair, error) {
+	return models.GetDuplicateExpenses(s.db, s.ledgerID)
+}
+
+func (s *SQL) DismissDuplicate(expenseID, duplicateID int64) error {
+	return models.DismissDuplicate(s.db, s.ledgerID, expenseID, duplicateID)
+}
+
+func (s *SQL) MergeExpense(duplicateID, targetID int64) (models.Expense, error) {
+	return models.MergeExpense(s.db, s.ledgerID, duplicateID, targetID)
+}
+
+func (s *SQL) GetIncomes() ([]models.Income, error) {
+	return models.GetIncomes(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListIncomes(opts models.ListOptions) ([]models.Income, int, error) {
+	return models.ListIncomes(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetIncomeByID(id int64) (models.Income, error) {
+	return models.GetIncomeByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateIncome(income models.Income) (models.Income, error) {
+	income.Date = dateOnly(income.Date)
+	return models.CreateIncome(s.db, s.ledgerID, income)
+}
+
+func (s *SQL) UpdateIncome(income models.Income) (models.Income, error) {
+	income.Date = dateOnly(income.Date)
+	return models.UpdateIncome(s.db, s.ledgerID, income)
+}
+
+func (s *SQL) DeleteIncome(id int64) error {
+	return models.DeleteIncome(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetCategories() ([]models.Category, error) {
+	return models.GetCategories(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListCategories(opts models.ListOptions) ([]models.Category, int, error) {
+	return models.ListCategories(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetCategoryByID(id int64) (models.Category, error) {
+	return models.GetCategoryByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateCategory(category models.Category) (models.Category, error) {
+	return models.CreateCategory(s.db, s.ledgerID, category)
+}
+
+func (s *SQL) UpdateCategory(category models.Category) (models.Category, error) {
+	return models.UpdateCategory(s.db, s.ledgerID, category)
+}
+
+func (s *SQL) DeleteCategory(id int64) error {
+	return models.DeleteCategory(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) MergeCategory(sourceID, targetID int64, strategy string) (models.Category, error) {
+	return models.MergeCategory(s.db, s.ledgerID, sourceID, targetID, strategy)
+}
+
+func (s *SQL) GetBudgets() ([]models.Budget, error) {
+	return models.GetBudgets(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListBudgets(opts models.ListOptions) ([]models.Budget, int, error) {
+	return models.ListBudgets(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetBudgetByID(id int64) (models.Budget, error) {
+	return models.GetBudgetByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetBudgetsByCategoryID(categoryID int64) ([]models.Budget, error) {
+	return models.GetBudgetsByCategoryID(s.db, s.ledgerID, categoryID)
+}
+
+func (s *SQL) GetBudgetsByCategoryName(categoryName string) ([]models.Budget, error) {
+	return models.GetBudgetsByCategoryName(s.db, s.ledgerID, categoryName)
+}
+
+func (s *SQL) CreateBudget(budget models.Budget) (models.Budget, error) {
+	budget.StartDate, budget.EndDate = dateOnly(budget.StartDate), dateOnly(budget.EndDate)
+	return models.CreateBudget(s.db, s.ledgerID, budget)
+}
+
+func (s *SQL) UpdateBudget(budget models.Budget) (models.Budget, error) {
+	budget.StartDate, budget.EndDate = dateOnly(budget.StartDate), dateOnly(budget.EndDate)
+	return models.UpdateBudget(s.db, s.ledgerID, budget)
+}
+
+func (s *SQL) DeleteBudget(id int64) error {
+	return models.DeleteBudget(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) ListTransactions(opts models.ListOptions) ([]models.Transaction, int, error) {
+	return models.ListTransactions(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetAccounts() ([]models.Account, error) {
+	return models.GetAccounts(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListAccounts(opts models.ListOptions) ([]models.Account, int, error) {
+	return models.ListAccounts(s.db, s.ledgerID, opts)
+}
+
+func (s *SQL) GetAccountByID(id int64) (models.Account, error) {
+	return models.GetAccountByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateAccount(account models.Account) (models.Account, error) {
+	return models.CreateAccount(s.db, s.ledgerID, account)
+}
+
+func (s *SQL) UpdateAccount(account models.Account) (models.Account, error) {
+	return models.UpdateAccount(s.db, s.ledgerID, account)
+}
+
+func (s *SQL) DeleteAccount(id int64) error {
+	return models.DeleteAccount(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetAccountBalances(id int64, from, to time.Time, interval models.BalanceInterval) ([]models.AccountBalance, error) {
+	return models.GetAccountBalances(s.db, s.ledgerID, id, dateOnly(from), dateOnly(to), interval)
+}
+
+func (s *SQL) GetTransfers() ([]models.Transfer, error) {
+	return models.GetTransfers(s.db, s.ledgerID)
+}
+
+func (s *SQL) ListTransfers(opts models.ListOptions) ([]models.Transfer, int, error) {
+	return models.ListTransfers(s.db, s.ledgerID, listDatesOnly(opts))
+}
+
+func (s *SQL) GetTransferByID(id int64) (models.Transfer, error) {
+	return models.GetTransferByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateTransfer(transfer models.Transfer) (models.Transfer, error) {
+	transfer.Date = dateOnly(transfer.Date)
+	return models.CreateTransfer(s.db, s.ledgerID, transfer)
+}
+
+func (s *SQL) UpdateTransfer(transfer models.Transfer) (models.Transfer, error) {
+	transfer.Date = dateOnly(transfer.Date)
+	return models.UpdateTransfer(s.db, s.ledgerID, transfer)
+}
+
+func (s *SQL) DeleteTransfer(id int64) error {
+	return models.DeleteTransfer(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetExchangeRates(baseCurrency, quoteCurrency string) ([]models.ExchangeRate, error) {
+	return models.GetExchangeRates(s.db, baseCurrency, quoteCurrency)
+}
+
+func (s *SQL) ImportExchangeRates(rates []models.ExchangeRate) (int, error) {
+	normalized := make([]models.ExchangeRate, len(rates))
+	for i, rate := range rates {
+		rate.Date = dateOnly(rate.Date)
+		normalized[i] = rate
+	}
+	return models.ImportExchangeRates(s.db, normalized)
+}
+
+func (s *SQL) ConvertAmount(amount models.Money, from, to string, on time.Time) (models.Conversion, error) {
+	return models.ConvertAmount(s.db, amount, from, to, dateOnly(on))
+}
+
+func (s *SQL) GetCategoryRules() ([]models.CategoryRule, error) {
+	return models.GetCategoryRules(s.db, s.ledgerID)
+}
+
+func (s *SQL) GetCategoryRuleByID(id int64) (models.CategoryRule, error) {
+	return models.GetCategoryRuleByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error) {
+	return models.CreateCategoryRule(s.db, s.ledgerID, rule)
+}
+
+func (s *SQL) UpdateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error) {
+	return models.UpdateCategoryRule(s.db, s.ledgerID, rule)
+}
+
+func (s *SQL) DeleteCategoryRule(id int64) error {
+	return models.DeleteCategoryRule(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) TestCategoryRule(rule models.CategoryRule) ([]models.Expense, error) {
+	return models.TestCategoryRule(s.db, s.ledgerID, rule)
+}
+
+func (s *SQL) ApplyCategoryRules() ([]models.Expense, error) {
+	return models.ApplyCategoryRules(s.db, s.ledgerID)
+}
+
+func (s *SQL) GetImportProfiles() ([]models.ImportProfile, error) {
+	return models.GetImportProfiles(s.db, s.ledgerID)
+}
+
+func (s *SQL) GetImportProfileByID(id int64) (models.ImportProfile, error) {
+	return models.GetImportProfileByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetImportProfileByName(name string) (models.ImportProfile, error) {
+	return models.GetImportProfileByName(s.db, s.ledgerID, name)
+}
+
+func (s *SQL) CreateImportProfile(profile models.ImportProfile) (models.ImportProfile, error) {
+	return models.CreateImportProfile(s.db, s.ledgerID, profile)
+}
+
+func (s *SQL) UpdateImportProfile(profile models.ImportProfile) (models.ImportProfile, error) {
+	return models.UpdateImportProfile(s.db, s.ledgerID, profile)
+}
+
+func (s *SQL) DeleteImportProfile(id int64) error {
+	return models.DeleteImportProfile(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) ImportStatement(statement models.Statement, dryRun bool) (models.StatementImport, error) {
+	rows := make([]models.StatementRow, len(statement.Rows))
+	for i, row := range statement.Rows {
+		row.Date = dateOnly(row.Date)
+		rows[i] = row
+	}
+	statement.Rows = rows
+	return models.ImportStatement(s.db, s.ledgerID, statement, dryRun)
+}
+
+func (s *SQL) GetSummary(currency string, from, to time.Time) (models.Summary, error) {
+	return models.GetSummary(s.db, s.ledgerID, currency, dateOnly(from), dateOnly(to))
+}
+
+func (s *SQL) GetRecurringRules() ([]models.RecurringRule, error) {
+	return models.GetRecurringRules(s.db, s.ledgerID)
+}
+
+func (s *SQL) GetRecurringRuleByID(id int64) (models.RecurringRule, error) {
+	return models.GetRecurringRuleByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error) {
+	return models.CreateRecurringRule(s.db, s.ledgerID, ruleDatesOnly(rule))
+}
+
+func (s *SQL) UpdateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error) {
+	return models.UpdateRecurringRule(s.db, s.ledgerID, ruleDatesOnly(rule))
+}
+
+func (s *SQL) DeleteRecurringRule(id int64) error {
+	return models.DeleteRecurringRule(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) MaterializeRecurringRules(asOf time.Time) (int, error) {
+	return models.MaterializeRecurringRules(s.db, s.ledgerID, dateOnly(asOf))
+}
+
+func ruleDatesOnly(rule models.RecurringRule) models.RecurringRule {
+	rule.StartDate = dateOnly(rule.StartDate)
+	if rule.EndDate != nil {
+		endDate := dateOnly(*rule.EndDate)
+		rule.EndDate = &endDate
+	}
+	return rule
+}
+
+func (s *SQL) GetTags() ([]models.Tag, error) {
+	return models.GetTags(s.db, s.ledgerID)
+}
+
+func (s *SQL) GetTagByID(id int64) (models.Tag, error) {
+	return models.GetTagByID(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) CreateTag(tag models.Tag) (models.Tag, error) {
+	return models.CreateTag(s.db, s.ledgerID, tag)
+}
+
+func (s *SQL) UpdateTag(tag models.Tag) (models.Tag, error) {
+	return models.UpdateTag(s.db, s.ledgerID, tag)
+}
+
+func (s *SQL) DeleteTag(id int64) error {
+	return models.DeleteTag(s.db, s.ledgerID, id)
+}
+
+func (s *SQL) GetTagSummary(currency string, from, to time.Time) ([]models.TagTotal, error) {
+	return models.GetTagSummary(s.db, s.ledgerID, currency, dateOnly(from), dateOnly(to))
+}
+
+func (s *SQL) GetAttachments(expenseID int64) ([]models.Attachment, error) {
+	return models.GetAttachments(s.db, s.ledgerID, expenseID)
+}
+
+func (s *SQL) GetAttachmentByID(expenseID, id int64) (models.Attachment, error) {
+	return models.GetAttachmentByID(s.db, s.ledgerID, expenseID, id)
+}
+
+func (s *SQL) CreateAttachment(attachment models.Attachment, content io.Reader) (models.Attachment, error) {
+	return saveAttachment(s.blobs, attachment, content, func(attachment models.Attachment) (models.Attachment, error) {
+		return models.CreateAttachment(s.db, s.ledgerID, attachment)
+	})
+}
+
+func (s *SQL) OpenAttachment(expenseID, id int64) (models.Attachment, io.ReadCloser, error) {
+	attachment, err := models.GetAttachmentByID(s.db, s.ledgerID, expenseID, id)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	content, err := s.blobs.Open(attachment.Key)
+	if err != nil {
+		return models.Attachment{}, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (s *SQL) DeleteAttachment(expenseID, id int64) error {
+	attachment, err := models.DeleteAttachment(s.db, s.ledgerID, expenseID, id)
+	if err != nil {
+		return err
+	}
+	removeBlobs(s.blobs, []models.Attachment{attachment})
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package store defines the persistence interfaces used by the HTTP API,
+// along with a database/sql implementation for Postgres and SQLite and an
+// in-memory implementation for tests and throwaway instances.
+package store
+
+import (
+	"io"
+	"time"
+
+	"expense-tracker/internal/models"
+)
+
+// ExpenseStore persists expenses and keeps budget spend in step with them.
+// It also finds expenses that look recorded twice, which can be merged or
+// dismissed as not duplicates. Deleting an expense removes its attachments.
+type ExpenseStore interface {
+	GetExpenses() ([]models.Expense, error)
+	ListExpenses(opts models.ListOptions) ([]models.Expense, int, error)
+	GetExpenseByID(id int64) (models.Expense, error)
+	CreateExpense(expense models.Expense) (models.Expense, error)
+	UpdateExpense(expense models.Expense) (models.Expense, error)
+	DeleteExpense(id int64) error
+	GetDuplicateExpenses() ([]models.DuplicatePair, error)
+	DismissDuplicate(expenseID, duplicateID int64) error
+	MergeExpense(duplicateID, targetID int64) (models.Expense, error)
+}
+
+// IncomeStore persists incomes.
+type IncomeStore interface {
+	GetIncomes() ([]models.Income, error)
+	ListIncomes(opts models.ListOptions) ([]models.Income, int, error)
+	GetIncomeByID(id int64) (models.Income, error)
+	CreateIncome(income models.Income) (models.Income, error)
+	UpdateIncome(income models.Income) (models.Income, error)
+	DeleteIncome(id int64) error
+}
+
+// CategoryStore persists categories. Deleting a category moves its expenses
+// to the 'Other' category.
+type CategoryStore interface {
+	GetCategories() ([]models.Category, error)
+	ListCategories(opts models.ListOptions) ([]models.Category, int, error)
+	GetCategoryByID(id int64) (models.Category, error)
+	CreateCategory(category models.Category) (models.Category, error)
+	UpdateCategory(category models.Category) (models.Category, error)
+	DeleteCategory(id int64) error
+	MergeCategory(sourceID, targetID int64, strategy string) (models.Category, error)
+}
+
+// CategoryRuleStore persists the rules that categorize expenses created
+// without a category and imported expenses.
+type CategoryRuleStore interface {
+	GetCategoryRules() ([]models.CategoryRule, error)
+	GetCategoryRuleByID(id int64) (models.CategoryRule, error)
+	CreateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error)
+	UpdateCategoryRule(rule models.CategoryRule) (models.CategoryRule, error)
+	DeleteCategoryRule(id int64) error
+	TestCategoryRule(rule models.CategoryRule) ([]models.Expense, error)
+	ApplyCategoryRules() ([]models.Expense, error)
+}
+
+// BudgetStore persists budgets and enforces that budgets for the same
+// category do not overlap.
+type BudgetStore interface {
+	GetBudgets() ([]models.Budget, error)
+	ListBudgets(opts models.ListOptions) ([]models.Budget, int, error)
+	GetBudgetByID(id int64) (models.Budget, error)
+	GetBudgetsByCategoryID(categoryID int64) ([]models.Budget, error)
+	GetBudgetsByCategoryName(categoryName string) ([]models.Budget, error)
+	CreateBudget(budget models.Budget) (models.Budget, error)
+	UpdateBudget(budget models.Budget) (models.Budget, error)
+	DeleteBudget(id int64) error
+}
+
+// AccountStore persists accounts and computes their balances from the
+// expenses and incomes recorded against them.
+type AccountStore interface {
+	GetAccounts() ([]models.Account, error)
+	ListAccounts(opts models.ListOptions) ([]models.Account, int, error)
+	GetAccountByID(id int64) (models.Account, error)
+	CreateAccount(account models.Account) (models.Account, error)
+	UpdateAccount(account models.Account) (models.Account, error)
+	DeleteAccount(id int64) error
+	GetAccountBalances(id int64, from, to time.Time, interval models.BalanceInterval) ([]models.AccountBalance, error)
+}
+
+// TransferStore persists transfers between accounts.
+type TransferStore interface {
+	GetTransfers() ([]models.Transfer, error)
+	ListTransfers(opts models.ListOptions) ([]models.Transfer, int, error)
+	GetTransferByID(id int64) (models.Transfer, error)
+	CreateTransfer(transfer models.Transfer) (models.Transfer, error)
+	UpdateTransfer(transfer models.Transfer) (models.Transfer, error)
+	DeleteTransfer(id int64) error
+}
+
+// TransactionStore lists expenses, incomes and transfers together as one
+// ledger.
+type TransactionStore interface {
+	ListTransactions(opts models.ListOptions) ([]models.Transaction, int, error)
+}
+
+// ExchangeRateStore persists exchange rates and converts amounts with them.
+type ExchangeRateStore interface {
+	GetExchangeRates(baseCurrency, quoteCurrency string) ([]models.ExchangeRate, error)
+	ImportExchangeRates(rates []models.ExchangeRate) (int, error)
+	ConvertAmount(amount models.Money, from, to string, on time.Time) (models.Conversion, error)
+}
+
+// ImportStore persists import profiles and imports bank statements.
+type ImportStore interface {
+	GetImportProfiles() ([]models.ImportProfile, error)
+	GetImportProfileByID(id int64) (models.ImportProfile, error)
+	GetImportProfileByName(name string) (models.ImportProfile, error)
+	CreateImportProfile(profile models.ImportProfile) (models.ImportProfile, error)
+	UpdateImportProfile(profile models.ImportProfile) (models.ImportProfile, error)
+	DeleteImportProfile(id int64) error
+	ImportStatement(statement models.Statement, dryRun bool) (models.StatementImport, error)
+}
+
+// ReportStore computes reports across expenses and incomes.
+type ReportStore interface {
+	GetSummary(currency string, from, to time.Time) (models.Summary, error)
+}
+
+// RecurringRuleStore persists recurring rules and generates the expenses
+// and incomes that fall due.
+type RecurringRuleStore interface {
+	GetRecurringRules() ([]models.RecurringRule, error)
+	GetRecurringRuleByID(id int64) (models.RecurringRule, error)
+	CreateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error)
+	UpdateRecurringRule(rule models.RecurringRule) (models.RecurringRule, error)
+	DeleteRecurringRule(id int64) error
+	MaterializeRecurringRules(asOf time.Time) (int, error)
+}
+
+// TagStore persists tags and totals spending per tag. Renaming or deleting a
+// tag applies to every expense and income carrying it.
+type TagStore interface {
+	GetTags() ([]models.Tag, error)
+	GetTagByID(id int64) (models.Tag, error)
+	CreateTag(tag models.Tag) (models.Tag, error)
+	UpdateTag(tag models.Tag) (models.Tag, error)
+	DeleteTag(id int64) error
+	GetTagSummary(currency string, from, to time.Time) ([]models.TagTotal, error)
+}
+
+// AttachmentStore persists the files attached to expenses, keeping their
+// content in a blob store and their metadata alongside the expense.
+type AttachmentStore interface {
+	GetAttachments(expenseID int64) ([]models.Attachment, error)
+	GetAttachmentByID(expenseID, id int64) (models.Attachment, error)
+	CreateAttachment(attachment models.Attachment, content io.Reader) (models.Attachment, error)
+	OpenAttachment(expenseID, id int64) (models.Attachment, io.ReadCloser, error)
+	DeleteAttachment(expenseID, id int64) error
+}
+
+// UserStore persists users and their sessions. Registering checks the email
+// and password and gives the user a personal ledger; signing in with either
+// wrong fails the same way, as does authenticating with a session that is
+// unknown or has expired.
+type UserStore interface {
+	GetUsers() ([]models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	Register(email, password string) (models.User, error)
+	Login(email, password string) (models.Session, error)
+	Authenticate(token string) (models.User, error)
+	Logout(token string) error
+}
+
+// LedgerStore persists ledgers, their members and invitations. Ledgers are
+// only found through their members: one a user does not belong to is
+// missing. Every ledger keeps at least one owner, and an invitation can be
+// accepted once, before it expires.
+type LedgerStore interface {
+	GetLedgers() ([]models.Ledger, error)
+	GetUserLedgers(userID int64) ([]models.Ledger, error)
+	GetUserLedger(ledgerID, userID int64) (models.Ledger, error)
+	CreateLedger(userID int64, name string) (models.Ledger, error)
+	GetMembers(ledgerID int64) ([]models.Member, error)
+	UpdateMember(ledgerID, userID int64, role models.Role) (models.Member, error)
+	RemoveMember(ledgerID, userID int64) error
+	CreateInvitation(ledgerID, invitedBy int64, role models.Role) (models.Invitation, error)
+	AcceptInvitation(token string, userID int64) (models.Ledger, error)
+}
+
+// TokenStore persists the API tokens of users. Authenticating fails alike
+// for tokens that are unknown, revoked or expired, and records when a token
+// was last used.
+type TokenStore interface {
+	GetAPITokens(userID int64) ([]models.APIToken, error)
+	CreateAPIToken(userID int64, token models.APIToken) (models.APIToken, error)
+	RevokeAPIToken(userID, id int64) error
+	AuthenticateAPIToken(token string) (models.User, models.APIToken, error)
+}
+
+// Backend holds every ledger. Users signs users in, Tokens manages their API
+// tokens, Ledgers manages who belongs to which ledger, and Stores returns
+// the stores of one ledger, which only see and change that ledger's data.
+type Backend interface {
+	Users() UserStore
+	Tokens() TokenStore
+	Ledgers() LedgerStore
+	Stores(ledgerID int64) Stores
+}
+
+// Stores groups the stores needed by the API.
+type Stores struct {
+	Expenses      ExpenseStore
+	Incomes       IncomeStore
+	Categories    CategoryStore
+	CategoryRules CategoryRuleStore
+	Budgets       BudgetStore
+	Transactions  TransactionStore
+	Accounts      AccountStore
+	Transfers     TransferStore
+	ExchangeRates ExchangeRateStore
+	Imports       ImportStore
+	Reports       ReportStore
+	Recurring     RecurringRuleStore
+	Tags          TagStore
+	Attachments   AttachmentStore
+}
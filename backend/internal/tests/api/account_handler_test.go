@@ -0,0 +1,47 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountBalancesEndpoint(t *testing.T) {
+	router, _ := newRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`{"name": "Visa", "type": "credit_card", "opening_balance": -250}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var account models.Account
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&account))
+	id := strconv.FormatInt(account.ID, 10)
+
+	body := `{"category_id": 1, "account_id": ` + id + `, "amount": 40, "date": "2024-03-02T00:00:00Z", "description": "Dinner"}`
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/accounts/"+id+"/balances?interval=day&from=2024-03-01&to=2024-03-02", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var balances []models.AccountBalance
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&balances))
+	if assert.Len(t, balances, 2) {
+		assert.Equal(t, models.MustParseMoney("-250.00"), balances[0].Balance)
+		assert.Equal(t, models.MustParseMoney("-290.00"), balances[1].Balance)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/accounts/"+id, nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/accounts/"+id+"/balances?interval=year", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/api"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createAPIToken has a signed-in user create an API token with scopes.
+func createAPIToken(t *testing.T, user http.Handler, scopes string) models.APIToken {
+	rec := serve(user, http.MethodPost, "/api-tokens", `{"name": "script", "scopes": [`+scopes+`]}`)
+	var token models.APIToken
+	if err := json.NewDecoder(rec.Body).Decode(&token); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("failed to create API token: %d %v", rec.Code, err)
+	}
+	return token
+}
+
+func TestAPITokenEndpoints(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+	sessionToken, _ := signIn(t, router, "ann@example.com")
+	user := withToken(router, sessionToken)
+
+	rec := serve(user, http.MethodPost, "/api-tokens", `{"name": "script", "scopes": ["expenses:admin"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = serve(user, http.MethodPost, "/api-tokens", `{"name": "script", "scopes": ["expenses:write"], "expires_at": "2020-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	writer := createAPIToken(t, user, `"expenses:write", "categories:read"`)
+	reader := createAPIToken(t, user, `"reports:read"`)
+	assert.Equal(t, []string{"categories:read", "expenses:write"}, writer.Scopes)
+	script, reports := withToken(router, writer.Token), withToken(router, reader.Token)
+
+	rec = serve(user, http.MethodGet, "/api-tokens", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var tokens []models.APIToken
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&tokens))
+	if assert.Len(t, tokens, 2) {
+		assert.Empty(t, tokens[0].Token)
+	}
+
+	// Tokens reach what their scopes cover, writing including reading
+	rec = serve(user, http.MethodPost, "/categories", `{"name": "Groceries"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var groceries models.Category
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&groceries))
+	rec = serve(script, http.MethodPost, "/expenses", `{"category_id": `+strconv.FormatInt(groceries.ID, 10)+
+		`, "amount": 42, "date": "2024-03-02T00:00:00Z", "description": "Market"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	rec = serve(script, http.MethodGet, "/expenses", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	rec = serve(reports, http.MethodGet, "/summary", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	for _, req := range [][3]string{
+		{http.MethodPost, "/categories", `{"name": "Books"}`},
+		{http.MethodGet, "/budgets", ""},
+	} {
+		rec = serve(script, req[0], req[1], req[2])
+		assert.Equal(t, http.StatusForbidden, rec.Code, req[0]+" "+req[1])
+	}
+	rec = serve(reports, http.MethodPost, "/expenses", `{"category_id": 1, "amount": 1, "date": "2024-03-02T00:00:00Z"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "API token lacks the expenses:write scope", decodeProblem(t, rec).Message)
+
+	// Accounts, ledgers and tokens are managed after signing in
+	for _, path := range []string{"/auth/me", "/ledgers", "/api-tokens"} {
+		rec = serve(script, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusForbidden, rec.Code, path)
+	}
+
+	// Revoked and unknown tokens are rejected
+	rec = serve(user, http.MethodDelete, "/api-tokens/"+strconv.FormatInt(writer.ID, 10), "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(script, http.MethodGet, "/expenses", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = serve(withToken(router, models.APITokenPrefix+"unknown"), http.MethodGet, "/summary", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = serve(user, http.MethodDelete, "/api-tokens/999", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPITokenKeepsLedgerRole(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+	annToken, _ := signIn(t, router, "ann@example.com")
+	bobToken, _ := signIn(t, router, "bob@example.com")
+	owner, viewer := withToken(router, annToken), withToken(router, bobToken)
+
+	rec := serve(owner, http.MethodPost, "/ledgers", `{"name": "Household"}`)
+	var household models.Ledger
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&household))
+	prefix := "/ledgers/" + strconv.FormatInt(household.ID, 10)
+	invite(t, owner, viewer, household.ID, models.RoleViewer)
+
+	script := withToken(router, createAPIToken(t, viewer, `"categories:write"`).Token)
+	rec = serve(script, http.MethodGet, prefix+"/categories", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(script, http.MethodPost, prefix+"/categories", `{"name": "Groceries"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "viewers can only read the ledger", decodeProblem(t, rec).Message)
+	rec = serve(script, http.MethodPost, "/categories", `{"name": "Groceries"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
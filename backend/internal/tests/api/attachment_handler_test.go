@@ -0,0 +1,91 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadRequest builds a multipart upload of content in the field "file".
+func uploadRequest(t *testing.T, path, fileName, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write([]byte(content))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAttachmentEndpoints(t *testing.T) {
+	router, _ := newRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(`{"description": "Hotel", "amount": "200.00", "date": "2024-03-02T00:00:00Z"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var expense models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expense))
+	path := fmt.Sprintf("/expenses/%d/attachments", expense.ID)
+
+	// The content type is sniffed when the part does not name one
+	receipt := "%PDF-1.4 hotel receipt"
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, uploadRequest(t, path, "receipt.pdf", receipt))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var attachment models.Attachment
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&attachment))
+	assert.Equal(t, "receipt.pdf", attachment.FileName)
+	assert.Equal(t, "application/pdf", attachment.ContentType)
+	assert.Equal(t, int64(len(receipt)), attachment.Size)
+	assert.Len(t, attachment.SHA256, 64)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"file": "receipt.pdf"}`)))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, uploadRequest(t, path, "huge.bin", strings.Repeat("x", models.MaxAttachmentSize+1)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, uploadRequest(t, "/expenses/999/attachments", "receipt.pdf", receipt))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var attachments []models.Attachment
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&attachments))
+	assert.Len(t, attachments, 1)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%d", path, attachment.ID), nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=receipt.pdf`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, receipt, rec.Body.String())
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"/receipt", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, attachment.ID), nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%d", path, attachment.ID), nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/api"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Full-strength password hashing would dominate the test run
+	models.PasswordHashIterations = 1000
+	os.Exit(m.Run())
+}
+
+// newRouter returns a router over an in-memory backend that sends requests
+// as a freshly registered user, along with the stores of their personal
+// ledger.
+func newRouter(t *testing.T) (http.Handler, store.Stores) {
+	backend := store.NewMemoryBackend()
+	router := api.NewRouter(backend)
+	token, user := signIn(t, router, "owner@example.com")
+	ledgers, err := backend.Ledgers().GetUserLedgers(user.ID)
+	if err != nil || len(ledgers) != 1 {
+		t.Fatalf("failed to find the personal ledger: %v", err)
+	}
+	return withToken(router, token), backend.Stores(ledgers[0].ID)
+}
+
+// signIn registers a user through the API and signs them in.
+func signIn(t *testing.T, router http.Handler, email string) (string, models.User) {
+	body := `{"email": "` + email + `", "password": "correct horse"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("failed to register %s: %d %s", email, rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body)))
+	var session models.Session
+	if err := json.NewDecoder(rec.Body).Decode(&session); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("failed to sign in %s: %d %v", email, rec.Code, err)
+	}
+	return session.Token, session.User
+}
+
+// withToken sends every request with a bearer token, unless it sets its own
+// Authorization header.
+func withToken(router http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+func TestAuthEndpoints(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, "unauthorized", decodeProblem(t, rec).Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"email": "ann@example.com", "password": "short"}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	token, user := signIn(t, router, "Ann@Example.com")
+	assert.Equal(t, "ann@example.com", user.Email)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"email": "ann@example.com", "password": "another password"}`)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "ann@example.com", "password": "wrong password"}`)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "bob@example.com", "password": "correct horse"}`)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	signedIn := withToken(router, token)
+	rec = httptest.NewRecorder()
+	signedIn.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var me models.User
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&me))
+	assert.Equal(t, user, me)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	rec = httptest.NewRecorder()
+	signedIn.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	signedIn.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/logout", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = httptest.NewRecorder()
+	signedIn.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestUsersOnlySeeTheirOwnData(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+	annToken, _ := signIn(t, router, "ann@example.com")
+	bobToken, _ := signIn(t, router, "bob@example.com")
+	ann, bob := withToken(router, annToken), withToken(router, bobToken)
+
+	rec := httptest.NewRecorder()
+	ann.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name": "Food"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var food models.Category
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&food))
+
+	// Category names only need to be unique per user
+	rec = httptest.NewRecorder()
+	bob.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name": "Food"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ann.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(
+		`{"category_id": `+strconv.FormatInt(food.ID, 10)+`, "amount": 12.5, "date": "2024-03-02T00:00:00Z", "description": "Lunch"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var lunch models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&lunch))
+
+	rec = httptest.NewRecorder()
+	bob.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-Total-Count"))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/expenses/"+strconv.FormatInt(lunch.ID, 10), nil),
+		httptest.NewRequest(http.MethodDelete, "/expenses/"+strconv.FormatInt(lunch.ID, 10), nil),
+		httptest.NewRequest(http.MethodGet, "/categories/"+strconv.FormatInt(food.ID, 10), nil),
+	} {
+		rec = httptest.NewRecorder()
+		bob.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code, req.Method+" "+req.URL.Path)
+	}
+
+	// Nor can Bob file an expense under Ann's category
+	rec = httptest.NewRecorder()
+	bob.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(
+		`{"category_id": `+strconv.FormatInt(food.ID, 10)+`, "amount": 3, "date": "2024-03-02T00:00:00Z", "description": "Coffee"}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ann.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses/"+strconv.FormatInt(lunch.ID, 10), nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
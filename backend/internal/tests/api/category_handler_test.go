@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteOtherCategoryForbidden(t *testing.T) {
+	router, _ := newRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/categories/1", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestGetCategoriesTree(t *testing.T) {
+	router, stores := newRouter(t)
+	food, err := stores.Categories.CreateCategory(models.Category{Name: "Food"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	if _, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries", ParentID: &food.ID}); err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/categories?tree=true", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+	var tree []models.Category
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "Food", tree[1].Name)
+	assert.Len(t, tree[1].Children, 1)
+	assert.Equal(t, "Groceries", tree[1].Children[0].Name)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/categories?tree=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMergeCategory(t *testing.T) {
+	router, stores := newRouter(t)
+	source, err := stores.Categories.CreateCategory(models.Category{Name: "Eating out"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	target, err := stores.Categories.CreateCategory(models.Category{Name: "Restaurants"})
+	if err != nil {
+		t.Fatalf("failed to create category: %v", err)
+	}
+	merge := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/categories/%d/merge-into/%d%s", source.ID, target.ID, query), nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, merge("?strategy=average").Code)
+
+	rec := merge("?strategy=sum")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var merged models.Category
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &merged))
+	assert.Equal(t, target.ID, merged.ID)
+
+	assert.Equal(t, http.StatusNotFound, merge("").Code)
+}
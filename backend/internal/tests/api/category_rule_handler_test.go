@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryRuleEndpoints(t *testing.T) {
+	router, stores := newRouter(t)
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Streaming"})
+	assert.NoError(t, err)
+	_, err = stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("15.99"), Date: time.Now(), Description: "NETFLIX.COM"})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/category-rules/test", strings.NewReader(`{"description_contains": "netflix"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var matches []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&matches))
+	assert.Len(t, matches, 1)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/category-rules", strings.NewReader(`{"name": "Streaming", "category_id": 1}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "a rule needs at least one condition", decodeProblem(t, rec).Message)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/category-rules", strings.NewReader(
+		`{"name": "Streaming", "category_id": `+strconv.FormatInt(category.ID, 10)+`, "description_pattern": "(?i)netflix|spotify"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/category-rules/apply", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var moved []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&moved))
+	if assert.Len(t, moved, 1) {
+		assert.Equal(t, category.ID, moved[0].CategoryID)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(
+		`{"description": "Spotify", "amount": "9.99", "date": "2024-03-01T00:00:00Z"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var expense models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expense))
+	assert.Equal(t, category.ID, expense.CategoryID)
+}
@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type problem struct {
+	Status  int               `json:"status"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problem {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	var p problem
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	assert.Equal(t, rec.Code, p.Status)
+	return p
+}
+
+func TestErrorResponses(t *testing.T) {
+	router, _ := newRouter(t)
+
+	budget := `{"category_id": 1, "amount": 100, "start_date": "2024-01-01T00:00:00Z", "end_date": "2024-01-31T00:00:00Z"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/budgets", strings.NewReader(budget)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"invalid budget", http.MethodPost, "/budgets", `{"category_id": 1, "amount": 100, "start_date": "2024-02-10T00:00:00Z", "end_date": "2024-02-01T00:00:00Z"}`, http.StatusUnprocessableEntity, "validation_failed", "end_date"},
+		{"overlapping budget", http.MethodPost, "/budgets", budget, http.StatusConflict, "conflict", ""},
+		{"missing category", http.MethodPost, "/budgets", `{"category_id": 42, "amount": 100, "start_date": "2024-03-01T00:00:00Z", "end_date": "2024-03-31T00:00:00Z"}`, http.StatusUnprocessableEntity, "validation_failed", "category_id"},
+		{"unknown expense", http.MethodGet, "/expenses/99", "", http.StatusNotFound, "not_found", ""},
+		{"invalid id", http.MethodGet, "/expenses/abc", "", http.StatusBadRequest, "bad_request", "id"},
+		{"wrong field type", http.MethodPost, "/expenses", `{"category_id": "food"}`, http.StatusBadRequest, "bad_request", "category_id"},
+		{"malformed body", http.MethodPost, "/expenses", `{`, http.StatusBadRequest, "bad_request", ""},
+		{"other category", http.MethodDelete, "/categories/1", "", http.StatusForbidden, "forbidden", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, rec.Code)
+			p := decodeProblem(t, rec)
+			assert.Equal(t, tt.code, p.Code)
+			assert.NotEmpty(t, p.Message)
+			if tt.field != "" {
+				assert.Contains(t, p.Fields, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertAndSummarize(t *testing.T) {
+	router, stores := newRouter(t)
+	_, err := stores.ExchangeRates.ImportExchangeRates([]models.ExchangeRate{
+		{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.10"},
+	})
+	if err != nil {
+		t.Fatalf("failed to import rates: %v", err)
+	}
+
+	body := `{"category_id": 1, "amount": 50, "currency": "eur", "date": "2024-03-02T00:00:00Z", "description": "Train"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?currency=USD", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var expenses []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expenses))
+	assert.Len(t, expenses, 1)
+	assert.Equal(t, "EUR", expenses[0].Currency)
+	assert.Equal(t, &models.Conversion{Amount: models.MustParseMoney("55.00"), Currency: "USD", Rate: "1.1"}, expenses[0].Converted)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/summary?currency=USD&from=2024-03-01&to=2024-03-31", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var summary models.Summary
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&summary))
+	assert.Equal(t, models.MustParseMoney("55.00"), summary.Expenses.Converted)
+	assert.Equal(t, models.MustParseMoney("-55.00"), summary.Net)
+	assert.Equal(t, []models.CurrencyAmount{{Currency: "EUR", Amount: models.MustParseMoney("50.00")}}, summary.Expenses.Original)
+}
+
+func TestSummaryWithoutExchangeRate(t *testing.T) {
+	router, _ := newRouter(t)
+
+	body := `{"category_id": 1, "amount": 50, "currency": "GBP", "date": "2024-03-02T00:00:00Z", "description": "Train"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/summary?currency=USD", nil))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestExchangeRatesCannotBeImportedOverHTTP(t *testing.T) {
+	router, stores := newRouter(t)
+
+	rates := "date,base_currency,quote_currency,rate\n2024-03-01,EUR,USD,1.10\n"
+	req := httptest.NewRequest(http.MethodPost, "/exchange-rates/import", strings.NewReader(rates))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	stored, err := stores.ExchangeRates.GetExchangeRates("", "")
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
+}
@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndGetExpense(t *testing.T) {
+	router, _ := newRouter(t)
+
+	date := time.Now().UTC().Truncate(time.Second).Format(time.RFC3339)
+	body := `{"category_id": 1, "amount": 42.5, "date": "` + date + `", "description": "Lunch"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var created models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "Lunch", created.Description)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var expenses []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expenses))
+	assert.Len(t, expenses, 1)
+	assert.Equal(t, created.ID, expenses[0].ID)
+}
+
+func TestGetExpenseNotFound(t *testing.T) {
+	router, _ := newRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses/99", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestListExpensesPaged(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for _, day := range []string{"2024-03-01", "2024-03-02", "2024-03-03"} {
+		body := `{"category_id": 1, "amount": 10, "date": "` + day + `T00:00:00Z", "description": "Lunch on ` + day + `"}`
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?sort=-date&limit=2&from=2024-03-01&q=lunch", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+	var expenses []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expenses))
+	if assert.Len(t, expenses, 2) {
+		assert.Equal(t, "Lunch on 2024-03-03", expenses[0].Description)
+	}
+}
+
+func TestListExpensesInvalidParameters(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for _, query := range []string{"sort=colour", "limit=0", "limit=5000", "offset=10", "min_amount=ten", "from=03/01/2024"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -0,0 +1,131 @@
+package api_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportRouter(t *testing.T) http.Handler {
+	router, stores := newRouter(t)
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+	assert.NoError(t, err)
+	for _, expense := range []models.Expense{
+		{CategoryID: category.ID, Amount: models.MustParseMoney("12.30"), Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Description: "Market, stall 4"},
+		{CategoryID: category.ID, Amount: models.MustParseMoney("99.00"), Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Description: "Wholesale"},
+	} {
+		_, err := stores.Expenses.CreateExpense(expense)
+		assert.NoError(t, err)
+	}
+	_, err = stores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("1500.00"), Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Source: "Salary"})
+	assert.NoError(t, err)
+	return router
+}
+
+func readZip(t *testing.T, body []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("export is not a ZIP archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		files[file.Name] = string(data)
+	}
+	return files
+}
+
+func TestExportJSON(t *testing.T) {
+	router := newExportRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?from=2024-03-01&to=2024-03-31", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="ledger.json"`, rec.Header().Get("Content-Disposition"))
+
+	var ledger struct {
+		From       string            `json:"from"`
+		Categories []models.Category `json:"categories"`
+		Expenses   []models.Expense  `json:"expenses"`
+		Incomes    []models.Income   `json:"incomes"`
+	}
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&ledger))
+	assert.Equal(t, "2024-03-01T00:00:00Z", ledger.From)
+	assert.NotEmpty(t, ledger.Categories)
+	if assert.Len(t, ledger.Expenses, 1) {
+		assert.Equal(t, "Market, stall 4", ledger.Expenses[0].Description)
+	}
+	assert.Len(t, ledger.Incomes, 1)
+}
+
+func TestExportCSV(t *testing.T) {
+	router := newExportRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?format=csv", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+
+	files := readZip(t, rec.Body.Bytes())
+	for _, name := range []string{"expenses.csv", "splits.csv", "incomes.csv", "budgets.csv", "categories.csv", "accounts.csv"} {
+		assert.Contains(t, files, name)
+	}
+	records, err := csv.NewReader(strings.NewReader(files["expenses.csv"])).ReadAll()
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	assert.Equal(t, []string{"id", "date", "description", "amount", "currency", "category_id", "category", "account_id", "account", "recurring_rule_id"}, records[0])
+	assert.Equal(t, "2024-03-05", records[1][1])
+	assert.Equal(t, "Market, stall 4", records[1][2])
+	assert.Equal(t, "12.30", records[1][3])
+	assert.Equal(t, "Groceries", records[1][6])
+	assert.Equal(t, "", records[1][7])
+}
+
+func TestExportXLSX(t *testing.T) {
+	router := newExportRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?format=xlsx&to=2024-03-31", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `attachment; filename="ledger.xlsx"`, rec.Header().Get("Content-Disposition"))
+
+	files := readZip(t, rec.Body.Bytes())
+	assert.Contains(t, files, "[Content_Types].xml")
+	assert.Contains(t, files["xl/workbook.xml"], `<sheet name="Expenses" sheetId="1" r:id="rId1"/>`)
+	sheet := files["xl/worksheets/sheet1.xml"]
+	// 2024-03-05 is day 45356 of the spreadsheet calendar
+	assert.Contains(t, sheet, `<c r="B2" s="1"><v>45356</v></c>`)
+	assert.Contains(t, sheet, `<c r="D2" s="2"><v>12.30</v></c>`)
+	assert.Contains(t, sheet, `<c r="G2" s="0" t="inlineStr"><is><t xml:space="preserve">Groceries</t></is></c>`)
+	assert.NotContains(t, sheet, "Wholesale")
+}
+
+func TestExportInvalidParameters(t *testing.T) {
+	router := newExportRouter(t)
+
+	for _, url := range []string{"/export?format=pdf", "/export?from=March"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+	}
+}
@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportCSVEndpoint(t *testing.T) {
+	router, stores := newRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/import-profiles", strings.NewReader(
+		`{"name": "Card", "date_column": "Posted", "date_format": "DD/MM/YYYY", "description_column": "Payee", "amount_column": "Amount", "sign_convention": "positive_expense"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var profile models.ImportProfile
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&profile))
+	url := "/imports/csv?profile_id=" + strconv.FormatInt(profile.ID, 10)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader("Posted,Payee,Amount\n09/03/2024,Airline,412.20\n31/02/2024,Hotel,90.00\n")))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	p := decodeProblem(t, rec)
+	assert.Equal(t, "1 of 2 rows cannot be imported", p.Message)
+	assert.Equal(t, map[string]string{"line 3": `invalid date "31/02/2024" (expected DD/MM/YYYY)`}, p.Fields)
+
+	csv := "Posted,Payee,Amount\n09/03/2024,Airline,412.20\n10/03/2024,Payment received,-500.00\n"
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url+"&dry_run=true", strings.NewReader(csv)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var report models.StatementImport
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Expenses)
+	assert.Equal(t, 1, report.Incomes)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader(csv)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	expenses, err := stores.Expenses.GetExpenses()
+	assert.NoError(t, err)
+	if assert.Len(t, expenses, 1) {
+		assert.Equal(t, "Airline", expenses[0].Description)
+		assert.Equal(t, models.MustParseMoney("412.20"), expenses[0].Amount)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/imports/csv?profile_id=999", strings.NewReader(csv)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader("Date,Payee,Amount\n")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestImportOFXEndpoint(t *testing.T) {
+	router, stores := newRouter(t)
+	account, err := stores.Accounts.CreateAccount(models.Account{Name: "Checking", Type: models.AccountChecking, Currency: "EUR"})
+	assert.NoError(t, err)
+	url := "/imports/ofx?account_id=" + strconv.FormatInt(account.ID, 10)
+
+	ofx := `<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>EUR</CURDEF><BANKACCTFROM><ACCTID>DE89</ACCTID></BANKACCTFROM>
+<BANKTRANLIST><STMTTRN><DTPOSTED>20240305</DTPOSTED><TRNAMT>-42.50</TRNAMT><FITID>T-1</FITID><NAME>Bakery</NAME></STMTTRN></BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+	for _, want := range []int{1, 0} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, url, strings.NewReader(ofx)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		var report models.StatementImport
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		assert.Equal(t, want, report.Expenses)
+		assert.Equal(t, 1-want, report.Duplicates)
+	}
+	account, err = stores.Accounts.GetAccountByID(account.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("-42.50"), account.Balance)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/imports/mt940", strings.NewReader(ofx)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestImportStatementTooLarge(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for _, format := range []string{"ofx", "qif"} {
+		body := strings.Repeat("NBakery\n", models.MaxStatementSize/8+1)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/imports/"+format, strings.NewReader(body)))
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, format)
+		assert.Equal(t, "statement is too large (max 10 MiB)", decodeProblem(t, rec).Fields["file"], format)
+	}
+}
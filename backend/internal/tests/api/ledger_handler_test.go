@@ -0,0 +1,174 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/api"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serve sends a request through router and returns the recorded response.
+func serve(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+// invite has the owner invite a user to a ledger with a role, and the user
+// accept the invitation.
+func invite(t *testing.T, owner, user http.Handler, ledgerID int64, role models.Role) {
+	rec := serve(owner, http.MethodPost, "/ledgers/"+strconv.FormatInt(ledgerID, 10)+"/invitations", `{"role": "`+string(role)+`"}`)
+	var invitation models.Invitation
+	if err := json.NewDecoder(rec.Body).Decode(&invitation); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("failed to invite a %s: %d %v", role, rec.Code, err)
+	}
+	rec = serve(user, http.MethodPost, "/invitations/accept", `{"token": "`+invitation.Token+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to accept invitation: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLedgerEndpoints(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+	annToken, ann := signIn(t, router, "ann@example.com")
+	bobToken, bob := signIn(t, router, "bob@example.com")
+	owner, member := withToken(router, annToken), withToken(router, bobToken)
+
+	rec := serve(owner, http.MethodGet, "/ledgers", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var ledgers []models.Ledger
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&ledgers))
+	if assert.Len(t, ledgers, 1) {
+		assert.Equal(t, "Personal", ledgers[0].Name)
+		assert.Equal(t, models.RoleOwner, ledgers[0].Role)
+	}
+
+	rec = serve(owner, http.MethodPost, "/ledgers", `{"name": ""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = serve(owner, http.MethodPost, "/ledgers", `{"name": "Household"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var household models.Ledger
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&household))
+	prefix := "/ledgers/" + strconv.FormatInt(household.ID, 10)
+
+	// Non-members cannot tell the ledger exists
+	for _, path := range []string{prefix + "/members", prefix + "/expenses"} {
+		rec = serve(member, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+	rec = serve(member, http.MethodPost, prefix+"/invitations", `{"role": "owner"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(member, http.MethodPost, "/invitations/accept", `{"token": "not-a-token"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serve(owner, http.MethodPost, prefix+"/invitations", `{"role": "admin"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	invite(t, owner, member, household.ID, models.RoleViewer)
+
+	rec = serve(member, http.MethodGet, prefix+"/members", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var members []models.Member
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&members))
+	if assert.Len(t, members, 2) {
+		assert.Equal(t, ann.ID, members[0].UserID)
+		assert.Equal(t, models.RoleViewer, members[1].Role)
+	}
+
+	// Only owners manage members, and a ledger keeps an owner
+	bobPath := prefix + "/members/" + strconv.FormatInt(bob.ID, 10)
+	annPath := prefix + "/members/" + strconv.FormatInt(ann.ID, 10)
+	rec = serve(member, http.MethodPut, bobPath, `{"role": "owner"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(member, http.MethodPost, prefix+"/invitations", `{"role": "viewer"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(member, http.MethodDelete, annPath, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(owner, http.MethodDelete, annPath, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = serve(owner, http.MethodPut, bobPath, `{"role": "editor"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Members can leave
+	rec = serve(member, http.MethodDelete, bobPath, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(member, http.MethodGet, prefix+"/expenses", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLedgerRoles(t *testing.T) {
+	router := api.NewRouter(store.NewMemoryBackend())
+	annToken, _ := signIn(t, router, "ann@example.com")
+	bobToken, _ := signIn(t, router, "bob@example.com")
+	carolToken, _ := signIn(t, router, "carol@example.com")
+	owner, editor, viewer := withToken(router, annToken), withToken(router, bobToken), withToken(router, carolToken)
+
+	rec := serve(owner, http.MethodPost, "/ledgers", `{"name": "Household"}`)
+	var household models.Ledger
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&household))
+	prefix := "/ledgers/" + strconv.FormatInt(household.ID, 10)
+	invite(t, owner, editor, household.ID, models.RoleEditor)
+	invite(t, owner, viewer, household.ID, models.RoleViewer)
+
+	// Editors change the shared data
+	rec = serve(editor, http.MethodPost, prefix+"/categories", `{"name": "Groceries"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var groceries models.Category
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&groceries))
+	categoryPath := prefix + "/categories/" + strconv.FormatInt(groceries.ID, 10)
+	rec = serve(editor, http.MethodPost, prefix+"/budgets", `{"category_id": `+strconv.FormatInt(groceries.ID, 10)+
+		`, "amount": 400, "start_date": "2024-03-01T00:00:00Z", "end_date": "2024-03-31T00:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var budget models.Budget
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&budget))
+	budgetPath := prefix + "/budgets/" + strconv.FormatInt(budget.ID, 10)
+	rec = serve(editor, http.MethodPost, prefix+"/expenses", `{"category_id": `+strconv.FormatInt(groceries.ID, 10)+
+		`, "amount": 42, "date": "2024-03-02T00:00:00Z", "description": "Market"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// Everyone reads it, but not through their personal ledger
+	for _, user := range []http.Handler{owner, editor, viewer} {
+		rec = serve(user, http.MethodGet, prefix+"/expenses", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+		rec = serve(user, http.MethodGet, "/expenses", "")
+		assert.Equal(t, "0", rec.Header().Get("X-Total-Count"))
+	}
+
+	// Viewers can only read
+	for _, req := range [][3]string{
+		{http.MethodPost, prefix + "/expenses", `{"category_id": 1, "amount": 1, "date": "2024-03-02T00:00:00Z"}`},
+		{http.MethodPut, categoryPath, `{"name": "Food"}`},
+		{http.MethodDelete, budgetPath, ""},
+	} {
+		rec = serve(viewer, req[0], req[1], req[2])
+		assert.Equal(t, http.StatusForbidden, rec.Code, req[0]+" "+req[1])
+		assert.Equal(t, "forbidden", decodeProblem(t, rec).Code)
+	}
+
+	// Only owners delete categories and budgets
+	rec = serve(editor, http.MethodDelete, budgetPath, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "only owners can delete budgets", decodeProblem(t, rec).Message)
+	rec = serve(editor, http.MethodDelete, categoryPath, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(editor, http.MethodPost, categoryPath+"/merge-into/1", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(owner, http.MethodDelete, budgetPath, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = serve(owner, http.MethodDelete, categoryPath, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	// In their own ledger, everyone is an owner
+	rec = serve(viewer, http.MethodPost, "/categories", `{"name": "Books"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = serve(viewer, http.MethodGet, "/ledgers/abc/expenses", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRecurringRuleGeneratesDueExpenses(t *testing.T) {
+	router, _ := newRouter(t)
+
+	start := time.Now().UTC().AddDate(0, 0, -2).Format("2006-01-02")
+	body := `{"kind": "expense", "frequency": "daily", "start_date": "` + start + `T00:00:00Z", "category_id": 1, "amount": 3.5, "description": "Coffee"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/recurring-rules", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var rule models.RecurringRule
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&rule))
+	assert.Equal(t, 1, rule.Interval)
+	if assert.NotNil(t, rule.NextDate) {
+		assert.True(t, rule.NextDate.After(time.Now().UTC().AddDate(0, 0, -1)))
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses", nil))
+	var expenses []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expenses))
+	assert.Len(t, expenses, 3)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recurring-rules/"+strconv.FormatInt(rule.ID, 10)+"/occurrences?count=2", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var dates []time.Time
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&dates))
+	assert.Len(t, dates, 2)
+	assert.Equal(t, 24*time.Hour, dates[1].Sub(dates[0]))
+}
+
+func TestCreateRecurringRuleValidation(t *testing.T) {
+	router, _ := newRouter(t)
+
+	body := `{"kind": "expense", "frequency": "hourly", "start_date": "2024-01-01T00:00:00Z", "category_id": 1, "amount": 3.5, "description": "Coffee"}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/recurring-rules", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "frequency must be daily, weekly, monthly or yearly")
+}
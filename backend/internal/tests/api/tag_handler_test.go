@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagEndpoints(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for _, body := range []string{
+		`{"description": "Hotel", "amount": "200.00", "date": "2024-03-02T00:00:00Z", "tags": ["Vacation", "reimbursable"]}`,
+		`{"description": "Museum", "amount": "30.00", "date": "2024-03-03T00:00:00Z", "tags": ["vacation"]}`,
+		`{"description": "Coffee", "amount": "5.00", "date": "2024-03-04T00:00:00Z"}`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/expenses", strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	// Repeated and comma-separated tag parameters must all match
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?tag=vacation", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?tag=Vacation,reimbursable", nil))
+	var expenses []models.Expense
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&expenses))
+	if assert.Len(t, expenses, 1) {
+		assert.Equal(t, []string{"reimbursable", "vacation"}, expenses[0].Tags)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags", nil))
+	var tags []models.Tag
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&tags))
+	assert.Len(t, tags, 2)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tags", strings.NewReader(`{"name": "  "}`)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tags", strings.NewReader(`{"name": "vacation"}`)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/summary?from=2024-03-01&to=2024-03-31", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var summary []models.TagTotal
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&summary))
+	if assert.Len(t, summary, 2) {
+		assert.Equal(t, "vacation", summary[1].Tag)
+		assert.Equal(t, models.MustParseMoney("230.00"), summary[1].Expenses.Converted)
+	}
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/summary?from=March", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/tags/"+strconv.FormatInt(tags[1].ID, 10), strings.NewReader(`{"name": "Holiday"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/expenses?tag=holiday", nil))
+	assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/tags/"+strconv.FormatInt(tags[1].ID, 10), nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/"+strconv.FormatInt(tags[1].ID, 10), nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTransactions(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for path, body := range map[string]string{
+		"/incomes":  `{"amount": 1000, "date": "2024-03-01T00:00:00Z", "source": "Salary"}`,
+		"/expenses": `{"category_id": 1, "amount": 42.5, "date": "2024-03-02T00:00:00Z", "description": "Lunch"}`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?sort=-date&limit=1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("X-Total-Count"))
+	var transactions []models.Transaction
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&transactions))
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, models.TransactionExpense, transactions[0].Type)
+		assert.Equal(t, "Other", transactions[0].Category)
+		assert.Equal(t, models.MustParseMoney("-42.50"), transactions[0].Amount)
+		assert.Equal(t, models.MustParseMoney("957.50"), transactions[0].Balance)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?sort=source", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestListTransactionsByTag(t *testing.T) {
+	router, _ := newRouter(t)
+
+	for path, body := range map[string]string{
+		"/incomes":  `{"amount": 1000, "date": "2024-03-01T00:00:00Z", "source": "Salary"}`,
+		"/expenses": `{"category_id": 1, "amount": 200, "date": "2024-03-02T00:00:00Z", "description": "Hotel", "tags": ["vacation-2026"]}`,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?tag=Vacation-2026", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Total-Count"))
+	var transactions []models.Transaction
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&transactions))
+	if assert.Len(t, transactions, 1) {
+		assert.Equal(t, "Hotel", transactions[0].Description)
+		assert.Equal(t, models.MustParseMoney("-200.00"), transactions[0].Balance)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?tag=vacation-2026,reimbursable", nil))
+	assert.Equal(t, "0", rec.Header().Get("X-Total-Count"))
+}
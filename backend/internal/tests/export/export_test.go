@@ -0,0 +1,58 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"expense-tracker/internal/export"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedExpenses records the options of every expense list.
+type pagedExpenses struct {
+	store.ExpenseStore
+	calls []models.ListOptions
+}
+
+func (p *pagedExpenses) ListExpenses(opts models.ListOptions) ([]models.Expense, int, error) {
+	p.calls = append(p.calls, opts)
+	return p.ExpenseStore.ListExpenses(opts)
+}
+
+func TestOpenReadsExpensesByPage(t *testing.T) {
+	stores := store.NewMemory().Stores()
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 1001 {
+		if _, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("1.00"), Date: day.AddDate(0, 0, i%28), Description: "Coffee"}); err != nil {
+			t.Fatalf("failed to create expense: %v", err)
+		}
+	}
+	expenses := &pagedExpenses{ExpenseStore: stores.Expenses}
+	stores.Expenses = expenses
+
+	ledger, err := export.Open(stores, time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, expenses.calls)
+
+	var buf bytes.Buffer
+	assert.NoError(t, export.WriteJSON(&buf, ledger))
+	var written struct {
+		Expenses []models.Expense `json:"expenses"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &written))
+	if assert.Len(t, written.Expenses, 1001) {
+		for i := 1; i < len(written.Expenses); i++ {
+			assert.False(t, written.Expenses[i].Date.Before(written.Expenses[i-1].Date))
+		}
+	}
+	if assert.Len(t, expenses.calls, 3) {
+		for i, opts := range expenses.calls {
+			assert.Equal(t, 500, opts.Limit)
+			assert.Equal(t, 500*i, opts.Offset)
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// ledgerID is the user the queries under test are scoped to.
+const ledgerID int64 = 7
+
 func TestBudgetExpenseIntegration(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -18,12 +21,17 @@ func TestBudgetExpenseIntegration(t *testing.T) {
 
 	t.Run("Complete Budget-Expense Workflow", func(t *testing.T) {
 		// Step 1: Create Category
-		mock.ExpectQuery(`INSERT INTO Category \(name, description\) VALUES \(\$1, \$2\) RETURNING id`).
-			WithArgs("Groceries", "Food and household items").
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = 1 AND name = \$1\)`).
+			WithArgs("Groceries").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery(`INSERT INTO Category \(name, description, parent_id, ledger_id\) VALUES \(\$1, \$2, \$3, \$4\) RETURNING id`).
+			WithArgs("Groceries", "Food and household items", nil, ledgerID).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
 
 		category := models.Category{Name: "Groceries", Description: "Food and household items"}
-		createdCategory, err := models.CreateCategory(db, category)
+		createdCategory, err := models.CreateCategory(db, ledgerID, category)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), createdCategory.ID)
 
@@ -31,68 +39,82 @@ func TestBudgetExpenseIntegration(t *testing.T) {
 		startDate := time.Now().AddDate(0, -1, 0)
 		endDate := time.Now().AddDate(0, 1, 0)
 
+		// Mock the check that the category is the ledger's
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = \$1 AND \(ledger_id = \$2 OR id = 1\)\)`).
+			WithArgs(createdCategory.ID, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
 		// Mock budget overlap check
-		mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE category_id = \$1 AND id <> \$4 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
-			WithArgs(createdCategory.ID, startDate, endDate, 0).
+		mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE \(category_id IN \(SELECT id FROM subcategories\) OR category_id IN \(SELECT id FROM parent_categories\)\) AND id <> \$4 AND ledger_id = \$5 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
+			WithArgs(createdCategory.ID, startDate, endDate, 0, ledgerID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 		// Mock total spent calculation (initially 0)
-		mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM Expense WHERE category_id = \$1 AND date >= \$2 AND date <= \$3`).
-			WithArgs(createdCategory.ID, startDate, endDate).
-			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0.0))
+		mock.ExpectQuery(`SELECT currency, date, COALESCE\(SUM\(amount\), 0\) FROM expense_allocations WHERE category_id IN \(SELECT id FROM subcategories\) AND date >= \$2 AND date <= \$3 AND ledger_id = \$4 GROUP BY currency, date`).
+			WithArgs(createdCategory.ID, startDate, endDate, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "date", "sum"}))
 
 		// Mock budget creation
-		mock.ExpectQuery(`INSERT INTO Budget \(category_id, amount, spent, start_date, end_date\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING id`).
-			WithArgs(createdCategory.ID, 500.0, 0.0, startDate, endDate).
+		mock.ExpectQuery(`INSERT INTO Budget \(category_id, amount, spent, currency, start_date, end_date, ledger_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) RETURNING id`).
+			WithArgs(createdCategory.ID, models.MustParseMoney("500.00"), models.MustParseMoney("0.00"), "USD", startDate, endDate, ledgerID).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 		budget := models.Budget{
 			CategoryID: createdCategory.ID,
-			Amount:     500.0,
+			Amount:     models.MustParseMoney("500.00"),
 			StartDate:  startDate,
 			EndDate:    endDate,
 		}
-		createdBudget, err := models.CreateBudget(db, budget)
+		createdBudget, err := models.CreateBudget(db, ledgerID, budget)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), createdBudget.ID)
-		assert.Equal(t, float64(0.0), createdBudget.Spent)
+		assert.Equal(t, models.MustParseMoney("0.00"), createdBudget.Spent)
 
 		// Step 3: Create Expense
-		mock.ExpectQuery(`INSERT INTO Expense \(category_id, amount, date, description\) VALUES \(\$1, \$2, \$3, \$4\) RETURNING id, category_id, amount, date, description`).
-			WithArgs(createdCategory.ID, 100.0, sqlmock.AnyArg(), "Weekly groceries").
-			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-				AddRow(1, createdCategory.ID, 100.0, time.Now(), "Weekly groceries"))
-
-		// Mock budget existence check
-		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM Budget WHERE category_id = \$1`).
-			WithArgs(createdCategory.ID).
-			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = \$1 AND \(ledger_id = \$2 OR id = 1\)\)`).
+			WithArgs(createdCategory.ID, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`INSERT INTO Expense \(category_id, amount, currency, date, description, recurring_rule_id, account_id, ledger_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8\) RETURNING id, category_id, amount, currency, date, description, recurring_rule_id, account_id`).
+			WithArgs(createdCategory.ID, models.MustParseMoney("100.00"), "USD", sqlmock.AnyArg(), "Weekly groceries", nil, nil, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+				AddRow(1, createdCategory.ID, 100.0, "USD", time.Now(), "Weekly groceries", nil, nil))
+
+		// Mock lookup of the budgets covering the expense date
+		mock.ExpectQuery(`SELECT id, currency FROM Budget WHERE category_id IN \(SELECT id FROM parent_categories\) AND start_date <= \$2 AND end_date >= \$2 AND ledger_id = \$3`).
+			WithArgs(createdCategory.ID, sqlmock.AnyArg(), ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
 
 		// Mock budget spent update
-		mock.ExpectExec(`UPDATE Budget SET spent = spent \+ \$1 WHERE category_id = \$2`).
-			WithArgs(100.0, createdCategory.ID).
+		mock.ExpectExec(`UPDATE Budget SET spent = spent \+ \$1 WHERE id = \$2`).
+			WithArgs(models.MustParseMoney("100.00"), int64(1)).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
+		// Mock the search for possible duplicates
+		mock.ExpectQuery(`SELECT (.+) FROM Expense WHERE id <> \$1 AND currency = \$2 AND amount = \$3`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}))
+		mock.ExpectCommit()
+
 		expense := models.Expense{
 			CategoryID:  createdCategory.ID,
-			Amount:      100.0,
+			Amount:      models.MustParseMoney("100.00"),
 			Date:        time.Now(),
 			Description: "Weekly groceries",
 		}
-		createdExpense, err := models.CreateExpense(db, expense)
+		createdExpense, err := models.CreateExpense(db, ledgerID, expense)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), createdExpense.ID)
 
 		// Step 4: Verify Budget Update
-		mock.ExpectQuery(`SELECT id, category_id, amount, spent, start_date, end_date FROM Budget WHERE category_id = \$1`).
-			WithArgs(createdCategory.ID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "start_date", "end_date"}).
-				AddRow(1, createdCategory.ID, 500.0, 100.0, startDate, endDate))
+		mock.ExpectQuery(`SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id = \$1 AND ledger_id = \$2`).
+			WithArgs(createdCategory.ID, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}).
+				AddRow(1, createdCategory.ID, 500.0, 100.0, "USD", startDate, endDate, false))
 
-		updatedBudgets, err := models.GetBudgetsByCategoryID(db, createdCategory.ID)
+		updatedBudgets, err := models.GetBudgetsByCategoryID(db, ledgerID, createdCategory.ID)
 		assert.NoError(t, err)
 		assert.Len(t, updatedBudgets, 1)
-		assert.Equal(t, float64(100.0), updatedBudgets[0].Spent)
+		assert.Equal(t, models.MustParseMoney("100.00"), updatedBudgets[0].Spent)
 	})
 
 	// Verify all expectations were met
@@ -21,62 +21,86 @@ func TestCategoryBudgetIntegration(t *testing.T) {
 	t.Run("Category Deletion Cascade", func(t *testing.T) {
 		// 1. Setup - Create Category with Budget
 		categoryRows := sqlmock.NewRows([]string{"id"}).AddRow(2)
-		mock.ExpectQuery(`INSERT INTO Category \(name, description\) VALUES \(\$1, \$2\) RETURNING id`).
-			WithArgs("Entertainment", "Entertainment expenses").
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = 1 AND name = \$1\)`).
+			WithArgs("Entertainment").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectQuery(`INSERT INTO Category \(name, description, parent_id, ledger_id\) VALUES \(\$1, \$2, \$3, \$4\) RETURNING id`).
+			WithArgs("Entertainment", "Entertainment expenses", nil, ledgerID).
 			WillReturnRows(categoryRows)
+		mock.ExpectCommit()
 
 		category := models.Category{
 			Name:        "Entertainment",
 			Description: "Entertainment expenses",
 		}
-		createdCategory, err := models.CreateCategory(db, category)
+		createdCategory, err := models.CreateCategory(db, ledgerID, category)
 		assert.NoError(t, err)
 
+		// Mock the check that the category is the ledger's
+		mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = \$1 AND \(ledger_id = \$2 OR id = 1\)\)`).
+			WithArgs(createdCategory.ID, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
 		// Mock budget overlap check
 		startDate := time.Now()
 		endDate := startDate.AddDate(0, 1, 0)
-		mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE category_id = \$1 AND id <> \$4 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
-			WithArgs(createdCategory.ID, startDate, endDate, 0).
+		mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE \(category_id IN \(SELECT id FROM subcategories\) OR category_id IN \(SELECT id FROM parent_categories\)\) AND id <> \$4 AND ledger_id = \$5 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
+			WithArgs(createdCategory.ID, startDate, endDate, 0, ledgerID).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 		// Mock total spent calculation
-		mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM Expense WHERE category_id = \$1 AND date >= \$2 AND date <= \$3`).
-			WithArgs(createdCategory.ID, startDate, endDate).
-			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0.0))
+		mock.ExpectQuery(`SELECT currency, date, COALESCE\(SUM\(amount\), 0\) FROM expense_allocations WHERE category_id IN \(SELECT id FROM subcategories\) AND date >= \$2 AND date <= \$3 AND ledger_id = \$4 GROUP BY currency, date`).
+			WithArgs(createdCategory.ID, startDate, endDate, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"currency", "date", "sum"}))
 
 		// Mock budget creation
 		budgetRows := sqlmock.NewRows([]string{"id"}).AddRow(1)
-		mock.ExpectQuery(`INSERT INTO Budget \(category_id, amount, spent, start_date, end_date\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING id`).
-			WithArgs(createdCategory.ID, 300.0, 0.0, startDate, endDate).
+		mock.ExpectQuery(`INSERT INTO Budget \(category_id, amount, spent, currency, start_date, end_date, ledger_id\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) RETURNING id`).
+			WithArgs(createdCategory.ID, models.MustParseMoney("300.00"), models.MustParseMoney("0.00"), "USD", startDate, endDate, ledgerID).
 			WillReturnRows(budgetRows)
 
 		budget := models.Budget{
 			CategoryID: createdCategory.ID,
-			Amount:     300.0,
+			Amount:     models.MustParseMoney("300.00"),
 			StartDate:  startDate,
 			EndDate:    endDate,
 		}
-		_, err = models.CreateBudget(db, budget)
+		_, err = models.CreateBudget(db, ledgerID, budget)
 		assert.NoError(t, err)
 
 		// 2. Delete Category (should cascade to budget)
-		mock.ExpectExec(`UPDATE Expense SET category_id = 1 WHERE category_id = \$1`).
-			WithArgs(createdCategory.ID).
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT parent_id FROM Category WHERE id = \$1 AND ledger_id = \$2`).
+			WithArgs(createdCategory.ID, ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+		mock.ExpectExec(`UPDATE Category SET parent_id = \$1 WHERE parent_id = \$2`).
+			WithArgs(nil, createdCategory.ID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE Expense SET category_id = \$1 WHERE category_id = \$2`).
+			WithArgs(int64(1), createdCategory.ID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE ExpenseSplit SET category_id = \$1 WHERE category_id = \$2`).
+			WithArgs(int64(1), createdCategory.ID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
 
 		mock.ExpectExec(`DELETE FROM Category WHERE id = \$1`).
 			WithArgs(createdCategory.ID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id IN \(SELECT id FROM parent_categories\) AND ledger_id = \$2`).
+			WithArgs(int64(1), ledgerID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}))
+		mock.ExpectCommit()
 
-		err = models.DeleteCategory(db, createdCategory.ID)
+		err = models.DeleteCategory(db, ledgerID, createdCategory.ID)
 		assert.NoError(t, err)
 
 		// 3. Verify Budget is deleted (should return no rows)
-		mock.ExpectQuery(`SELECT id, category_id, amount, spent, start_date, end_date FROM Budget WHERE category_id = \$1`).
-			WithArgs(createdCategory.ID).
+		mock.ExpectQuery(`SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id = \$1 AND ledger_id = \$2`).
+			WithArgs(createdCategory.ID, ledgerID).
 			WillReturnError(sql.ErrNoRows)
 
-		_, err = models.GetBudgetsByCategoryID(db, createdCategory.ID)
+		_, err = models.GetBudgetsByCategoryID(db, ledgerID, createdCategory.ID)
 		assert.Error(t, err)
 		assert.True(t, errors.Is(err, sql.ErrNoRows), "Expected sql.ErrNoRows, got: %v", err)
 	})
@@ -0,0 +1,129 @@
+package migrations_test
+
+import (
+	"expense-tracker/internal/migrations"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testMigrations = []migrations.Migration{
+	{Version: 1, Name: "create_widget", Up: "CREATE TABLE Widget (id INT)", Down: "DROP TABLE Widget"},
+	{Version: 2, Name: "add_widget_name", Up: "ALTER TABLE Widget ADD COLUMN name TEXT", Down: "ALTER TABLE Widget DROP COLUMN name"},
+}
+
+func TestLoadPostgresMigrations(t *testing.T) {
+	loaded, err := migrations.Load("postgres")
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, loaded)
+	for i, migration := range loaded {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+	assert.Equal(t, "initial_schema", loaded[0].Name)
+}
+
+func TestLoadUnknownDialect(t *testing.T) {
+	_, err := migrations.Load("oracle")
+
+	assert.Error(t, err)
+}
+
+func TestMigrateUpAppliesPendingMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations ORDER BY version").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE Widget ADD COLUMN name TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations \\(version, name, applied_at\\) VALUES \\(\\$1, \\$2, \\$3\\)").
+		WithArgs(2, "add_widget_name", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := migrations.NewWithMigrations(db, testMigrations).Up()
+
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateUpRollsBackFailedMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations ORDER BY version").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE Widget").WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+
+	applied, err := migrations.NewWithMigrations(db, testMigrations).Up()
+
+	assert.Error(t, err)
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateDownRevertsLatestMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations ORDER BY version").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE Widget DROP COLUMN name").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, ok, err := migrations.NewWithMigrations(db, testMigrations).Down()
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, reverted.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	appliedAt := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations ORDER BY version").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	statuses, err := migrations.NewWithMigrations(db, testMigrations).Status()
+
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.Equal(t, appliedAt, statuses[0].AppliedAt)
+	assert.False(t, statuses[1].Applied)
+}
@@ -0,0 +1,40 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceHistoryByWeek(t *testing.T) {
+	// 2024-03-06 is a Wednesday; weeks start on Monday
+	starts, err := models.BalancePeriods(date(2024, 3, 6), date(2024, 3, 18), models.IntervalWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{date(2024, 3, 4), date(2024, 3, 11), date(2024, 3, 18)}, starts)
+
+	movements := []models.AccountMovement{
+		{Date: date(2024, 2, 20), Inflow: models.MustParseMoney("50.00")},
+		{Date: date(2024, 3, 4), Outflow: models.MustParseMoney("20.00")},
+		{Date: date(2024, 3, 10), Inflow: models.MustParseMoney("5.00"), Outflow: models.MustParseMoney("1.00")},
+		{Date: date(2024, 3, 19), Outflow: models.MustParseMoney("4.00")},
+	}
+	history := models.BalanceHistory(models.MustParseMoney("10.00"), movements, starts, models.IntervalWeek)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, date(2024, 3, 10), history[0].End)
+		assert.Equal(t, models.MustParseMoney("5.00"), history[0].Inflow)
+		assert.Equal(t, models.MustParseMoney("21.00"), history[0].Outflow)
+		assert.Equal(t, models.MustParseMoney("44.00"), history[0].Balance)
+		assert.Equal(t, models.MustParseMoney("44.00"), history[1].Balance)
+		assert.Equal(t, models.MustParseMoney("40.00"), history[2].Balance)
+	}
+}
+
+func TestBalancePeriodsValidation(t *testing.T) {
+	_, err := models.BalancePeriods(date(2024, 1, 1), date(2024, 2, 1), "quarter")
+	assert.EqualError(t, err, "interval must be day, week or month")
+
+	_, err = models.BalancePeriods(date(2020, 1, 1), date(2024, 1, 1), models.IntervalDay)
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
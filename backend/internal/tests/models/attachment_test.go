@@ -0,0 +1,30 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAttachment(t *testing.T) {
+	attachment, err := models.NormalizeAttachment(models.Attachment{FileName: " ../../etc/receipt.PDF ", ContentType: "Text/Plain; Charset=UTF-8", Size: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "receipt.PDF", attachment.FileName)
+	assert.Equal(t, "text/plain; charset=UTF-8", attachment.ContentType)
+
+	attachment, err = models.NormalizeAttachment(models.Attachment{FileName: "scan", Size: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", attachment.ContentType)
+
+	for _, attachment := range []models.Attachment{
+		{FileName: "", ContentType: "image/png", Size: 10},
+		{FileName: "/", ContentType: "image/png", Size: 10},
+		{FileName: "scan.png", ContentType: "image/", Size: 10},
+		{FileName: "scan.png", ContentType: "image/png", Size: 0},
+		{FileName: "scan.png", ContentType: "image/png", Size: models.MaxAttachmentSize + 1},
+	} {
+		_, err := models.NormalizeAttachment(attachment)
+		assert.ErrorIs(t, err, models.ErrValidation, attachment.FileName)
+	}
+}
@@ -1,7 +1,6 @@
 package models_test
 
 import (
-	"errors"
 	"expense-tracker/internal/models"
 	"testing"
 	"time"
@@ -10,6 +9,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// ledgerID is the user the queries under test are scoped to.
+const ledgerID int64 = 7
+
 func TestGetBudgets(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -17,21 +19,22 @@ func TestGetBudgets(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "start_date", "end_date"}).
-		AddRow(1, int64(1), 500.0, 200.0, time.Now(), time.Now().Add(30*time.Hour*24)).
-		AddRow(2, int64(2), 300.0, 100.0, time.Now(), time.Now().Add(30*time.Hour*24))
+	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}).
+		AddRow(1, int64(1), 500.0, 200.0, "USD", time.Now(), time.Now().Add(30*time.Hour*24), false).
+		AddRow(2, int64(2), 300.0, 100.0, "EUR", time.Now(), time.Now().Add(30*time.Hour*24), false)
 
-	mock.ExpectQuery("SELECT id, category_id, amount, spent, start_date, end_date FROM Budget").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget").WillReturnRows(rows)
 
-	budgets, err := models.GetBudgets(db)
+	budgets, err := models.GetBudgets(db, ledgerID)
 
 	assert.NoError(t, err)
 	assert.Len(t, budgets, 2)
 	assert.Equal(t, int64(1), budgets[0].CategoryID) // Updated to int64
-	assert.Equal(t, float64(500), budgets[0].Amount)
-	assert.Equal(t, float64(200), budgets[0].Spent)
+	assert.Equal(t, models.MustParseMoney("500.00"), budgets[0].Amount)
+	assert.Equal(t, models.MustParseMoney("200.00"), budgets[0].Spent)
 	assert.Equal(t, int64(2), budgets[1].CategoryID) // Updated to int64
-	assert.Equal(t, float64(300), budgets[1].Amount)
+	assert.Equal(t, models.MustParseMoney("300.00"), budgets[1].Amount)
+	assert.Equal(t, "EUR", budgets[1].Currency)
 }
 
 func TestGetBudgetByCategory(t *testing.T) {
@@ -41,20 +44,20 @@ func TestGetBudgetByCategory(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "start_date", "end_date"}).
-		AddRow(1, int64(1), 500.0, 200.0, time.Now(), time.Now().Add(30*time.Hour*24))
+	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}).
+		AddRow(1, int64(1), 500.0, 200.0, "USD", time.Now(), time.Now().Add(30*time.Hour*24), false)
 
-	mock.ExpectQuery("SELECT id, category_id, amount, spent, start_date, end_date FROM Budget WHERE category_id = \\$1").
-		WithArgs(int64(1)).
+	mock.ExpectQuery("SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id = \\$1 AND ledger_id = \\$2").
+		WithArgs(int64(1), ledgerID).
 		WillReturnRows(rows)
 
-	budgets, err := models.GetBudgetsByCategoryID(db, 1)
+	budgets, err := models.GetBudgetsByCategoryID(db, ledgerID, 1)
 
 	assert.NoError(t, err)
 	assert.Len(t, budgets, 1)
 	assert.Equal(t, int64(1), budgets[0].CategoryID)
-	assert.Equal(t, float64(500), budgets[0].Amount)
-	assert.Equal(t, float64(200), budgets[0].Spent)
+	assert.Equal(t, models.MustParseMoney("500.00"), budgets[0].Amount)
+	assert.Equal(t, models.MustParseMoney("200.00"), budgets[0].Spent)
 }
 func TestCreateBudget(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -63,35 +66,40 @@ func TestCreateBudget(t *testing.T) {
 	}
 	defer db.Close()
 
+	// Mock the check that the category is the ledger's
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = \$1 AND \(ledger_id = \$2 OR id = 1\)\)`).
+		WithArgs(int64(1), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
 	// Mock DoesBudgetOverlap query
-	mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE category_id = \$1 AND id <> \$4 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
-		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(0)).
+	mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE \(category_id IN \(SELECT id FROM subcategories\) OR category_id IN \(SELECT id FROM parent_categories\)\) AND id <> \$4 AND ledger_id = \$5 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
+		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(0), ledgerID).
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	// Mock CalculateTotalSpent query
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM Expense WHERE category_id = \\$1 AND date >= \\$2 AND date <= \\$3").
-		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"total_spent"}).AddRow(200.0))
+	mock.ExpectQuery("SELECT currency, date, COALESCE\\(SUM\\(amount\\), 0\\) FROM expense_allocations WHERE category_id IN \\(SELECT id FROM subcategories\\) AND date >= \\$2 AND date <= \\$3 AND ledger_id = \\$4 GROUP BY currency, date").
+		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "date", "total_spent"}).AddRow("USD", time.Now(), 200.0))
 
 	// Mock Insert query
-	mock.ExpectQuery("INSERT INTO Budget \\(category_id, amount, spent, start_date, end_date\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5\\) RETURNING id").
-		WithArgs(int64(1), 500.0, 200.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mock.ExpectQuery("INSERT INTO Budget \\(category_id, amount, spent, currency, start_date, end_date, ledger_id\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7\\) RETURNING id").
+		WithArgs(int64(1), models.MustParseMoney("500.00"), models.MustParseMoney("200.00"), "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), ledgerID).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	budget := models.Budget{
 		CategoryID: int64(1), // Change to int64
-		Amount:     500.0,
-		Spent:      200.0,
+		Amount:     models.MustParseMoney("500.00"),
+		Spent:      models.MustParseMoney("200.00"),
 		StartDate:  time.Now(),
 		EndDate:    time.Now().Add(30 * 24 * time.Hour),
 	}
-	createdBudget, err := models.CreateBudget(db, budget)
+	createdBudget, err := models.CreateBudget(db, ledgerID, budget)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), createdBudget.ID)
 	assert.Equal(t, int64(1), createdBudget.CategoryID)
-	assert.Equal(t, float64(500), createdBudget.Amount)
-	assert.Equal(t, float64(200), createdBudget.Spent)
+	assert.Equal(t, models.MustParseMoney("500.00"), createdBudget.Amount)
+	assert.Equal(t, models.MustParseMoney("200.00"), createdBudget.Spent)
 }
 
 func TestCreateBudgetWithEmptyCategory(t *testing.T) {
@@ -103,15 +111,16 @@ func TestCreateBudgetWithEmptyCategory(t *testing.T) {
 
 	budget := models.Budget{
 		CategoryID: 0,
-		Amount:     500.0,
-		Spent:      200.0,
+		Amount:     models.MustParseMoney("500.00"),
+		Spent:      models.MustParseMoney("200.00"),
 		StartDate:  time.Now(),
 		EndDate:    time.Now().Add(30 * 24 * time.Hour),
 	}
-	_, err = models.CreateBudget(db, budget)
+	_, err = models.CreateBudget(db, ledgerID, budget)
 
 	assert.Error(t, err)
-	assert.Equal(t, errors.New("category cannot be empty"), err)
+	assert.EqualError(t, err, "category cannot be empty")
+	assert.ErrorIs(t, err, models.ErrValidation)
 }
 
 func TestUpdateBudget(t *testing.T) {
@@ -121,35 +130,45 @@ func TestUpdateBudget(t *testing.T) {
 	}
 	defer db.Close()
 
+	// Mock lookup of the current currency, since the update does not change it
+	mock.ExpectQuery("SELECT currency FROM Budget WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(int64(1), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("USD"))
+
+	// Mock the check that the category is the ledger's
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM Category WHERE id = \$1 AND \(ledger_id = \$2 OR id = 1\)\)`).
+		WithArgs(int64(1), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
 	// Mock DoesBudgetOverlap query
-	mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE category_id = \$1 AND id <> \$4 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
-		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1)). // Ensure `id <> $4` matches the budget being updated
+	mock.ExpectQuery(`SELECT EXISTS \( SELECT 1 FROM Budget WHERE \(category_id IN \(SELECT id FROM subcategories\) OR category_id IN \(SELECT id FROM parent_categories\)\) AND id <> \$4 AND ledger_id = \$5 AND \( \(start_date <= \$3 AND end_date >= \$2\) \) \)`).
+		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1), ledgerID). // Ensure `id <> $4` matches the budget being updated
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	// Mock CalculateTotalSpent query
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM Expense WHERE category_id = \\$1 AND date >= \\$2 AND date <= \\$3").
-		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnRows(sqlmock.NewRows([]string{"total_spent"}).AddRow(250.0))
+	mock.ExpectQuery("SELECT currency, date, COALESCE\\(SUM\\(amount\\), 0\\) FROM expense_allocations WHERE category_id IN \\(SELECT id FROM subcategories\\) AND date >= \\$2 AND date <= \\$3 AND ledger_id = \\$4 GROUP BY currency, date").
+		WithArgs(int64(1), sqlmock.AnyArg(), sqlmock.AnyArg(), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "date", "total_spent"}).AddRow("USD", time.Now(), 250.0))
 
 	// Mock Update query
-	mock.ExpectExec("UPDATE Budget SET amount = \\$1, spent = \\$2, start_date = \\$3, end_date = \\$4 WHERE category_id = \\$5").
-		WithArgs(600.0, 250.0, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1)).
+	mock.ExpectExec("UPDATE Budget SET category_id = \\$1, amount = \\$2, spent = \\$3, currency = \\$4, start_date = \\$5, end_date = \\$6, stale = FALSE WHERE id = \\$7 AND ledger_id = \\$8").
+		WithArgs(int64(1), models.MustParseMoney("600.00"), models.MustParseMoney("250.00"), "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1), ledgerID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	budget := models.Budget{
 		ID:         1,                                   // Ensure ID matches the updated record
 		CategoryID: int64(1),                            // Category ID
-		Amount:     600.0,                               // Updated Amount
-		Spent:      250.0,                               // Updated Spent
+		Amount:     models.MustParseMoney("600.00"),     // Updated Amount
+		Spent:      models.MustParseMoney("250.00"),     // Updated Spent
 		StartDate:  time.Now(),                          // Updated StartDate
 		EndDate:    time.Now().Add(30 * 24 * time.Hour), // Updated EndDate
 	}
-	updatedBudget, err := models.UpdateBudget(db, budget)
+	updatedBudget, err := models.UpdateBudget(db, ledgerID, budget)
 
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), updatedBudget.CategoryID) // Ensure CategoryID is updated
-	assert.Equal(t, float64(600), updatedBudget.Amount) // Ensure Amount is updated
-	assert.Equal(t, float64(250), updatedBudget.Spent)  // Ensure Spent is updated
+	assert.Equal(t, int64(1), updatedBudget.CategoryID)                    // Ensure CategoryID is updated
+	assert.Equal(t, models.MustParseMoney("600.00"), updatedBudget.Amount) // Ensure Amount is updated
+	assert.Equal(t, models.MustParseMoney("250.00"), updatedBudget.Spent)  // Ensure Spent is updated
 }
 
 func TestUpdateBudgetWithEmptyCategory(t *testing.T) {
@@ -161,15 +180,16 @@ func TestUpdateBudgetWithEmptyCategory(t *testing.T) {
 
 	budget := models.Budget{
 		CategoryID: 0,
-		Amount:     600.0,
-		Spent:      250.0,
+		Amount:     models.MustParseMoney("600.00"),
+		Spent:      models.MustParseMoney("250.00"),
 		StartDate:  time.Now(),
 		EndDate:    time.Now().Add(30 * 24 * time.Hour),
 	}
-	_, err = models.UpdateBudget(db, budget)
+	_, err = models.UpdateBudget(db, ledgerID, budget)
 
 	assert.Error(t, err)
-	assert.Equal(t, errors.New("category id must be provided"), err)
+	assert.EqualError(t, err, "category id must be provided")
+	assert.ErrorIs(t, err, models.ErrValidation)
 }
 
 // func TestDeleteBudget(t *testing.T) {
@@ -183,7 +203,7 @@ func TestUpdateBudgetWithEmptyCategory(t *testing.T) {
 // 		WithArgs(int64(1)).
 // 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-// 	err = models.DeleteBudget(db, 1)
+// 	err = models.DeleteBudget(db, ledgerID, 1)
 
 // 	assert.NoError(t, err)
 // }
@@ -0,0 +1,57 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryRuleMatches(t *testing.T) {
+	min, max := models.MustParseMoney("10.00"), models.MustParseMoney("50.00")
+	account := int64(7)
+	rule, err := models.NormalizeCategoryRule(models.CategoryRule{
+		Name: "Supermarkets", CategoryID: 3, DescriptionPattern: `(?i)^(lidl|aldi)\b`, MinAmount: &min, MaxAmount: &max, AccountID: &account,
+	})
+	assert.NoError(t, err)
+
+	expense := models.Expense{Description: "LIDL 1234 Berlin", Amount: models.MustParseMoney("23.40"), AccountID: &account}
+	assert.True(t, rule.Matches(expense))
+
+	for name, change := range map[string]func(*models.Expense){
+		"description": func(e *models.Expense) { e.Description = "Paid at Lidl" },
+		"below min":   func(e *models.Expense) { e.Amount = models.MustParseMoney("9.99") },
+		"above max":   func(e *models.Expense) { e.Amount = models.MustParseMoney("50.01") },
+		"no account":  func(e *models.Expense) { e.AccountID = nil },
+	} {
+		changed := expense
+		change(&changed)
+		assert.False(t, rule.Matches(changed), name)
+	}
+
+	contains := models.CategoryRule{Name: "Coffee", CategoryID: 4, DescriptionContains: "coffee"}
+	assert.True(t, contains.Matches(models.Expense{Description: "Blue Bottle COFFEE"}))
+
+	rules := []models.CategoryRule{contains, {Name: "Cafés", CategoryID: 5, DescriptionContains: "blue"}}
+	assert.Equal(t, int64(4), models.Categorize(rules, models.Expense{Description: "Blue Bottle Coffee"}, 1))
+	assert.Equal(t, int64(5), models.Categorize(rules, models.Expense{Description: "Blue Bottle"}, 1))
+	assert.Equal(t, int64(1), models.Categorize(rules, models.Expense{Description: "Bakery"}, 1))
+}
+
+func TestNormalizeCategoryRule(t *testing.T) {
+	min, max := models.MustParseMoney("10.00"), models.MustParseMoney("5.00")
+	for _, tc := range []struct {
+		rule models.CategoryRule
+		err  string
+	}{
+		{models.CategoryRule{CategoryID: 2, DescriptionContains: "x"}, "name must be provided"},
+		{models.CategoryRule{Name: "Rule", DescriptionContains: "x"}, "category ID must be provided"},
+		{models.CategoryRule{Name: "Rule", CategoryID: 2}, "a rule needs at least one condition"},
+		{models.CategoryRule{Name: "Rule", CategoryID: 2, DescriptionPattern: "("}, "invalid description pattern: error parsing regexp: missing closing ): `(`"},
+		{models.CategoryRule{Name: "Rule", CategoryID: 2, MinAmount: &min, MaxAmount: &max}, "maximum amount must not be less than the minimum"},
+	} {
+		_, err := models.NormalizeCategoryRule(tc.rule)
+		assert.EqualError(t, err, tc.err)
+		assert.ErrorIs(t, err, models.ErrValidation)
+	}
+}
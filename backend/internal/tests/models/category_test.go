@@ -1,8 +1,6 @@
 package models_test
 
 import (
-	"database/sql"
-	"errors"
 	"testing"
 
 	"expense-tracker/internal/models"
@@ -18,13 +16,13 @@ func TestGetCategories(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description"}).
-		AddRow(1, "Food", "Food expenses").
-		AddRow(2, "Transport", "Transportation expenses")
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "parent_id"}).
+		AddRow(1, "Food", "Food expenses", nil).
+		AddRow(2, "Transport", "Transportation expenses", nil)
 
-	mock.ExpectQuery("SELECT id, name, description FROM Category").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, name, description, parent_id FROM Category").WillReturnRows(rows)
 
-	categories, err := models.GetCategories(db)
+	categories, err := models.GetCategories(db, ledgerID)
 
 	assert.NoError(t, err)
 	assert.Len(t, categories, 2)
@@ -43,14 +41,14 @@ func TestGetCategoryByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description"}).
-		AddRow(1, "Food", "Food expenses")
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "parent_id"}).
+		AddRow(1, "Food", "Food expenses", nil)
 
-	mock.ExpectQuery("SELECT id, name, description FROM Category WHERE id = \\$1").
-		WithArgs(1).
+	mock.ExpectQuery("SELECT id, name, description, parent_id FROM Category WHERE id = \\$1 AND \\(ledger_id = \\$2 OR id = 1\\)").
+		WithArgs(1, ledgerID).
 		WillReturnRows(rows)
 
-	category, err := models.GetCategoryByID(db, 1)
+	category, err := models.GetCategoryByID(db, ledgerID, 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), category.ID)
@@ -65,19 +63,42 @@ func TestCreateCategory(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("INSERT INTO Category \\(name, description\\) VALUES \\(\\$1, \\$2\\) RETURNING id").
-		WithArgs("Entertainment", "Entertainment expenses").
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = 1 AND name = \\$1\\)").
+		WithArgs("Entertainment").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO Category \\(name, description, parent_id, ledger_id\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\) RETURNING id").
+		WithArgs("Entertainment", "Entertainment expenses", nil, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
 
 	category := models.Category{Name: "Entertainment", Description: "Entertainment expenses"}
-	createdCategory, err := models.CreateCategory(db, category)
+	createdCategory, err := models.CreateCategory(db, ledgerID, category)
 
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), createdCategory.ID)
+	assert.Equal(t, int64(2), createdCategory.ID)
 	assert.Equal(t, "Entertainment", createdCategory.Name)
 	assert.Equal(t, "Entertainment expenses", createdCategory.Description)
 }
 
+func TestCreateCategoryNamedLikeSharedCategory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = 1 AND name = \\$1\\)").
+		WithArgs("Other").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err = models.CreateCategory(db, ledgerID, models.Category{Name: "Other"})
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestCreateCategoryWithEmptyName(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -86,10 +107,11 @@ func TestCreateCategoryWithEmptyName(t *testing.T) {
 	defer db.Close()
 
 	category := models.Category{Name: "", Description: "Empty category"}
-	_, err = models.CreateCategory(db, category)
+	_, err = models.CreateCategory(db, ledgerID, category)
 
 	assert.Error(t, err)
-	assert.Equal(t, errors.New("name must be provided"), err)
+	assert.EqualError(t, err, "name must be provided")
+	assert.ErrorIs(t, err, models.ErrValidation)
 }
 
 func TestUpdateCategory(t *testing.T) {
@@ -99,21 +121,93 @@ func TestUpdateCategory(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectExec("UPDATE Category SET name = \\$1, description = \\$2 WHERE id = \\$3").
-		WithArgs("Food", "Updated food expenses", 1).
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT parent_id FROM Category WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(2, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = 1 AND name = \\$1\\)").
+		WithArgs("Food").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE Category SET name = \\$1, description = \\$2, parent_id = \\$3 WHERE id = \\$4").
+		WithArgs("Food", "Updated food expenses", nil, 2).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	category := models.Category{
-		ID:          1,
+		ID:          2,
 		Name:        "Food",
 		Description: "Updated food expenses",
 	}
-	updatedCategory, err := models.UpdateCategory(db, category)
+	updatedCategory, err := models.UpdateCategory(db, ledgerID, category)
 
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), updatedCategory.ID)
+	assert.Equal(t, int64(2), updatedCategory.ID)
 	assert.Equal(t, "Food", updatedCategory.Name)
 	assert.Equal(t, "Updated food expenses", updatedCategory.Description)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The shared 'Other' category cannot be changed
+	_, err = models.UpdateCategory(db, ledgerID, models.Category{ID: 1, Name: "Misc"})
+	assert.ErrorIs(t, err, models.ErrForbidden)
+}
+
+func TestUpdateCategoryRejectsCycle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	parentID := int64(3)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT parent_id FROM Category WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(2, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = 1 AND name = \\$1\\)").
+		WithArgs("Food").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("WITH RECURSIVE subcategories").
+		WithArgs(2, 3, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists", "descendant"}).AddRow(true, true))
+	mock.ExpectRollback()
+
+	_, err = models.UpdateCategory(db, ledgerID, models.Category{ID: 2, Name: "Food", ParentID: &parentID})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.EqualError(t, err, "a category cannot be moved under one of its own sub-categories")
+
+	parentID = 2
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT parent_id FROM Category WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(2, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = 1 AND name = \\$1\\)").
+		WithArgs("Food").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+	_, err = models.UpdateCategory(db, ledgerID, models.Category{ID: 2, Name: "Food", ParentID: &parentID})
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCategoryTree(t *testing.T) {
+	food, groceries := int64(2), int64(3)
+	tree := models.CategoryTree([]models.Category{
+		{ID: 1, Name: "Other"},
+		{ID: 2, Name: "Food"},
+		{ID: 3, Name: "Groceries", ParentID: &food},
+		{ID: 4, Name: "Restaurants", ParentID: &food},
+		{ID: 5, Name: "Produce", ParentID: &groceries},
+	})
+
+	assert.Len(t, tree, 2)
+	assert.Equal(t, "Other", tree[0].Name)
+	assert.Empty(t, tree[0].Children)
+	assert.Equal(t, "Food", tree[1].Name)
+	assert.Len(t, tree[1].Children, 2)
+	assert.Equal(t, "Groceries", tree[1].Children[0].Name)
+	assert.Equal(t, "Restaurants", tree[1].Children[1].Name)
+	assert.Len(t, tree[1].Children[0].Children, 1)
+	assert.Equal(t, "Produce", tree[1].Children[0].Children[0].Name)
 }
 
 func TestUpdateCategoryWithoutID(t *testing.T) {
@@ -124,10 +218,11 @@ func TestUpdateCategoryWithoutID(t *testing.T) {
 	defer db.Close()
 
 	category := models.Category{Name: "Food", Description: "Updated category"}
-	_, err = models.UpdateCategory(db, category)
+	_, err = models.UpdateCategory(db, ledgerID, category)
 
 	assert.Error(t, err)
-	assert.Equal(t, errors.New("id must be provided"), err)
+	assert.EqualError(t, err, "id must be provided")
+	assert.ErrorIs(t, err, models.ErrValidation)
 }
 func TestDeleteCategory(t *testing.T) {
 	db, mock, err := sqlmock.New()
@@ -137,32 +232,103 @@ func TestDeleteCategory(t *testing.T) {
 	defer db.Close()
 
 	// Test case: Prevent deletion of the "Other" category
-	err = models.DeleteCategory(db, 1)
+	err = models.DeleteCategory(db, ledgerID, 1)
 	assert.Error(t, err)
 	assert.Equal(t, "cannot delete the 'Other' category", err.Error())
 
-	// Test case: Reassign expenses to "Other" and delete a category
-	mock.ExpectExec(`UPDATE Expense SET category_id = 1 WHERE category_id = \$1`).
-		WithArgs(int64(2)).
+	// Test case: Reassign expenses to "Other", delete a category and update
+	// the spend of the budgets on "Other" in one transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT parent_id FROM Category WHERE id = \$1 AND ledger_id = \$2`).
+		WithArgs(int64(2), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectExec(`UPDATE Category SET parent_id = \$1 WHERE parent_id = \$2`).
+		WithArgs(nil, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE Expense SET category_id = \$1 WHERE category_id = \$2`).
+		WithArgs(int64(1), int64(2)).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE ExpenseSplit SET category_id = \$1 WHERE category_id = \$2`).
+		WithArgs(int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	mock.ExpectExec(`DELETE FROM Category WHERE id = \$1`).
 		WithArgs(int64(2)).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id IN \(SELECT id FROM parent_categories\) AND ledger_id = \$2`).
+		WithArgs(int64(1), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}))
+	mock.ExpectCommit()
 
-	err = models.DeleteCategory(db, 2)
+	err = models.DeleteCategory(db, ledgerID, 2)
 	assert.NoError(t, err)
 
-	// Test case: Category not found
-	mock.ExpectExec(`UPDATE Expense SET category_id = 1 WHERE category_id = \$1`).
-		WithArgs(int64(3)).
+	// Test case: A sub-category's children and expenses move up to its parent
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT parent_id FROM Category WHERE id = \$1 AND ledger_id = \$2`).
+		WithArgs(int64(4), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(2))
+	mock.ExpectExec(`UPDATE Category SET parent_id = \$1 WHERE parent_id = \$2`).
+		WithArgs(int64(2), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE Expense SET category_id = \$1 WHERE category_id = \$2`).
+		WithArgs(int64(2), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE ExpenseSplit SET category_id = \$1 WHERE category_id = \$2`).
+		WithArgs(int64(2), int64(4)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
-
 	mock.ExpectExec(`DELETE FROM Category WHERE id = \$1`).
-		WithArgs(int64(3)).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WithArgs(int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, category_id, amount, spent, currency, start_date, end_date, stale FROM Budget WHERE category_id IN \(SELECT id FROM parent_categories\) AND ledger_id = \$2`).
+		WithArgs(int64(2), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "spent", "currency", "start_date", "end_date", "stale"}))
+	mock.ExpectCommit()
+
+	err = models.DeleteCategory(db, ledgerID, 4)
+	assert.NoError(t, err)
 
-	err = models.DeleteCategory(db, 3)
+	// Test case: Category not found
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT parent_id FROM Category WHERE id = \$1 AND ledger_id = \$2`).
+		WithArgs(int64(3), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}))
+	mock.ExpectRollback()
+
+	err = models.DeleteCategory(db, ledgerID, 3)
 	assert.Error(t, err)
-	assert.Equal(t, sql.ErrNoRows, err)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeCategoryValidation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	_, err = models.MergeCategory(db, ledgerID, 2, 3, "average")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = models.MergeCategory(db, ledgerID, 2, 2, models.MergeBudgetsSum)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	_, err = models.MergeCategory(db, ledgerID, 1, 2, models.MergeBudgetsSum)
+	assert.ErrorIs(t, err, models.ErrForbidden)
+
+	// Merging into a sub-category is refused before anything moves
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, description, parent_id FROM Category WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(2, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "parent_id"}).AddRow(2, "Food", "", nil))
+	mock.ExpectQuery("SELECT id, name, description, parent_id FROM Category WHERE id = \\$1 AND \\(ledger_id = \\$2 OR id = 1\\)").
+		WithArgs(3, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "parent_id"}).AddRow(3, "Groceries", "", 2))
+	mock.ExpectQuery("WITH RECURSIVE subcategories").
+		WithArgs(2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err = models.MergeCategory(db, ledgerID, 2, 3, models.MergeBudgetsSum)
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarDescriptions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Amazon", "AMAZON MKTPLACE 1234", true},
+		{"Whole Foods Market", "whole foods #102", true},
+		{"Shell petrol station", "Shell", true},
+		{"Uber trip", "Uber Eats", false},
+		{"Rent", "Netflix", false},
+	} {
+		assert.Equal(t, tc.similar, models.SimilarDescriptions(tc.a, tc.b), "%q and %q", tc.a, tc.b)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+	amount := models.MustParseMoney("64.20")
+	expenses := []models.Expense{
+		{ID: 1, Amount: amount, Currency: "USD", Date: day(1), Description: "Whole Foods"},
+		{ID: 2, Amount: amount, Currency: "USD", Date: day(3), Description: "WHOLE FOODS MARKET 102"},
+		{ID: 3, Amount: amount, Currency: "USD", Date: day(10), Description: "Whole Foods"},
+		{ID: 4, Amount: amount, Currency: "EUR", Date: day(1), Description: "Whole Foods"},
+		{ID: 5, Amount: models.MustParseMoney("64.21"), Currency: "USD", Date: day(1), Description: "Whole Foods"},
+		{ID: 6, Amount: amount, Currency: "USD", Date: day(2), Description: "Gym"},
+		{ID: 7, Amount: amount, Currency: "USD", Date: day(2), Description: "Whole foods"},
+	}
+
+	pairs := models.FindDuplicates(expenses, func(expenseID, duplicateID int64) bool {
+		return expenseID == 2 && duplicateID == 7
+	})
+	var ids [][2]int64
+	for _, pair := range pairs {
+		ids = append(ids, [2]int64{pair.Expense.ID, pair.Duplicate.ID})
+	}
+	assert.Equal(t, [][2]int64{{1, 2}, {1, 7}}, ids)
+}
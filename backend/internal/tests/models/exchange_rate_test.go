@@ -0,0 +1,81 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCurrency(t *testing.T) {
+	currency, err := models.NormalizeCurrency(" eur ")
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", currency)
+
+	currency, err = models.NormalizeCurrency("")
+	assert.NoError(t, err)
+	assert.Equal(t, models.DefaultCurrency, currency)
+
+	_, err = models.NormalizeCurrency("EU")
+	assert.Error(t, err)
+}
+
+func TestParseExchangeRatesCSV(t *testing.T) {
+	input := "date,base_currency,quote_currency,rate\n2024-03-01,eur,usd,1.0850\n2024-03-02,GBP,USD,1.27\n"
+
+	rates, err := models.ParseExchangeRates(strings.NewReader(input), "csv")
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 2)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), rates[0].Date)
+	assert.Equal(t, "EUR", rates[0].BaseCurrency)
+	assert.Equal(t, "USD", rates[0].QuoteCurrency)
+	assert.Equal(t, "1.085", rates[0].Rate)
+}
+
+func TestParseExchangeRatesJSON(t *testing.T) {
+	input := `[{"date": "2024-03-01", "base_currency": "USD", "quote_currency": "JPY", "rate": 149.5}]`
+
+	rates, err := models.ParseExchangeRates(strings.NewReader(input), "json")
+
+	assert.NoError(t, err)
+	assert.Len(t, rates, 1)
+	assert.Equal(t, "JPY", rates[0].QuoteCurrency)
+	assert.Equal(t, "149.5", rates[0].Rate)
+}
+
+func TestParseExchangeRatesRejectsBadRows(t *testing.T) {
+	_, err := models.ParseExchangeRates(strings.NewReader("date,base_currency,rate\n"), "csv")
+	assert.EqualError(t, err, "CSV header is missing the quote_currency column")
+
+	_, err = models.ParseExchangeRates(strings.NewReader("date,base_currency,quote_currency,rate\n2024-03-01,EUR,USD,-1\n"), "csv")
+	assert.EqualError(t, err, `line 2: rate must be a positive number, got "-1"`)
+
+	_, err = models.ParseExchangeRates(strings.NewReader("date,base_currency,quote_currency,rate\n2024-03-01,EUR,EUR,1\n"), "csv")
+	assert.EqualError(t, err, "line 2: base and quote currencies must differ")
+
+	_, err = models.ParseExchangeRates(strings.NewReader(""), "xml")
+	assert.Error(t, err)
+}
+
+func TestFindExchangeRate(t *testing.T) {
+	rates := []models.ExchangeRate{
+		{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.1"},
+		{Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.2"},
+	}
+
+	// The latest rate on or before the date applies
+	rate, err := models.FindExchangeRate(rates, "EUR", "USD", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("110.00"), models.MustParseMoney("100.00").MulRat(rate))
+
+	// The opposite direction uses the inverse
+	rate, err = models.FindExchangeRate(rates, "USD", "EUR", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("100.00"), models.MustParseMoney("120.00").MulRat(rate))
+
+	_, err = models.FindExchangeRate(rates, "EUR", "USD", time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, models.ErrNoExchangeRate)
+}
@@ -1,11 +1,13 @@
 package models_test
 
 import (
+	"errors"
 	"expense-tracker/internal/models"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,20 +18,33 @@ func TestGetExpenses(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-		AddRow(1, 1, 100.00, time.Now(), "Groceries").
-		AddRow(2, 2, 50.00, time.Now(), "Utilities")
+	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+		AddRow(1, 1, 100.00, "USD", time.Now(), "Groceries", nil, nil).
+		AddRow(2, 2, 50.00, "USD", time.Now(), "Utilities", nil, nil)
 
-	mock.ExpectQuery("SELECT id, category_id, amount, date, description FROM Expense").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit WHERE expense_id IN \\(\\$1, \\$2\\) ORDER BY id").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}).
+			AddRow(1, 1, 3, "70.00", "").AddRow(2, 1, 4, "30.00", "Detergent"))
+	mock.ExpectQuery("SELECT l.expense_id, t.name FROM ExpenseTag l JOIN Tag t ON t.id = l.tag_id WHERE l.expense_id IN \\(\\$1, \\$2\\) ORDER BY t.name").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"expense_id", "name"}).AddRow(1, "groceries").AddRow(1, "weekly"))
 
-	expenses, err := models.GetExpenses(db)
+	expenses, err := models.GetExpenses(db, ledgerID)
 
 	assert.NoError(t, err)
 	assert.Len(t, expenses, 2)
 	assert.Equal(t, "Groceries", expenses[0].Description)
+	assert.Equal(t, []string{"groceries", "weekly"}, expenses[0].Tags)
+	assert.Nil(t, expenses[1].Tags)
+	if assert.Len(t, expenses[0].Splits, 2) {
+		assert.Equal(t, models.ExpenseSplit{ID: 2, CategoryID: 4, Amount: models.MustParseMoney("30.00"), Memo: "Detergent"}, expenses[0].Splits[1])
+	}
+	assert.Nil(t, expenses[1].Splits)
 	assert.Equal(t, int64(1), expenses[0].ID)
 	assert.Equal(t, int64(1), expenses[0].CategoryID)
-	assert.Equal(t, 100.00, expenses[0].Amount)
+	assert.Equal(t, models.MustParseMoney("100.00"), expenses[0].Amount)
 }
 
 func TestGetExpenseByID(t *testing.T) {
@@ -39,19 +54,25 @@ func TestGetExpenseByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-		AddRow(1, 1, 100.00, time.Now(), "Groceries")
+	rows := sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+		AddRow(1, 1, 100.00, "USD", time.Now(), "Groceries", nil, nil)
 
-	mock.ExpectQuery("SELECT id, category_id, amount, date, description FROM Expense WHERE id = \\$1").
-		WithArgs(1).
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
 		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
+	mock.ExpectQuery("SELECT l.expense_id, t.name FROM ExpenseTag l").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"expense_id", "name"}))
 
-	expense, err := models.GetExpenseByID(db, 1)
+	expense, err := models.GetExpenseByID(db, ledgerID, 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), expense.ID)
 	assert.Equal(t, int64(1), expense.CategoryID)
-	assert.Equal(t, 100.00, expense.Amount)
+	assert.Equal(t, models.MustParseMoney("100.00"), expense.Amount)
 }
 
 func TestCreateExpense(t *testing.T) {
@@ -65,24 +86,36 @@ func TestCreateExpense(t *testing.T) {
 	expense := models.Expense{
 		Description: "Groceries",
 		CategoryID:  1,
-		Amount:      100.00,
-		 Date:        now,
+		Amount:      models.MustParseMoney("100.00"),
+		Date:        now,
 	}
 
-	mock.ExpectQuery("INSERT INTO Expense \\(category_id, amount, date, description\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\) RETURNING id, category_id, amount, date, description").
-		WithArgs(expense.CategoryID, expense.Amount, expense.Date, expense.Description).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-			AddRow(1, expense.CategoryID, expense.Amount, expense.Date, expense.Description))
-
-	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM Budget WHERE category_id = \\$1").
-		WithArgs(expense.CategoryID).
-		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-
-	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE category_id = \\$2").
-		WithArgs(expense.Amount, expense.CategoryID).
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = \\$1").
+		WithArgs(expense.CategoryID, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO Expense \\(category_id, amount, currency, date, description, recurring_rule_id, account_id, ledger_id\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7, \\$8\\) RETURNING id, category_id, amount, currency, date, description, recurring_rule_id, account_id").
+		WithArgs(expense.CategoryID, expense.Amount, "USD", expense.Date, expense.Description, nil, nil, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, expense.CategoryID, expense.Amount, "USD", expense.Date, expense.Description, nil, nil))
+
+	mock.ExpectQuery("SELECT id, currency FROM Budget WHERE category_id IN \\(SELECT id FROM parent_categories\\) AND start_date <= \\$2 AND end_date >= \\$2 AND ledger_id = \\$3").
+		WithArgs(expense.CategoryID, expense.Date, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WithArgs(expense.Amount, int64(1)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	createdExpense, err := models.CreateExpense(db, expense)
+	// An earlier expense with the same amount and a similar description is flagged
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id <> \\$1 AND currency = \\$2 AND amount = \\$3 AND date >= \\$4 AND date <= \\$5 AND ledger_id = \\$6 ORDER BY id").
+		WithArgs(int64(1), "USD", expense.Amount, expense.Date.Add(-models.DuplicateWindow*24*time.Hour), expense.Date.Add(models.DuplicateWindow*24*time.Hour), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(7, 1, expense.Amount, "USD", expense.Date, "GROCERIES 0412", nil, nil).
+			AddRow(8, 1, expense.Amount, "USD", expense.Date, "Hardware store", nil, nil))
+	mock.ExpectCommit()
+
+	createdExpense, err := models.CreateExpense(db, ledgerID, expense)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), createdExpense.ID)
@@ -90,6 +123,7 @@ func TestCreateExpense(t *testing.T) {
 	assert.Equal(t, expense.Amount, createdExpense.Amount)
 	assert.Equal(t, expense.Date, createdExpense.Date)
 	assert.Equal(t, expense.Description, createdExpense.Description)
+	assert.Equal(t, []int64{7}, createdExpense.PossibleDuplicates)
 
 	// Verify that all expectations were met
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -105,39 +139,55 @@ func TestUpdateExpense(t *testing.T) {
 	currentExpense := models.Expense{
 		ID:          1,
 		CategoryID:  1,
-		Amount:      100.00,
+		Amount:      models.MustParseMoney("100.00"),
 		Date:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
 		Description: "Groceries",
 	}
 
-	// First expect the GetExpenseByID query
-	mock.ExpectQuery("SELECT id, category_id, amount, date, description FROM Expense WHERE id = \\$1").
+	// First expect the GetExpenseByID query inside the transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(currentExpense.ID, currentExpense.CategoryID, currentExpense.Amount, "USD", currentExpense.Date, currentExpense.Description, nil, nil))
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit").
 		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-			AddRow(currentExpense.ID, currentExpense.CategoryID, currentExpense.Amount, currentExpense.Date, currentExpense.Description))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
 
 	updatedExpense := models.Expense{
 		ID:          1,
-		Amount:      150.00,
+		Amount:      models.MustParseMoney("150.00"),
 		Description: "Updated Groceries",
 	}
 
+	// Tags are left as they are, so the current ones are loaded
+	mock.ExpectQuery("SELECT l.expense_id, t.name FROM ExpenseTag l").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"expense_id", "name"}))
+
 	// Then expect the update query
 	mock.ExpectExec("UPDATE Expense SET amount = \\$1, description = \\$2 WHERE id = \\$3").
 		WithArgs(updatedExpense.Amount, updatedExpense.Description, updatedExpense.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	// Then check if budget exists
-	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM Budget WHERE category_id = \\$1").
-		WithArgs(currentExpense.CategoryID).
-		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// Then move the expense's contribution: remove the old amount...
+	mock.ExpectQuery("SELECT id, currency FROM Budget WHERE category_id IN \\(SELECT id FROM parent_categories\\) AND start_date <= \\$2 AND end_date >= \\$2 AND ledger_id = \\$3").
+		WithArgs(currentExpense.CategoryID, currentExpense.Date, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WithArgs(models.MustParseMoney("-100.00"), int64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	// Finally update budget spent amount (150 - 100 = 50 difference)
-	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE category_id = \\$2").
-		WithArgs(50.0, currentExpense.CategoryID).
+	// ...and add the new one
+	mock.ExpectQuery("SELECT id, currency FROM Budget WHERE category_id IN \\(SELECT id FROM parent_categories\\) AND start_date <= \\$2 AND end_date >= \\$2 AND ledger_id = \\$3").
+		WithArgs(currentExpense.CategoryID, currentExpense.Date, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WithArgs(models.MustParseMoney("150.00"), int64(1)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	result, err := models.UpdateExpense(db, updatedExpense)
+	result, err := models.UpdateExpense(db, ledgerID, updatedExpense)
 
 	assert.NoError(t, err)
 	assert.Equal(t, updatedExpense.Amount, result.Amount)
@@ -156,28 +206,195 @@ func TestDeleteExpense(t *testing.T) {
 	}
 	defer db.Close()
 
-	// Get the expense details first
-	mock.ExpectQuery("SELECT id, category_id, amount, date, description FROM Expense WHERE id = \\$1").
+	// Get the expense details first inside the transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, 1, 100.00, "USD", time.Now(), "Test Expense", nil, nil))
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit").
 		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "date", "description"}).
-			AddRow(1, 1, 100.00, time.Now(), "Test Expense"))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
 
 	// Delete the expense first
 	mock.ExpectExec("DELETE FROM Expense WHERE id = \\$1").
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	// Then check if budget exists
-	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM Budget WHERE category_id = \\$1").
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// Then find the budgets covering the expense
+	mock.ExpectQuery("SELECT id, currency FROM Budget WHERE category_id IN \\(SELECT id FROM parent_categories\\) AND start_date <= \\$2 AND end_date >= \\$2 AND ledger_id = \\$3").
+		WithArgs(1, sqlmock.AnyArg(), ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
 
 	// Finally update budget spent amount (using negative amount to decrease the spent value)
-	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE category_id = \\$2").
-		WithArgs(-100.00, 1).
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WithArgs(models.MustParseMoney("-100.00"), int64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = models.DeleteExpense(db, ledgerID, 1)
+
+	assert.NoError(t, err)
+}
+
+func TestCreateExpenseRollsBackWhenBudgetUpdateFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expense := models.Expense{Description: "Groceries", CategoryID: 1, Amount: models.MustParseMoney("100.00"), Date: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = \\$1").
+		WithArgs(expense.CategoryID, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO Expense").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, expense.CategoryID, expense.Amount, "USD", expense.Date, expense.Description, nil, nil))
+	mock.ExpectQuery("SELECT id, currency FROM Budget").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err = models.CreateExpense(db, ledgerID, expense)
+
+	assert.EqualError(t, err, "connection reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteExpenseRetriesSerializationFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expenseRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, 1, 100.00, "USD", time.Now(), "Test Expense", nil, nil)
+	}
+
+	// First attempt conflicts with a concurrent transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(expenseRows())
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
+	mock.ExpectExec("DELETE FROM Expense WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectRollback()
+
+	// Second attempt succeeds
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id FROM Expense WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(expenseRows())
+	mock.ExpectQuery("SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
+	mock.ExpectExec("DELETE FROM Expense WHERE id = \\$1").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT id, currency FROM Budget").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}))
+	mock.ExpectCommit()
+
+	err = models.DeleteExpense(db, ledgerID, 1)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateExpenseConvertsIntoBudgetCurrency(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	date := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	expense := models.Expense{Description: "Dinner in Paris", CategoryID: 1, Amount: models.MustParseMoney("40.00"), Currency: "eur", Date: date}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = \\$1").
+		WithArgs(expense.CategoryID, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO Expense").
+		WithArgs(expense.CategoryID, expense.Amount, "EUR", date, expense.Description, nil, nil, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, expense.CategoryID, expense.Amount, "EUR", date, expense.Description, nil, nil))
+	mock.ExpectQuery("SELECT id, currency FROM Budget").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(7, "USD"))
+
+	// Only the inverse rate is stored, so it is inverted: 40 EUR / 0.8 = 50 USD
+	mock.ExpectQuery("SELECT rate, base_currency FROM ExchangeRate").
+		WithArgs("EUR", "USD", date).
+		WillReturnRows(sqlmock.NewRows([]string{"rate", "base_currency"}).AddRow("0.80000000", "USD"))
+	mock.ExpectExec("UPDATE Budget SET spent = spent \\+ \\$1 WHERE id = \\$2").
+		WithArgs(models.MustParseMoney("50.00"), int64(7)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT (.+) FROM Expense WHERE id <> \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}))
+	mock.ExpectCommit()
+
+	createdExpense, err := models.CreateExpense(db, ledgerID, expense)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", createdExpense.Currency)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateExpenseMarksBudgetStaleWithoutExchangeRate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expense := models.Expense{Description: "Taxi", CategoryID: 1, Amount: models.MustParseMoney("12.00"), Currency: "GBP", Date: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM Category WHERE id = \\$1").
+		WithArgs(expense.CategoryID, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO Expense").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(1, expense.CategoryID, expense.Amount, "GBP", expense.Date, expense.Description, nil, nil))
+	mock.ExpectQuery("SELECT id, currency FROM Budget").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "currency"}).AddRow(1, "USD"))
+	mock.ExpectQuery("SELECT rate, base_currency FROM ExchangeRate").
+		WillReturnRows(sqlmock.NewRows([]string{"rate", "base_currency"}))
+
+	// The expense is still recorded, leaving the budget's spent amount as it was
+	mock.ExpectExec("UPDATE Budget SET stale = TRUE WHERE id = \\$1").
+		WithArgs(int64(1)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT (.+) FROM Expense WHERE id <> \\$1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}))
+	mock.ExpectCommit()
 
-	err = models.DeleteExpense(db, 1)
+	_, err = models.CreateExpense(db, ledgerID, expense)
 
 	assert.NoError(t, err)
-}
\ No newline at end of file
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateExpenseRejectsInvalidCurrency(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expense := models.Expense{Description: "Taxi", CategoryID: 1, Amount: models.MustParseMoney("12.00"), Currency: "euro", Date: time.Now()}
+
+	_, err = models.CreateExpense(db, ledgerID, expense)
+
+	assert.EqualError(t, err, `invalid currency code "EURO"`)
+}
@@ -16,18 +16,23 @@ func TestGetIncomes(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "amount", "date", "source"}).
-		AddRow(1, 1000.00, time.Now(), "Salary").
-		AddRow(2, 500.00, time.Now(), "Freelance")
+	rows := sqlmock.NewRows([]string{"id", "amount", "currency", "date", "source", "recurring_rule_id", "account_id"}).
+		AddRow(1, 1000.00, "USD", time.Now(), "Salary", nil, nil).
+		AddRow(2, 500.00, "EUR", time.Now(), "Freelance", nil, nil)
 
-	mock.ExpectQuery("SELECT id, amount, date, source FROM Income").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, amount, currency, date, source, recurring_rule_id, account_id FROM Income").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT l.income_id, t.name FROM IncomeTag l JOIN Tag t ON t.id = l.tag_id WHERE l.income_id IN \\(\\$1, \\$2\\) ORDER BY t.name").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"income_id", "name"}).AddRow(2, "side-project"))
 
-	incomes, err := models.GetIncomes(db)
+	incomes, err := models.GetIncomes(db, ledgerID)
 
 	assert.NoError(t, err)
 	assert.Len(t, incomes, 2)
+	assert.Nil(t, incomes[0].Tags)
+	assert.Equal(t, []string{"side-project"}, incomes[1].Tags)
 	assert.Equal(t, int64(1), incomes[0].ID)
-	assert.Equal(t, 1000.00, incomes[0].Amount)
+	assert.Equal(t, models.MustParseMoney("1000.00"), incomes[0].Amount)
 	assert.Equal(t, "Salary", incomes[0].Source)
 }
 
@@ -38,18 +43,21 @@ func TestGetIncomeByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "amount", "date", "source"}).
-		AddRow(1, 1000.00, time.Now(), "Salary")
+	rows := sqlmock.NewRows([]string{"id", "amount", "currency", "date", "source", "recurring_rule_id", "account_id"}).
+		AddRow(1, 1000.00, "USD", time.Now(), "Salary", nil, nil)
 
-	mock.ExpectQuery("SELECT id, amount, date, source FROM Income WHERE id = \\$1").
-		WithArgs(1).
+	mock.ExpectQuery("SELECT id, amount, currency, date, source, recurring_rule_id, account_id FROM Income WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
 		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT l.income_id, t.name FROM IncomeTag l").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"income_id", "name"}))
 
-	income, err := models.GetIncomeByID(db, 1)
+	income, err := models.GetIncomeByID(db, ledgerID, 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), income.ID)
-	assert.Equal(t, 1000.00, income.Amount)
+	assert.Equal(t, models.MustParseMoney("1000.00"), income.Amount)
 	assert.Equal(t, "Salary", income.Source)
 }
 
@@ -61,19 +69,32 @@ func TestCreateIncome(t *testing.T) {
 	defer db.Close()
 
 	income := models.Income{
-		Amount: 1000.00,
+		Amount: models.MustParseMoney("1000.00"),
 		Date:   time.Now(),
 		Source: "Salary",
+		Tags:   []string{" Bonus", "salary", "bonus"},
 	}
 
-	mock.ExpectQuery("INSERT INTO Income \\(amount, date, source\\) VALUES \\(\\$1, \\$2, \\$3\\) RETURNING id").
-		WithArgs(income.Amount, income.Date, income.Source).
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO Income \\(amount, currency, date, source, recurring_rule_id, account_id, ledger_id\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7\\) RETURNING id").
+		WithArgs(income.Amount, "USD", income.Date, income.Source, nil, nil, ledgerID).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	createdIncome, err := models.CreateIncome(db, income)
+	// Tags are normalized, reusing the existing ones and creating the rest
+	mock.ExpectExec("DELETE FROM IncomeTag WHERE income_id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM Tag WHERE name = \\$1 AND ledger_id = \\$2").WithArgs("bonus", ledgerID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO IncomeTag \\(income_id, tag_id\\) VALUES \\(\\$1, \\$2\\)").WithArgs(1, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM Tag WHERE name = \\$1 AND ledger_id = \\$2").WithArgs("salary", ledgerID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("INSERT INTO Tag \\(name, ledger_id\\) VALUES \\(\\$1, \\$2\\) RETURNING id").WithArgs("salary", ledgerID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec("INSERT INTO IncomeTag \\(income_id, tag_id\\) VALUES \\(\\$1, \\$2\\)").WithArgs(1, 8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	createdIncome, err := models.CreateIncome(db, ledgerID, income)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), createdIncome.ID)
+	assert.Equal(t, []string{"bonus", "salary"}, createdIncome.Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestUpdateIncome(t *testing.T) {
@@ -86,51 +107,62 @@ func TestUpdateIncome(t *testing.T) {
 	// Mock the current income data
 	currentIncome := models.Income{
 		ID:     1,
-		Amount: 1000.00,
+		Amount: models.MustParseMoney("1000.00"),
 		Date:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
 		Source: "Original Salary",
 	}
 
 	// Mock the GetIncomeByID call
-	mock.ExpectQuery("SELECT id, amount, date, source FROM Income WHERE id = \\$1").
-		WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "date", "source"}).
-				AddRow(currentIncome.ID, currentIncome.Amount, currentIncome.Date, currentIncome.Source))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, amount, currency, date, source, recurring_rule_id, account_id FROM Income WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "currency", "date", "source", "recurring_rule_id", "account_id"}).
+			AddRow(currentIncome.ID, currentIncome.Amount, "USD", currentIncome.Date, currentIncome.Source, nil, nil))
 
 	// Test case 1: Update only amount
 	updatedIncome := models.Income{
 		ID:     1,
-		Amount: 1500.00,
+		Amount: models.MustParseMoney("1500.00"),
 	}
 
 	mock.ExpectExec("UPDATE Income SET amount = \\$1 WHERE id = \\$2").
 		WithArgs(updatedIncome.Amount, updatedIncome.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT l.income_id, t.name FROM IncomeTag l").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"income_id", "name"}).AddRow(1, "salary"))
+	mock.ExpectCommit()
 
-	result, err := models.UpdateIncome(db, updatedIncome)
+	result, err := models.UpdateIncome(db, ledgerID, updatedIncome)
 
 	assert.NoError(t, err)
 	assert.Equal(t, updatedIncome.Amount, result.Amount)
 	assert.Equal(t, currentIncome.Date, result.Date)
 	assert.Equal(t, currentIncome.Source, result.Source)
+	assert.Equal(t, []string{"salary"}, result.Tags)
 
 	// Test case 2: Update amount and source
 	updatedIncome = models.Income{
 		ID:     1,
-		Amount: 2000.00,
+		Amount: models.MustParseMoney("2000.00"),
 		Source: "Updated Salary",
 	}
 
-	mock.ExpectQuery("SELECT id, amount, date, source FROM Income WHERE id = \\$1").
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "date", "source"}).
-			AddRow(currentIncome.ID, currentIncome.Amount, currentIncome.Date, currentIncome.Source))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, amount, currency, date, source, recurring_rule_id, account_id FROM Income WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "currency", "date", "source", "recurring_rule_id", "account_id"}).
+			AddRow(currentIncome.ID, currentIncome.Amount, "USD", currentIncome.Date, currentIncome.Source, nil, nil))
 
 	mock.ExpectExec("UPDATE Income SET amount = \\$1, source = \\$2 WHERE id = \\$3").
 		WithArgs(updatedIncome.Amount, updatedIncome.Source, updatedIncome.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT l.income_id, t.name FROM IncomeTag l").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"income_id", "name"}))
+	mock.ExpectCommit()
 
-	result, err = models.UpdateIncome(db, updatedIncome)
+	result, err = models.UpdateIncome(db, ledgerID, updatedIncome)
 
 	assert.NoError(t, err)
 	assert.Equal(t, updatedIncome.Amount, result.Amount)
@@ -148,11 +180,11 @@ func TestDeleteIncome(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectExec("DELETE FROM Income WHERE id = \\$1").
-		WithArgs(1).
+	mock.ExpectExec("DELETE FROM Income WHERE id = \\$1 AND ledger_id = \\$2").
+		WithArgs(1, ledgerID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err = models.DeleteIncome(db, 1)
+	err = models.DeleteIncome(db, ledgerID, 1)
 
 	assert.NoError(t, err)
-}
\ No newline at end of file
+}
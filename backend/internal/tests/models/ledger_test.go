@@ -0,0 +1,63 @@
+package models_test
+
+import (
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveLastOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT role FROM LedgerMember WHERE ledger_id = \\$1 AND user_id = \\$2").
+		WithArgs(ledgerID, int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("owner"))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM LedgerMember WHERE ledger_id = \\$1 AND user_id <> \\$2 AND role = \\$3\\)").
+		WithArgs(ledgerID, int64(3), models.RoleOwner).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	err = models.RemoveMember(db, ledgerID, 3)
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.EqualError(t, err, "a ledger must keep at least one owner")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMemberRoleRejectsUnknownRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	_, err = models.UpdateMemberRole(db, ledgerID, 3, "admin")
+	assert.ErrorIs(t, err, models.ErrValidation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAcceptInvitationUsesItUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM LedgerInvitation WHERE token_hash = \\$1 AND expires_at > \\$2 RETURNING ledger_id, role").
+		WithArgs(models.HashToken("token"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"ledger_id", "role"}))
+	mock.ExpectRollback()
+
+	_, err = models.AcceptInvitation(db, "token", 3)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	assert.EqualError(t, err, "invitation not found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
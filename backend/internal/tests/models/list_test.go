@@ -0,0 +1,65 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListExpensesQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	maxAmount := models.MustParseMoney("50.00")
+	opts := models.ListOptions{
+		From:       from,
+		CategoryID: 2,
+		MaxAmount:  &maxAmount,
+		Search:     "Café_",
+		Tags:       []string{"Coffee"},
+		Sort:       []models.SortField{{Field: "date", Desc: true}},
+		Limit:      10,
+		Offset:     20,
+	}
+
+	where := `FROM Expense WHERE ledger_id = \$1 AND date >= \$2 AND \(\(category_id = \$3 AND NOT EXISTS \(SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = Expense.id\)\)` +
+		` OR EXISTS \(SELECT 1 FROM ExpenseSplit s WHERE s.expense_id = Expense.id AND s.category_id = \$3\)\) AND amount <= \$4 AND \(LOWER\(description\) LIKE \$5 ESCAPE '\\'\)` +
+		` AND id IN \(SELECT l.expense_id FROM ExpenseTag l JOIN Tag t ON t.id = l.tag_id WHERE t.name = \$6\)`
+	mock.ExpectQuery(`SELECT COUNT\(\*\) `+where).
+		WithArgs(ledgerID, from, int64(2), maxAmount, `%café\_%`, "coffee").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+	mock.ExpectQuery(`SELECT id, category_id, amount, currency, date, description, recurring_rule_id, account_id `+where+` ORDER BY date DESC, id LIMIT \$7 OFFSET \$8`).
+		WithArgs(ledgerID, from, int64(2), maxAmount, `%café\_%`, "coffee", 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "category_id", "amount", "currency", "date", "description", "recurring_rule_id", "account_id"}).
+			AddRow(21, 2, "12.00", "USD", from, "Café_ au lait", nil, nil))
+	mock.ExpectQuery(`SELECT id, expense_id, category_id, amount, memo FROM ExpenseSplit WHERE expense_id IN \(\$1\) ORDER BY id`).
+		WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "expense_id", "category_id", "amount", "memo"}))
+	mock.ExpectQuery(`SELECT l.expense_id, t.name FROM ExpenseTag l JOIN Tag t ON t.id = l.tag_id WHERE l.expense_id IN \(\$1\) ORDER BY t.name`).
+		WithArgs(21).
+		WillReturnRows(sqlmock.NewRows([]string{"expense_id", "name"}).AddRow(21, "coffee"))
+
+	expenses, total, err := models.ListExpenses(db, ledgerID, opts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 21, total)
+	assert.Len(t, expenses, 1)
+	assert.Equal(t, []string{"coffee"}, expenses[0].Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestParseSort(t *testing.T) {
+	fields, err := models.ParseSort("-date, amount", models.ExpenseSortFields)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SortField{{Field: "date", Desc: true}, {Field: "amount"}}, fields)
+
+	_, err = models.ParseSort("spent", models.ExpenseSortFields)
+	assert.EqualError(t, err, `cannot sort by "spent"`)
+}
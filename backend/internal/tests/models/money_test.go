@@ -0,0 +1,100 @@
+package models_test
+
+import (
+	"encoding/json"
+	"expense-tracker/internal/models"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]int64{
+		"12":     1200,
+		"12.5":   1250,
+		"12.50":  1250,
+		"-0.25":  -25,
+		".75":    75,
+		"1e2":    10000,
+		"0.10":   10,
+		"999.99": 99999,
+	}
+	for input, cents := range cases {
+		amount, err := models.ParseMoney(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, cents, amount.Cents(), input)
+	}
+
+	for _, input := range []string{"", "abc", "1.005", "1/2", "0x10", "1,000"} {
+		_, err := models.ParseMoney(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "0.00", models.Money(0).String())
+	assert.Equal(t, "0.05", models.Money(5).String())
+	assert.Equal(t, "-0.05", models.Money(-5).String())
+	assert.Equal(t, "1234.50", models.Money(123450).String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var payload struct {
+		Number models.Money `json:"number"`
+		String models.Money `json:"string"`
+		Null   models.Money `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"number": 19.99, "string": "0.10", "null": null}`), &payload)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money(1999), payload.Number)
+	assert.Equal(t, models.Money(10), payload.String)
+	assert.Equal(t, models.Money(0), payload.Null)
+
+	encoded, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"number": 19.99, "string": 0.10, "null": 0}`, string(encoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"number": 0.001}`), &payload))
+}
+
+func TestMoneyScanAndValue(t *testing.T) {
+	var amount models.Money
+
+	assert.NoError(t, amount.Scan([]byte("123.45")))
+	assert.Equal(t, models.Money(12345), amount)
+
+	assert.NoError(t, amount.Scan(float64(0.1)+float64(0.2)))
+	assert.Equal(t, models.Money(30), amount)
+
+	assert.NoError(t, amount.Scan(int64(7)))
+	assert.Equal(t, models.Money(700), amount)
+
+	assert.NoError(t, amount.Scan(nil))
+	assert.Equal(t, models.Money(0), amount)
+
+	value, err := models.Money(-1050).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "-10.50", value)
+}
+
+func TestMoneyArithmeticDoesNotDrift(t *testing.T) {
+	var spent models.Money
+	for i := 0; i < 1000; i++ {
+		spent = spent.Add(models.MustParseMoney("0.10"))
+	}
+	assert.Equal(t, models.MustParseMoney("100.00"), spent)
+	assert.Equal(t, models.MustParseMoney("99.90"), spent.Sub(models.MustParseMoney("0.10")))
+	assert.Equal(t, models.MustParseMoney("-100.00"), spent.Neg())
+	assert.Equal(t, models.MustParseMoney("300.00"), spent.Mul(3))
+	assert.Equal(t, models.MustParseMoney("0.30"), models.Sum(10, 10, 10))
+}
+
+func TestMoneyMulRatRoundsHalfAwayFromZero(t *testing.T) {
+	rate := big.NewRat(1, 2)
+
+	assert.Equal(t, models.Money(3), models.Money(5).MulRat(rate))
+	assert.Equal(t, models.Money(-3), models.Money(-5).MulRat(rate))
+	assert.Equal(t, models.Money(2), models.Money(4).MulRat(rate))
+}
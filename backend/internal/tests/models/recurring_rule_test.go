@@ -0,0 +1,72 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRecurringRuleMonthlyClampsToMonthEnd(t *testing.T) {
+	rule := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: date(2024, time.January, 31)}
+
+	assert.Equal(t, []time.Time{
+		date(2024, time.January, 31),
+		date(2024, time.February, 29),
+		date(2024, time.March, 31),
+		date(2024, time.April, 30),
+	}, rule.Occurrences(rule.StartDate, time.Time{}, 4))
+}
+
+func TestRecurringRuleDayOfMonthAfterStart(t *testing.T) {
+	rule := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 2, DayOfMonth: 1, StartDate: date(2024, time.January, 15)}
+
+	assert.Equal(t, []time.Time{
+		date(2024, time.February, 1),
+		date(2024, time.April, 1),
+		date(2024, time.June, 1),
+	}, rule.Occurrences(rule.StartDate, time.Time{}, 3))
+}
+
+func TestRecurringRuleWeeklyStopsAtEndDate(t *testing.T) {
+	end := date(2024, time.March, 20)
+	rule := models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, StartDate: date(2024, time.March, 1), EndDate: &end}
+
+	assert.Equal(t, []time.Time{
+		date(2024, time.March, 8),
+		date(2024, time.March, 15),
+	}, rule.Occurrences(date(2024, time.March, 2), time.Time{}, 0))
+	assert.Nil(t, rule.NextOnOrAfter(date(2024, time.March, 16)))
+}
+
+func TestValidateRecurringRule(t *testing.T) {
+	rule, err := models.ValidateRecurringRule(models.RecurringRule{
+		Kind:        "Expense",
+		Frequency:   "MONTHLY",
+		StartDate:   date(2024, time.January, 1),
+		CategoryID:  1,
+		Amount:      models.MustParseMoney("9.99"),
+		Currency:    "eur",
+		Description: "Streaming",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.RecurringExpense, rule.Kind)
+	assert.Equal(t, models.FrequencyMonthly, rule.Frequency)
+	assert.Equal(t, 1, rule.Interval)
+	assert.Equal(t, "EUR", rule.Currency)
+
+	_, err = models.ValidateRecurringRule(models.RecurringRule{
+		Kind:        models.RecurringIncome,
+		Frequency:   models.FrequencyWeekly,
+		DayOfMonth:  5,
+		StartDate:   date(2024, time.January, 1),
+		Amount:      models.MustParseMoney("100.00"),
+		Description: "Allowance",
+	})
+	assert.EqualError(t, err, "day of month only applies to monthly and yearly rules")
+}
@@ -0,0 +1,40 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSplits(t *testing.T) {
+	amount := models.MustParseMoney("45.50")
+	splits, err := models.ValidateSplits(amount, []models.ExpenseSplit{
+		{ID: 9, CategoryID: 2, Amount: models.MustParseMoney("40.00")},
+		{CategoryID: 3, Amount: models.MustParseMoney("5.50"), Memo: "  Plasters "},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ExpenseSplit{
+		{CategoryID: 2, Amount: models.MustParseMoney("40.00")},
+		{CategoryID: 3, Amount: models.MustParseMoney("5.50"), Memo: "Plasters"},
+	}, splits)
+
+	splits, err = models.ValidateSplits(amount, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, splits)
+
+	_, err = models.ValidateSplits(amount, []models.ExpenseSplit{{CategoryID: 2, Amount: models.MustParseMoney("45.00")}})
+	assert.EqualError(t, err, "split amounts add up to 45.00, not the expense amount of 45.50")
+	_, err = models.ValidateSplits(amount, []models.ExpenseSplit{{CategoryID: 2, Amount: amount}, {CategoryID: 3}})
+	assert.EqualError(t, err, "split 2 amount must be greater than zero")
+	_, err = models.ValidateSplits(amount, []models.ExpenseSplit{{Amount: amount}})
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestExpenseAllocations(t *testing.T) {
+	expense := models.Expense{CategoryID: 1, Amount: models.MustParseMoney("10.00")}
+	assert.Equal(t, []models.ExpenseSplit{{CategoryID: 1, Amount: models.MustParseMoney("10.00")}}, expense.Allocations())
+
+	expense.Splits = []models.ExpenseSplit{{CategoryID: 2, Amount: models.MustParseMoney("4.00")}, {CategoryID: 3, Amount: models.MustParseMoney("6.00")}}
+	assert.Equal(t, expense.Splits, expense.Allocations())
+}
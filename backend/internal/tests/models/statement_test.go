@@ -0,0 +1,215 @@
+package models_test
+
+import (
+	"expense-tracker/internal/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatementCSVSignedAmounts(t *testing.T) {
+	csv := `Date,Description,Amount
+2024-03-01,Coffee,-3.50
+2024-03-02,"Salary, March","2,500.00"
+
+2024-03-03,Refund,(12.00)
+2024-03-40,Broken,-1.00
+2024-03-05,Nothing,0
+`
+	statement, err := models.ParseStatementCSV(strings.NewReader(csv), models.ImportProfile{
+		Name: "Bank", DateColumn: "date", DescriptionColumn: "DESCRIPTION", AmountColumn: "Amount",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), statement.CategoryID)
+	if assert.Len(t, statement.Rows, 5) {
+		assert.Equal(t, models.StatementRow{
+			Line: 2, Type: models.TransactionExpense, Date: date(2024, 3, 1), Description: "Coffee", Amount: models.MustParseMoney("3.50"),
+		}, statement.Rows[0])
+		assert.Equal(t, models.TransactionIncome, statement.Rows[1].Type)
+		assert.Equal(t, models.MustParseMoney("2500.00"), statement.Rows[1].Amount)
+		assert.Equal(t, 5, statement.Rows[2].Line)
+		assert.Equal(t, models.TransactionExpense, statement.Rows[2].Type)
+		assert.Equal(t, `invalid date "2024-03-40" (expected YYYY-MM-DD)`, statement.Rows[3].Error)
+		assert.Equal(t, "amount is missing or zero", statement.Rows[4].Error)
+	}
+}
+
+func TestParseStatementCSVDebitAndCredit(t *testing.T) {
+	csv := `Account statement;;;
+Exported 2024-03-31;;;
+Buchungstag;Text;Soll;Haben;Währung
+05.03.2024;Supermarkt;1.234,56;;EUR
+06.03.2024;Gehalt;;3.000,00;EUR
+07.03.2024;Both;1,00;2,00;EUR
+`
+	statement, err := models.ParseStatementCSV(strings.NewReader(csv), models.ImportProfile{
+		Name: "Sparkasse", Delimiter: ";", SkipRows: 2, DateColumn: "Buchungstag", DateFormat: "DD.MM.YYYY",
+		DescriptionColumn: "Text", DebitColumn: "Soll", CreditColumn: "Haben", DecimalSeparator: ",", CurrencyColumn: "Währung",
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 3) {
+		assert.Equal(t, models.StatementRow{
+			Line: 4, Type: models.TransactionExpense, Date: date(2024, 3, 5), Description: "Supermarkt", Amount: models.MustParseMoney("1234.56"), Currency: "EUR",
+		}, statement.Rows[0])
+		assert.Equal(t, models.TransactionIncome, statement.Rows[1].Type)
+		assert.Equal(t, models.MustParseMoney("3000.00"), statement.Rows[1].Amount)
+		assert.Equal(t, "row has both a debit and a credit", statement.Rows[2].Error)
+	}
+
+	_, err = models.ParseStatementCSV(strings.NewReader("Date,Text,Amount\n"), models.ImportProfile{
+		Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount",
+	})
+	assert.EqualError(t, err, `CSV header is missing the "Memo" column`)
+}
+
+func TestParseStatementCSVPositiveExpenses(t *testing.T) {
+	csv := "Posted,Payee,Amount\n3/9/24,Airline,412.20\n3/10/24,Payment received,-500.00\n"
+	statement, err := models.ParseStatementCSV(strings.NewReader(csv), models.ImportProfile{
+		Name: "Card", DateColumn: "Posted", DateFormat: "M/D/YY", DescriptionColumn: "Payee", AmountColumn: "Amount",
+		SignConvention: models.SignPositiveExpense,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 2) {
+		assert.Equal(t, date(2024, 3, 9), statement.Rows[0].Date)
+		assert.Equal(t, models.TransactionExpense, statement.Rows[0].Type)
+		assert.Equal(t, models.TransactionIncome, statement.Rows[1].Type)
+	}
+}
+
+func TestNormalizeImportProfile(t *testing.T) {
+	profile, err := models.NormalizeImportProfile(models.ImportProfile{
+		Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount", Currency: "eur",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ",", profile.Delimiter)
+	assert.Equal(t, "YYYY-MM-DD", profile.DateFormat)
+	assert.Equal(t, models.SignNegativeExpense, profile.SignConvention)
+	assert.Equal(t, "EUR", profile.Currency)
+
+	for _, tc := range []struct {
+		profile models.ImportProfile
+		field   string
+	}{
+		{models.ImportProfile{DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount"}, "name"},
+		{models.ImportProfile{Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", DebitColumn: "Out"}, "amount_column"},
+		{models.ImportProfile{Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount", DebitColumn: "Out", CreditColumn: "In"}, "amount_column"},
+		{models.ImportProfile{Name: "Bank", DateColumn: "Date", DateFormat: "MM/YYYY", DescriptionColumn: "Memo", AmountColumn: "Amount"}, "date_format"},
+		{models.ImportProfile{Name: "Bank", Delimiter: ",", DecimalSeparator: ",", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount"}, "decimal_separator"},
+		{models.ImportProfile{Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount", SignConvention: "debit"}, "sign_convention"},
+	} {
+		_, err := models.NormalizeImportProfile(tc.profile)
+		var domainErr *models.Error
+		if assert.ErrorAs(t, err, &domainErr) {
+			assert.Equal(t, tc.field, domainErr.Field)
+		}
+	}
+}
+
+func TestParseStatementOFX(t *testing.T) {
+	sgml := `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM><BANKID>123<ACCTID>987654<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240305120000.000[-5:EST]
+<TRNAMT>-42.50
+<FITID>T-1
+<NAME>Bakery &amp; Co
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240306
+<TRNAMT>1500,00
+<FITID>T-2
+<MEMO>Salary
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+	statement, err := models.ParseStatementOFX(strings.NewReader(sgml))
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 2) {
+		assert.Equal(t, models.StatementRow{
+			Line: 10, Type: models.TransactionExpense, Date: date(2024, 3, 5), Description: "Bakery & Co",
+			Amount: models.MustParseMoney("42.50"), Currency: "EUR", ExternalID: "ofx:987654:T-1",
+		}, statement.Rows[0])
+		assert.Equal(t, models.TransactionIncome, statement.Rows[1].Type)
+		assert.Equal(t, "Salary", statement.Rows[1].Description)
+		assert.Equal(t, models.MustParseMoney("1500.00"), statement.Rows[1].Amount)
+	}
+
+	xml := `<?xml version="1.0"?><?OFX OFXHEADER="200" VERSION="220"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS><CURDEF>USD</CURDEF>
+<CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST><STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240309</DTPOSTED><TRNAMT>-9.99</TRNAMT><FITID>A1</FITID><NAME>Streaming</NAME></STMTTRN>
+<STMTTRN><DTPOSTED>2024</DTPOSTED><TRNAMT>-1.00</TRNAMT><FITID>A2</FITID></STMTTRN></BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>`
+	statement, err = models.ParseStatementOFX(strings.NewReader(xml))
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 2) {
+		assert.Equal(t, "ofx:4111:A1", statement.Rows[0].ExternalID)
+		assert.Equal(t, "Streaming", statement.Rows[0].Description)
+		assert.Equal(t, "USD", statement.Rows[0].Currency)
+		assert.Equal(t, `invalid date "2024"`, statement.Rows[1].Error)
+	}
+
+	_, err = models.ParseStatementOFX(strings.NewReader("Date,Amount\n"))
+	assert.ErrorIs(t, err, models.ErrValidation)
+}
+
+func TestParseStatementQIF(t *testing.T) {
+	qif := `!Type:Cat
+NGroceries
+^
+!Type:Bank
+D3/ 5'24
+T-4.20
+PCoffee
+^
+D03/05/2024
+T-4.20
+PCoffee
+^
+D3/6/2024
+T1,250.00
+MRefund
+^
+D13/40/2024
+T-1.00
+^
+`
+	statement, err := models.ParseStatementQIF(strings.NewReader(qif), "")
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 4) {
+		assert.Equal(t, 5, statement.Rows[0].Line)
+		assert.Equal(t, date(2024, 3, 5), statement.Rows[0].Date)
+		assert.Equal(t, models.TransactionExpense, statement.Rows[0].Type)
+		assert.Equal(t, "Coffee", statement.Rows[0].Description)
+		// The same coffee bought twice in a day is two transactions
+		assert.NotEmpty(t, statement.Rows[0].ExternalID)
+		assert.NotEqual(t, statement.Rows[0].ExternalID, statement.Rows[1].ExternalID)
+		assert.Equal(t, models.TransactionIncome, statement.Rows[2].Type)
+		assert.Equal(t, "Refund", statement.Rows[2].Description)
+		assert.Equal(t, models.MustParseMoney("1250.00"), statement.Rows[2].Amount)
+		assert.Equal(t, `invalid date "13/40/2024" (expected M/D/YYYY)`, statement.Rows[3].Error)
+	}
+
+	// Hashes are stable across files
+	again, err := models.ParseStatementQIF(strings.NewReader(qif), "")
+	assert.NoError(t, err)
+	assert.Equal(t, statement.Rows[1].ExternalID, again.Rows[1].ExternalID)
+
+	statement, err = models.ParseStatementQIF(strings.NewReader("!Type:CCard\nD05.03.24\nT-3.00\nPBus\n^\n"), "DD.MM.YYYY")
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Rows, 1) {
+		assert.Equal(t, date(2024, 3, 5), statement.Rows[0].Date)
+	}
+}
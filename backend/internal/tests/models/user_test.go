@@ -0,0 +1,94 @@
+package models_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"expense-tracker/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	email, err := models.NormalizeEmail("  Ann@Example.COM ")
+	assert.NoError(t, err)
+	assert.Equal(t, "ann@example.com", email)
+
+	for _, invalid := range []string{"", "ann", "Ann <ann@example.com>", "ann@example.com, bob@example.com"} {
+		_, err := models.NormalizeEmail(invalid)
+		assert.ErrorIs(t, err, models.ErrValidation, invalid)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	assert.NoError(t, models.ValidatePassword("correct horse"))
+	assert.ErrorIs(t, models.ValidatePassword("short"), models.ErrValidation)
+	assert.ErrorIs(t, models.ValidatePassword(strings.Repeat("x", 257)), models.ErrValidation)
+}
+
+func TestPasswordHash(t *testing.T) {
+	iterations := models.PasswordHashIterations
+	models.PasswordHashIterations = 1000
+	defer func() { models.PasswordHashIterations = iterations }()
+
+	hash, err := models.HashPassword("correct horse")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "pbkdf2-sha256$1000$"))
+	assert.True(t, models.CheckPassword(hash, "correct horse"))
+	assert.False(t, models.CheckPassword(hash, "correct horse!"))
+
+	// Each hash has its own salt
+	other, err := models.HashPassword("correct horse")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	assert.False(t, models.CheckPassword("", "correct horse"))
+	assert.False(t, models.CheckPassword("bcrypt$10$salt$key", "correct horse"))
+}
+
+func TestPasswordHashMatchesPBKDF2(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vector from RFC 7914, section 11
+	key, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	hash := "pbkdf2-sha256$1$" + base64.RawStdEncoding.EncodeToString([]byte("salt")) + "$" + base64.RawStdEncoding.EncodeToString(key)
+
+	assert.True(t, models.CheckPassword(hash, "passwd"))
+	assert.False(t, models.CheckPassword(hash, "password"))
+}
+
+func TestCreateSessionRejectsUnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, email, created_at, password_hash FROM UserAccount WHERE email = \\$1").
+		WithArgs("ann@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "created_at", "password_hash"}))
+
+	_, err = models.CreateSession(db, "Ann@example.com", "correct horse")
+	assert.ErrorIs(t, err, models.ErrUnauthorized)
+	assert.EqualError(t, err, "invalid email or password")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSessionUserRejectsUnknownToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Only the hash of the token is looked up
+	mock.ExpectQuery("FROM Session s JOIN UserAccount u ON u.id = s.user_id WHERE s.token_hash = \\$1 AND s.expires_at > \\$2").
+		WithArgs(models.HashToken("token"), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "created_at"}))
+
+	_, err = models.GetSessionUser(db, "token")
+	assert.ErrorIs(t, err, models.ErrUnauthorized)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,72 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountBalances(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		checking, err := stores.Accounts.CreateAccount(models.Account{
+			Name: "Checking", Type: models.AccountChecking, Currency: "eur", OpeningBalance: models.MustParseMoney("100.00"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "EUR", checking.Currency)
+
+		_, err = stores.Accounts.CreateAccount(models.Account{Name: "Checking", Type: models.AccountCash})
+		assert.ErrorIs(t, err, models.ErrConflict)
+		_, err = stores.Accounts.CreateAccount(models.Account{Name: "Wallet", Type: "piggy_bank"})
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+
+		// Entries default to the account's currency and must not use another
+		income, err := stores.Incomes.CreateIncome(models.Income{AccountID: &checking.ID, Amount: models.MustParseMoney("500.00"), Date: day(time.January, 31), Source: "Salary"})
+		assert.NoError(t, err)
+		assert.Equal(t, "EUR", income.Currency)
+		_, err = stores.Expenses.CreateExpense(models.Expense{AccountID: &checking.ID, CategoryID: 1, Amount: models.MustParseMoney("20.00"), Currency: "USD", Date: day(time.February, 3), Description: "Books"})
+		assert.EqualError(t, err, "currency must match the account currency EUR")
+		missing := int64(999)
+		_, err = stores.Expenses.CreateExpense(models.Expense{AccountID: &missing, CategoryID: 1, Amount: models.MustParseMoney("20.00"), Date: day(time.February, 3), Description: "Books"})
+		assert.EqualError(t, err, "account 999 does not exist")
+
+		_, err = stores.Expenses.CreateExpense(models.Expense{AccountID: &checking.ID, CategoryID: 1, Amount: models.MustParseMoney("150.00"), Date: day(time.February, 3), Description: "Rent"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("9.99"), Date: day(time.February, 4), Description: "Not on an account"})
+		assert.NoError(t, err)
+
+		account, err := stores.Accounts.GetAccountByID(checking.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("450.00"), account.Balance)
+
+		balances, err := stores.Accounts.GetAccountBalances(checking.ID, day(time.February, 10), day(time.March, 10), models.IntervalMonth)
+		assert.NoError(t, err)
+		if assert.Len(t, balances, 2) {
+			assert.Equal(t, day(time.February, 1), balances[0].Start)
+			assert.Equal(t, day(time.February, 29), balances[0].End)
+			assert.Equal(t, models.MustParseMoney("150.00"), balances[0].Outflow)
+			assert.Equal(t, models.MustParseMoney("450.00"), balances[0].Balance)
+			assert.Equal(t, models.MustParseMoney("450.00"), balances[1].Balance)
+		}
+
+		expenses, total, err := stores.Expenses.ListExpenses(models.ListOptions{AccountID: checking.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, expenses, 1) {
+			assert.Equal(t, "Rent", expenses[0].Description)
+		}
+
+		_, err = stores.Accounts.UpdateAccount(models.Account{ID: checking.ID, Name: "Checking", Type: models.AccountChecking, Currency: "USD"})
+		assert.ErrorIs(t, err, models.ErrConflict)
+		account, err = stores.Accounts.UpdateAccount(models.Account{ID: checking.ID, Name: "Main", Type: models.AccountChecking, Currency: "EUR"})
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("350.00"), account.Balance)
+
+		assert.ErrorIs(t, stores.Accounts.DeleteAccount(checking.ID), models.ErrConflict)
+		assert.ErrorIs(t, stores.Accounts.DeleteAccount(missing), models.ErrNotFound)
+	})
+}
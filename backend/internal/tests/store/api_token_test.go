@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokens(t *testing.T) {
+	eachBackend(t, func(t *testing.T, backend store.Backend) {
+		tokens := backend.Tokens()
+		ann, err := backend.Users().Register("ann@example.com", "correct horse")
+		assert.NoError(t, err)
+		bob, err := backend.Users().Register("bob@example.com", "correct horse")
+		assert.NoError(t, err)
+
+		_, err = tokens.CreateAPIToken(ann.ID, models.APIToken{Name: "bot", Scopes: []string{"expenses:delete"}})
+		assert.ErrorIs(t, err, models.ErrValidation)
+		past := time.Now().Add(-time.Hour)
+		_, err = tokens.CreateAPIToken(ann.ID, models.APIToken{Name: "bot", Scopes: []string{"expenses:write"}, ExpiresAt: &past})
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		expiresAt := time.Now().Add(24 * time.Hour)
+		bot, err := tokens.CreateAPIToken(ann.ID, models.APIToken{Name: " bot ", Scopes: []string{"reports:read", "expenses:write", "reports:read"}, ExpiresAt: &expiresAt})
+		assert.NoError(t, err)
+		assert.Equal(t, "bot", bot.Name)
+		assert.Equal(t, []string{"expenses:write", "reports:read"}, bot.Scopes)
+		assert.True(t, strings.HasPrefix(bot.Token, models.APITokenPrefix))
+		assert.Nil(t, bot.LastUsedAt)
+
+		user, used, err := tokens.AuthenticateAPIToken(bot.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, ann.ID, user.ID)
+		assert.Equal(t, bot.ID, used.ID)
+		assert.Equal(t, bot.Scopes, used.Scopes)
+		assert.True(t, used.HasScope("expenses", true))
+		assert.True(t, used.HasScope("reports", false))
+		assert.False(t, used.HasScope("reports", true))
+		assert.False(t, used.HasScope("budgets", false))
+		_, _, err = tokens.AuthenticateAPIToken(models.APITokenPrefix + "unknown")
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+
+		// The token itself is never listed, but its last use is
+		listed, err := tokens.GetAPITokens(ann.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, listed, 1) {
+			assert.Empty(t, listed[0].Token)
+			if assert.NotNil(t, listed[0].LastUsedAt) {
+				assert.WithinDuration(t, time.Now(), *listed[0].LastUsedAt, time.Minute)
+			}
+			if assert.NotNil(t, listed[0].ExpiresAt) {
+				assert.WithinDuration(t, expiresAt, *listed[0].ExpiresAt, time.Second)
+			}
+		}
+		listed, err = tokens.GetAPITokens(bob.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, listed)
+
+		// Only its user can revoke it, which they can do twice
+		assert.ErrorIs(t, tokens.RevokeAPIToken(bob.ID, bot.ID), models.ErrNotFound)
+		assert.NoError(t, tokens.RevokeAPIToken(ann.ID, bot.ID))
+		assert.NoError(t, tokens.RevokeAPIToken(ann.ID, bot.ID))
+		_, _, err = tokens.AuthenticateAPIToken(bot.Token)
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+		listed, err = tokens.GetAPITokens(ann.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, listed, 1) {
+			assert.NotNil(t, listed[0].RevokedAt)
+		}
+	})
+}
+
+func TestExpiredAPIToken(t *testing.T) {
+	db, backend := newSQLiteBackend(t)
+	ann, err := backend.Users().Register("ann@example.com", "correct horse")
+	assert.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour)
+	bot, err := backend.Tokens().CreateAPIToken(ann.ID, models.APIToken{Name: "bot", Scopes: []string{"expenses:read"}, ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	if _, err := db.Exec("UPDATE ApiToken SET expires_at = $1", time.Now().Add(-time.Minute).UTC()); err != nil {
+		t.Fatalf("failed to expire token: %v", err)
+	}
+	_, _, err = backend.Tokens().AuthenticateAPIToken(bot.Token)
+	assert.ErrorIs(t, err, models.ErrUnauthorized)
+}
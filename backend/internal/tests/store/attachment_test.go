@@ -0,0 +1,72 @@
+package store_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachments(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+		expense, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("42.00"), Date: day, Description: "Hotel"})
+		assert.NoError(t, err)
+
+		receipt := "%PDF-1.4 hotel receipt"
+		digest := sha256.Sum256([]byte(receipt))
+		attachment, err := stores.Attachments.CreateAttachment(models.Attachment{ExpenseID: expense.ID, FileName: `C:\scans\receipt.pdf`, ContentType: "application/pdf"}, strings.NewReader(receipt))
+		assert.NoError(t, err)
+		assert.Equal(t, "receipt.pdf", attachment.FileName)
+		assert.Equal(t, int64(len(receipt)), attachment.Size)
+		assert.Equal(t, hex.EncodeToString(digest[:]), attachment.SHA256)
+
+		_, content, err := stores.Attachments.OpenAttachment(expense.ID, attachment.ID)
+		if assert.NoError(t, err) {
+			stored, err := io.ReadAll(content)
+			content.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, receipt, string(stored))
+		}
+
+		// Rejected uploads and missing expenses keep nothing
+		_, err = stores.Attachments.CreateAttachment(models.Attachment{ExpenseID: expense.ID, FileName: "empty.txt"}, strings.NewReader(""))
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = stores.Attachments.CreateAttachment(models.Attachment{ExpenseID: 999, FileName: "receipt.pdf"}, strings.NewReader(receipt))
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		attachments, err := stores.Attachments.GetAttachments(expense.ID)
+		assert.NoError(t, err)
+		assert.Len(t, attachments, 1)
+		_, err = stores.Attachments.GetAttachments(999)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+
+		// An attachment is only found under its own expense
+		other, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("42.00"), Date: day, Description: "Hotel"})
+		assert.NoError(t, err)
+		_, err = stores.Attachments.GetAttachmentByID(other.ID, attachment.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+
+		// Merging a duplicate moves its attachments to the kept expense
+		scan, err := stores.Attachments.CreateAttachment(models.Attachment{ExpenseID: other.ID, FileName: "scan.png", ContentType: "image/png"}, strings.NewReader("png"))
+		assert.NoError(t, err)
+		_, err = stores.Expenses.MergeExpense(other.ID, expense.ID)
+		assert.NoError(t, err)
+		attachments, err = stores.Attachments.GetAttachments(expense.ID)
+		assert.NoError(t, err)
+		assert.Len(t, attachments, 2)
+
+		assert.NoError(t, stores.Attachments.DeleteAttachment(expense.ID, scan.ID))
+		assert.ErrorIs(t, stores.Attachments.DeleteAttachment(expense.ID, scan.ID), models.ErrNotFound)
+
+		// Deleting the expense removes its attachments
+		assert.NoError(t, stores.Expenses.DeleteExpense(expense.ID))
+		_, _, err = stores.Attachments.OpenAttachment(expense.ID, attachment.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBudgetCategory(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		food, err := stores.Categories.CreateCategory(models.Category{Name: "Food"})
+		assert.NoError(t, err)
+		travel, err := stores.Categories.CreateCategory(models.Category{Name: "Travel"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: food.ID, Amount: models.MustParseMoney("10.00"), Date: day(2), Description: "Snacks"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: travel.ID, Amount: models.MustParseMoney("80.00"), Date: day(3), Description: "Train"})
+		assert.NoError(t, err)
+
+		budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: food.ID, Amount: models.MustParseMoney("300.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		budget.CategoryID = 999
+		_, err = stores.Budgets.UpdateBudget(budget)
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		// Moving a budget to another category counts that category's spending
+		budget.CategoryID = travel.ID
+		updated, err := stores.Budgets.UpdateBudget(budget)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("80.00"), updated.Spent)
+		stored, err := stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, travel.ID, stored.CategoryID)
+		assert.Equal(t, models.MustParseMoney("80.00"), stored.Spent)
+
+		// and keeps following it
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: travel.ID, Amount: models.MustParseMoney("20.00"), Date: day(4), Description: "Bus"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: food.ID, Amount: models.MustParseMoney("5.00"), Date: day(4), Description: "Coffee"})
+		assert.NoError(t, err)
+		stored, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("100.00"), stored.Spent)
+	})
+}
+
+func TestBudgetWithoutExchangeRate(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("300.00"), Currency: "EUR", StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		assert.False(t, budget.Stale)
+
+		// An expense no rate converts into the budget currency is still
+		// recorded, and leaves the budget stale instead
+		taxi, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("12.50"), Currency: "USD", Date: day(2), Description: "Taxi"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("8.00"), Currency: "EUR", Date: day(3), Description: "Lunch"})
+		assert.NoError(t, err)
+		taxi.Amount = models.MustParseMoney("25.00")
+		_, err = stores.Expenses.UpdateExpense(taxi)
+		assert.NoError(t, err)
+
+		stored, err := stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Stale)
+		assert.Equal(t, models.MustParseMoney("8.00"), stored.Spent)
+
+		// Importing the missing rate recalculates it
+		_, err = stores.ExchangeRates.ImportExchangeRates([]models.ExchangeRate{{Date: day(1), BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.25"}})
+		assert.NoError(t, err)
+		stored, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.False(t, stored.Stale)
+		assert.Equal(t, models.MustParseMoney("28.00"), stored.Spent) // 8.00 + 25.00 / 1.25
+
+		// Deleting a category recalculates what it can rather than failing
+		other, err := stores.Categories.CreateCategory(models.Category{Name: "Travel"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: other.ID, Amount: models.MustParseMoney("40.00"), Currency: "GBP", Date: day(4), Description: "Train"})
+		assert.NoError(t, err)
+		assert.NoError(t, stores.Categories.DeleteCategory(other.ID))
+		stored, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Stale)
+		assert.Equal(t, models.MustParseMoney("28.00"), stored.Spent)
+
+		// Creating a budget still needs every rate
+		assert.NoError(t, stores.Budgets.DeleteBudget(budget.ID))
+		_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("300.00"), Currency: "EUR", StartDate: day(1), EndDate: day(31)})
+		assert.ErrorIs(t, err, models.ErrNoExchangeRate)
+	})
+}
@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryRules(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		groceries, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+		transport, err := stores.Categories.CreateCategory(models.Category{Name: "Transport"})
+		assert.NoError(t, err)
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		budget, err := stores.Budgets.CreateBudget(models.Budget{
+			CategoryID: groceries.ID, Amount: models.MustParseMoney("300.00"), StartDate: day(1), EndDate: day(31),
+		})
+		assert.NoError(t, err)
+
+		// Expenses recorded before the rules exist stay in 'Other'
+		for _, description := range []string{"Market hall", "Uber trip", "Bookshop"} {
+			expense, err := stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("20.00"), Date: day(2), Description: description})
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), expense.CategoryID)
+		}
+
+		_, err = stores.CategoryRules.CreateCategoryRule(models.CategoryRule{Name: "Taxis", Priority: 2, CategoryID: transport.ID, DescriptionPattern: `(?i)uber|taxi`})
+		assert.NoError(t, err)
+		market, err := stores.CategoryRules.CreateCategoryRule(models.CategoryRule{Name: "Markets", Priority: 1, CategoryID: groceries.ID, DescriptionContains: "market"})
+		assert.NoError(t, err)
+		_, err = stores.CategoryRules.CreateCategoryRule(models.CategoryRule{Name: "Missing", CategoryID: 999, DescriptionContains: "x"})
+		assert.EqualError(t, err, "category 999 does not exist")
+
+		rules, err := stores.CategoryRules.GetCategoryRules()
+		assert.NoError(t, err)
+		if assert.Len(t, rules, 2) {
+			assert.Equal(t, "Markets", rules[0].Name)
+		}
+
+		matches, err := stores.CategoryRules.TestCategoryRule(models.CategoryRule{DescriptionContains: "BOOK"})
+		assert.NoError(t, err)
+		if assert.Len(t, matches, 1) {
+			assert.Equal(t, "Bookshop", matches[0].Description)
+		}
+
+		moved, err := stores.CategoryRules.ApplyCategoryRules()
+		assert.NoError(t, err)
+		assert.Len(t, moved, 2)
+		budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("20.00"), budget.Spent)
+		others, _, err := stores.Expenses.ListExpenses(models.ListOptions{CategoryID: 1})
+		assert.NoError(t, err)
+		if assert.Len(t, others, 1) {
+			assert.Equal(t, "Bookshop", others[0].Description)
+		}
+
+		// New expenses without a category, and imported ones, are categorized
+		created, err := stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("8.00"), Date: day(3), Description: "Taxi to market"})
+		assert.NoError(t, err)
+		assert.Equal(t, groceries.ID, created.CategoryID)
+		created, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("8.00"), Date: day(3), Description: "Taxi"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), created.CategoryID)
+
+		profile, err := stores.Imports.CreateImportProfile(models.ImportProfile{Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount"})
+		assert.NoError(t, err)
+		statement, err := models.ParseStatementCSV(strings.NewReader("Date,Memo,Amount\n2024-03-04,UBER *TRIP,-12.00\n2024-03-05,Cinema,-9.00\n"), profile)
+		assert.NoError(t, err)
+		report, err := stores.Imports.ImportStatement(statement, false)
+		assert.NoError(t, err)
+		assert.Equal(t, transport.ID, report.Rows[0].CategoryID)
+		assert.Equal(t, int64(1), report.Rows[1].CategoryID)
+
+		// Deleting the category a rule assigns deletes the rule
+		assert.NoError(t, stores.Categories.DeleteCategory(groceries.ID))
+		_, err = stores.CategoryRules.GetCategoryRuleByID(market.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
@@ -0,0 +1,189 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryHierarchy(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		food, err := stores.Categories.CreateCategory(models.Category{Name: "Food"})
+		assert.NoError(t, err)
+		groceries, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries", ParentID: &food.ID})
+		assert.NoError(t, err)
+		produce, err := stores.Categories.CreateCategory(models.Category{Name: "Produce", ParentID: &groceries.ID})
+		assert.NoError(t, err)
+		missing := int64(999)
+		_, err = stores.Categories.CreateCategory(models.Category{Name: "Lost", ParentID: &missing})
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		// A parent cannot move under its own sub-category
+		food.ParentID = &produce.ID
+		_, err = stores.Categories.UpdateCategory(food)
+		assert.ErrorIs(t, err, models.ErrValidation)
+		food.ParentID = nil
+
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: food.ID, Amount: models.MustParseMoney("10.00"), Date: day(2), Description: "Snacks"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: produce.ID, Amount: models.MustParseMoney("25.00"), Date: day(3), Description: "Apples"})
+		assert.NoError(t, err)
+
+		// A budget on the parent counts spending in every sub-category
+		budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: food.ID, Amount: models.MustParseMoney("300.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("35.00"), budget.Spent)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: groceries.ID, Amount: models.MustParseMoney("40.00"), Date: day(4), Description: "Weekly shop"})
+		assert.NoError(t, err)
+		budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("75.00"), budget.Spent)
+
+		// and so clashes with a budget on one of them for the same period
+		_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: produce.ID, Amount: models.MustParseMoney("50.00"), StartDate: day(15), EndDate: day(20)})
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		// Moving a sub-category out takes its spending out of the parent's budgets
+		groceries.ParentID = nil
+		_, err = stores.Categories.UpdateCategory(groceries)
+		assert.NoError(t, err)
+		budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("10.00"), budget.Spent)
+		groceries.ParentID = &food.ID
+		_, err = stores.Categories.UpdateCategory(groceries)
+		assert.NoError(t, err)
+
+		categories, err := stores.Categories.GetCategories()
+		assert.NoError(t, err)
+		tree := models.CategoryTree(categories)
+		assert.Len(t, tree, 2)
+		assert.Equal(t, "Food", tree[1].Name)
+		assert.Equal(t, "Groceries", tree[1].Children[0].Name)
+		assert.Equal(t, "Produce", tree[1].Children[0].Children[0].Name)
+
+		// Deleting a category moves its sub-categories and expenses up a level
+		assert.NoError(t, stores.Categories.DeleteCategory(groceries.ID))
+		produce, err = stores.Categories.GetCategoryByID(produce.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, &food.ID, produce.ParentID)
+		expenses, _, err := stores.Expenses.ListExpenses(models.ListOptions{CategoryID: food.ID})
+		assert.NoError(t, err)
+		assert.Len(t, expenses, 2)
+		budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("75.00"), budget.Spent)
+
+		// and a top-level category's expenses go to 'Other'
+		assert.NoError(t, stores.Categories.DeleteCategory(food.ID))
+		produce, err = stores.Categories.GetCategoryByID(produce.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, produce.ParentID)
+		expenses, _, err = stores.Expenses.ListExpenses(models.ListOptions{CategoryID: 1})
+		assert.NoError(t, err)
+		assert.Len(t, expenses, 2)
+	})
+}
+
+func TestDeleteCategoryUpdatesOtherBudgets(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		hobbies, err := stores.Categories.CreateCategory(models.Category{Name: "Hobbies"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Date: day(2), Description: "Stamps"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: hobbies.ID, Amount: models.MustParseMoney("30.00"), Date: day(3), Description: "Paint"})
+		assert.NoError(t, err)
+		other, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("5.00"), other.Spent)
+
+		assert.NoError(t, stores.Categories.DeleteCategory(hobbies.ID))
+		other, err = stores.Budgets.GetBudgetByID(other.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("35.00"), other.Spent)
+	})
+}
+
+func TestMergeCategory(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		setup := func() (models.Category, models.Category, models.Budget, models.Budget) {
+			eatingOut, err := stores.Categories.CreateCategory(models.Category{Name: "Eating out"})
+			assert.NoError(t, err)
+			restaurants, err := stores.Categories.CreateCategory(models.Category{Name: "Restaurants"})
+			assert.NoError(t, err)
+			_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: eatingOut.ID, Amount: models.MustParseMoney("30.00"), Date: day(2), Description: "Pizza"})
+			assert.NoError(t, err)
+			_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: restaurants.ID, Amount: models.MustParseMoney("45.00"), Date: day(3), Description: "Bistro"})
+			assert.NoError(t, err)
+			source, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: eatingOut.ID, Amount: models.MustParseMoney("100.00"), StartDate: day(1), EndDate: day(31)})
+			assert.NoError(t, err)
+			target, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: restaurants.ID, Amount: models.MustParseMoney("150.00"), StartDate: day(1), EndDate: day(31)})
+			assert.NoError(t, err)
+			return eatingOut, restaurants, source, target
+		}
+
+		// Overlapping budgets refuse the merge by default, changing nothing
+		eatingOut, restaurants, sourceBudget, targetBudget := setup()
+		_, err := stores.Categories.MergeCategory(eatingOut.ID, restaurants.ID, models.MergeBudgetsFail)
+		assert.ErrorIs(t, err, models.ErrConflict)
+		_, err = stores.Categories.GetCategoryByID(eatingOut.ID)
+		assert.NoError(t, err)
+		_, err = stores.Budgets.GetBudgetByID(sourceBudget.ID)
+		assert.NoError(t, err)
+
+		_, err = stores.Categories.MergeCategory(eatingOut.ID, eatingOut.ID, models.MergeBudgetsSum)
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = stores.Categories.MergeCategory(1, restaurants.ID, models.MergeBudgetsSum)
+		assert.ErrorIs(t, err, models.ErrForbidden)
+		_, err = stores.Categories.MergeCategory(999, restaurants.ID, models.MergeBudgetsSum)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+
+		// sum adds the merged budget to the target's and counts both categories' spending
+		rule, err := stores.CategoryRules.CreateCategoryRule(models.CategoryRule{Name: "Pizza", CategoryID: eatingOut.ID, DescriptionContains: "pizza"})
+		assert.NoError(t, err)
+		merged, err := stores.Categories.MergeCategory(eatingOut.ID, restaurants.ID, models.MergeBudgetsSum)
+		assert.NoError(t, err)
+		assert.Equal(t, restaurants.ID, merged.ID)
+		_, err = stores.Categories.GetCategoryByID(eatingOut.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		_, err = stores.Budgets.GetBudgetByID(sourceBudget.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		targetBudget, err = stores.Budgets.GetBudgetByID(targetBudget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("250.00"), targetBudget.Amount)
+		assert.Equal(t, models.MustParseMoney("75.00"), targetBudget.Spent)
+		expenses, _, err := stores.Expenses.ListExpenses(models.ListOptions{CategoryID: restaurants.ID})
+		assert.NoError(t, err)
+		assert.Len(t, expenses, 2)
+		rule, err = stores.CategoryRules.GetCategoryRuleByID(rule.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, restaurants.ID, rule.CategoryID)
+
+		// keep_target drops the merged budget; budgets that do not overlap move over
+		takeaway, err := stores.Categories.CreateCategory(models.Category{Name: "Takeaway"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: takeaway.ID, Amount: models.MustParseMoney("12.00"), Date: day(9), Description: "Noodles"})
+		assert.NoError(t, err)
+		dropped, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: takeaway.ID, Amount: models.MustParseMoney("40.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		moved, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: takeaway.ID, Amount: models.MustParseMoney("40.00"), StartDate: day(1).AddDate(0, 1, 0), EndDate: day(30).AddDate(0, 1, 0)})
+		assert.NoError(t, err)
+		_, err = stores.Categories.MergeCategory(takeaway.ID, restaurants.ID, models.MergeBudgetsKeepTarget)
+		assert.NoError(t, err)
+		_, err = stores.Budgets.GetBudgetByID(dropped.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		moved, err = stores.Budgets.GetBudgetByID(moved.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, restaurants.ID, moved.CategoryID)
+		targetBudget, err = stores.Budgets.GetBudgetByID(targetBudget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("250.00"), targetBudget.Amount)
+		assert.Equal(t, models.MustParseMoney("87.00"), targetBudget.Spent)
+	})
+}
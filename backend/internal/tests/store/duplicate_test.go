@@ -0,0 +1,58 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateExpenses(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("500.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+
+		create := func(description string, d int) models.Expense {
+			expense, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("42.00"), Date: day(d), Description: description})
+			assert.NoError(t, err)
+			return expense
+		}
+		original := create("Hardware store", 4)
+		assert.Empty(t, original.PossibleDuplicates)
+		far := create("Hardware store", 12)
+		assert.Empty(t, far.PossibleDuplicates)
+		double := create("HARDWARE STORE #7", 6)
+		assert.Equal(t, []int64{original.ID}, double.PossibleDuplicates)
+		lookalike := create("Hardware store", 3)
+		assert.Equal(t, []int64{original.ID, double.ID}, lookalike.PossibleDuplicates)
+
+		pairs, err := stores.Expenses.GetDuplicateExpenses()
+		assert.NoError(t, err)
+		assert.Len(t, pairs, 3)
+
+		// Dismissed pairs stay dismissed, whichever way round they are given
+		assert.NoError(t, stores.Expenses.DismissDuplicate(lookalike.ID, original.ID))
+		assert.NoError(t, stores.Expenses.DismissDuplicate(original.ID, lookalike.ID))
+		assert.ErrorIs(t, stores.Expenses.DismissDuplicate(original.ID, 999), models.ErrNotFound)
+		assert.ErrorIs(t, stores.Expenses.DismissDuplicate(original.ID, original.ID), models.ErrValidation)
+		pairs, err = stores.Expenses.GetDuplicateExpenses()
+		assert.NoError(t, err)
+		assert.Len(t, pairs, 2)
+
+		kept, err := stores.Expenses.MergeExpense(double.ID, original.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, original.ID, kept.ID)
+		_, err = stores.Expenses.GetExpenseByID(double.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("126.00"), budget.Spent)
+
+		pairs, err = stores.Expenses.GetDuplicateExpenses()
+		assert.NoError(t, err)
+		assert.Empty(t, pairs)
+	})
+}
@@ -0,0 +1,148 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportStatement(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		account, err := stores.Accounts.CreateAccount(models.Account{Name: "Checking", Type: models.AccountChecking, Currency: "EUR"})
+		assert.NoError(t, err)
+		groceries, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		_, err = stores.Budgets.CreateBudget(models.Budget{
+			CategoryID: groceries.ID, Amount: models.MustParseMoney("400.00"), Currency: "EUR", StartDate: day(1), EndDate: day(31),
+		})
+		assert.NoError(t, err)
+
+		profile, err := stores.Imports.CreateImportProfile(models.ImportProfile{
+			Name: "Bank", DateColumn: "Date", DescriptionColumn: "Memo", AmountColumn: "Amount",
+			CategoryID: groceries.ID, AccountID: &account.ID,
+		})
+		assert.NoError(t, err)
+		_, err = stores.Imports.CreateImportProfile(models.ImportProfile{Name: "Bank", DateColumn: "D", DescriptionColumn: "M", AmountColumn: "A"})
+		assert.ErrorIs(t, err, models.ErrConflict)
+		_, err = stores.Imports.CreateImportProfile(models.ImportProfile{Name: "Other bank", DateColumn: "D", DescriptionColumn: "M", AmountColumn: "A", CategoryID: 999})
+		assert.EqualError(t, err, "category 999 does not exist")
+
+		parse := func(csv string) models.Statement {
+			statement, err := models.ParseStatementCSV(strings.NewReader(csv), profile)
+			assert.NoError(t, err)
+			return statement
+		}
+		counts := func() (int, int) {
+			expenses, err := stores.Expenses.GetExpenses()
+			assert.NoError(t, err)
+			incomes, err := stores.Incomes.GetIncomes()
+			assert.NoError(t, err)
+			return len(expenses), len(incomes)
+		}
+
+		// One bad row blocks the whole statement
+		report, err := stores.Imports.ImportStatement(parse("Date,Memo,Amount\n2024-03-02,Market,-45.10\n2024-03-03,Market,-1.00,\n2024-03-04,,-2.00\n2024-03-05,Lost,x\n"), false)
+		assert.ErrorIs(t, err, models.ErrValidation)
+		assert.Equal(t, 2, report.Errors)
+		assert.Equal(t, "description is required", report.Rows[2].Error)
+		assert.Equal(t, `invalid amount "x"`, report.Rows[3].Error)
+		expenses, incomes := counts()
+		assert.Zero(t, expenses+incomes)
+
+		statement := parse("Date,Memo,Amount\n2024-03-02,Market,-45.10\n2024-03-15,Salary,2000\n")
+		report, err = stores.Imports.ImportStatement(statement, true)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatementImport{DryRun: true, Expenses: 1, Incomes: 1, Rows: report.Rows}, report)
+		assert.Equal(t, "EUR", report.Rows[0].Currency)
+		expenses, incomes = counts()
+		assert.Zero(t, expenses+incomes)
+
+		report, err = stores.Imports.ImportStatement(statement, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Expenses)
+		expenses, incomes = counts()
+		assert.Equal(t, 1, expenses)
+		assert.Equal(t, 1, incomes)
+
+		budgets, err := stores.Budgets.GetBudgetsByCategoryID(groceries.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, budgets, 1) {
+			assert.Equal(t, models.MustParseMoney("45.10"), budgets[0].Spent)
+		}
+		account, err = stores.Accounts.GetAccountByID(account.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("1954.90"), account.Balance)
+
+		// Rows in another currency than the account's are rejected
+		report, err = stores.Imports.ImportStatement(models.Statement{CategoryID: groceries.ID, AccountID: &account.ID, Rows: []models.StatementRow{
+			{Line: 2, Type: models.TransactionExpense, Date: day(4), Description: "Duty free", Amount: models.MustParseMoney("9.00"), Currency: "USD"},
+		}}, false)
+		assert.ErrorIs(t, err, models.ErrValidation)
+		assert.Equal(t, "currency must match the account currency EUR", report.Rows[0].Error)
+
+		// Deleting the category moves the profile to 'Other'
+		assert.NoError(t, stores.Categories.DeleteCategory(groceries.ID))
+		profile, err = stores.Imports.GetImportProfileByName("Bank")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), profile.CategoryID)
+
+		profile.SignConvention = models.SignPositiveExpense
+		profile, err = stores.Imports.UpdateImportProfile(profile)
+		assert.NoError(t, err)
+		assert.Equal(t, models.SignPositiveExpense, profile.SignConvention)
+		assert.NoError(t, stores.Imports.DeleteImportProfile(profile.ID))
+		assert.ErrorIs(t, stores.Imports.DeleteImportProfile(profile.ID), models.ErrNotFound)
+	})
+}
+
+func TestImportStatementSkipsDuplicates(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		qif := "!Type:Bank\nD3/5/2024\nT-4.20\nPCoffee\n^\nD3/5/2024\nT-4.20\nPCoffee\n^\nD3/6/2024\nT100.00\nPRefund\n^\n"
+		statement, err := models.ParseStatementQIF(strings.NewReader(qif), "")
+		assert.NoError(t, err)
+		statement.CategoryID = 1
+
+		report, err := stores.Imports.ImportStatement(statement, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Expenses)
+		assert.Equal(t, 1, report.Incomes)
+		assert.Zero(t, report.Duplicates)
+
+		// A later export overlapping the first only adds the new transaction
+		statement, err = models.ParseStatementQIF(strings.NewReader(qif+"D3/7/2024\nT-8.00\nPLunch\n^\n"), "")
+		assert.NoError(t, err)
+		statement.CategoryID = 1
+		report, err = stores.Imports.ImportStatement(statement, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Duplicates)
+		assert.Equal(t, 1, report.Expenses)
+		assert.True(t, report.Rows[0].Duplicate)
+
+		report, err = stores.Imports.ImportStatement(statement, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Duplicates)
+		expenses, err := stores.Expenses.GetExpenses()
+		assert.NoError(t, err)
+		assert.Len(t, expenses, 3)
+
+		// Deleting an imported expense does not bring it back on the next import
+		assert.NoError(t, stores.Expenses.DeleteExpense(expenses[0].ID))
+		report, err = stores.Imports.ImportStatement(statement, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, report.Duplicates)
+
+		// The same FITID twice in one file is imported once
+		report, err = stores.Imports.ImportStatement(models.Statement{CategoryID: 1, Rows: []models.StatementRow{
+			{Line: 1, Type: models.TransactionIncome, Date: time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC), Description: "Interest", Amount: models.MustParseMoney("0.12"), ExternalID: "ofx:1:X"},
+			{Line: 2, Type: models.TransactionIncome, Date: time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC), Description: "Interest", Amount: models.MustParseMoney("0.12"), ExternalID: "ofx:1:X"},
+		}}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Incomes)
+		assert.Equal(t, 1, report.Duplicates)
+	})
+}
@@ -0,0 +1,176 @@
+package store_test
+
+import (
+	"expense-tracker/internal/migrations"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedgerMembership(t *testing.T) {
+	eachBackend(t, func(t *testing.T, backend store.Backend) {
+		ledgers := backend.Ledgers()
+		ann, err := backend.Users().Register("ann@example.com", "correct horse")
+		assert.NoError(t, err)
+		bob, err := backend.Users().Register("bob@example.com", "correct horse")
+		assert.NoError(t, err)
+
+		personal, err := ledgers.GetUserLedgers(ann.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, personal, 1) {
+			assert.Equal(t, models.PersonalLedgerName, personal[0].Name)
+			assert.Equal(t, models.RoleOwner, personal[0].Role)
+		}
+
+		_, err = ledgers.CreateLedger(ann.ID, "  ")
+		assert.ErrorIs(t, err, models.ErrValidation)
+		household, err := ledgers.CreateLedger(ann.ID, " Household ")
+		assert.NoError(t, err)
+		assert.Equal(t, "Household", household.Name)
+		assert.Equal(t, models.RoleOwner, household.Role)
+
+		// Bob cannot see the ledger until he accepts an invitation to it
+		_, err = ledgers.GetUserLedger(household.ID, bob.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		_, err = ledgers.CreateInvitation(household.ID, ann.ID, "admin")
+		assert.ErrorIs(t, err, models.ErrValidation)
+		invitation, err := ledgers.CreateInvitation(household.ID, ann.ID, models.RoleEditor)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, invitation.Token)
+		assert.WithinDuration(t, time.Now().Add(models.InvitationLifetime), invitation.ExpiresAt, time.Minute)
+
+		_, err = ledgers.AcceptInvitation("not-a-token", bob.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		joined, err := ledgers.AcceptInvitation(invitation.Token, bob.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, household.ID, joined.ID)
+		assert.Equal(t, models.RoleEditor, joined.Role)
+
+		// Invitations can only be used once
+		_, err = ledgers.AcceptInvitation(invitation.Token, bob.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		again, err := ledgers.CreateInvitation(household.ID, ann.ID, models.RoleViewer)
+		assert.NoError(t, err)
+		_, err = ledgers.AcceptInvitation(again.Token, bob.ID)
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		bobLedgers, err := ledgers.GetUserLedgers(bob.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, bobLedgers, 2) {
+			assert.Equal(t, models.PersonalLedgerName, bobLedgers[0].Name)
+			assert.Equal(t, household.ID, bobLedgers[1].ID)
+		}
+
+		members, err := ledgers.GetMembers(household.ID)
+		assert.NoError(t, err)
+		if assert.Len(t, members, 2) {
+			assert.Equal(t, models.Member{UserID: ann.ID, Email: "ann@example.com", Role: models.RoleOwner, JoinedAt: members[0].JoinedAt}, members[0])
+			assert.Equal(t, bob.ID, members[1].UserID)
+			assert.Equal(t, models.RoleEditor, members[1].Role)
+		}
+
+		// A ledger keeps at least one owner
+		_, err = ledgers.UpdateMember(household.ID, ann.ID, models.RoleViewer)
+		assert.ErrorIs(t, err, models.ErrConflict)
+		assert.ErrorIs(t, ledgers.RemoveMember(household.ID, ann.ID), models.ErrConflict)
+		_, err = ledgers.UpdateMember(household.ID, bob.ID, "admin")
+		assert.ErrorIs(t, err, models.ErrValidation)
+		member, err := ledgers.UpdateMember(household.ID, bob.ID, models.RoleOwner)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleOwner, member.Role)
+		assert.NoError(t, ledgers.RemoveMember(household.ID, ann.ID))
+		assert.ErrorIs(t, ledgers.RemoveMember(household.ID, ann.ID), models.ErrNotFound)
+		_, err = ledgers.UpdateMember(household.ID, ann.ID, models.RoleOwner)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+
+		_, err = ledgers.GetUserLedger(household.ID, ann.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		all, err := ledgers.GetLedgers()
+		assert.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+}
+
+func TestLedgerDataIsShared(t *testing.T) {
+	eachBackend(t, func(t *testing.T, backend store.Backend) {
+		ann, err := backend.Users().Register("ann@example.com", "correct horse")
+		assert.NoError(t, err)
+		bob, err := backend.Users().Register("bob@example.com", "correct horse")
+		assert.NoError(t, err)
+		household, err := backend.Ledgers().CreateLedger(ann.ID, "Household")
+		assert.NoError(t, err)
+		invitation, err := backend.Ledgers().CreateInvitation(household.ID, ann.ID, models.RoleEditor)
+		assert.NoError(t, err)
+		_, err = backend.Ledgers().AcceptInvitation(invitation.Token, bob.ID)
+		assert.NoError(t, err)
+
+		groceries, err := backend.Stores(household.ID).Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+		_, err = backend.Stores(household.ID).Expenses.CreateExpense(models.Expense{CategoryID: groceries.ID,
+			Amount: models.MustParseMoney("42.00"), Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Description: "Market"})
+		assert.NoError(t, err)
+
+		expenses, err := backend.Stores(household.ID).Expenses.GetExpenses()
+		assert.NoError(t, err)
+		assert.Len(t, expenses, 1)
+
+		// Neither personal ledger sees the household's data
+		for _, user := range []models.User{ann, bob} {
+			expenses, err := personalStores(t, backend, user).Expenses.GetExpenses()
+			assert.NoError(t, err)
+			assert.Empty(t, expenses)
+			_, err = personalStores(t, backend, user).Categories.GetCategoryByID(groceries.ID)
+			assert.ErrorIs(t, err, models.ErrNotFound)
+		}
+	})
+}
+
+func TestSQLiteLedgerMigrationRoundTrip(t *testing.T) {
+	db, backend := newSQLiteBackend(t)
+	ann, err := backend.Users().Register("ann@example.com", "correct horse")
+	assert.NoError(t, err)
+	household, err := backend.Ledgers().CreateLedger(ann.ID, "Household")
+	assert.NoError(t, err)
+	for _, stores := range []store.Stores{personalStores(t, backend, ann), backend.Stores(household.ID)} {
+		_, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"),
+			Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Description: "Coffee"})
+		assert.NoError(t, err)
+	}
+
+	// Reverting hands the household's expense to its owner, and migrating
+	// again puts both in their personal ledger
+	migrator, err := migrations.New(db, "sqlite")
+	assert.NoError(t, err)
+	for {
+		migration, reverted, err := migrator.Down()
+		if err != nil || !reverted {
+			t.Fatalf("failed to revert the ledger migration: %v", err)
+		}
+		if migration.Name == "ledgers" {
+			break
+		}
+	}
+	var owned int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM Expense WHERE user_id = $1", ann.ID).Scan(&owned))
+	assert.Equal(t, 2, owned)
+
+	_, err = migrator.Up()
+	assert.NoError(t, err)
+	ledgers, err := backend.Ledgers().GetUserLedgers(ann.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, ledgers, 1) {
+		assert.Equal(t, ann.ID, ledgers[0].ID)
+		assert.Equal(t, models.RoleOwner, ledgers[0].Role)
+	}
+	expenses, err := backend.Stores(ann.ID).Expenses.GetExpenses()
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 2)
+
+	// New ledgers are numbered after the migrated ones
+	ledger, err := backend.Ledgers().CreateLedger(ann.ID, "Trip")
+	assert.NoError(t, err)
+	assert.Greater(t, ledger.ID, ann.ID)
+}
@@ -0,0 +1,110 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eachStore runs a test against the in-memory store and against SQLite, so
+// both apply list options the same way.
+func eachStore(t *testing.T, test func(t *testing.T, stores store.Stores)) {
+	t.Run("memory", func(t *testing.T) { test(t, store.NewMemory().Stores()) })
+	t.Run("sqlite", func(t *testing.T) {
+		_, stores := newSQLiteStore(t)
+		test(t, stores)
+	})
+}
+
+func TestListExpenses(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		category, err := stores.Categories.CreateCategory(models.Category{Name: "Travel"})
+		assert.NoError(t, err)
+
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		for _, expense := range []models.Expense{
+			{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Date: day(1), Description: "Coffee"},
+			{CategoryID: category.ID, Amount: models.MustParseMoney("120.00"), Date: day(3), Description: "Train to Lyon"},
+			{CategoryID: category.ID, Amount: models.MustParseMoney("80.00"), Date: day(5), Description: "Hotel 100% refundable"},
+			{CategoryID: category.ID, Amount: models.MustParseMoney("80.00"), Date: day(9), Description: "Train home"},
+		} {
+			_, err := stores.Expenses.CreateExpense(expense)
+			assert.NoError(t, err)
+		}
+
+		expenses, total, err := stores.Expenses.ListExpenses(models.ListOptions{
+			CategoryID: category.ID,
+			Sort:       []models.SortField{{Field: "amount", Desc: true}, {Field: "date", Desc: true}},
+			Limit:      2,
+			Offset:     1,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		if assert.Len(t, expenses, 2) {
+			assert.Equal(t, "Train home", expenses[0].Description)
+			assert.Equal(t, "Hotel 100% refundable", expenses[1].Description)
+		}
+
+		minAmount := models.MustParseMoney("50.00")
+		expenses, total, err = stores.Expenses.ListExpenses(models.ListOptions{From: day(2), To: day(5), MinAmount: &minAmount, Search: "TRAIN"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, expenses, 1) {
+			assert.Equal(t, "Train to Lyon", expenses[0].Description)
+		}
+
+		// LIKE wildcards in the search text are matched literally
+		_, total, err = stores.Expenses.ListExpenses(models.ListOptions{Search: "0%"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+
+		_, _, err = stores.Expenses.ListExpenses(models.ListOptions{Sort: []models.SortField{{Field: "source"}}})
+		assert.EqualError(t, err, `cannot sort by "source"`)
+	})
+}
+
+func TestListBudgetsByPeriod(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		for month := time.January; month <= time.March; month++ {
+			_, err := stores.Budgets.CreateBudget(models.Budget{
+				CategoryID: 1,
+				Amount:     models.MustParseMoney("100.00"),
+				StartDate:  time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC),
+				EndDate:    time.Date(2024, month+1, 0, 0, 0, 0, 0, time.UTC),
+			})
+			assert.NoError(t, err)
+		}
+
+		budgets, total, err := stores.Budgets.ListBudgets(models.ListOptions{
+			From: time.Date(2024, time.January, 20, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC),
+			Sort: []models.SortField{{Field: "start_date", Desc: true}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, budgets, 2) {
+			assert.Equal(t, time.February, budgets[0].StartDate.Month())
+			assert.Equal(t, time.January, budgets[1].StartDate.Month())
+		}
+	})
+}
+
+func TestListCategoriesSearch(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		_, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries", Description: "Food shopping"})
+		assert.NoError(t, err)
+		_, err = stores.Categories.CreateCategory(models.Category{Name: "Dining", Description: "Restaurants and FOOD delivery"})
+		assert.NoError(t, err)
+
+		categories, total, err := stores.Categories.ListCategories(models.ListOptions{Search: "food", Sort: []models.SortField{{Field: "name"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, categories, 2) {
+			assert.Equal(t, "Dining", categories[0].Name)
+			assert.Equal(t, "Groceries", categories[1].Name)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryExpenseUpdatesBudgetSpent(t *testing.T) {
+	stores := store.NewMemory().Stores()
+
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+	assert.NoError(t, err)
+
+	budget, err := stores.Budgets.CreateBudget(models.Budget{
+		CategoryID: category.ID,
+		Amount:     models.MustParseMoney("500.00"),
+		StartDate:  time.Now().AddDate(0, -1, 0),
+		EndDate:    time.Now().AddDate(0, 1, 0),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("0.00"), budget.Spent)
+
+	expense, err := stores.Expenses.CreateExpense(models.Expense{
+		CategoryID:  category.ID,
+		Amount:      models.MustParseMoney("100.00"),
+		Date:        time.Now(),
+		Description: "Weekly groceries",
+	})
+	assert.NoError(t, err)
+
+	_, err = stores.Expenses.UpdateExpense(models.Expense{ID: expense.ID, Amount: models.MustParseMoney("150.00")})
+	assert.NoError(t, err)
+
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("150.00"), budget.Spent)
+
+	assert.NoError(t, stores.Expenses.DeleteExpense(expense.ID))
+
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("0.00"), budget.Spent)
+}
+
+func TestMemoryCreateExpenseValidation(t *testing.T) {
+	stores := store.NewMemory().Stores()
+
+	_, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("10.00"), Date: time.Now()})
+
+	assert.EqualError(t, err, "description is required")
+}
+
+func TestMemoryBudgetOverlap(t *testing.T) {
+	stores := store.NewMemory().Stores()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start, EndDate: start.AddDate(0, 1, -1)})
+	assert.NoError(t, err)
+
+	_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start.AddDate(0, 0, 15), EndDate: start.AddDate(0, 2, 0)})
+	assert.EqualError(t, err, "budget dates overlap with an existing budget")
+
+	_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start.AddDate(0, 1, 0), EndDate: start.AddDate(0, 2, -1)})
+	assert.NoError(t, err)
+}
+
+func TestMemoryDeleteCategoryReassignsExpenses(t *testing.T) {
+	stores := store.NewMemory().Stores()
+
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Entertainment"})
+	assert.NoError(t, err)
+	expense, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("20.00"), Date: time.Now(), Description: "Cinema"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, stores.Categories.DeleteCategory(category.ID))
+
+	expense, err = stores.Expenses.GetExpenseByID(expense.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expense.CategoryID)
+
+	_, err = stores.Categories.GetCategoryByID(category.ID)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	assert.Error(t, stores.Categories.DeleteCategory(1))
+}
+
+func TestMemoryMaterializeRecurringIncome(t *testing.T) {
+	stores := store.NewMemory().Stores()
+
+	rule, err := stores.Recurring.CreateRecurringRule(models.RecurringRule{
+		Kind:        models.RecurringIncome,
+		Frequency:   models.FrequencyWeekly,
+		Interval:    2,
+		StartDate:   time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+		Amount:      models.MustParseMoney("800.00"),
+		Description: "Salary",
+	})
+	assert.NoError(t, err)
+
+	asOf := time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+	created, err := stores.Recurring.MaterializeRecurringRules(asOf)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created)
+
+	created, err = stores.Recurring.MaterializeRecurringRules(asOf)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	rule, err = stores.Recurring.GetRecurringRuleByID(rule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.April, 12, 0, 0, 0, 0, time.UTC), *rule.NextDate)
+
+	incomes, err := stores.Incomes.GetIncomes()
+	assert.NoError(t, err)
+	assert.Len(t, incomes, 3)
+	assert.Equal(t, "Salary", incomes[2].Source)
+}
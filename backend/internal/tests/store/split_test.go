@@ -0,0 +1,139 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitExpenses(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		groceries, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+		household, err := stores.Categories.CreateCategory(models.Category{Name: "Household"})
+		assert.NoError(t, err)
+		pharmacy, err := stores.Categories.CreateCategory(models.Category{Name: "Pharmacy"})
+		assert.NoError(t, err)
+		groceriesBudget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: groceries.ID, Amount: models.MustParseMoney("300.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		householdBudget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: household.ID, Amount: models.MustParseMoney("100.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+
+		// The lines must add up to the expense amount
+		_, err = stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("90.00"), Date: day(2), Description: "Supermarket",
+			Splits: []models.ExpenseSplit{{CategoryID: groceries.ID, Amount: models.MustParseMoney("60.00")}, {CategoryID: household.ID, Amount: models.MustParseMoney("20.00")}}})
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("90.00"), Date: day(2), Description: "Supermarket",
+			Splits: []models.ExpenseSplit{{CategoryID: groceries.ID, Amount: models.MustParseMoney("60.00")}, {CategoryID: 999, Amount: models.MustParseMoney("30.00")}}})
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		receipt, err := stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("90.00"), Date: day(2), Description: "Supermarket",
+			Splits: []models.ExpenseSplit{
+				{CategoryID: groceries.ID, Amount: models.MustParseMoney("60.00")},
+				{CategoryID: household.ID, Amount: models.MustParseMoney("20.00"), Memo: " Detergent "},
+				{CategoryID: pharmacy.ID, Amount: models.MustParseMoney("10.00")},
+			}})
+		assert.NoError(t, err)
+		assert.Equal(t, groceries.ID, receipt.CategoryID)
+		if assert.Len(t, receipt.Splits, 3) {
+			assert.Equal(t, "Detergent", receipt.Splits[1].Memo)
+			assert.NotZero(t, receipt.Splits[1].ID)
+		}
+
+		// Each line counts toward its own category's budget
+		spent := func(budget models.Budget) models.Money {
+			budget, err := stores.Budgets.GetBudgetByID(budget.ID)
+			assert.NoError(t, err)
+			return budget.Spent
+		}
+		assert.Equal(t, models.MustParseMoney("60.00"), spent(groceriesBudget))
+		assert.Equal(t, models.MustParseMoney("20.00"), spent(householdBudget))
+		pharmacyBudget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: pharmacy.ID, Amount: models.MustParseMoney("50.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("10.00"), pharmacyBudget.Spent)
+
+		summary, err := stores.Reports.GetSummary("USD", day(1), day(31))
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("90.00"), summary.Expenses.Converted)
+		if assert.Len(t, summary.Categories, 3) {
+			assert.Equal(t, household.ID, summary.Categories[1].CategoryID)
+			assert.Equal(t, models.MustParseMoney("20.00"), summary.Categories[1].Converted)
+		}
+
+		// Changing the amount needs new lines that add up to it
+		_, err = stores.Expenses.UpdateExpense(models.Expense{ID: receipt.ID, Amount: models.MustParseMoney("100.00")})
+		assert.ErrorIs(t, err, models.ErrValidation)
+		receipt, err = stores.Expenses.UpdateExpense(models.Expense{ID: receipt.ID, Amount: models.MustParseMoney("100.00"), Splits: []models.ExpenseSplit{
+			{CategoryID: groceries.ID, Amount: models.MustParseMoney("70.00")},
+			{CategoryID: household.ID, Amount: models.MustParseMoney("30.00")},
+		}})
+		assert.NoError(t, err)
+		assert.Len(t, receipt.Splits, 2)
+		assert.Equal(t, models.MustParseMoney("70.00"), spent(groceriesBudget))
+		assert.Equal(t, models.MustParseMoney("30.00"), spent(householdBudget))
+		assert.Equal(t, models.Money(0), spent(pharmacyBudget))
+
+		// Deleting a category moves its lines with its expenses
+		assert.NoError(t, stores.Categories.DeleteCategory(household.ID))
+		receipt, err = stores.Expenses.GetExpenseByID(receipt.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), receipt.Splits[1].CategoryID)
+
+		// An empty list removes the split, leaving the whole amount in the expense's category
+		receipt, err = stores.Expenses.UpdateExpense(models.Expense{ID: receipt.ID, Amount: receipt.Amount, Splits: []models.ExpenseSplit{}})
+		assert.NoError(t, err)
+		assert.Empty(t, receipt.Splits)
+		assert.Equal(t, models.MustParseMoney("100.00"), spent(groceriesBudget))
+
+		assert.NoError(t, stores.Expenses.DeleteExpense(receipt.ID))
+		assert.Equal(t, models.Money(0), spent(groceriesBudget))
+	})
+}
+
+func TestListSplitExpensesByCategory(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		groceries, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+		household, err := stores.Categories.CreateCategory(models.Category{Name: "Household"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{Amount: models.MustParseMoney("90.00"), Date: day(2), Description: "Supermarket",
+			Splits: []models.ExpenseSplit{
+				{CategoryID: groceries.ID, Amount: models.MustParseMoney("60.00")},
+				{CategoryID: household.ID, Amount: models.MustParseMoney("30.00")},
+			}})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: groceries.ID, Amount: models.MustParseMoney("15.00"), Date: day(3), Description: "Market"})
+		assert.NoError(t, err)
+
+		// A split expense is listed under the category of any of its lines,
+		// not only that of its first
+		expenses, total, err := stores.Expenses.ListExpenses(models.ListOptions{CategoryID: household.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, expenses, 1) {
+			assert.Equal(t, "Supermarket", expenses[0].Description)
+		}
+		_, total, err = stores.Expenses.ListExpenses(models.ListOptions{CategoryID: groceries.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+
+		transactions, total, err := stores.Transactions.ListTransactions(models.ListOptions{CategoryID: household.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, transactions, 1) {
+			assert.Equal(t, "Supermarket", transactions[0].Description)
+			assert.Nil(t, transactions[0].CategoryID)
+			assert.Empty(t, transactions[0].Category)
+		}
+		transactions, _, err = stores.Transactions.ListTransactions(models.ListOptions{CategoryID: groceries.ID})
+		assert.NoError(t, err)
+		if assert.Len(t, transactions, 2) {
+			assert.Equal(t, "Groceries", transactions[1].Category)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package store_test
+
+import (
+	"database/sql"
+	"expense-tracker/internal/blob"
+	"expense-tracker/internal/migrations"
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// newSQLiteStore returns the stores of the personal ledger of a user of a
+// freshly migrated SQLite file.
+func newSQLiteStore(t *testing.T) (*sql.DB, store.Stores) {
+	t.Helper()
+
+	db, backend := newSQLiteBackend(t)
+	user, err := backend.Users().Register("owner@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+	return db, personalStores(t, backend, user)
+}
+
+// newSQLiteBackend returns a backend over a freshly migrated SQLite file.
+func newSQLiteBackend(t *testing.T) (*sql.DB, *store.SQL) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "expense-tracker.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("failed to load sqlite migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate sqlite database: %v", err)
+	}
+	blobs, err := blob.NewLocal(filepath.Join(t.TempDir(), "attachments"))
+	if err != nil {
+		t.Fatalf("failed to open blob store: %v", err)
+	}
+	return db, store.NewSQL(db, blobs)
+}
+
+func TestSQLiteMigrationsRoundTrip(t *testing.T) {
+	db, _ := newSQLiteStore(t)
+	migrator, err := migrations.New(db, "sqlite")
+	assert.NoError(t, err)
+
+	for {
+		_, reverted, err := migrator.Down()
+		assert.NoError(t, err)
+		if !reverted {
+			break
+		}
+	}
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, applied)
+}
+
+func TestSQLiteBudgetSpentTracksExpenses(t *testing.T) {
+	_, stores := newSQLiteStore(t)
+
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries", Description: "Food"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(1), category.ID)
+
+	start := time.Now().AddDate(0, -1, 0)
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("40.00"), Date: start.AddDate(0, 0, -1), Description: "Before the budget"})
+	assert.NoError(t, err)
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("60.00"), Date: start, Description: "First day of the budget"})
+	assert.NoError(t, err)
+
+	budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: category.ID, Amount: models.MustParseMoney("500.00"), StartDate: start, EndDate: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("60.00"), budget.Spent)
+
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("25.50"), Date: time.Now(), Description: "Last day of the budget"})
+	assert.NoError(t, err)
+
+	budgets, err := stores.Budgets.GetBudgetsByCategoryName("Groceries")
+	assert.NoError(t, err)
+	assert.Len(t, budgets, 1)
+	assert.Equal(t, models.MustParseMoney("85.50"), budgets[0].Spent)
+}
+
+func TestSQLiteBudgetOverlap(t *testing.T) {
+	_, stores := newSQLiteStore(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start, EndDate: start.AddDate(0, 1, -1)})
+	assert.NoError(t, err)
+
+	_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start.AddDate(0, 1, -1), EndDate: start.AddDate(0, 2, 0)})
+	assert.EqualError(t, err, "budget dates overlap with an existing budget")
+
+	_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("100.00"), StartDate: start.AddDate(0, 1, 0), EndDate: start.AddDate(0, 2, -1)})
+	assert.NoError(t, err)
+
+	first.Amount = models.MustParseMoney("150.00")
+	_, err = stores.Budgets.UpdateBudget(first)
+	assert.NoError(t, err)
+}
+
+func TestSQLiteDeleteCategoryReassignsExpenses(t *testing.T) {
+	_, stores := newSQLiteStore(t)
+
+	category, err := stores.Categories.CreateCategory(models.Category{Name: "Entertainment"})
+	assert.NoError(t, err)
+	expense, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("20.00"), Date: time.Now(), Description: "Cinema"})
+	assert.NoError(t, err)
+	_, err = stores.Budgets.CreateBudget(models.Budget{CategoryID: category.ID, Amount: models.MustParseMoney("50.00"), StartDate: time.Now().AddDate(0, 0, -7), EndDate: time.Now()})
+	assert.NoError(t, err)
+
+	assert.NoError(t, stores.Categories.DeleteCategory(category.ID))
+
+	expense, err = stores.Expenses.GetExpenseByID(expense.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expense.CategoryID)
+
+	budgets, err := stores.Budgets.GetBudgetsByCategoryID(category.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, budgets)
+
+	assert.ErrorIs(t, stores.Categories.DeleteCategory(category.ID), models.ErrNotFound)
+}
+
+func TestSQLiteConvertsIntoBudgetCurrency(t *testing.T) {
+	_, stores := newSQLiteStore(t)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	imported, err := stores.ExchangeRates.ImportExchangeRates([]models.ExchangeRate{
+		{Date: day.AddDate(0, 0, -9), BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.10"},
+		{Date: day, BaseCurrency: "EUR", QuoteCurrency: "USD", Rate: "1.25"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported)
+
+	budget, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("500.00"), Currency: "USD", StartDate: day.AddDate(0, 0, -9), EndDate: day.AddDate(0, 0, 20)})
+	assert.NoError(t, err)
+
+	// Each expense converts at the rate of its own date
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("10.00"), Currency: "EUR", Date: day.AddDate(0, 0, -1), Description: "Museum"})
+	assert.NoError(t, err)
+	expense, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("20.00"), Currency: "EUR", Date: day, Description: "Dinner"})
+	assert.NoError(t, err)
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Date: day, Description: "Coffee"})
+	assert.NoError(t, err)
+
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("41.00"), budget.Spent) // 11.00 + 25.00 + 5.00
+
+	assert.NoError(t, stores.Expenses.DeleteExpense(expense.ID))
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("16.00"), budget.Spent)
+
+	// Recalculating from scratch agrees with the running total
+	budget, err = stores.Budgets.UpdateBudget(budget)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("16.00"), budget.Spent)
+	assert.Equal(t, "USD", budget.Currency)
+
+	summary, err := stores.Reports.GetSummary("EUR", day.AddDate(0, 0, -1), day)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("14.00"), summary.Expenses.Converted) // 10.00 + 5.00 / 1.25
+	assert.Equal(t, []models.CurrencyAmount{
+		{Currency: "EUR", Amount: models.MustParseMoney("10.00")},
+		{Currency: "USD", Amount: models.MustParseMoney("5.00")},
+	}, summary.Expenses.Original)
+
+	// Without a rate the expense is recorded and the budget marked stale
+	_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Currency: "GBP", Date: day, Description: "Tea"})
+	assert.NoError(t, err)
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.True(t, budget.Stale)
+	assert.Equal(t, models.MustParseMoney("16.00"), budget.Spent)
+}
+
+func TestSQLiteMaterializeRecurringRules(t *testing.T) {
+	_, stores := newSQLiteStore(t)
+
+	budget, err := stores.Budgets.CreateBudget(models.Budget{
+		CategoryID: 1,
+		Amount:     models.MustParseMoney("500.00"),
+		StartDate:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:    time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+
+	end := time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC)
+	rule, err := stores.Recurring.CreateRecurringRule(models.RecurringRule{
+		Kind:        models.RecurringExpense,
+		Frequency:   models.FrequencyMonthly,
+		StartDate:   time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+		EndDate:     &end,
+		CategoryID:  1,
+		Amount:      models.MustParseMoney("12.50"),
+		Description: "Gym",
+	})
+	assert.NoError(t, err)
+
+	created, err := stores.Recurring.MaterializeRecurringRules(time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	// A second run over the same period generates nothing new
+	created, err = stores.Recurring.MaterializeRecurringRules(time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	created, err = stores.Recurring.MaterializeRecurringRules(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, created)
+
+	rule, err = stores.Recurring.GetRecurringRuleByID(rule.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, rule.NextDate)
+
+	expenses, err := stores.Expenses.GetExpenses()
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 4)
+	assert.Equal(t, time.February, expenses[1].Date.Month())
+	assert.Equal(t, 29, expenses[1].Date.Day())
+	assert.Equal(t, &rule.ID, expenses[1].RecurringRuleID)
+
+	budget, err = stores.Budgets.GetBudgetByID(budget.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MustParseMoney("50.00"), budget.Spent)
+
+	// Deleting the rule keeps what it generated
+	assert.NoError(t, stores.Recurring.DeleteRecurringRule(rule.ID))
+	expenses, err = stores.Expenses.GetExpenses()
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 4)
+	assert.Nil(t, expenses[0].RecurringRuleID)
+}
@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		hotel, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("200.00"), Date: day(2),
+			Description: "Hotel", Tags: []string{"Vacation-2024", " reimbursable", "vacation-2024"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"reimbursable", "vacation-2024"}, hotel.Tags)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("30.00"), Date: day(3),
+			Description: "Museum", Tags: []string{"vacation-2024"}})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Date: day(4), Description: "Coffee"})
+		assert.NoError(t, err)
+		_, err = stores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("200.00"), Date: day(20), Source: "Expense claim", Tags: []string{"reimbursable"}})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("5.00"), Date: day(4), Description: "Bad", Tags: []string{"a,b"}})
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		// Tags are created as they are first used
+		tags, err := stores.Tags.GetTags()
+		assert.NoError(t, err)
+		if assert.Len(t, tags, 2) {
+			assert.Equal(t, "reimbursable", tags[0].Name)
+			assert.Equal(t, "vacation-2024", tags[1].Name)
+		}
+		_, err = stores.Tags.CreateTag(models.Tag{Name: "Reimbursable"})
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		// Lists filter on every tag given
+		expenses, total, err := stores.Expenses.ListExpenses(models.ListOptions{Tags: []string{"vacation-2024"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Len(t, expenses, 2)
+		expenses, _, err = stores.Expenses.ListExpenses(models.ListOptions{Tags: []string{"vacation-2024", "Reimbursable"}})
+		assert.NoError(t, err)
+		if assert.Len(t, expenses, 1) {
+			assert.Equal(t, hotel.ID, expenses[0].ID)
+		}
+		incomes, _, err := stores.Incomes.ListIncomes(models.ListOptions{Tags: []string{"reimbursable"}})
+		assert.NoError(t, err)
+		assert.Len(t, incomes, 1)
+
+		summary, err := stores.Tags.GetTagSummary("USD", day(1), day(10))
+		assert.NoError(t, err)
+		if assert.Len(t, summary, 2) {
+			assert.Equal(t, "reimbursable", summary[0].Tag)
+			assert.Equal(t, models.MustParseMoney("200.00"), summary[0].Expenses.Converted)
+			assert.Equal(t, models.Money(0), summary[0].Incomes.Converted)
+			assert.Equal(t, models.MustParseMoney("230.00"), summary[1].Expenses.Converted)
+			assert.Equal(t, models.MustParseMoney("-230.00"), summary[1].Net)
+		}
+		summary, err = stores.Tags.GetTagSummary("USD", time.Time{}, time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money(0), summary[0].Net)
+
+		// Updates keep the tags unless given, and an empty list clears them
+		hotel, err = stores.Expenses.UpdateExpense(models.Expense{ID: hotel.ID, Amount: hotel.Amount, Description: "Hotel Lyon"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"reimbursable", "vacation-2024"}, hotel.Tags)
+		hotel, err = stores.Expenses.UpdateExpense(models.Expense{ID: hotel.ID, Amount: hotel.Amount, Tags: []string{}})
+		assert.NoError(t, err)
+		assert.Empty(t, hotel.Tags)
+		hotel, err = stores.Expenses.UpdateExpense(models.Expense{ID: hotel.ID, Amount: hotel.Amount, Tags: []string{"vacation-2024"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"vacation-2024"}, hotel.Tags)
+
+		// Renaming and deleting a tag applies to everything carrying it
+		vacation := tags[1]
+		vacation.Name = "Lyon-2024"
+		vacation, err = stores.Tags.UpdateTag(vacation)
+		assert.NoError(t, err)
+		assert.Equal(t, "lyon-2024", vacation.Name)
+		hotel, err = stores.Expenses.GetExpenseByID(hotel.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"lyon-2024"}, hotel.Tags)
+		vacation.Name = "reimbursable"
+		_, err = stores.Tags.UpdateTag(vacation)
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		assert.NoError(t, stores.Tags.DeleteTag(vacation.ID))
+		hotel, err = stores.Expenses.GetExpenseByID(hotel.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, hotel.Tags)
+		assert.ErrorIs(t, stores.Tags.DeleteTag(vacation.ID), models.ErrNotFound)
+		_, err = stores.Tags.GetTagByID(vacation.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
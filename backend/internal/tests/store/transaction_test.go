@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTransactions(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		category, err := stores.Categories.CreateCategory(models.Category{Name: "Groceries"})
+		assert.NoError(t, err)
+
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		_, err = stores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("1000.00"), Date: day(1), Source: "Salary"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: category.ID, Amount: models.MustParseMoney("45.50"), Date: day(2), Description: "Market"})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("20.00"), Date: day(2), Description: "Parking"})
+		assert.NoError(t, err)
+		_, err = stores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("300.00"), Currency: "EUR", Date: day(3), Source: "Refund"})
+		assert.NoError(t, err)
+
+		transactions, total, err := stores.Transactions.ListTransactions(models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+		if assert.Len(t, transactions, 4) {
+			assert.Equal(t, models.TransactionIncome, transactions[0].Type)
+			assert.Equal(t, models.MustParseMoney("1000.00"), transactions[0].Balance)
+
+			assert.Equal(t, models.TransactionExpense, transactions[1].Type)
+			assert.Equal(t, "Market", transactions[1].Description)
+			assert.Equal(t, "Groceries", transactions[1].Category)
+			assert.Equal(t, models.MustParseMoney("-45.50"), transactions[1].Amount)
+			assert.Equal(t, models.MustParseMoney("954.50"), transactions[1].Balance)
+
+			assert.Equal(t, models.MustParseMoney("934.50"), transactions[2].Balance)
+
+			// Each currency keeps its own balance
+			assert.Equal(t, "EUR", transactions[3].Currency)
+			assert.Equal(t, models.MustParseMoney("300.00"), transactions[3].Balance)
+		}
+
+		// Balances include the entries on earlier pages, whatever the page order
+		transactions, total, err = stores.Transactions.ListTransactions(models.ListOptions{
+			Sort:  []models.SortField{{Field: "date", Desc: true}},
+			Limit: 2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+		if assert.Len(t, transactions, 2) {
+			assert.Equal(t, "Refund", transactions[0].Description)
+			assert.Equal(t, "Market", transactions[1].Description)
+			assert.Equal(t, models.MustParseMoney("954.50"), transactions[1].Balance)
+		}
+
+		// Amount bounds apply to the unsigned amount
+		minAmount := models.MustParseMoney("40.00")
+		maxAmount := models.MustParseMoney("500.00")
+		transactions, total, err = stores.Transactions.ListTransactions(models.ListOptions{MinAmount: &minAmount, MaxAmount: &maxAmount})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, transactions, 2) {
+			assert.Equal(t, "Market", transactions[0].Description)
+			assert.Equal(t, models.MustParseMoney("-45.50"), transactions[0].Balance)
+		}
+
+		transactions, total, err = stores.Transactions.ListTransactions(models.ListOptions{Search: "grocer"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, transactions, 1) {
+			assert.Equal(t, "Market", transactions[0].Description)
+		}
+
+		_, total, err = stores.Transactions.ListTransactions(models.ListOptions{CategoryID: 1, From: day(2), To: day(2)})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+	})
+}
+
+func TestListTransactionsByTag(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		_, err := stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("20.00"), Date: day(1), Description: "Parking"})
+		assert.NoError(t, err)
+		_, err = stores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("300.00"), Date: day(2), Source: "Refund", Tags: []string{"trip"}})
+		assert.NoError(t, err)
+		_, err = stores.Expenses.CreateExpense(models.Expense{CategoryID: 1, Amount: models.MustParseMoney("45.50"), Date: day(3), Description: "Hotel", Tags: []string{"trip"}})
+		assert.NoError(t, err)
+
+		// An income tag does not match the expense sharing its id
+		transactions, total, err := stores.Transactions.ListTransactions(models.ListOptions{Tags: []string{"Trip"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, transactions, 2) {
+			assert.Equal(t, "Refund", transactions[0].Description)
+			assert.Equal(t, "Hotel", transactions[1].Description)
+			assert.Equal(t, models.MustParseMoney("254.50"), transactions[1].Balance)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransfers(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores store.Stores) {
+		newAccount := func(name string, accountType models.AccountType, currency, opening string) models.Account {
+			account, err := stores.Accounts.CreateAccount(models.Account{
+				Name: name, Type: accountType, Currency: currency, OpeningBalance: models.MustParseMoney(opening),
+			})
+			assert.NoError(t, err)
+			return account
+		}
+		checking := newAccount("Checking", models.AccountChecking, "USD", "1000.00")
+		card := newAccount("Card", models.AccountCreditCard, "USD", "-300.00")
+		euros := newAccount("Euros", models.AccountSavings, "EUR", "0")
+
+		day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+		_, err := stores.Budgets.CreateBudget(models.Budget{CategoryID: 1, Amount: models.MustParseMoney("500.00"), StartDate: day(1), EndDate: day(31)})
+		assert.NoError(t, err)
+
+		payoff, err := stores.Transfers.CreateTransfer(models.Transfer{
+			FromAccountID: checking.ID, ToAccountID: card.ID, Amount: models.MustParseMoney("300.00"), Date: day(5), Description: "Card payment",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, payoff.Amount, payoff.ToAmount)
+
+		_, err = stores.Transfers.CreateTransfer(models.Transfer{FromAccountID: checking.ID, ToAccountID: euros.ID, Amount: models.MustParseMoney("110.00"), Date: day(6)})
+		assert.EqualError(t, err, "to amount must be provided to transfer from USD to EUR")
+		_, err = stores.Transfers.CreateTransfer(models.Transfer{
+			FromAccountID: checking.ID, ToAccountID: euros.ID, Amount: models.MustParseMoney("110.00"), ToAmount: models.MustParseMoney("100.00"), Date: day(6),
+		})
+		assert.NoError(t, err)
+		_, err = stores.Transfers.CreateTransfer(models.Transfer{FromAccountID: checking.ID, ToAccountID: checking.ID, Amount: models.MustParseMoney("1.00"), Date: day(6)})
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = stores.Transfers.CreateTransfer(models.Transfer{FromAccountID: checking.ID, ToAccountID: 999, Amount: models.MustParseMoney("1.00"), Date: day(6)})
+		assert.EqualError(t, err, "account 999 does not exist")
+
+		// Balances move, spending and income totals do not
+		for id, balance := range map[int64]string{checking.ID: "590.00", card.ID: "0.00", euros.ID: "100.00"} {
+			account, err := stores.Accounts.GetAccountByID(id)
+			assert.NoError(t, err)
+			assert.Equal(t, models.MustParseMoney(balance), account.Balance, account.Name)
+		}
+		budgets, err := stores.Budgets.GetBudgets()
+		assert.NoError(t, err)
+		if assert.Len(t, budgets, 1) {
+			assert.Zero(t, budgets[0].Spent)
+		}
+		summary, err := stores.Reports.GetSummary("USD", day(1), day(31))
+		assert.NoError(t, err)
+		assert.Zero(t, summary.Expenses.Converted)
+		assert.Zero(t, summary.Incomes.Converted)
+
+		balances, err := stores.Accounts.GetAccountBalances(card.ID, day(1), day(31), models.IntervalMonth)
+		assert.NoError(t, err)
+		if assert.Len(t, balances, 1) {
+			assert.Equal(t, models.MustParseMoney("300.00"), balances[0].Inflow)
+		}
+
+		// Each transfer shows up once per account in the ledger
+		transactions, total, err := stores.Transactions.ListTransactions(models.ListOptions{AccountID: checking.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		if assert.Len(t, transactions, 2) {
+			assert.Equal(t, models.TransactionTransfer, transactions[0].Type)
+			assert.Equal(t, models.MustParseMoney("-300.00"), transactions[0].Amount)
+			assert.Equal(t, card.ID, *transactions[0].TransferAccountID)
+			assert.Equal(t, models.MustParseMoney("-410.00"), transactions[1].Balance)
+		}
+		_, total, err = stores.Transactions.ListTransactions(models.ListOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, total)
+
+		payoff.Amount = models.MustParseMoney("250.00")
+		payoff.ToAmount = 0
+		payoff, err = stores.Transfers.UpdateTransfer(payoff)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("250.00"), payoff.ToAmount)
+
+		transfers, total, err := stores.Transfers.ListTransfers(models.ListOptions{AccountID: card.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, total)
+		if assert.Len(t, transfers, 1) {
+			assert.Equal(t, "Card payment", transfers[0].Description)
+		}
+
+		assert.ErrorIs(t, stores.Accounts.DeleteAccount(card.ID), models.ErrConflict)
+		assert.NoError(t, stores.Transfers.DeleteTransfer(payoff.ID))
+		assert.NoError(t, stores.Accounts.DeleteAccount(card.ID))
+		assert.ErrorIs(t, stores.Transfers.DeleteTransfer(payoff.ID), models.ErrNotFound)
+	})
+}
@@ -0,0 +1,190 @@
+package store_test
+
+import (
+	"expense-tracker/internal/models"
+	"expense-tracker/internal/store"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// Full-strength password hashing would dominate the test run
+	models.PasswordHashIterations = 1000
+	os.Exit(m.Run())
+}
+
+// eachBackend runs a test against the in-memory and SQLite backends.
+func eachBackend(t *testing.T, test func(t *testing.T, backend store.Backend)) {
+	t.Run("memory", func(t *testing.T) { test(t, store.NewMemoryBackend()) })
+	t.Run("sqlite", func(t *testing.T) {
+		_, backend := newSQLiteBackend(t)
+		test(t, backend)
+	})
+}
+
+// personalStores returns the stores of the personal ledger of a user.
+func personalStores(t *testing.T, backend store.Backend, user models.User) store.Stores {
+	t.Helper()
+
+	ledgers, err := backend.Ledgers().GetUserLedgers(user.ID)
+	if err != nil || len(ledgers) == 0 {
+		t.Fatalf("failed to find the personal ledger of %s: %v", user.Email, err)
+	}
+	return backend.Stores(ledgers[0].ID)
+}
+
+func TestUserSessions(t *testing.T) {
+	eachBackend(t, func(t *testing.T, backend store.Backend) {
+		users := backend.Users()
+
+		_, err := users.Register("not an email", "correct horse")
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = users.Register("ann@example.com", "short")
+		assert.ErrorIs(t, err, models.ErrValidation)
+
+		ann, err := users.Register(" Ann@Example.com ", "correct horse")
+		assert.NoError(t, err)
+		assert.Equal(t, "ann@example.com", ann.Email)
+		_, err = users.Register("ann@example.com", "another password")
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		found, err := users.GetUserByEmail("ANN@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, ann.ID, found.ID)
+		_, err = users.GetUserByEmail("bob@example.com")
+		assert.ErrorIs(t, err, models.ErrNotFound)
+
+		_, err = users.Login("ann@example.com", "wrong password")
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+		_, err = users.Login("bob@example.com", "correct horse")
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+
+		session, err := users.Login("ann@example.com", "correct horse")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, session.Token)
+		assert.Equal(t, ann.ID, session.User.ID)
+		assert.WithinDuration(t, time.Now().Add(models.SessionLifetime), session.ExpiresAt, time.Minute)
+
+		user, err := users.Authenticate(session.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, ann.ID, user.ID)
+		_, err = users.Authenticate("not-a-token")
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+
+		assert.NoError(t, users.Logout(session.Token))
+		_, err = users.Authenticate(session.Token)
+		assert.ErrorIs(t, err, models.ErrUnauthorized)
+	})
+}
+
+func TestUserDataIsIsolated(t *testing.T) {
+	eachBackend(t, func(t *testing.T, backend store.Backend) {
+		ann, err := backend.Users().Register("ann@example.com", "correct horse")
+		assert.NoError(t, err)
+		bob, err := backend.Users().Register("bob@example.com", "correct horse")
+		assert.NoError(t, err)
+		annStores, bobStores := personalStores(t, backend, ann), personalStores(t, backend, bob)
+
+		food, err := annStores.Categories.CreateCategory(models.Category{Name: "Food"})
+		assert.NoError(t, err)
+		_, err = bobStores.Categories.CreateCategory(models.Category{Name: "Food"})
+		assert.NoError(t, err)
+		_, err = annStores.Budgets.CreateBudget(models.Budget{CategoryID: food.ID, Amount: models.MustParseMoney("100.00"),
+			StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)})
+		assert.NoError(t, err)
+		lunch, err := annStores.Expenses.CreateExpense(models.Expense{CategoryID: food.ID, Amount: models.MustParseMoney("12.50"),
+			Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Description: "Lunch"})
+		assert.NoError(t, err)
+		_, err = annStores.Incomes.CreateIncome(models.Income{Amount: models.MustParseMoney("1500.00"),
+			Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Source: "Salary"})
+		assert.NoError(t, err)
+
+		// Both users share the 'Other' category
+		categories, err := bobStores.Categories.GetCategories()
+		assert.NoError(t, err)
+		if assert.Len(t, categories, 2) {
+			assert.Equal(t, "Other", categories[0].Name)
+			assert.NotEqual(t, food.ID, categories[1].ID)
+		}
+
+		expenses, err := bobStores.Expenses.GetExpenses()
+		assert.NoError(t, err)
+		assert.Empty(t, expenses)
+		incomes, err := bobStores.Incomes.GetIncomes()
+		assert.NoError(t, err)
+		assert.Empty(t, incomes)
+		budgets, err := bobStores.Budgets.GetBudgets()
+		assert.NoError(t, err)
+		assert.Empty(t, budgets)
+
+		_, err = bobStores.Expenses.GetExpenseByID(lunch.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		assert.ErrorIs(t, bobStores.Expenses.DeleteExpense(lunch.ID), models.ErrNotFound)
+		_, err = bobStores.Categories.GetCategoryByID(food.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+		_, err = bobStores.Expenses.CreateExpense(models.Expense{CategoryID: food.ID, Amount: models.MustParseMoney("3.00"),
+			Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Description: "Coffee"})
+		assert.ErrorIs(t, err, models.ErrValidation)
+		_, err = bobStores.Categories.UpdateCategory(models.Category{ID: 1, Name: "Misc"})
+		assert.ErrorIs(t, err, models.ErrForbidden)
+
+		summary, err := bobStores.Reports.GetSummary("USD", time.Time{}, time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money(0), summary.Expenses.Converted)
+		summary, err = annStores.Reports.GetSummary("USD", time.Time{}, time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, models.MustParseMoney("12.50"), summary.Expenses.Converted)
+		assert.Equal(t, models.MustParseMoney("1500.00"), summary.Incomes.Converted)
+
+		budgets, err = annStores.Budgets.GetBudgets()
+		assert.NoError(t, err)
+		if assert.Len(t, budgets, 1) {
+			assert.Equal(t, models.MustParseMoney("12.50"), budgets[0].Spent)
+		}
+	})
+}
+
+func TestAdoptExistingData(t *testing.T) {
+	db, backend := newSQLiteBackend(t)
+	if _, err := db.Exec("INSERT INTO Category (name, description) VALUES ('Food', '')"); err != nil {
+		t.Fatalf("failed to insert category: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO Expense (category_id, amount, date, description, currency) VALUES (1, 5, '2024-03-01', 'Coffee', 'EUR')"); err != nil {
+		t.Fatalf("failed to insert expense: %v", err)
+	}
+
+	ann, err := backend.Users().Register("ann@example.com", "correct horse")
+	assert.NoError(t, err)
+	bob, err := backend.Users().Register("bob@example.com", "correct horse")
+	assert.NoError(t, err)
+
+	// Registering first does not take over anything
+	for _, user := range []models.User{ann, bob} {
+		categories, err := personalStores(t, backend, user).Categories.GetCategories()
+		assert.NoError(t, err)
+		assert.Len(t, categories, 1)
+		expenses, err := personalStores(t, backend, user).Expenses.GetExpenses()
+		assert.NoError(t, err)
+		assert.Empty(t, expenses)
+	}
+
+	_, err = models.AdoptUnownedData(db, "carol@example.com")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	adopted, err := models.AdoptUnownedData(db, "BOB@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), adopted)
+
+	categories, err := personalStores(t, backend, bob).Categories.GetCategories()
+	assert.NoError(t, err)
+	assert.Len(t, categories, 2)
+	expenses, err := personalStores(t, backend, bob).Expenses.GetExpenses()
+	assert.NoError(t, err)
+	assert.Len(t, expenses, 1)
+
+	categories, err = personalStores(t, backend, ann).Categories.GetCategories()
+	assert.NoError(t, err)
+	assert.Len(t, categories, 1)
+}
@@ -1,9 +1,11 @@
 import { Routes, Route, Navigate } from "react-router-dom";
 import { Navbar } from "./components/navbar";
+import { RequireSession } from "./components/require-session";
 import { Income } from "./pages/income";
 import { Expenses } from "./pages/expenses";
 import { Categories } from "./pages/categories";
 import { Budgets } from "./pages/budgets";
+import { Login } from "./pages/login";
 
 function App() {
   return (
@@ -12,10 +14,39 @@ function App() {
       <div className="container mx-auto px-4 py-8">
         <Routes>
           <Route path="/" element={<Navigate to="/income" replace />} />
-          <Route path="/income" element={<Income />} />
-          <Route path="/expenses" element={<Expenses />} />
-          <Route path="/categories" element={<Categories />} />
-          <Route path="/budgets" element={<Budgets />} />
+          <Route path="/login" element={<Login />} />
+          <Route
+            path="/income"
+            element={
+              <RequireSession>
+                <Income />
+              </RequireSession>
+            }
+          />
+          <Route
+            path="/expenses"
+            element={
+              <RequireSession>
+                <Expenses />
+              </RequireSession>
+            }
+          />
+          <Route
+            path="/categories"
+            element={
+              <RequireSession>
+                <Categories />
+              </RequireSession>
+            }
+          />
+          <Route
+            path="/budgets"
+            element={
+              <RequireSession>
+                <Budgets />
+              </RequireSession>
+            }
+          />
         </Routes>
       </div>
     </div>
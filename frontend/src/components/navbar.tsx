@@ -1,9 +1,12 @@
 import { Link, useLocation } from "react-router-dom";
 import { cn } from "@/lib/utils";
+import { signOut } from "@/lib/auth";
+import { useSession } from "@/lib/use-session";
 
 export function Navbar() {
   const location = useLocation();
   const pathname = location.pathname;
+  const session = useSession();
 
   return (
     <nav className="border-b">
@@ -18,59 +21,72 @@ export function Navbar() {
             <span className="text-xl font-bold">Expensify</span>
           </div>
 
-          <div className="flex space-x-8">
-            <Link
-              to="/income"
-              className={cn(
-                "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
-                pathname === "/income"
-                  ? "border-emerald-500 text-emerald-600"
-                  : "border-transparent text-gray-500 hover:border-emerald-300 hover:text-emerald-600",
-                "transition-colors duration-200"
-              )}
-            >
-              Income
-            </Link>
+          {session && (
+            <div className="flex space-x-8">
+              <Link
+                to="/income"
+                className={cn(
+                  "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
+                  pathname === "/income"
+                    ? "border-emerald-500 text-emerald-600"
+                    : "border-transparent text-gray-500 hover:border-emerald-300 hover:text-emerald-600",
+                  "transition-colors duration-200"
+                )}
+              >
+                Income
+              </Link>
 
-            <Link
-              to="/expenses"
-              className={cn(
-                "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
-                pathname === "/expenses"
-                  ? "border-red-500 text-red-600"
-                  : "border-transparent text-gray-500 hover:border-red-300 hover:text-red-600",
-                "transition-colors duration-200"
-              )}
-            >
-              Expenses
-            </Link>
+              <Link
+                to="/expenses"
+                className={cn(
+                  "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
+                  pathname === "/expenses"
+                    ? "border-red-500 text-red-600"
+                    : "border-transparent text-gray-500 hover:border-red-300 hover:text-red-600",
+                  "transition-colors duration-200"
+                )}
+              >
+                Expenses
+              </Link>
 
-            <Link
-              to="/categories"
-              className={cn(
-                "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
-                pathname === "/categories"
-                  ? "border-orange-500 text-orange-600"
-                  : "border-transparent text-gray-500 hover:border-orange-300 hover:text-orange-600",
-                "transition-colors duration-200"
-              )}
-            >
-              Categories
-            </Link>
+              <Link
+                to="/categories"
+                className={cn(
+                  "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
+                  pathname === "/categories"
+                    ? "border-orange-500 text-orange-600"
+                    : "border-transparent text-gray-500 hover:border-orange-300 hover:text-orange-600",
+                  "transition-colors duration-200"
+                )}
+              >
+                Categories
+              </Link>
 
-            <Link
-              to="/budgets"
-              className={cn(
-                "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
-                pathname === "/budgets"
-                  ? "border-purple-500 text-purple-600"
-                  : "border-transparent text-gray-500 hover:border-purple-300 hover:text-purple-600",
-                "transition-colors duration-200"
-              )}
-            >
-              Budgets
-            </Link>
-          </div>
+              <Link
+                to="/budgets"
+                className={cn(
+                  "inline-flex items-center px-1 pt-1 border-b-2 text-sm font-medium",
+                  pathname === "/budgets"
+                    ? "border-purple-500 text-purple-600"
+                    : "border-transparent text-gray-500 hover:border-purple-300 hover:text-purple-600",
+                  "transition-colors duration-200"
+                )}
+              >
+                Budgets
+              </Link>
+
+              <div className="inline-flex items-center space-x-3 text-sm text-gray-500">
+                <span className="hidden sm:inline">{session.user.email}</span>
+                <button
+                  type="button"
+                  onClick={() => signOut()}
+                  className="font-medium hover:text-gray-900 transition-colors duration-200"
+                >
+                  Sign out
+                </button>
+              </div>
+            </div>
+          )}
         </div>
       </div>
     </nav>
@@ -0,0 +1,12 @@
+import { Navigate, useLocation } from "react-router-dom";
+import { useSession } from "@/lib/use-session";
+
+export function RequireSession({ children }: { children: JSX.Element }) {
+  const session = useSession();
+  const location = useLocation();
+
+  if (!session) {
+    return <Navigate to="/login" state={{ from: location.pathname }} replace />;
+  }
+  return children;
+}
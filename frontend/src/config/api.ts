@@ -2,6 +2,12 @@ const API_BASE_URL =
   "https://expense-tracker-golang-backend-eyhfeyfnhqexcxe2.canadacentral-01.azurewebsites.net";
 
 export const API_ENDPOINTS = {
+  auth: {
+    register: `${API_BASE_URL}/auth/register`,
+    login: `${API_BASE_URL}/auth/login`,
+    logout: `${API_BASE_URL}/auth/logout`,
+    me: `${API_BASE_URL}/auth/me`,
+  },
   budgets: {
     getAll: `${API_BASE_URL}/budgets`,
     create: `${API_BASE_URL}/budgets`,
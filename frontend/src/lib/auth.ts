@@ -0,0 +1,87 @@
+import { API_ENDPOINTS } from "@/config/api";
+import { Session } from "@/types/user";
+
+const SESSION_KEY = "expensify.session";
+const SESSION_CHANGE_EVENT = "expensify:session";
+
+export function getSession(): Session | null {
+  const stored = localStorage.getItem(SESSION_KEY);
+  if (!stored) return null;
+  try {
+    const session: Session = JSON.parse(stored);
+    if (new Date(session.expires_at) <= new Date()) {
+      localStorage.removeItem(SESSION_KEY);
+      return null;
+    }
+    return session;
+  } catch {
+    localStorage.removeItem(SESSION_KEY);
+    return null;
+  }
+}
+
+function setSession(session: Session | null) {
+  if (session) {
+    localStorage.setItem(SESSION_KEY, JSON.stringify(session));
+  } else {
+    localStorage.removeItem(SESSION_KEY);
+  }
+  window.dispatchEvent(new Event(SESSION_CHANGE_EVENT));
+}
+
+export function onSessionChange(listener: () => void) {
+  window.addEventListener(SESSION_CHANGE_EVENT, listener);
+  return () => window.removeEventListener(SESSION_CHANGE_EVENT, listener);
+}
+
+// apiFetch is fetch with the signed-in user's bearer token. A 401 means the
+// session has ended, so it is forgotten and the app goes back to sign-in.
+export async function apiFetch(input: string, init: RequestInit = {}) {
+  const headers = new Headers(init.headers);
+  const session = getSession();
+  if (session) {
+    headers.set("Authorization", `Bearer ${session.token}`);
+  }
+  const response = await fetch(input, { ...init, headers });
+  if (response.status === 401) {
+    setSession(null);
+  }
+  return response;
+}
+
+async function errorMessage(response: Response) {
+  try {
+    const problem = await response.json();
+    return problem.message || `HTTP error! status: ${response.status}`;
+  } catch {
+    return `HTTP error! status: ${response.status}`;
+  }
+}
+
+export async function signIn(email: string, password: string) {
+  const response = await fetch(API_ENDPOINTS.auth.login, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email, password }),
+  });
+  if (!response.ok) throw new Error(await errorMessage(response));
+  setSession(await response.json());
+}
+
+export async function register(email: string, password: string) {
+  const response = await fetch(API_ENDPOINTS.auth.register, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email, password }),
+  });
+  if (!response.ok) throw new Error(await errorMessage(response));
+  await signIn(email, password);
+}
+
+export async function signOut() {
+  try {
+    await apiFetch(API_ENDPOINTS.auth.logout, { method: "POST" });
+  } finally {
+    setSession(null);
+  }
+}
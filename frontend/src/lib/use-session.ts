@@ -0,0 +1,10 @@
+import { useEffect, useState } from "react";
+import { getSession, onSessionChange } from "@/lib/auth";
+
+export function useSession() {
+  const [session, setSession] = useState(getSession);
+
+  useEffect(() => onSessionChange(() => setSession(getSession())), []);
+
+  return session;
+}
@@ -21,6 +21,7 @@ import DatePicker from "react-datepicker";
 import "react-datepicker/dist/react-datepicker.css";
 import { cn } from "@/lib/utils";
 import { API_ENDPOINTS } from "@/config/api";
+import { apiFetch } from "@/lib/auth";
 
 // Utility function to format the month-year date
 function formatMonth(date: Date): string {
@@ -70,7 +71,7 @@ export function Budgets() {
     async function fetchBudgets() {
       try {
         setErrorGlobal(null); // Clear previous errors
-        const response = await fetch(API_ENDPOINTS.budgets.getAll);
+        const response = await apiFetch(API_ENDPOINTS.budgets.getAll);
         if (!response.ok) throw new Error(await response.text());
         const data = await response.json();
         setBudgets(data || []);
@@ -83,7 +84,7 @@ export function Budgets() {
     async function fetchCategories() {
       try {
         setErrorGlobal(null); // Clear previous errors
-        const response = await fetch(API_ENDPOINTS.categories.getAll);
+        const response = await apiFetch(API_ENDPOINTS.categories.getAll);
         if (!response.ok) throw new Error(await response.text());
         const data = await response.json();
         setCategories(data || []);
@@ -155,7 +156,7 @@ export function Budgets() {
       0
     ).toISOString();
     try {
-      const response = await fetch(API_ENDPOINTS.budgets.create, {
+      const response = await apiFetch(API_ENDPOINTS.budgets.create, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -185,7 +186,7 @@ export function Budgets() {
     if (!editingBudget) return;
 
     try {
-      const response = await fetch(
+      const response = await apiFetch(
         API_ENDPOINTS.budgets.update(editingBudget.id),
         {
           method: "PUT",
@@ -219,7 +220,7 @@ export function Budgets() {
   async function handleDeleteBudget(id: number) {
     setErrorGlobal(null); // Clear global errors
     try {
-      const response = await fetch(API_ENDPOINTS.budgets.delete(id), {
+      const response = await apiFetch(API_ENDPOINTS.budgets.delete(id), {
         method: "DELETE",
       });
       if (!response.ok) throw new Error(await response.text());
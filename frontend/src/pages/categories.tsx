@@ -16,6 +16,7 @@ import { CategoryExpenseChart } from "@/components/charts/category-expense-chart
 import { Expense } from "@/types/expense";
 import { cn } from "@/lib/utils";
 import { API_ENDPOINTS } from "@/config/api";
+import { apiFetch } from "@/lib/auth";
 
 export function CategoriesPage() {
   return (
@@ -51,7 +52,7 @@ export function Categories() {
     async function fetchCategories() {
       try {
         setIsLoading(true);
-        const response = await fetch(API_ENDPOINTS.categories.getAll);
+        const response = await apiFetch(API_ENDPOINTS.categories.getAll);
         if (!response.ok)
           throw new Error(`HTTP error! status: ${response.status}`);
         const data = await response.json();
@@ -82,7 +83,7 @@ export function Categories() {
 
     async function fetchExpenses() {
       try {
-        const response = await fetch(API_ENDPOINTS.expenses.getAll);
+        const response = await apiFetch(API_ENDPOINTS.expenses.getAll);
         if (!response.ok)
           throw new Error(`HTTP error! status: ${response.status}`);
         const data = await response.json();
@@ -110,7 +111,7 @@ export function Categories() {
   async function handleSubmit(e: React.FormEvent) {
     e.preventDefault();
     try {
-      const response = await fetch(API_ENDPOINTS.categories.create, {
+      const response = await apiFetch(API_ENDPOINTS.categories.create, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -131,7 +132,7 @@ export function Categories() {
 
   async function handleDelete(id: number) {
     try {
-      const response = await fetch(API_ENDPOINTS.categories.delete(id), {
+      const response = await apiFetch(API_ENDPOINTS.categories.delete(id), {
         method: "DELETE",
       });
 
@@ -152,7 +153,7 @@ export function Categories() {
     if (!editingCategory) return;
 
     try {
-      const response = await fetch(
+      const response = await apiFetch(
         API_ENDPOINTS.categories.update(editingCategory.id),
         {
           method: "PUT",
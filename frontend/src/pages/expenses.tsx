@@ -17,6 +17,7 @@ import { MonthlyExpenseChart } from "@/components/charts/monthly-expense-chart";
 import { SortButton } from "@/components/ui/sort-button";
 import { cn } from "@/lib/utils";
 import { API_ENDPOINTS } from "@/config/api";
+import { apiFetch } from "@/lib/auth";
 
 type SortField = "category" | "amount" | "date";
 type SortDirection = "asc" | "desc" | null;
@@ -58,7 +59,7 @@ export function Expenses() {
     async function fetchExpenses() {
       try {
         setIsLoading(true);
-        const response = await fetch(API_ENDPOINTS.expenses.getAll);
+        const response = await apiFetch(API_ENDPOINTS.expenses.getAll);
         if (!response.ok)
           throw new Error(`HTTP error! status: ${response.status}`);
         const data = await response.json();
@@ -89,7 +90,7 @@ export function Expenses() {
 
     async function fetchCategories() {
       try {
-        const response = await fetch(API_ENDPOINTS.categories.getAll);
+        const response = await apiFetch(API_ENDPOINTS.categories.getAll);
         if (!response.ok)
           throw new Error(`HTTP error! status: ${response.status}`);
         const data = await response.json();
@@ -176,7 +177,7 @@ export function Expenses() {
         return;
       }
 
-      const response = await fetch(API_ENDPOINTS.expenses.create, {
+      const response = await apiFetch(API_ENDPOINTS.expenses.create, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -210,7 +211,7 @@ export function Expenses() {
 
   async function handleDelete(id: number) {
     try {
-      const response = await fetch(API_ENDPOINTS.expenses.delete(id), {
+      const response = await apiFetch(API_ENDPOINTS.expenses.delete(id), {
         method: "DELETE",
       });
 
@@ -229,7 +230,7 @@ export function Expenses() {
     try {
       const formattedDate = new Date(editingExpense.date).toISOString();
 
-      const response = await fetch(
+      const response = await apiFetch(
         API_ENDPOINTS.expenses.update(editingExpense.id),
         {
           method: "PUT",
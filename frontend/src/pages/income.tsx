@@ -15,6 +15,7 @@ import { IncomeSourceChart } from "@/components/charts/income-source-chart";
 import { SortButton } from "@/components/ui/sort-button";
 import { cn } from "@/lib/utils";
 import { API_ENDPOINTS } from "@/config/api";
+import { apiFetch } from "@/lib/auth";
 
 type SortField = "amount" | "date";
 type SortDirection = "asc" | "desc" | null;
@@ -40,7 +41,7 @@ export function Income() {
   });
 
   useEffect(() => {
-    fetch(API_ENDPOINTS.incomes.getAll)
+    apiFetch(API_ENDPOINTS.incomes.getAll)
       .then((response) => response.json())
       .then((data) => setIncomes(data));
   }, []);
@@ -50,7 +51,7 @@ export function Income() {
     try {
       const formattedDate = new Date(newIncome.date).toISOString();
 
-      const response = await fetch(API_ENDPOINTS.incomes.create, {
+      const response = await apiFetch(API_ENDPOINTS.incomes.create, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -79,7 +80,7 @@ export function Income() {
 
   async function handleDelete(id: number) {
     try {
-      const response = await fetch(API_ENDPOINTS.incomes.delete(id), {
+      const response = await apiFetch(API_ENDPOINTS.incomes.delete(id), {
         method: "DELETE",
       });
 
@@ -98,7 +99,7 @@ export function Income() {
     try {
       const formattedDate = new Date(editingIncome.date).toISOString();
 
-      const response = await fetch(
+      const response = await apiFetch(
         API_ENDPOINTS.incomes.update(editingIncome.id),
         {
           method: "PUT",
@@ -0,0 +1,103 @@
+import { useState } from "react";
+import { Navigate, useLocation } from "react-router-dom";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { cn } from "@/lib/utils";
+import { register, signIn } from "@/lib/auth";
+import { useSession } from "@/lib/use-session";
+
+export function Login() {
+  const session = useSession();
+  const location = useLocation();
+  const [mode, setMode] = useState<"signIn" | "register">("signIn");
+  const [email, setEmail] = useState("");
+  const [password, setPassword] = useState("");
+  const [error, setError] = useState<string | null>(null);
+  const [isSubmitting, setIsSubmitting] = useState(false);
+
+  if (session) {
+    const from = (location.state as { from?: string } | null)?.from;
+    return <Navigate to={from || "/income"} replace />;
+  }
+
+  async function handleSubmit(e: React.FormEvent) {
+    e.preventDefault();
+    setError(null);
+    setIsSubmitting(true);
+    try {
+      if (mode === "signIn") {
+        await signIn(email, password);
+      } else {
+        await register(email, password);
+      }
+    } catch (err: any) {
+      setError(err.message);
+    } finally {
+      setIsSubmitting(false);
+    }
+  }
+
+  return (
+    <div className="min-h-[60vh] flex items-center justify-center p-4">
+      <div className="w-full max-w-sm bg-white rounded-xl shadow-lg p-6 space-y-6">
+        <h1 className="text-2xl font-bold bg-gradient-to-r from-emerald-600 to-teal-500 bg-clip-text text-transparent">
+          {mode === "signIn" ? "Sign in" : "Create an account"}
+        </h1>
+        <form onSubmit={handleSubmit} className="space-y-4">
+          <div className="space-y-2">
+            <Label htmlFor="email">Email</Label>
+            <Input
+              id="email"
+              type="email"
+              autoComplete="email"
+              required
+              value={email}
+              onChange={(e) => setEmail(e.target.value)}
+            />
+          </div>
+          <div className="space-y-2">
+            <Label htmlFor="password">Password</Label>
+            <Input
+              id="password"
+              type="password"
+              autoComplete={
+                mode === "signIn" ? "current-password" : "new-password"
+              }
+              required
+              minLength={mode === "register" ? 8 : undefined}
+              value={password}
+              onChange={(e) => setPassword(e.target.value)}
+            />
+          </div>
+          {error && <p className="text-sm text-red-600">{error}</p>}
+          <Button
+            type="submit"
+            disabled={isSubmitting}
+            className={cn(
+              "w-full",
+              "bg-gradient-to-r from-emerald-600 to-teal-500",
+              "hover:from-emerald-700 hover:to-teal-600",
+              "text-white shadow-md hover:shadow-lg",
+              "transition-all duration-200"
+            )}
+          >
+            {mode === "signIn" ? "Sign in" : "Register"}
+          </Button>
+        </form>
+        <button
+          type="button"
+          className="text-sm text-gray-500 hover:text-emerald-600"
+          onClick={() => {
+            setMode(mode === "signIn" ? "register" : "signIn");
+            setError(null);
+          }}
+        >
+          {mode === "signIn"
+            ? "No account yet? Register"
+            : "Already registered? Sign in"}
+        </button>
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,11 @@
+export interface User {
+  id: number;
+  email: string;
+  created_at: string;
+}
+
+export interface Session {
+  token: string;
+  expires_at: string;
+  user: User;
+}